@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -9,16 +10,20 @@ import (
 // Config holds the application's configuration.
 type Config struct {
 	Network struct {
-		ListenPort     int      `yaml:"listen_port"`
-		BootstrapPeers []string `yaml:"bootstrap_peers"`
-		EnableMDNS     bool     `yaml:"enable_mdns"`
-		EnableDHT      bool     `yaml:"enable_dht"`
+		ListenPort         int      `yaml:"listen_port"`
+		BootstrapPeers     []string `yaml:"bootstrap_peers"`
+		EnableMDNS         bool     `yaml:"enable_mdns"`
+		EnableDHT          bool     `yaml:"enable_dht"`
+		EnableRelayService bool     `yaml:"enable_relay_service"`
+		StaticRelays       []string `yaml:"static_relays"`
+		IdentityKeyPath    string   `yaml:"identity_key_path"`
 	} `yaml:"network"`
 
 	UI struct {
 		Theme         string `yaml:"theme"`
 		RefreshRate   int    `yaml:"refresh_rate_ms"`
 		MaxPostLength int    `yaml:"max_post_length"`
+		MentionBell   bool   `yaml:"mention_bell"` // Ring the terminal bell when someone mentions us
 	} `yaml:"ui"`
 
 	Privacy struct {
@@ -26,6 +31,91 @@ type Config struct {
 		KeyPath         string `yaml:"key_path"`
 		AutoClear       bool   `yaml:"auto_clear_on_exit"`
 	} `yaml:"privacy"`
+
+	PubSub struct {
+		EnablePeerScoring           bool                        `yaml:"enable_peer_scoring"`
+		MaxMessageSize              int                         `yaml:"max_message_bytes"`
+		DecayInterval               time.Duration               `yaml:"decay_interval"`
+		RetainScore                 time.Duration               `yaml:"retain_score"`
+		IPColocationFactorWeight    float64                     `yaml:"ip_colocation_factor_weight"`
+		IPColocationFactorThreshold int                         `yaml:"ip_colocation_factor_threshold"`
+		BehaviourPenaltyWeight      float64                     `yaml:"behaviour_penalty_weight"`
+		BehaviourPenaltyDecay       time.Duration               `yaml:"behaviour_penalty_decay"`
+		Thresholds                  ScoreThresholdsConfig       `yaml:"thresholds"`
+		DefaultTopicScore           TopicScoreConfig            `yaml:"default_topic_score"`
+		TopicScores                 map[string]TopicScoreConfig `yaml:"topic_scores"` // Keyed by topic name, e.g. "socli/hashtag/general"
+	} `yaml:"pubsub"`
+	Sync struct {
+		EnableHistorySync bool          `yaml:"enable_history_sync"`
+		Window            time.Duration `yaml:"window"`
+		MaxPosts          int           `yaml:"max_posts"`
+	} `yaml:"sync"`
+	Directory struct {
+		EnableAnnouncements bool          `yaml:"enable_announcements"`
+		AnnounceInterval    time.Duration `yaml:"announce_interval"`
+	} `yaml:"directory"`
+	Presence struct {
+		EnableHeartbeats  bool          `yaml:"enable_heartbeats"`
+		HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	} `yaml:"presence"`
+	Attachments struct {
+		MaxSize    int64 `yaml:"max_size"`
+		ServeBlobs bool  `yaml:"serve_blobs"`
+	} `yaml:"attachments"`
+	RateLimit struct {
+		Enabled       bool          `yaml:"enabled"`
+		PeerRate      float64       `yaml:"peer_rate"` // Messages per second
+		PeerBurst     int           `yaml:"peer_burst"`
+		AuthorRate    float64       `yaml:"author_rate"` // Messages per second
+		AuthorBurst   int           `yaml:"author_burst"`
+		BlockAfter    int           `yaml:"block_after"` // Throttled messages before a temporary block
+		BlockDuration time.Duration `yaml:"block_duration"`
+	} `yaml:"rate_limit"`
+	Wire struct {
+		Format        string `yaml:"format"`         // "json" or "binary"
+		CompressAbove int    `yaml:"compress_above"` // Bytes; 0 disables compression
+	} `yaml:"wire"`
+	Favorites struct {
+		Peers      []string      `yaml:"peers"` // Peer IDs or full multiaddrs to stay connected to
+		MinBackoff time.Duration `yaml:"min_backoff"`
+		MaxBackoff time.Duration `yaml:"max_backoff"`
+	} `yaml:"favorites"`
+	Schedule struct {
+		Persist bool   `yaml:"persist"` // Keep scheduled posts on disk across restarts instead of only in memory
+		Path    string `yaml:"path"`
+	} `yaml:"schedule"`
+	Outbox struct {
+		RetryInterval time.Duration `yaml:"retry_interval"` // How often posts waiting for peers are tried again
+		MaxWait       time.Duration `yaml:"max_wait"`       // How long a post waits on a topic before it is given up on
+	} `yaml:"outbox"`
+	Expiry struct {
+		PurgeInterval time.Duration   `yaml:"purge_interval"` // How often expired posts are removed from the store
+		Presets       []time.Duration `yaml:"presets"`        // Lifetimes ctrl+t cycles through in the compose view
+	} `yaml:"expiry"`
+}
+
+// ScoreThresholdsConfig holds the GossipSub peer score thresholds.
+// Peers scoring below a threshold lose the corresponding privilege.
+type ScoreThresholdsConfig struct {
+	Gossip             float64 `yaml:"gossip"`
+	Publish            float64 `yaml:"publish"`
+	Graylist           float64 `yaml:"graylist"`
+	AcceptPX           float64 `yaml:"accept_px"`
+	OpportunisticGraft float64 `yaml:"opportunistic_graft"`
+}
+
+// TopicScoreConfig holds the GossipSub score parameters for a single topic.
+// Decays are given as the time it takes a counter to decay to (almost) zero.
+type TopicScoreConfig struct {
+	TopicWeight                    float64       `yaml:"topic_weight"`
+	TimeInMeshWeight               float64       `yaml:"time_in_mesh_weight"`
+	TimeInMeshQuantum              time.Duration `yaml:"time_in_mesh_quantum"`
+	TimeInMeshCap                  float64       `yaml:"time_in_mesh_cap"`
+	FirstMessageDeliveriesWeight   float64       `yaml:"first_message_deliveries_weight"`
+	FirstMessageDeliveriesDecay    time.Duration `yaml:"first_message_deliveries_decay"`
+	FirstMessageDeliveriesCap      float64       `yaml:"first_message_deliveries_cap"`
+	InvalidMessageDeliveriesWeight float64       `yaml:"invalid_message_deliveries_weight"`
+	InvalidMessageDeliveriesDecay  time.Duration `yaml:"invalid_message_deliveries_decay"`
 }
 
 // LoadConfig loads the configuration from the given path.
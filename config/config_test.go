@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 // TestLoadConfigDefault tests that a default config is created if the file doesn't exist.
@@ -116,4 +118,48 @@ privacy:
 	if cfg.Privacy.AutoClear != false {
 		t.Errorf("cfg.Privacy.AutoClear = %v, want false", cfg.Privacy.AutoClear)
 	}
-}
\ No newline at end of file
+}
+
+// TestLoadConfigPubSubScores tests that topic score parameters are read from YAML
+// and that unspecified values keep their defaults.
+func TestLoadConfigPubSubScores(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `pubsub:
+  thresholds:
+    graylist: -5000
+  topic_scores:
+    socli/hashtag/general:
+      topic_weight: 0.5
+      invalid_message_deliveries_weight: -200
+      invalid_message_deliveries_decay: 30m
+`
+	if err := os.WriteFile(tmpFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v, want nil", err)
+	}
+
+	if !cfg.PubSub.EnablePeerScoring {
+		t.Error("cfg.PubSub.EnablePeerScoring = false, want true (default)")
+	}
+	if cfg.PubSub.Thresholds.Graylist != -5000 {
+		t.Errorf("cfg.PubSub.Thresholds.Graylist = %v, want -5000", cfg.PubSub.Thresholds.Graylist)
+	}
+	if cfg.PubSub.Thresholds.Gossip != -500 {
+		t.Errorf("cfg.PubSub.Thresholds.Gossip = %v, want -500 (default)", cfg.PubSub.Thresholds.Gossip)
+	}
+
+	general, ok := cfg.PubSub.TopicScores["socli/hashtag/general"]
+	if !ok {
+		t.Fatal("cfg.PubSub.TopicScores has no entry for socli/hashtag/general")
+	}
+	if general.TopicWeight != 0.5 {
+		t.Errorf("TopicWeight = %v, want 0.5", general.TopicWeight)
+	}
+	if general.InvalidMessageDeliveriesDecay != 30*time.Minute {
+		t.Errorf("InvalidMessageDeliveriesDecay = %v, want 30m", general.InvalidMessageDeliveriesDecay)
+	}
+}
@@ -1,27 +1,37 @@
 package config
 
+import "time"
+
 // DefaultConfig returns the default configuration for the application.
 func DefaultConfig() *Config {
 	return &Config{
 		Network: struct {
-			ListenPort     int      `yaml:"listen_port"`
-			BootstrapPeers []string `yaml:"bootstrap_peers"`
-			EnableMDNS     bool     `yaml:"enable_mdns"`
-			EnableDHT      bool     `yaml:"enable_dht"`
+			ListenPort         int      `yaml:"listen_port"`
+			BootstrapPeers     []string `yaml:"bootstrap_peers"`
+			EnableMDNS         bool     `yaml:"enable_mdns"`
+			EnableDHT          bool     `yaml:"enable_dht"`
+			EnableRelayService bool     `yaml:"enable_relay_service"`
+			StaticRelays       []string `yaml:"static_relays"`
+			IdentityKeyPath    string   `yaml:"identity_key_path"`
 		}{
-			ListenPort:     0, // 0 means a random port
-			BootstrapPeers: []string{},
-			EnableMDNS:     true,
-			EnableDHT:      true,
+			ListenPort:         0, // 0 means a random port
+			BootstrapPeers:     []string{},
+			EnableMDNS:         true,
+			EnableDHT:          true,
+			EnableRelayService: false,
+			StaticRelays:       []string{},
+			IdentityKeyPath:    "", // empty means a fresh peer ID on every start
 		},
 		UI: struct {
 			Theme         string `yaml:"theme"`
 			RefreshRate   int    `yaml:"refresh_rate_ms"`
 			MaxPostLength int    `yaml:"max_post_length"`
+			MentionBell   bool   `yaml:"mention_bell"`
 		}{
 			Theme:         "default",
 			RefreshRate:   100,
 			MaxPostLength: 280,
+			MentionBell:   true,
 		},
 		Privacy: struct {
 			EncryptMessages bool   `yaml:"encrypt_messages"`
@@ -32,5 +42,134 @@ func DefaultConfig() *Config {
 			KeyPath:         "socli.key",
 			AutoClear:       true,
 		},
+		PubSub: struct {
+			EnablePeerScoring           bool                        `yaml:"enable_peer_scoring"`
+			MaxMessageSize              int                         `yaml:"max_message_bytes"`
+			DecayInterval               time.Duration               `yaml:"decay_interval"`
+			RetainScore                 time.Duration               `yaml:"retain_score"`
+			IPColocationFactorWeight    float64                     `yaml:"ip_colocation_factor_weight"`
+			IPColocationFactorThreshold int                         `yaml:"ip_colocation_factor_threshold"`
+			BehaviourPenaltyWeight      float64                     `yaml:"behaviour_penalty_weight"`
+			BehaviourPenaltyDecay       time.Duration               `yaml:"behaviour_penalty_decay"`
+			Thresholds                  ScoreThresholdsConfig       `yaml:"thresholds"`
+			DefaultTopicScore           TopicScoreConfig            `yaml:"default_topic_score"`
+			TopicScores                 map[string]TopicScoreConfig `yaml:"topic_scores"` // Keyed by topic name, e.g. "socli/hashtag/general"
+		}{
+			EnablePeerScoring: true,
+			MaxMessageSize:    64 * 1024,
+			DecayInterval:     time.Second,
+			RetainScore:       10 * time.Minute,
+			// Teams often share an office or VPN exit IP, so co-location is not penalized by default
+			IPColocationFactorWeight:    0,
+			IPColocationFactorThreshold: 1,
+			BehaviourPenaltyWeight:      -10,
+			BehaviourPenaltyDecay:       10 * time.Minute,
+			Thresholds: ScoreThresholdsConfig{
+				Gossip:             -500,
+				Publish:            -1000,
+				Graylist:           -2500,
+				AcceptPX:           100,
+				OpportunisticGraft: 5,
+			},
+			DefaultTopicScore: TopicScoreConfig{
+				TopicWeight:                    1,
+				TimeInMeshWeight:               0.01,
+				TimeInMeshQuantum:              time.Second,
+				TimeInMeshCap:                  3600,
+				FirstMessageDeliveriesWeight:   1,
+				FirstMessageDeliveriesDecay:    time.Hour,
+				FirstMessageDeliveriesCap:      100,
+				InvalidMessageDeliveriesWeight: -100,
+				InvalidMessageDeliveriesDecay:  time.Hour,
+			},
+			TopicScores: map[string]TopicScoreConfig{},
+		},
+		Sync: struct {
+			EnableHistorySync bool          `yaml:"enable_history_sync"`
+			Window            time.Duration `yaml:"window"`
+			MaxPosts          int           `yaml:"max_posts"`
+		}{
+			EnableHistorySync: true,
+			Window:            24 * time.Hour,
+			MaxPosts:          200,
+		},
+		Directory: struct {
+			EnableAnnouncements bool          `yaml:"enable_announcements"`
+			AnnounceInterval    time.Duration `yaml:"announce_interval"`
+		}{
+			EnableAnnouncements: true,
+			AnnounceInterval:    time.Minute,
+		},
+		Presence: struct {
+			EnableHeartbeats  bool          `yaml:"enable_heartbeats"`
+			HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+		}{
+			EnableHeartbeats:  true,
+			HeartbeatInterval: 30 * time.Second,
+		},
+		Attachments: struct {
+			MaxSize    int64 `yaml:"max_size"`
+			ServeBlobs bool  `yaml:"serve_blobs"`
+		}{
+			MaxSize:    1024 * 1024,
+			ServeBlobs: true,
+		},
+		RateLimit: struct {
+			Enabled       bool          `yaml:"enabled"`
+			PeerRate      float64       `yaml:"peer_rate"` // Messages per second
+			PeerBurst     int           `yaml:"peer_burst"`
+			AuthorRate    float64       `yaml:"author_rate"` // Messages per second
+			AuthorBurst   int           `yaml:"author_burst"`
+			BlockAfter    int           `yaml:"block_after"` // Throttled messages before a temporary block
+			BlockDuration time.Duration `yaml:"block_duration"`
+		}{
+			Enabled:       true,
+			PeerRate:      10,
+			PeerBurst:     50,
+			AuthorRate:    2,
+			AuthorBurst:   20,
+			BlockAfter:    50,
+			BlockDuration: 5 * time.Minute,
+		},
+		Wire: struct {
+			Format        string `yaml:"format"`         // "json" or "binary"
+			CompressAbove int    `yaml:"compress_above"` // Bytes; 0 disables compression
+		}{
+			// JSON until every peer runs a build that reads the binary format
+			Format:        "json",
+			CompressAbove: 512,
+		},
+		Favorites: struct {
+			Peers      []string      `yaml:"peers"` // Peer IDs or full multiaddrs to stay connected to
+			MinBackoff time.Duration `yaml:"min_backoff"`
+			MaxBackoff time.Duration `yaml:"max_backoff"`
+		}{
+			Peers:      []string{},
+			MinBackoff: 2 * time.Second,
+			MaxBackoff: 2 * time.Minute,
+		},
+		Schedule: struct {
+			Persist bool   `yaml:"persist"` // Keep scheduled posts on disk across restarts instead of only in memory
+			Path    string `yaml:"path"`
+		}{
+			// Posts that haven't been published yet stay off the disk unless asked
+			Persist: false,
+			Path:    "scheduled.json",
+		},
+		Outbox: struct {
+			RetryInterval time.Duration `yaml:"retry_interval"` // How often posts waiting for peers are tried again
+			MaxWait       time.Duration `yaml:"max_wait"`       // How long a post waits on a topic before it is given up on
+		}{
+			RetryInterval: 5 * time.Second,
+			// Well below the hour after which validators reject a post as too old
+			MaxWait: 10 * time.Minute,
+		},
+		Expiry: struct {
+			PurgeInterval time.Duration   `yaml:"purge_interval"` // How often expired posts are removed from the store
+			Presets       []time.Duration `yaml:"presets"`        // Lifetimes ctrl+t cycles through in the compose view
+		}{
+			PurgeInterval: 10 * time.Second,
+			Presets:       []time.Duration{10 * time.Minute, time.Hour, 24 * time.Hour},
+		},
 	}
 }
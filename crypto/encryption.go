@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
 )
 
 // Encrypt secures a message for a recipient using their public key.
@@ -25,3 +26,32 @@ func Decrypt(encrypted []byte, senderPubKey *[32]byte, recipientPrivKey *[32]byt
 	decrypted, ok := box.Open(nil, encrypted[24:], &nonce, senderPubKey, recipientPrivKey)
 	return decrypted, ok
 }
+
+// GenerateGroupKey creates a random key for SealGroup and OpenGroup.
+func GenerateGroupKey() (*[32]byte, error) {
+	var key [32]byte
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// SealGroup encrypts a message with a key shared by a group, so every
+// member can read it.
+func SealGroup(msg []byte, key *[32]byte) ([]byte, error) {
+	var nonce [24]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, err
+	}
+	return secretbox.Seal(nonce[:], msg, &nonce, key), nil
+}
+
+// OpenGroup decrypts a message sealed by SealGroup with the same key.
+func OpenGroup(sealed []byte, key *[32]byte) ([]byte, bool) {
+	if len(sealed) < 24+secretbox.Overhead {
+		return nil, false
+	}
+	var nonce [24]byte
+	copy(nonce[:], sealed[:24])
+	return secretbox.Open(nil, sealed[24:], &nonce, key)
+}
@@ -56,4 +56,32 @@ func TestEncryptDecrypt(t *testing.T) {
 	if ok {
 		t.Error("Decrypt() with wrong key returned true, want false")
 	}
-}
\ No newline at end of file
+}
+
+// TestSealOpenGroup tests that group messages open with the group key only.
+func TestSealOpenGroup(t *testing.T) {
+	key, err := GenerateGroupKey()
+	if err != nil {
+		t.Fatalf("GenerateGroupKey() error = %v, want nil", err)
+	}
+	otherKey, err := GenerateGroupKey()
+	if err != nil {
+		t.Fatalf("GenerateGroupKey() error = %v, want nil", err)
+	}
+
+	plaintext := []byte("Only for the circle")
+	sealed, err := SealGroup(plaintext, key)
+	if err != nil {
+		t.Fatalf("SealGroup() error = %v, want nil", err)
+	}
+	opened, ok := OpenGroup(sealed, key)
+	if !ok || string(opened) != string(plaintext) {
+		t.Errorf("OpenGroup() = %q, %t, want %q, true", opened, ok, plaintext)
+	}
+	if _, ok := OpenGroup(sealed, otherKey); ok {
+		t.Error("OpenGroup() with another key returned true, want false")
+	}
+	if _, ok := OpenGroup(sealed[:10], key); ok {
+		t.Error("OpenGroup() of a truncated message returned true, want false")
+	}
+}
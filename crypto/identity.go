@@ -30,4 +30,13 @@ func VerifyMessageSignature(message, signature []byte, pubKey *[32]byte) bool {
 	
 	// Verify the signature
 	return ed25519.Verify(ed25519PubKey, message, signature)
-}
\ No newline at end of file
+}
+
+// SigningPublicKey returns the Ed25519 public key matching the signatures made
+// by SignMessage with the given private key. Peers need it to verify our messages.
+func SigningPublicKey(privKey *[32]byte) *[32]byte {
+	ed25519PrivKey := ed25519.NewKeyFromSeed(privKey[:])
+	var pubKey [32]byte
+	copy(pubKey[:], ed25519PrivKey.Public().(ed25519.PublicKey))
+	return &pubKey
+}
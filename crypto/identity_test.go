@@ -73,4 +73,27 @@ func TestVerifyMessageSignature(t *testing.T) {
 	if VerifyMessageSignature(message, corruptedSignature, &pubKey32) {
 		t.Error("VerifyMessageSignature() should have failed for a corrupted signature")
 	}
-}
\ No newline at end of file
+}
+
+// TestSigningPublicKey tests that signatures made with a key pair verify against its signing public key.
+func TestSigningPublicKey(t *testing.T) {
+	keyPair, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	message := []byte("This is a test message")
+	signature, err := SignMessage(message, keyPair.PrivateKey)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v, want nil", err)
+	}
+
+	if !VerifyMessageSignature(message, signature, SigningPublicKey(keyPair.PrivateKey)) {
+		t.Error("VerifyMessageSignature() failed with the key from SigningPublicKey()")
+	}
+
+	// The NaCl box public key is a different key and must not verify the signature
+	if VerifyMessageSignature(message, signature, keyPair.PublicKey) {
+		t.Error("VerifyMessageSignature() succeeded with the box public key, want failure")
+	}
+}
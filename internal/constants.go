@@ -7,4 +7,6 @@ const (
 	ConfigFileName = "config.yaml"
 	// DefaultKeyFileName is the default name for the private key file.
 	DefaultKeyFileName = "socli.key"
+	// DefaultNodeKeyFileName is the default name for the headless node's host key file.
+	DefaultNodeKeyFileName = "node.key"
 )
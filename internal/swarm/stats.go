@@ -0,0 +1,206 @@
+package swarm
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// TopicStats summarizes how posts on one hashtag spread through a swarm.
+type TopicStats struct {
+	Hashtag    string
+	Posts      int             // Posts published
+	Expected   int             // Deliveries expected: online subscribers other than the author, per post
+	Delivered  int             // Deliveries that happened
+	Duplicates int             // Extra copies received and dropped by the routers
+	Latencies  []time.Duration // Time from publishing to each delivery, sorted
+}
+
+// DeliveryRatio returns the fraction of expected deliveries that happened.
+func (s TopicStats) DeliveryRatio() float64 {
+	if s.Expected == 0 {
+		return 0
+	}
+	return float64(s.Delivered) / float64(s.Expected)
+}
+
+// DuplicateRate returns the number of duplicate copies received per delivery.
+func (s TopicStats) DuplicateRate() float64 {
+	if s.Delivered == 0 {
+		return 0
+	}
+	return float64(s.Duplicates) / float64(s.Delivered)
+}
+
+// Percentile returns the delivery latency below which the fraction p of
+// deliveries fall, e.g. 0.9 for the 90th percentile.
+func (s TopicStats) Percentile(p float64) time.Duration {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(s.Latencies)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(s.Latencies) {
+		i = len(s.Latencies) - 1
+	}
+	return s.Latencies[i]
+}
+
+// published is what the recorder knows about one post.
+type published struct {
+	hashtag   string
+	at        time.Time
+	expected  map[int]bool // Nodes expected to receive the post
+	delivered map[int]bool
+}
+
+// Recorder collects publish and delivery events from a swarm and turns them
+// into per-hashtag statistics. It is safe for concurrent use.
+type Recorder struct {
+	mu         sync.Mutex
+	posts      map[string]*published // Keyed by post ID
+	latencies  map[string][]time.Duration
+	duplicates map[string]int // Keyed by pubsub topic
+}
+
+// NewRecorder creates an empty recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		posts:      make(map[string]*published),
+		latencies:  make(map[string][]time.Duration),
+		duplicates: make(map[string]int),
+	}
+}
+
+// Published records a post published on hashtag at the given time, which the
+// nodes in expected should receive.
+func (r *Recorder) Published(id, hashtag string, at time.Time, expected []int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	post := &published{hashtag: hashtag, at: at, expected: make(map[int]bool), delivered: make(map[int]bool)}
+	for _, node := range expected {
+		post.expected[node] = true
+	}
+	r.posts[id] = post
+}
+
+// Delivered records that node received the post at the given time. Only the
+// first delivery of an expected post to each node counts.
+func (r *Recorder) Delivered(node int, id string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	post, ok := r.posts[id]
+	if !ok || !post.expected[node] || post.delivered[node] {
+		return
+	}
+	post.delivered[node] = true
+	r.latencies[post.hashtag] = append(r.latencies[post.hashtag], at.Sub(post.at))
+}
+
+// Tracer returns a GossipSub tracer that counts the duplicate copies a node
+// receives. Pass it to WithPubSubOptions with pubsub.WithRawTracer.
+func (r *Recorder) Tracer() pubsub.RawTracer {
+	return &duplicateTracer{recorder: r}
+}
+
+// Stats returns the statistics per hashtag, sorted by hashtag.
+// Duplicates are matched to hashtags by their topic name.
+func (r *Recorder) Stats(topicName func(hashtag string) string) []TopicStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byHashtag := make(map[string]*TopicStats)
+	for _, post := range r.posts {
+		stats, ok := byHashtag[post.hashtag]
+		if !ok {
+			stats = &TopicStats{Hashtag: post.hashtag}
+			byHashtag[post.hashtag] = stats
+		}
+		stats.Posts++
+		stats.Expected += len(post.expected)
+		stats.Delivered += len(post.delivered)
+	}
+
+	result := make([]TopicStats, 0, len(byHashtag))
+	for hashtag, stats := range byHashtag {
+		stats.Duplicates = r.duplicates[topicName(hashtag)]
+		stats.Latencies = append([]time.Duration(nil), r.latencies[hashtag]...)
+		sort.Slice(stats.Latencies, func(i, j int) bool { return stats.Latencies[i] < stats.Latencies[j] })
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Hashtag < result[j].Hashtag })
+	return result
+}
+
+// Total combines per-hashtag statistics into one summary.
+func Total(stats []TopicStats) TopicStats {
+	total := TopicStats{Hashtag: "all"}
+	for _, s := range stats {
+		total.Posts += s.Posts
+		total.Expected += s.Expected
+		total.Delivered += s.Delivered
+		total.Duplicates += s.Duplicates
+		total.Latencies = append(total.Latencies, s.Latencies...)
+	}
+	sort.Slice(total.Latencies, func(i, j int) bool { return total.Latencies[i] < total.Latencies[j] })
+	return total
+}
+
+// duplicateTracer counts duplicate messages per topic. Every other event is ignored.
+type duplicateTracer struct {
+	recorder *Recorder
+}
+
+func (t *duplicateTracer) DuplicateMessage(msg *pubsub.Message) {
+	t.recorder.mu.Lock()
+	defer t.recorder.mu.Unlock()
+	t.recorder.duplicates[msg.GetTopic()]++
+}
+
+func (t *duplicateTracer) AddPeer(p peer.ID, proto protocol.ID)             {}
+func (t *duplicateTracer) RemovePeer(p peer.ID)                             {}
+func (t *duplicateTracer) Join(topic string)                                {}
+func (t *duplicateTracer) Leave(topic string)                               {}
+func (t *duplicateTracer) Graft(p peer.ID, topic string)                    {}
+func (t *duplicateTracer) Prune(p peer.ID, topic string)                    {}
+func (t *duplicateTracer) ValidateMessage(msg *pubsub.Message)              {}
+func (t *duplicateTracer) DeliverMessage(msg *pubsub.Message)               {}
+func (t *duplicateTracer) RejectMessage(msg *pubsub.Message, reason string) {}
+func (t *duplicateTracer) ThrottlePeer(p peer.ID)                           {}
+func (t *duplicateTracer) RecvRPC(rpc *pubsub.RPC)                          {}
+func (t *duplicateTracer) SendRPC(rpc *pubsub.RPC, p peer.ID)               {}
+func (t *duplicateTracer) DropRPC(rpc *pubsub.RPC, p peer.ID)               {}
+func (t *duplicateTracer) UndeliverableMessage(msg *pubsub.Message)         {}
+
+// LossyLinks returns a router option that drops each incoming message copy
+// with probability loss, as if it was lost on the link. Control messages are
+// kept, so the mesh still forms, and gossip can recover lost messages from
+// other peers. The option locks rng while it uses it, so rng must not be
+// shared with other nodes or anything else.
+func LossyLinks(loss float64, rng *rand.Rand) pubsub.Option {
+	var mu sync.Mutex
+	return pubsub.WithAppSpecificRpcInspector(func(from peer.ID, rpc *pubsub.RPC) error {
+		if loss <= 0 || len(rpc.Publish) == 0 {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		kept := make([]*pb.Message, 0, len(rpc.Publish))
+		for _, msg := range rpc.Publish {
+			if rng.Float64() >= loss {
+				kept = append(kept, msg)
+			}
+		}
+		rpc.Publish = kept
+		return nil
+	})
+}
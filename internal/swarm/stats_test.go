@@ -0,0 +1,72 @@
+package swarm
+
+import (
+	"socli/messaging"
+	"testing"
+	"time"
+)
+
+// TestRecorderStats tests that deliveries are counted once per expected node
+// and summarized per hashtag.
+func TestRecorderStats(t *testing.T) {
+	r := NewRecorder()
+	start := time.Now()
+
+	r.Published("a", "go", start, []int{1, 2})
+	r.Published("b", "ops", start, []int{1, 2, 3})
+	r.Delivered(1, "a", start.Add(10*time.Millisecond))
+	r.Delivered(1, "a", start.Add(50*time.Millisecond)) // A second copy doesn't count
+	r.Delivered(2, "a", start.Add(30*time.Millisecond))
+	r.Delivered(0, "a", start.Add(time.Millisecond)) // The author isn't expected
+	r.Delivered(3, "b", start.Add(20*time.Millisecond))
+	r.Delivered(1, "unknown", start)
+	r.duplicates[messaging.GetTopicForHashtag("go")] = 4
+
+	stats := r.Stats(messaging.GetTopicForHashtag)
+	if len(stats) != 2 || stats[0].Hashtag != "go" || stats[1].Hashtag != "ops" {
+		t.Fatalf("Stats() = %+v, want #go and #ops", stats)
+	}
+	goStats, opsStats := stats[0], stats[1]
+	if goStats.Posts != 1 || goStats.Expected != 2 || goStats.Delivered != 2 || goStats.DeliveryRatio() != 1 {
+		t.Errorf("#go stats = %+v, want 2 of 2 deliveries", goStats)
+	}
+	if goStats.DuplicateRate() != 2 {
+		t.Errorf("#go DuplicateRate() = %v, want 2", goStats.DuplicateRate())
+	}
+	if goStats.Percentile(0.5) != 10*time.Millisecond || goStats.Percentile(1) != 30*time.Millisecond {
+		t.Errorf("#go latencies = %v, want 10ms and 30ms", goStats.Latencies)
+	}
+	if opsStats.Delivered != 1 || opsStats.Expected != 3 {
+		t.Errorf("#ops stats = %+v, want 1 of 3 deliveries", opsStats)
+	}
+
+	total := Total(stats)
+	if total.Posts != 2 || total.Expected != 5 || total.Delivered != 3 || len(total.Latencies) != 3 {
+		t.Errorf("Total() = %+v, want 3 of 5 deliveries over 2 posts", total)
+	}
+}
+
+// TestTopicStatsPercentile tests percentiles of sorted latencies.
+func TestTopicStatsPercentile(t *testing.T) {
+	var s TopicStats
+	if got := s.Percentile(0.5); got != 0 {
+		t.Errorf("Percentile() without deliveries = %v, want 0", got)
+	}
+	for i := 1; i <= 100; i++ {
+		s.Latencies = append(s.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{0.5, 50 * time.Millisecond},
+		{0.99, 99 * time.Millisecond},
+		{1, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := s.Percentile(tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+// Package swarm runs several socli nodes in one process on a libp2p mocknet.
+// Each node is wired like the real client, with the PubSubManager, topic
+// validator, Broadcaster, memory store and inbound pipeline, so tests can
+// check end-to-end behavior without real networking.
+package swarm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"socli/config"
+	"socli/crypto"
+	"socli/internal"
+	"socli/messaging"
+	"socli/p2p"
+	"socli/storage"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// settleDelay is how long to wait after peers see each other on a topic
+// before publishing. Messages published right after peers meet can be lost
+// while the gossip streams are still opening, and reconnected peers are only
+// grafted back into the mesh on the next heartbeat.
+var settleDelay = pubsub.GossipSubHeartbeatInterval + 100*time.Millisecond
+
+// pollInterval is how often the Wait functions check for progress.
+const pollInterval = 10 * time.Millisecond
+
+// settings collects the options passed to New.
+type settings struct {
+	nodes   []func(i int, n *Node)
+	link    mocknet.LinkOptions
+	pubsub  func(i int) []pubsub.Option
+	deliver func(i int, hashtag string, msg *messaging.Message)
+}
+
+// Option customizes a swarm before its nodes are started.
+type Option func(s *settings)
+
+// WithConfig lets configure change the config of node i, e.g. to turn off encryption.
+func WithConfig(configure func(i int, cfg *config.Config)) Option {
+	return func(s *settings) {
+		s.nodes = append(s.nodes, func(i int, n *Node) {
+			configure(i, n.Config)
+		})
+	}
+}
+
+// WithKeyPair makes node i use the key pair returned by keyPair instead of a
+// new one. Nodes sharing a key pair can read each other's encrypted posts.
+func WithKeyPair(keyPair func(i int) *crypto.KeyPair) Option {
+	return func(s *settings) {
+		s.nodes = append(s.nodes, func(i int, n *Node) {
+			if kp := keyPair(i); kp != nil {
+				n.KeyPair = kp
+			}
+		})
+	}
+}
+
+// WithLinkOptions sets the latency and bandwidth of every link in the mocknet.
+func WithLinkOptions(opts mocknet.LinkOptions) Option {
+	return func(s *settings) {
+		s.link = opts
+	}
+}
+
+// WithPubSubOptions adds the router options returned by opts to node i's
+// GossipSub router, e.g. a tracer.
+func WithPubSubOptions(opts func(i int) []pubsub.Option) Option {
+	return func(s *settings) {
+		s.pubsub = opts
+	}
+}
+
+// WithDeliveryHook calls deliver for every post node i receives on a
+// subscribed hashtag, before it is stored. It is called from the
+// subscription's goroutine.
+func WithDeliveryHook(deliver func(i int, hashtag string, msg *messaging.Message)) Option {
+	return func(s *settings) {
+		s.deliver = deliver
+	}
+}
+
+// Swarm is a set of socli nodes connected through a mocknet.
+type Swarm struct {
+	Net   mocknet.Mocknet
+	Nodes []*Node
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	isolated map[int][]peer.ID // Former connections of isolated nodes
+}
+
+// Node is one socli peer in a swarm.
+type Node struct {
+	Host        host.Host
+	PubSub      *p2p.PubSubManager
+	Validator   *messaging.Validator
+	Broadcaster *messaging.Broadcaster
+	Circles     *messaging.Circles
+	Store       *storage.MemoryStore
+	KeyPair     *crypto.KeyPair
+	Config      *config.Config
+
+	ctx     context.Context
+	deliver func(hashtag string, msg *messaging.Message)
+	mu      sync.Mutex
+	subs    map[string]*pubsub.Subscription // Keyed by hashtag
+}
+
+// New creates n nodes on a fresh mocknet, each with the default config and
+// its own key pair unless changed by the options. The nodes are linked but
+// not connected; call Connect or ConnectRandom to connect them.
+func New(ctx context.Context, n int, opts ...Option) (*Swarm, error) {
+	var set settings
+	for _, opt := range opts {
+		opt(&set)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Swarm{Net: mocknet.New(), ctx: ctx, cancel: cancel, isolated: make(map[int][]peer.ID)}
+	s.Net.SetLinkDefaults(set.link)
+
+	for i := 0; i < n; i++ {
+		node, err := s.newNode(i, &set)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("creating node %d: %w", i, err)
+		}
+		s.Nodes = append(s.Nodes, node)
+	}
+	if err := s.Net.LinkAll(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// newNode creates node i and wires it up like main.go does.
+func (s *Swarm) newNode(i int, set *settings) (*Node, error) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	h, err := s.Net.GenPeer()
+	if err != nil {
+		return nil, err
+	}
+	node := &Node{
+		Host:    h,
+		Store:   storage.NewMemoryStore(),
+		KeyPair: keyPair,
+		Config:  config.DefaultConfig(),
+		ctx:     s.ctx,
+		subs:    make(map[string]*pubsub.Subscription),
+	}
+	for _, configure := range set.nodes {
+		configure(i, node)
+	}
+	if set.deliver != nil {
+		node.deliver = func(hashtag string, msg *messaging.Message) {
+			set.deliver(i, hashtag, msg)
+		}
+	}
+
+	var extra []pubsub.Option
+	if set.pubsub != nil {
+		extra = set.pubsub(i)
+	}
+	node.PubSub, err = p2p.NewPubSubManager(s.ctx, h, node.Config, extra...)
+	if err != nil {
+		return nil, err
+	}
+	node.Validator = messaging.NewValidator(node.Config, node.KeyPair)
+	node.Validator.SetPosts(node.Store)
+	node.PubSub.SetMessageValidator(node.Validator.Validate)
+	node.Broadcaster = messaging.NewBroadcaster(node.PubSub, node.Config, node.KeyPair)
+	node.Circles = messaging.NewCircles(node.PubSub, node.Config, node.KeyPair, h.ID())
+	node.Circles.Start(s.ctx)
+	node.Validator.SetCircles(node.Circles)
+	node.Broadcaster.SetCircles(node.Circles)
+	return node, nil
+}
+
+// Connect connects every node to every other node.
+func (s *Swarm) Connect() error {
+	return s.Net.ConnectAllButSelf()
+}
+
+// ConnectRandom connects the nodes in a ring, so the swarm is never split,
+// and adds random connections until each node has about degree peers.
+func (s *Swarm) ConnectRandom(degree int, rng *rand.Rand) error {
+	n := len(s.Nodes)
+	if n < 2 {
+		return nil
+	}
+	for i := range s.Nodes {
+		if _, err := s.Net.ConnectPeers(s.Nodes[i].ID(), s.Nodes[(i+1)%n].ID()); err != nil {
+			return err
+		}
+	}
+	for i, node := range s.Nodes {
+		for attempts := 0; len(node.Host.Network().Peers()) < degree && attempts < 4*degree; attempts++ {
+			j := rng.Intn(n)
+			if j == i || node.Host.Network().Connectedness(s.Nodes[j].ID()) == network.Connected {
+				continue
+			}
+			if _, err := s.Net.ConnectPeers(node.ID(), s.Nodes[j].ID()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Isolate cuts node i off from the network, as if it went offline.
+// Its connections are remembered for Rejoin.
+func (s *Swarm) Isolate(i int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.isolated[i]; ok {
+		return nil
+	}
+
+	node := s.Nodes[i]
+	peers := node.Host.Network().Peers()
+	for _, p := range peers {
+		if err := s.Net.UnlinkPeers(node.ID(), p); err != nil {
+			return err
+		}
+		if err := s.Net.DisconnectPeers(node.ID(), p); err != nil {
+			return err
+		}
+	}
+	s.isolated[i] = peers
+	return nil
+}
+
+// Rejoin reconnects an isolated node i to the peers it had before.
+func (s *Swarm) Rejoin(i int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	peers, ok := s.isolated[i]
+	if !ok {
+		return nil
+	}
+	delete(s.isolated, i)
+
+	node := s.Nodes[i]
+	for _, p := range peers {
+		if _, err := s.Net.LinkPeers(node.ID(), p); err != nil {
+			return err
+		}
+		if _, err := s.Net.ConnectPeers(node.ID(), p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Isolated reports whether node i is currently cut off by Isolate.
+func (s *Swarm) Isolated(i int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.isolated[i]
+	return ok
+}
+
+// Close stops all nodes and the mocknet.
+func (s *Swarm) Close() error {
+	s.cancel()
+	return s.Net.Close()
+}
+
+// SubscribeAll subscribes every node to the hashtag.
+func (s *Swarm) SubscribeAll(hashtag string) error {
+	for i, node := range s.Nodes {
+		if err := node.Subscribe(hashtag); err != nil {
+			return fmt.Errorf("node %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// WaitForMesh waits until every node subscribed to the hashtag sees at least
+// one other subscriber, and then gives the gossip streams a moment to open.
+func (s *Swarm) WaitForMesh(ctx context.Context, hashtag string) error {
+	topicName := messaging.GetTopicForHashtag(hashtag)
+	for _, node := range s.Nodes {
+		if !node.Subscribed(hashtag) {
+			continue
+		}
+		topic, err := node.PubSub.JoinTopic(topicName)
+		if err != nil {
+			return err
+		}
+		for len(topic.ListPeers()) == 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("waiting for peers on #%s: %w", hashtag, ctx.Err())
+			case <-time.After(pollInterval):
+			}
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(settleDelay):
+		return nil
+	}
+}
+
+// ID returns the node's peer ID.
+func (n *Node) ID() peer.ID {
+	return n.Host.ID()
+}
+
+// Subscribe joins the hashtag's topic and stores every post delivered on it,
+// like the client's subscriptions do.
+func (n *Node) Subscribe(hashtag string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.subs[hashtag]; ok {
+		return nil
+	}
+
+	topic, err := n.PubSub.JoinTopic(messaging.GetTopicForHashtag(hashtag))
+	if err != nil {
+		return err
+	}
+	sub, err := n.PubSub.SubscribeToTopic(topic)
+	if err != nil {
+		return err
+	}
+	n.subs[hashtag] = sub
+
+	go messaging.ReadSubscription(n.ctx, sub, n.ID(), func(msg *messaging.Message, _ peer.ID) {
+		if n.deliver != nil {
+			n.deliver(hashtag, msg)
+		}
+		if internal.ApplyFilters(msg) {
+			n.Store.AddPost(msg)
+		}
+	})
+	return nil
+}
+
+// Unsubscribe stops receiving posts on the hashtag.
+func (n *Node) Unsubscribe(hashtag string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if sub, ok := n.subs[hashtag]; ok {
+		sub.Cancel()
+		delete(n.subs, hashtag)
+	}
+}
+
+// Subscribed reports whether the node is subscribed to the hashtag.
+func (n *Node) Subscribed(hashtag string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, ok := n.subs[hashtag]
+	return ok
+}
+
+// Post composes, signs, stores and broadcasts a post, like the compose view does.
+func (n *Node) Post(ctx context.Context, content string) (*messaging.Message, error) {
+	msg, err := n.NewPost(content)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.Publish(ctx, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// NewPost composes and signs a post without publishing it.
+func (n *Node) NewPost(content string) (*messaging.Message, error) {
+	msg := &messaging.Message{
+		ID:        uuid.New().String(),
+		Author:    n.ID().String(),
+		Content:   content,
+		Hashtags:  internal.ExtractHashtags(content),
+		Timestamp: time.Now(),
+		Type:      messaging.PostMsg,
+	}
+	if err := msg.Sign(n.KeyPair); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Publish stores a post and broadcasts it on its hashtags.
+func (n *Node) Publish(ctx context.Context, msg *messaging.Message) error {
+	n.Store.AddPost(msg)
+	return n.Broadcaster.Broadcast(ctx, msg)
+}
+
+// PublishRaw publishes msg on the hashtag's topic exactly as given, without
+// signing or encrypting it, e.g. to check that forged posts are rejected.
+// Local publishes go through the node's own validator too, so an invalid
+// post is refused with an error before it reaches any peer.
+func (n *Node) PublishRaw(ctx context.Context, hashtag string, msg *messaging.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	topic, err := n.PubSub.JoinTopic(messaging.GetTopicForHashtag(hashtag))
+	if err != nil {
+		return err
+	}
+	return n.PubSub.PublishMessage(ctx, topic, data)
+}
+
+// Has reports whether the node has stored the post with the given ID.
+func (n *Node) Has(id string) bool {
+	_, ok := n.Store.GetPost(id)
+	return ok
+}
+
+// WaitForPost waits until the node has stored the post with the given ID.
+func (n *Node) WaitForPost(ctx context.Context, id string) (*messaging.Message, error) {
+	for {
+		if post, ok := n.Store.GetPost(id); ok {
+			return post, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(fmt.Errorf("post %s never arrived", id), ctx.Err())
+		case <-time.After(pollInterval):
+		}
+	}
+}
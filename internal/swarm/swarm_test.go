@@ -0,0 +1,372 @@
+package swarm
+
+import (
+	"context"
+	"math/rand"
+	"socli/config"
+	"socli/crypto"
+	"socli/messaging"
+	"testing"
+	"time"
+)
+
+// newSwarm creates and connects a swarm of n nodes, closing it when the test ends.
+func newSwarm(t *testing.T, ctx context.Context, n int, opts ...Option) *Swarm {
+	t.Helper()
+	s, err := New(ctx, n, opts...)
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if err := s.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v, want nil", err)
+	}
+	return s
+}
+
+// plaintext turns off payload encryption, so every node can read every post.
+func plaintext(i int, cfg *config.Config) {
+	cfg.Privacy.EncryptMessages = false
+}
+
+// TestSwarmDelivery tests that a post reaches every subscribed node exactly
+// once, with its signature intact.
+func TestSwarmDelivery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s := newSwarm(t, ctx, 4, WithConfig(plaintext))
+	if err := s.SubscribeAll("general"); err != nil {
+		t.Fatalf("SubscribeAll() error = %v, want nil", err)
+	}
+	if err := s.WaitForMesh(ctx, "general"); err != nil {
+		t.Fatalf("WaitForMesh() error = %v, want nil", err)
+	}
+
+	post, err := s.Nodes[0].Post(ctx, "Hello swarm")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	for i, node := range s.Nodes[1:] {
+		got, err := node.WaitForPost(ctx, post.ID)
+		if err != nil {
+			t.Fatalf("Node %d: %v", i+1, err)
+		}
+		if got.Content != post.Content || !got.VerifySignature() {
+			t.Errorf("Node %d got %+v, want the signed post %q", i+1, got, post.Content)
+		}
+		if n := len(node.Store.GetAllPosts()); n != 1 {
+			t.Errorf("Node %d stored %d posts, want 1", i+1, n)
+		}
+	}
+}
+
+// TestSwarmSubscriptionChanges tests that nodes only receive posts on the
+// hashtags they are subscribed to.
+func TestSwarmSubscriptionChanges(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s := newSwarm(t, ctx, 3, WithConfig(plaintext))
+	if err := s.SubscribeAll("go"); err != nil {
+		t.Fatalf("SubscribeAll() error = %v, want nil", err)
+	}
+	if err := s.WaitForMesh(ctx, "go"); err != nil {
+		t.Fatalf("WaitForMesh() error = %v, want nil", err)
+	}
+
+	s.Nodes[2].Unsubscribe("go")
+	first, err := s.Nodes[0].Post(ctx, "Generics are neat #go")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	if _, err := s.Nodes[1].WaitForPost(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s.Nodes[2].Has(first.ID) {
+		t.Error("Unsubscribed node received a post")
+	}
+
+	// Subscribing again delivers new posts
+	if err := s.Nodes[2].Subscribe("go"); err != nil {
+		t.Fatalf("Subscribe() error = %v, want nil", err)
+	}
+	if err := s.WaitForMesh(ctx, "go"); err != nil {
+		t.Fatalf("WaitForMesh() error = %v, want nil", err)
+	}
+	second, err := s.Nodes[0].Post(ctx, "So are iterators #go")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	if _, err := s.Nodes[2].WaitForPost(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSwarmEncryption tests that encrypted posts are only readable by nodes
+// holding the sender's key.
+func TestSwarmEncryption(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	shared, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	// Nodes 0 and 1 share a key; node 2 has its own
+	s := newSwarm(t, ctx, 3, WithKeyPair(func(i int) *crypto.KeyPair {
+		if i < 2 {
+			return shared
+		}
+		return nil
+	}))
+	if err := s.SubscribeAll("general"); err != nil {
+		t.Fatalf("SubscribeAll() error = %v, want nil", err)
+	}
+	if err := s.WaitForMesh(ctx, "general"); err != nil {
+		t.Fatalf("WaitForMesh() error = %v, want nil", err)
+	}
+
+	secret, err := s.Nodes[0].Post(ctx, "For key holders only")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	if _, err := s.Nodes[1].WaitForPost(ctx, secret.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Node 2's own post arrives at node 1 only if node 1 held node 2's key,
+	// so wait for node 0's next post instead to be sure gossip has settled
+	other, err := s.Nodes[2].Post(ctx, "Nobody else can read this")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	marker, err := s.Nodes[0].Post(ctx, "Still here")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	if _, err := s.Nodes[1].WaitForPost(ctx, marker.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s.Nodes[2].Has(secret.ID) || s.Nodes[2].Has(marker.ID) {
+		t.Error("Node without the key stored an encrypted post")
+	}
+	if s.Nodes[1].Has(other.ID) {
+		t.Error("Node stored a post encrypted for another key")
+	}
+}
+
+// TestSwarmMixedWireFormats tests that peers publishing binary messages and
+// peers still publishing JSON read each other's posts.
+func TestSwarmMixedWireFormats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s := newSwarm(t, ctx, 2, WithConfig(func(i int, cfg *config.Config) {
+		plaintext(i, cfg)
+		if i == 0 {
+			cfg.Wire.Format = messaging.WireBinary
+			cfg.Wire.CompressAbove = 16
+		}
+	}))
+	if err := s.SubscribeAll("general"); err != nil {
+		t.Fatalf("SubscribeAll() error = %v, want nil", err)
+	}
+	if err := s.WaitForMesh(ctx, "general"); err != nil {
+		t.Fatalf("WaitForMesh() error = %v, want nil", err)
+	}
+
+	for i, text := range []string{"Compact and compressed, as binary", "Plain old JSON"} {
+		post, err := s.Nodes[i].Post(ctx, text)
+		if err != nil {
+			t.Fatalf("Post() error = %v, want nil", err)
+		}
+		received, err := s.Nodes[1-i].WaitForPost(ctx, post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if received.Content != text {
+			t.Errorf("Received content = %q, want %q", received.Content, text)
+		}
+	}
+}
+
+// TestSwarmForgedPost tests that a post with a broken signature is refused
+// and never reaches other nodes.
+func TestSwarmForgedPost(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s := newSwarm(t, ctx, 2, WithConfig(plaintext))
+	if err := s.SubscribeAll("general"); err != nil {
+		t.Fatalf("SubscribeAll() error = %v, want nil", err)
+	}
+	if err := s.WaitForMesh(ctx, "general"); err != nil {
+		t.Fatalf("WaitForMesh() error = %v, want nil", err)
+	}
+
+	post, err := s.Nodes[0].Post(ctx, "Original text")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	if _, err := s.Nodes[1].WaitForPost(ctx, post.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	forged := *post
+	forged.ID = "forged-" + post.ID
+	forged.Content = "Tampered text"
+	if err := s.Nodes[0].PublishRaw(ctx, "general", &forged); err == nil {
+		t.Error("PublishRaw() of a forged post error = nil, want a validation error")
+	}
+
+	marker, err := s.Nodes[0].Post(ctx, "After the forgery")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	if _, err := s.Nodes[1].WaitForPost(ctx, marker.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s.Nodes[1].Has(forged.ID) {
+		t.Error("Forged post was delivered")
+	}
+}
+
+// TestSwarmChurn tests that an isolated node misses posts and receives new
+// ones after rejoining.
+func TestSwarmChurn(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s, err := New(ctx, 4, WithConfig(plaintext))
+	if err != nil {
+		t.Fatalf("New() error = %v, want nil", err)
+	}
+	defer s.Close()
+	if err := s.ConnectRandom(2, rand.New(rand.NewSource(1))); err != nil {
+		t.Fatalf("ConnectRandom() error = %v, want nil", err)
+	}
+	if err := s.SubscribeAll("general"); err != nil {
+		t.Fatalf("SubscribeAll() error = %v, want nil", err)
+	}
+	if err := s.WaitForMesh(ctx, "general"); err != nil {
+		t.Fatalf("WaitForMesh() error = %v, want nil", err)
+	}
+
+	if err := s.Isolate(3); err != nil {
+		t.Fatalf("Isolate() error = %v, want nil", err)
+	}
+	if !s.Isolated(3) || len(s.Nodes[3].Host.Network().Peers()) != 0 {
+		t.Fatal("Isolated node still has connections")
+	}
+	missed, err := s.Nodes[0].Post(ctx, "While you were away")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	for _, node := range s.Nodes[1:3] {
+		if _, err := node.WaitForPost(ctx, missed.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Nodes[3].Has(missed.ID) {
+		t.Error("Isolated node received a post")
+	}
+
+	if err := s.Rejoin(3); err != nil {
+		t.Fatalf("Rejoin() error = %v, want nil", err)
+	}
+	if err := s.WaitForMesh(ctx, "general"); err != nil {
+		t.Fatalf("WaitForMesh() error = %v, want nil", err)
+	}
+	post, err := s.Nodes[0].Post(ctx, "Welcome back")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	if _, err := s.Nodes[3].WaitForPost(ctx, post.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForJoin waits until the owner of a circle has seen member join it.
+func waitForJoin(ctx context.Context, owner *Node, name string, member *Node) error {
+	for {
+		for _, m := range owner.Circles.Members(name) {
+			if m.PeerID == member.ID().String() && len(m.BoxKey) == 32 {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// TestSwarmCircle tests that circle posts only reach members, that outsiders
+// can't post in a circle, and that a removed member can't read new posts.
+func TestSwarmCircle(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	s := newSwarm(t, ctx, 4)
+	owner, removed, outsider, member := s.Nodes[0], s.Nodes[1], s.Nodes[2], s.Nodes[3]
+
+	if err := owner.Circles.Create("secret"); err != nil {
+		t.Fatalf("Create() error = %v, want nil", err)
+	}
+	for _, node := range []*Node{removed, member} {
+		token, err := owner.Circles.Invite(ctx, "secret", node.ID())
+		if err != nil {
+			t.Fatalf("Invite() error = %v, want nil", err)
+		}
+		if name, err := node.Circles.Join(token); err != nil || name != "secret" {
+			t.Fatalf("Join() = %q, %v, want secret, nil", name, err)
+		}
+		if err := waitForJoin(ctx, owner, "secret", node); err != nil {
+			t.Fatalf("Waiting for the join: %v", err)
+		}
+	}
+	// The outsider listens on the circle's hashtag like any other
+	if err := s.SubscribeAll("secret"); err != nil {
+		t.Fatalf("SubscribeAll() error = %v, want nil", err)
+	}
+	if err := s.WaitForMesh(ctx, "secret"); err != nil {
+		t.Fatalf("WaitForMesh() error = %v, want nil", err)
+	}
+
+	post, err := owner.Post(ctx, "Members only #secret")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	for _, node := range []*Node{removed, member} {
+		if _, err := node.WaitForPost(ctx, post.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	intrusion, err := outsider.Post(ctx, "Let me in #secret")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+
+	if err := owner.Circles.Remove(ctx, "secret", removed.ID()); err != nil {
+		t.Fatalf("Remove() error = %v, want nil", err)
+	}
+	for removed.Circles.IsCircle("secret") {
+		select {
+		case <-ctx.Done():
+			t.Fatal("Removed member still has the circle")
+		case <-time.After(pollInterval):
+		}
+	}
+	after, err := owner.Post(ctx, "After the rotation #secret")
+	if err != nil {
+		t.Fatalf("Post() error = %v, want nil", err)
+	}
+	if _, err := member.WaitForPost(ctx, after.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if outsider.Has(post.ID) || outsider.Has(after.ID) {
+		t.Error("Outsider stored a circle post")
+	}
+	if owner.Has(intrusion.ID) || member.Has(intrusion.ID) {
+		t.Error("Members stored a post from an outsider")
+	}
+	if removed.Has(after.ID) {
+		t.Error("Removed member stored a post sent after the rotation")
+	}
+}
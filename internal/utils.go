@@ -1,8 +1,10 @@
 package internal
 
 import (
+	"fmt"
 	"regexp"
 	"socli/messaging"
+	"time"
 )
 
 // ExtractHashtags finds all hashtags in a message content.
@@ -26,10 +28,70 @@ func ExtractHashtags(content string) []string {
 	return hashtags
 }
 
+// MentionPattern matches an @mention of a peer ID or a short name. The @
+// must not follow a word character, so e-mail addresses aren't mentions.
+var MentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w+)`)
+
+// ExtractMentions finds the names mentioned with @ in a message content,
+// each once and in order of appearance. It returns nil if there are none.
+func ExtractMentions(content string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+	for _, match := range MentionPattern.FindAllStringSubmatch(content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			mentions = append(mentions, match[1])
+		}
+	}
+	return mentions
+}
+
+// ReplyHashtags returns the hashtags of a reply: those of the post it replies
+// to, so the reply reaches the same topics, followed by any new ones in the
+// reply's own content.
+func ReplyHashtags(parent *messaging.Message, content string) []string {
+	hashtags := append([]string(nil), parent.Hashtags...)
+	seen := make(map[string]bool, len(hashtags))
+	for _, tag := range hashtags {
+		seen[tag] = true
+	}
+	for _, match := range regexp.MustCompile(`#(\w+)`).FindAllStringSubmatch(content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			hashtags = append(hashtags, match[1])
+		}
+	}
+
+	if len(hashtags) == 0 {
+		hashtags = append(hashtags, "general")
+	}
+	return hashtags
+}
+
 // ApplyFilters applies any defined message filters.
-// This is a placeholder for future filtering logic.
+// It reports whether a received message belongs in the feed.
 func ApplyFilters(msg *messaging.Message) bool {
-	// Placeholder: Always allow messages for now.
+	// Circle member lists and joins are handled by messaging.Circles
+	if msg.Type == messaging.CircleMsg {
+		return false
+	}
 	// Future implementation could filter based on content, author, etc.
 	return true
-}
\ No newline at end of file
+}
+
+// ParseScheduleTime reads when a scheduled post is due: a time of day such
+// as "09:00", which is its next occurrence after now, or a delay such as
+// "10m" or "1h30m".
+func ParseScheduleTime(s string, now time.Time) (time.Time, error) {
+	if clock, err := time.Parse("15:04", s); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+	if delay, err := time.ParseDuration(s); err == nil && delay > 0 {
+		return now.Add(delay), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a time like 09:00 nor a delay like 10m", s)
+}
@@ -3,7 +3,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -19,14 +18,23 @@ import (
 	"socli/tui"
 	"syscall"
 
-	"github.com/libp2p/go-libp2p/core/peer"
 	tea "github.com/charmbracelet/bubbletea"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // Version is the application version, set at build time.
 var Version = "dev"
 
 func main() {
+	// Subcommands are dispatched before the top-level flags are parsed
+	if len(os.Args) > 1 && os.Args[1] == "node" {
+		os.Exit(runNode(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "sim" {
+		os.Exit(runSim(os.Args[2:]))
+	}
+
 	// Define command-line flags
 	versionFlag := flag.Bool("version", false, "Print the version number and exit")
 	flag.Parse()
@@ -87,14 +95,47 @@ func main() {
 	}
 
 	// Set up pubsub
-	psManager, err := p2p.NewPubSubManager(ctx, netManager.Host)
+	psManager, err := p2p.NewPubSubManager(ctx, netManager.Host, cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating pubsub manager: %v\n", err)
 		os.Exit(1)
 	}
+	// Reject badly signed messages on every topic before they are forwarded
+	validator := messaging.NewValidator(cfg, keyPair)
+	psManager.SetMessageValidator(validator.Validate)
 
 	// Create a new in-memory store
 	store := storage.NewMemoryStore()
+	validator.SetPosts(store)
+	// Posts with a TTL are hidden once they expire and purged on a timer
+	store.StartPurging(ctx, cfg.Expiry.PurgeInterval)
+
+	// Serve recent posts to peers that join later, and fetch ours from them
+	historySync := messaging.NewHistorySync(netManager.Host, store, cfg, validator)
+
+	// Collect and publish hashtag announcements for the topic directory
+	directory := messaging.NewDirectory(psManager, cfg, keyPair, netManager.Host.ID())
+	if err := directory.Start(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error joining directory topic: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Send presence heartbeats and track those of our peers
+	presence := messaging.NewPresence(psManager, cfg, keyPair, netManager.Host.ID())
+	if err := presence.Start(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error joining presence topic: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Share and fetch attachment chunks over the blob protocol
+	blobs := messaging.NewBlobs(netManager.Host, store, cfg)
+
+	// Exchange versions with peers, so the TUI can warn about incompatible ones
+	handshake := messaging.NewHandshake(netManager.Host, Version)
+
+	// Rate limits for posts on their way to the feed, shared by all subscriptions.
+	// The topic validator applies its own limits before posts are relayed.
+	inboundLimits := messaging.NewRateLimits(cfg)
 
 	// Create a new markdown renderer
 	renderer, err := content.NewMarkdownRenderer()
@@ -106,8 +147,49 @@ func main() {
 	// Create a new broadcaster, passing the keyPair
 	broadcaster := messaging.NewBroadcaster(psManager, cfg, keyPair)
 
+	// Our posts go out through the outbox, which holds them for topics
+	// without peers yet and retries them
+	outbox := messaging.NewOutbox(broadcaster, cfg)
+	outbox.Start(ctx)
+
+	// Invite-only circles: their posts are sealed with a group key that the
+	// validator opens and the broadcaster seals with
+	circles := messaging.NewCircles(psManager, cfg, keyPair, netManager.Host.ID())
+	circles.Start(ctx)
+	validator.SetCircles(circles)
+	broadcaster.SetCircles(circles)
+
+	// Posts scheduled with /schedule wait here until they are due. They are
+	// only written to disk if the user opted into it.
+	schedulePath := ""
+	if cfg.Schedule.Persist {
+		schedulePath = cfg.Schedule.Path
+	}
+	schedule, err := storage.NewScheduleQueue(schedulePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading scheduled posts: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Initialize the main application model from the tui package, passing the keyPair and config
-	appModel, err := tui.NewApp(netManager, store, renderer, psManager, broadcaster, keyPair, cfg)
+	appModel, err := tui.NewApp(tui.Deps{
+		NetManager:    netManager,
+		Store:         store,
+		Renderer:      renderer,
+		PubSub:        psManager,
+		Broadcaster:   broadcaster,
+		Outbox:        outbox,
+		HistorySync:   historySync,
+		Directory:     directory,
+		Presence:      presence,
+		Blobs:         blobs,
+		Handshake:     handshake,
+		InboundLimits: inboundLimits,
+		Circles:       circles,
+		Schedule:      schedule,
+		KeyPair:       keyPair,
+		Config:        cfg,
+	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing application: %v\n", err)
 		os.Exit(1)
@@ -116,12 +198,17 @@ func main() {
 	// Create a new BubbleTea program
 	p := tea.NewProgram(appModel, tea.WithAltScreen())
 
-	// Set the peer connected callback after components are initialized
+	// Tell the TUI about every peer that connects from now on, whether we
+	// dialed it or it dialed us. Peers connected before Init are synced there.
 	netManager.SetPeerConnectedCallback(func(id peer.ID) {
 		// Send a message to the TUI about the new peer
 		log.Printf("Main: Notifying TUI of peer connected: %s\n", id.String()) // Use log for background processes
 		p.Send(tui.PeerConnectedMsg{PeerID: id.String()})
 	})
+	// Peers that say hello first are checked for their version as well
+	handshake.SetHelloCallback(func(id peer.ID, version messaging.PeerVersion) {
+		p.Send(tui.PeerVersionMsg{PeerID: id, Version: version})
+	})
 
 	// Subscribe to a default topic and start listening for messages
 	// In a more advanced version, this would be dynamic based on user subscriptions.
@@ -138,55 +225,35 @@ func main() {
 		os.Exit(1)
 	}
 
-	go func() {
-		for {
-			msg, err := sub.Next(ctx)
-			if err != nil {
-				// Handle error (e.g., context cancellation)
-				return
-			}
-			// Don't display our own messages
-			if msg.ReceivedFrom == netManager.Host.ID() {
-				continue
-			}
-
-			// --- Message Processing ---
-			data := msg.Data
-
-			// Check if decryption is needed
-			if cfg.Privacy.EncryptMessages {
-				// Attempt to decrypt the message data using our private key.
-				// We assume the sender encrypted it with our public key.
-				// This is a simplification for prototype purposes.
-				// A real implementation would require proper key exchange.
-				decryptedData, ok := crypto.Decrypt(data, keyPair.PublicKey, keyPair.PrivateKey)
-				if ok {
-					data = decryptedData
-				} else {
-					// If decryption fails, the message might not be encrypted for us,
-					// or it's not encrypted at all. Log and try to parse as plaintext JSON.
-					// This is a simplification for the prototype.
-					log.Println("Warning: Failed to decrypt message, attempting to parse as plaintext.")
+	// Posts that mention us arrive on our inbox topic, whatever their hashtags
+	inboxTopic, err := psManager.JoinTopic(messaging.GetInboxTopic(netManager.Host.ID().String()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error joining inbox topic: %v\n", err)
+		os.Exit(1)
+	}
+	inbox, err := psManager.SubscribeToTopic(inboxTopic)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error subscribing to inbox topic: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, sub := range []*pubsub.Subscription{sub, inbox} {
+		go func() {
+			// Messages were decoded and validated by the topic validator before delivery
+			messaging.ReadSubscription(ctx, sub, netManager.Host.ID(), func(receivedMsg *messaging.Message, origin peer.ID) {
+				// Apply filters (placeholder)
+				if !internal.ApplyFilters(receivedMsg) {
+					return // Message was filtered out
 				}
-			}
-
-			var receivedMsg messaging.Message
-			if err := json.Unmarshal(data, &receivedMsg); err != nil {
-				// Handle unmarshalling error
-				log.Printf("Error unmarshalling message: %v\n", err)
-				continue
-			}
-
-			// Apply filters (placeholder)
-			if !internal.ApplyFilters(&receivedMsg) {
-				continue // Message was filtered out
-			}
-
-			// Send the processed message to the TUI
-			p.Send(tui.PostReceivedMsg{Post: &receivedMsg})
-			// --- End Message Processing ---
-		}
-	}()
+				if !inboundLimits.Allow(origin, receivedMsg) {
+					return // Publisher is over its rate limit
+				}
+
+				// Send the processed message to the TUI
+				p.Send(tui.PostReceivedMsg{Post: receivedMsg})
+			})
+		}()
+	}
 
 	// Set up a channel to listen for OS interrupt signals (Ctrl+C)
 	sigChan := make(chan os.Signal, 1)
@@ -241,4 +308,4 @@ func main() {
 
 	// The program will exit when the BubbleTea program finishes
 	// (which should happen quickly after context cancellation)
-}
\ No newline at end of file
+}
@@ -0,0 +1,182 @@
+package messaging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"socli/config"
+	"socli/p2p"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ChunkSize is the size attachments are split into. Every chunk but the last
+// is exactly this long.
+const ChunkSize = p2p.MaxBlobChunkBytes
+
+// maxAttachmentNameLength is the longest file name an attachment may carry.
+const maxAttachmentNameLength = 255
+
+// BlobStore is the part of the store that keeps attachment chunks, keyed by
+// their content address.
+type BlobStore interface {
+	PutChunk(hash string, data []byte)
+	GetChunk(hash string) ([]byte, bool)
+}
+
+// blobHash returns the content address of data.
+func blobHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// isBlobHash reports whether s looks like a content address.
+func isBlobHash(s string) bool {
+	if len(s) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// validate checks that the attachment is well-formed and no larger than maxSize bytes.
+func (a *Attachment) validate(maxSize int64) error {
+	if a.Name == "" || len(a.Name) > maxAttachmentNameLength || filepath.Base(a.Name) != a.Name {
+		return fmt.Errorf("invalid attachment name %q", a.Name)
+	}
+	if a.Size <= 0 || a.Size > maxSize {
+		return fmt.Errorf("attachment is %d bytes, limit is %d", a.Size, maxSize)
+	}
+	if !isBlobHash(a.Hash) {
+		return fmt.Errorf("invalid attachment hash %q", a.Hash)
+	}
+	if want := (a.Size + ChunkSize - 1) / ChunkSize; int64(len(a.Chunks)) != want {
+		return fmt.Errorf("attachment of %d bytes lists %d chunks, want %d", a.Size, len(a.Chunks), want)
+	}
+	for _, chunk := range a.Chunks {
+		if !isBlobHash(chunk) {
+			return fmt.Errorf("invalid chunk hash %q", chunk)
+		}
+	}
+	return nil
+}
+
+// Assemble puts the attachment together from the chunks in store. It reports
+// false if a chunk is missing or the result doesn't match the attachment's hash.
+func (a *Attachment) Assemble(store BlobStore) ([]byte, bool) {
+	data := make([]byte, 0, a.Size)
+	for _, hash := range a.Chunks {
+		chunk, ok := store.GetChunk(hash)
+		if !ok {
+			return nil, false
+		}
+		data = append(data, chunk...)
+	}
+	if int64(len(data)) != a.Size || blobHash(data) != a.Hash {
+		return nil, false
+	}
+	return data, true
+}
+
+// Blobs stores the files we attach and fetch, and shares them with peers over
+// the blob protocol. Like posts, blobs are only kept in memory.
+type Blobs struct {
+	host  host.Host
+	store BlobStore
+	cfg   *config.Config
+}
+
+// NewBlobs creates the attachment service and, if enabled in the config,
+// starts serving the chunks in store to other peers.
+func NewBlobs(h host.Host, store BlobStore, cfg *config.Config) *Blobs {
+	b := &Blobs{host: h, store: store, cfg: cfg}
+	if cfg.Attachments.ServeBlobs {
+		p2p.RegisterBlobHandler(h, b.serve)
+	}
+	return b
+}
+
+// serve answers a chunk request from the store.
+func (b *Blobs) serve(from peer.ID, hash string) ([]byte, bool) {
+	return b.store.GetChunk(hash)
+}
+
+// Add splits a file into chunks, keeps them in the store so peers can fetch
+// them, and returns the attachment describing it.
+func (b *Blobs) Add(name string, data []byte) (*Attachment, error) {
+	if len(data) == 0 {
+		return nil, errors.New("file is empty")
+	}
+	if int64(len(data)) > b.cfg.Attachments.MaxSize {
+		return nil, fmt.Errorf("file is %d bytes, limit is %d", len(data), b.cfg.Attachments.MaxSize)
+	}
+
+	attachment := &Attachment{
+		Name: filepath.Base(name),
+		Size: int64(len(data)),
+		Hash: blobHash(data),
+	}
+	for start := 0; start < len(data); start += ChunkSize {
+		end := start + ChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[start:end]
+		hash := blobHash(chunk)
+		b.store.PutChunk(hash, chunk)
+		attachment.Chunks = append(attachment.Chunks, hash)
+	}
+	return attachment, nil
+}
+
+// Fetch returns the attachment's content, fetching missing chunks from the
+// peers in the order given. Every chunk is checked against its hash, so any
+// peer that cached the file can serve it.
+func (b *Blobs) Fetch(ctx context.Context, attachment *Attachment, peers []peer.ID) ([]byte, error) {
+	if err := attachment.validate(b.cfg.Attachments.MaxSize); err != nil {
+		return nil, err
+	}
+
+	for _, hash := range attachment.Chunks {
+		if _, ok := b.store.GetChunk(hash); ok {
+			continue
+		}
+		if err := b.fetchChunk(ctx, hash, peers); err != nil {
+			return nil, err
+		}
+	}
+
+	data, ok := attachment.Assemble(b.store)
+	if !ok {
+		return nil, fmt.Errorf("attachment %s doesn't match its hash", attachment.Name)
+	}
+	return data, nil
+}
+
+// fetchChunk asks the peers for one chunk until one of them sends the right data.
+func (b *Blobs) fetchChunk(ctx context.Context, hash string, peers []peer.ID) error {
+	for _, p := range peers {
+		if p == b.host.ID() {
+			continue
+		}
+		data, err := p2p.RequestBlob(ctx, b.host, p, hash)
+		if err != nil {
+			if !errors.Is(err, p2p.ErrBlobNotFound) {
+				log.Printf("Blob: Request to %s failed: %v\n", p.String(), err)
+			}
+			continue
+		}
+		if blobHash(data) != hash {
+			log.Printf("Blob: Dropping chunk %s from %s: hash mismatch\n", hash, p.String())
+			continue
+		}
+		b.store.PutChunk(hash, data)
+		return nil
+	}
+	return fmt.Errorf("no peer could send chunk %s", hash)
+}
@@ -0,0 +1,152 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"socli/config"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// mapBlobStore keeps chunks in a map, like the memory store does.
+type mapBlobStore struct {
+	mu     sync.Mutex
+	chunks map[string][]byte
+}
+
+func newMapBlobStore() *mapBlobStore {
+	return &mapBlobStore{chunks: make(map[string][]byte)}
+}
+
+func (s *mapBlobStore) PutChunk(hash string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks[hash] = data
+}
+
+func (s *mapBlobStore) GetChunk(hash string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.chunks[hash]
+	return data, ok
+}
+
+// TestBlobsAdd tests that files are split into hashed chunks that assemble
+// back into the file.
+func TestBlobsAdd(t *testing.T) {
+	cfg := config.DefaultConfig()
+	store := newMapBlobStore()
+	blobs := &Blobs{store: store, cfg: cfg}
+
+	data := bytes.Repeat([]byte("0123456789"), ChunkSize/4) // Two and a half chunks
+	attachment, err := blobs.Add("/var/log/app.log", data)
+	if err != nil {
+		t.Fatalf("Add() error = %v, want nil", err)
+	}
+	if attachment.Name != "app.log" || attachment.Size != int64(len(data)) || len(attachment.Chunks) != 3 {
+		t.Errorf("Add() = %+v, want app.log of %d bytes in 3 chunks", attachment, len(data))
+	}
+	if err := attachment.validate(cfg.Attachments.MaxSize); err != nil {
+		t.Errorf("validate() error = %v, want nil", err)
+	}
+	if got, ok := attachment.Assemble(store); !ok || !bytes.Equal(got, data) {
+		t.Error("Assemble() did not return the original file")
+	}
+
+	if _, err := blobs.Add("empty.txt", nil); err == nil {
+		t.Error("Add() of an empty file error = nil, want an error")
+	}
+	if _, err := blobs.Add("big.bin", make([]byte, cfg.Attachments.MaxSize+1)); err == nil {
+		t.Error("Add() of a file over max_size error = nil, want an error")
+	}
+}
+
+// TestAttachmentValidate tests that malformed attachments are rejected.
+func TestAttachmentValidate(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	tests := []struct {
+		name       string
+		attachment Attachment
+		wantErr    bool
+	}{
+		{"Valid", Attachment{Name: "notes.txt", Size: 10, Hash: hash, Chunks: []string{hash}}, false},
+		{"PathInName", Attachment{Name: "../notes.txt", Size: 10, Hash: hash, Chunks: []string{hash}}, true},
+		{"EmptyName", Attachment{Size: 10, Hash: hash, Chunks: []string{hash}}, true},
+		{"TooLarge", Attachment{Name: "big.bin", Size: 1 << 30, Hash: hash, Chunks: []string{hash}}, true},
+		{"BadHash", Attachment{Name: "notes.txt", Size: 10, Hash: "not-a-hash", Chunks: []string{hash}}, true},
+		{"WrongChunkCount", Attachment{Name: "notes.txt", Size: ChunkSize + 1, Hash: hash, Chunks: []string{hash}}, true},
+		{"BadChunkHash", Attachment{Name: "notes.txt", Size: 10, Hash: hash, Chunks: []string{"zz"}}, true},
+	}
+
+	maxSize := config.DefaultConfig().Attachments.MaxSize
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.attachment.validate(maxSize); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestBlobsFetch tests fetching an attachment from its author, and then from
+// a peer that only cached it.
+func TestBlobsFetch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cfg := config.DefaultConfig()
+
+	mn := mocknet.New()
+	defer mn.Close()
+	hosts := make([]host.Host, 3)
+	blobs := make([]*Blobs, 3)
+	for i := range blobs {
+		var err error
+		if hosts[i], err = mn.GenPeer(); err != nil {
+			t.Fatalf("Failed to create mock host: %v", err)
+		}
+		blobs[i] = NewBlobs(hosts[i], newMapBlobStore(), cfg)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+
+	data := bytes.Repeat([]byte("log line\n"), ChunkSize/8)
+	attachment, err := blobs[0].Add("app.log", data)
+	if err != nil {
+		t.Fatalf("Add() error = %v, want nil", err)
+	}
+
+	// The first peer fetches from the author
+	got, err := blobs[1].Fetch(ctx, attachment, []peer.ID{hosts[0].ID()})
+	if err != nil {
+		t.Fatalf("Fetch() from the author error = %v, want nil", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Fetch() from the author returned different data")
+	}
+
+	// With the author gone, the second peer fetches from the first one's cache
+	if err := mn.UnlinkPeers(hosts[0].ID(), hosts[2].ID()); err != nil {
+		t.Fatalf("Failed to unlink peers: %v", err)
+	}
+	got, err = blobs[2].Fetch(ctx, attachment, []peer.ID{hosts[0].ID(), hosts[1].ID()})
+	if err != nil {
+		t.Fatalf("Fetch() from a caching peer error = %v, want nil", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Fetch() from a caching peer returned different data")
+	}
+
+	// A tampered attachment can't be assembled from honest chunks
+	tampered := *attachment
+	tampered.Hash = strings.Repeat("00", 32)
+	if _, err := blobs[2].Fetch(ctx, &tampered, []peer.ID{hosts[1].ID()}); err == nil {
+		t.Error("Fetch() of an attachment with the wrong hash error = nil, want an error")
+	}
+}
@@ -2,7 +2,8 @@ package messaging
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"socli/config"
 	"socli/crypto"
@@ -14,6 +15,7 @@ type Broadcaster struct {
 	psm     p2p.PubSubManagerInterface // Use the interface
 	cfg     *config.Config
 	keyPair *crypto.KeyPair // Dedicated key pair for application-level encryption/signing
+	circles *Circles        // Circles whose posts are sealed with a group key; may be nil
 }
 
 // NewBroadcaster creates a new message broadcaster.
@@ -22,11 +24,42 @@ func NewBroadcaster(psm p2p.PubSubManagerInterface, cfg *config.Config, keyPair
 	return &Broadcaster{psm: psm, cfg: cfg, keyPair: keyPair}
 }
 
-// Broadcast sends a message to all relevant topics.
-func (b *Broadcaster) Broadcast(ctx context.Context, msg *Message) error {
-	data, err := json.Marshal(msg)
+// SetCircles makes the broadcaster seal posts on circle hashtags with the
+// circle's group key.
+func (b *Broadcaster) SetCircles(circles *Circles) {
+	b.circles = circles
+}
+
+// envelope is a message encoded for one of the topics it goes to.
+type envelope struct {
+	topic string
+	data  []byte
+}
+
+// envelopes encodes a message for every topic it goes to: sealed for the
+// circles it is posted in, or else encrypted if enabled, for its hashtags
+// and the inboxes of the peers it mentions.
+func (b *Broadcaster) envelopes(msg *Message) ([]envelope, error) {
+	data, err := EncodeMessage(msg, b.cfg)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// A post on a circle only goes to the circle's topic, sealed with its
+	// group key, so it never leaks onto the post's public hashtags
+	var envelopes []envelope
+	for _, hashtag := range msg.Hashtags {
+		if !b.circles.IsCircle(hashtag) {
+			continue
+		}
+		sealed, err := b.circles.Seal(hashtag, data)
+		if err != nil {
+			return nil, err
+		}
+		envelopes = append(envelopes, envelope{topic: GetTopicForHashtag(hashtag), data: sealed})
+	}
+	if len(envelopes) > 0 {
+		return envelopes, nil
 	}
 
 	// Check if encryption is enabled in the configuration
@@ -38,36 +71,63 @@ func (b *Broadcaster) Broadcast(ctx context.Context, msg *Message) error {
 		encryptedData, err := crypto.Encrypt(data, b.keyPair.PublicKey, b.keyPair.PrivateKey)
 		if err != nil {
 			log.Printf("Error encrypting message ID %s: %v", msg.ID, err)
-			return err // Handle encryption error
+			return nil, err // Handle encryption error
 		}
 		data = encryptedData
 	}
 
 	for _, hashtag := range msg.Hashtags {
-		topicName := GetTopicForHashtag(hashtag)
-		// Attempt to join the topic. If it's already joined, pubsub implementations
-		// usually handle this gracefully or return a specific error.
-		// We will log all errors for now, but not stop broadcasting.
-		// A more advanced implementation might cache joined topics.
-		topic, err := b.psm.JoinTopic(topicName)
-		if err != nil {
-			// Log the error but continue with other hashtags.
-			// The "topic already exists" error is likely benign and can be ignored
-			// if the goal is simply to ensure the topic is joined before publishing.
-			// However, without knowing the specific error type, we log it for visibility.
-			log.Printf("Warning: Could not join topic '%s' for message ID %s: %v", topicName, msg.ID, err)
-			// Depending on the pubsub implementation, we might still be able to publish
-			// to a topic we think we failed to join. This is a potential area for refinement.
-			// For now, we skip publishing to this specific topic if join failed.
+		envelopes = append(envelopes, envelope{topic: GetTopicForHashtag(hashtag), data: data})
+	}
+
+	// Mentioned peers also get the post in their inbox, so they see it even
+	// on hashtags they don't follow
+	for _, mention := range msg.Mentions {
+		if mention == msg.Author {
 			continue
 		}
-		
-		if err := b.psm.PublishMessage(ctx, topic, data); err != nil {
-			// Log publish errors
-			log.Printf("Error publishing to topic '%s' for message ID %s: %v", topicName, msg.ID, err)
-			// Continue with other hashtags even if one fails
-		}
+		envelopes = append(envelopes, envelope{topic: GetInboxTopic(mention), data: data})
 	}
+	return envelopes, nil
+}
 
+// publish publishes data on a topic and returns the number of peers
+// subscribed to it. If waitForPeers is set and there are none, nothing is
+// published, since the message would reach nobody.
+func (b *Broadcaster) publish(ctx context.Context, topicName string, data []byte, waitForPeers bool) (int, error) {
+	// Joining a topic we already joined returns the cached handle
+	topic, err := b.psm.JoinTopic(topicName)
+	if err != nil {
+		return 0, fmt.Errorf("could not join topic '%s': %w", topicName, err)
+	}
+	peers := len(b.psm.ListPeers(topicName))
+	if peers == 0 && waitForPeers {
+		return 0, nil
+	}
+	if err := b.psm.PublishMessage(ctx, topic, data); err != nil {
+		return peers, fmt.Errorf("could not publish to topic '%s': %w", topicName, err)
+	}
+	return peers, nil
+}
+
+// Broadcast sends a message to all relevant topics. A topic that fails
+// doesn't stop the others; an error is only returned if the message
+// couldn't be published on any of them.
+func (b *Broadcaster) Broadcast(ctx context.Context, msg *Message) error {
+	envelopes, err := b.envelopes(msg)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, env := range envelopes {
+		if _, err := b.publish(ctx, env.topic, env.data, false); err != nil {
+			log.Printf("Error broadcasting message ID %s: %v", msg.ID, err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 && len(errs) == len(envelopes) {
+		return fmt.Errorf("message %s was not published on any topic: %w", msg.ID, errors.Join(errs...))
+	}
 	return nil
-}
\ No newline at end of file
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"reflect"
 	"socli/config"
 	"socli/crypto"
 	"testing"
 	"time"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // mockPubSubManager is a mock implementation of p2p.PubSubManagerInterface for testing.
@@ -17,6 +19,7 @@ type mockPubSubManager struct {
 	joinTopicFunc      func(topicName string) (*pubsub.Topic, error)
 	publishMessageFunc func(ctx context.Context, topic *pubsub.Topic, data []byte) error
 	subscribeToTopicFunc func(topic *pubsub.Topic) (*pubsub.Subscription, error)
+	listPeersFunc      func(topicName string) []peer.ID
 }
 
 // JoinTopic mocks the JoinTopic method.
@@ -46,6 +49,15 @@ func (m *mockPubSubManager) SubscribeToTopic(topic *pubsub.Topic) (*pubsub.Subsc
 	return nil, nil
 }
 
+// ListPeers mocks the ListPeers method.
+func (m *mockPubSubManager) ListPeers(topicName string) []peer.ID {
+	if m.listPeersFunc != nil {
+		return m.listPeersFunc(topicName)
+	}
+	// Default mock behavior: no peers on any topic
+	return nil
+}
+
 // TestBroadcasterBroadcast tests the Broadcast method of Broadcaster.
 func TestBroadcasterBroadcast(t *testing.T) {
 	// Create a test key pair
@@ -220,6 +232,38 @@ func TestBroadcasterBroadcast(t *testing.T) {
 		}
 	})
 
+	// Test that mentioned peers get the message in their inbox
+	t.Run("BroadcastWithMentions", func(t *testing.T) {
+		mentionMsg := &Message{
+			ID:        "mention-id",
+			Author:    "test-author",
+			Content:   "@alice @test-author have a look",
+			Hashtags:  []string{"test"},
+			Timestamp: time.Now(),
+			Type:      PostMsg,
+			Mentions:  []string{"alice", "test-author"},
+		}
+
+		var joined []string
+		mockPSM := &mockPubSubManager{
+			joinTopicFunc: func(topicName string) (*pubsub.Topic, error) {
+				joined = append(joined, topicName)
+				return nil, nil
+			},
+		}
+
+		broadcaster := NewBroadcaster(mockPSM, cfg, keyPair)
+		if err := broadcaster.Broadcast(context.Background(), mentionMsg); err != nil {
+			t.Errorf("Broadcast() error = %v, want nil", err)
+		}
+
+		// The author's own mention doesn't go to its inbox
+		want := []string{GetTopicForHashtag("test"), GetInboxTopic("alice")}
+		if !reflect.DeepEqual(joined, want) {
+			t.Errorf("JoinTopic called with %v, want %v", joined, want)
+		}
+	})
+
 	// Test broadcast with join topic error
 	t.Run("BroadcastWithJoinTopicError", func(t *testing.T) {
 		// Create a mock PubSubManager that returns an error on JoinTopic
@@ -239,11 +283,10 @@ func TestBroadcasterBroadcast(t *testing.T) {
 		broadcaster := NewBroadcaster(mockPSM, cfg, keyPair)
 
 		// Perform the broadcast
-		// We expect the broadcast to succeed even if joining a topic fails,
-		// as it should continue with other hashtags.
+		// The message wasn't published anywhere, so the broadcast fails
 		err := broadcaster.Broadcast(context.Background(), msg)
-		if err != nil {
-			t.Errorf("Broadcast() error = %v, want nil", err)
+		if err == nil {
+			t.Error("Broadcast() error = nil, want an error when no topic could be joined")
 		}
 	})
 
@@ -265,10 +308,35 @@ func TestBroadcasterBroadcast(t *testing.T) {
 		broadcaster := NewBroadcaster(mockPSM, cfg, keyPair)
 
 		// Perform the broadcast
-		// We expect the broadcast to succeed even if publishing fails,
-		// as it should continue with other hashtags and log the error.
+		// The message wasn't published anywhere, so the broadcast fails
 		err := broadcaster.Broadcast(context.Background(), msg)
-		if err != nil {
+		if err == nil {
+			t.Error("Broadcast() error = nil, want an error when every publish failed")
+		}
+	})
+
+	// Test broadcast where only some topics fail
+	t.Run("BroadcastWithPartialFailure", func(t *testing.T) {
+		partialMsg := &Message{
+			ID:        "partial-id",
+			Author:    "test-author",
+			Content:   "This is a test message on two hashtags",
+			Hashtags:  []string{"test", "broken"},
+			Timestamp: time.Now(),
+			Type:      PostMsg,
+		}
+		mockPSM := &mockPubSubManager{
+			joinTopicFunc: func(topicName string) (*pubsub.Topic, error) {
+				if topicName == GetTopicForHashtag("broken") {
+					return nil, errors.New("join topic error")
+				}
+				return nil, nil
+			},
+		}
+
+		// The message still went out on #test, so the broadcast succeeds
+		broadcaster := NewBroadcaster(mockPSM, cfg, keyPair)
+		if err := broadcaster.Broadcast(context.Background(), partialMsg); err != nil {
 			t.Errorf("Broadcast() error = %v, want nil", err)
 		}
 	})
@@ -0,0 +1,668 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"socli/config"
+	"socli/crypto"
+	"socli/p2p"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// circleMarker is the leading byte of a payload sealed with a circle's
+	// group key. It is followed by the key's epoch as a big-endian uint32.
+	circleMarker byte = 0x02
+	// circleHeaderLength is the length of the marker and the epoch.
+	circleHeaderLength = 5
+	// maxCircleMembers is the most members a circle may have.
+	maxCircleMembers = 256
+	// InvitePrefix starts every invite token.
+	InvitePrefix = "socli-invite:"
+	// joinRetryInterval is how long to wait for the owner to confirm our join
+	// before announcing it again.
+	joinRetryInterval = 3 * time.Second
+	// joinPollInterval is how often to check for peers on a circle's topic
+	// before announcing our join.
+	joinPollInterval = 100 * time.Millisecond
+)
+
+// errSealed is returned by DecodeMessage for payloads sealed for a circle we
+// aren't a member of.
+var errSealed = errors.New("message is sealed for a circle")
+
+// circleNamePattern matches the hashtags that can name a circle.
+var circleNamePattern = regexp.MustCompile(`^\w{1,64}$`)
+
+// InviteToken lets a peer join a circle. It carries the owner's signed member
+// list, which must include the invitee, and the current group key.
+type InviteToken struct {
+	Circle *Message `json:"circle"`
+	Key    []byte   `json:"key"`
+}
+
+// circle is a circle we are a member of.
+type circle struct {
+	name     string
+	owner    string // Peer ID of the owner, the only one who can change members
+	ownerKey []byte // Ed25519 key the owner signs member lists with
+	revision int
+	epoch    int
+	members  []CircleMember
+	keys     map[int]*[32]byte // Group keys by epoch: the current one and the one before
+	update   *Message          // Latest signed member list, handed out with invites
+	cancel   context.CancelFunc
+}
+
+// member returns the index of a peer in the member list.
+func (c *circle) member(id string) (int, bool) {
+	for i, m := range c.members {
+		if m.PeerID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// Circles manages the invite-only circles we are a member of. A circle is a
+// hashtag whose posts are sealed with a group key only members hold. Its
+// owner signs the member list, and rotates the key when removing a member.
+// Circles are kept in memory only, like posts.
+type Circles struct {
+	psm     p2p.PubSubManagerInterface
+	cfg     *config.Config
+	keyPair *crypto.KeyPair
+	self    peer.ID
+
+	mu      sync.Mutex
+	ctx     context.Context
+	circles map[string]*circle // Keyed by name
+}
+
+// NewCircles creates a circle manager for self, signing with keyPair.
+func NewCircles(psm p2p.PubSubManagerInterface, cfg *config.Config, keyPair *crypto.KeyPair, self peer.ID) *Circles {
+	return &Circles{
+		psm:     psm,
+		cfg:     cfg,
+		keyPair: keyPair,
+		self:    self,
+		ctx:     context.Background(),
+		circles: make(map[string]*circle),
+	}
+}
+
+// Start sets the context that circle subscriptions run in until it is done.
+func (cs *Circles) Start(ctx context.Context) {
+	cs.mu.Lock()
+	cs.ctx = ctx
+	cs.mu.Unlock()
+}
+
+// Create creates a circle owned by us, with us as its only member.
+func (cs *Circles) Create(name string) error {
+	if !circleNamePattern.MatchString(name) || name == "general" {
+		return fmt.Errorf("invalid circle name %q", name)
+	}
+	key, err := crypto.GenerateGroupKey()
+	if err != nil {
+		return err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if _, ok := cs.circles[name]; ok {
+		return fmt.Errorf("circle #%s already exists", name)
+	}
+	c := &circle{
+		name:     name,
+		owner:    cs.self.String(),
+		ownerKey: crypto.SigningPublicKey(cs.keyPair.PrivateKey)[:],
+		revision: 1,
+		epoch:    1,
+		members:  []CircleMember{{PeerID: cs.self.String(), BoxKey: cs.keyPair.PublicKey[:], SigningKey: crypto.SigningPublicKey(cs.keyPair.PrivateKey)[:]}},
+		keys:     map[int]*[32]byte{1: key},
+	}
+	if c.update, err = cs.signUpdate(c, nil); err != nil {
+		return err
+	}
+	if err := cs.subscribe(c); err != nil {
+		return err
+	}
+	cs.circles[name] = c
+	return nil
+}
+
+// Invite adds a peer to a circle we own, announces the new member list and
+// returns an invite token for the peer.
+func (cs *Circles) Invite(ctx context.Context, name string, invitee peer.ID) (string, error) {
+	cs.mu.Lock()
+	c, err := cs.owned(name)
+	if err != nil {
+		cs.mu.Unlock()
+		return "", err
+	}
+	if _, ok := c.member(invitee.String()); !ok {
+		if len(c.members) >= maxCircleMembers {
+			cs.mu.Unlock()
+			return "", fmt.Errorf("circle #%s is full", name)
+		}
+		c.members = append(c.members, CircleMember{PeerID: invitee.String()})
+		c.revision++
+		if c.update, err = cs.signUpdate(c, nil); err != nil {
+			cs.mu.Unlock()
+			return "", err
+		}
+	}
+	update, epoch, key := c.update, c.epoch, c.keys[c.epoch]
+	cs.mu.Unlock()
+
+	if err := cs.publish(ctx, name, epoch, update); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(InviteToken{Circle: update, Key: key[:]})
+	if err != nil {
+		return "", err
+	}
+	return InvitePrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Join joins the circle an invite token is for, and sends our encryption key
+// to its owner in the background so we receive rotated group keys. It returns
+// the circle's name.
+func (cs *Circles) Join(token string) (string, error) {
+	update, key, err := parseInvite(token)
+	if err != nil {
+		return "", err
+	}
+	name := update.Circle.Name
+
+	c := &circle{
+		name:     name,
+		owner:    update.Author,
+		ownerKey: update.PublicKey,
+		revision: update.Circle.Revision,
+		epoch:    update.Circle.Epoch,
+		members:  update.Circle.Members,
+		keys:     map[int]*[32]byte{update.Circle.Epoch: key},
+		update:   update,
+	}
+	if _, ok := c.member(cs.self.String()); !ok {
+		return "", errors.New("the invite is for another peer")
+	}
+
+	cs.mu.Lock()
+	if existing, ok := cs.circles[name]; ok {
+		if existing.owner != c.owner || existing.revision >= c.revision {
+			cs.mu.Unlock()
+			return "", fmt.Errorf("already a member of #%s", name)
+		}
+		existing.cancel()
+	}
+	if err := cs.subscribe(c); err != nil {
+		cs.mu.Unlock()
+		return "", err
+	}
+	cs.circles[name] = c
+	cs.mu.Unlock()
+
+	join := &Message{
+		ID:        uuid.New().String(),
+		Author:    cs.self.String(),
+		Timestamp: time.Now(),
+		Type:      CircleMsg,
+		Circle:    &CircleUpdate{Name: name, BoxKey: cs.keyPair.PublicKey[:]},
+	}
+	if err := join.Sign(cs.keyPair); err != nil {
+		return "", err
+	}
+	go cs.announceJoin(name, join)
+	return name, nil
+}
+
+// announceJoin publishes our join once the circle's topic has peers, and
+// again every joinRetryInterval until the owner's member list has our key.
+func (cs *Circles) announceJoin(name string, join *Message) {
+	cs.mu.Lock()
+	ctx := cs.ctx
+	cs.mu.Unlock()
+	topic, err := cs.psm.JoinTopic(GetTopicForHashtag(name))
+	if err != nil {
+		log.Printf("Circles: Failed to join #%s: %v\n", name, err)
+		return
+	}
+
+	for {
+		cs.mu.Lock()
+		c, ok := cs.circles[name]
+		joined := false
+		epoch := 0
+		if ok {
+			i, _ := c.member(cs.self.String())
+			joined = i >= 0 && bytes.Equal(c.members[i].BoxKey, join.Circle.BoxKey)
+			epoch = c.epoch
+		}
+		cs.mu.Unlock()
+		if !ok || joined {
+			return
+		}
+
+		wait := joinPollInterval
+		if len(topic.ListPeers()) > 0 {
+			if err := cs.publish(ctx, name, epoch, join); err != nil {
+				log.Printf("Circles: Failed to announce joining #%s: %v\n", name, err)
+			}
+			wait = joinRetryInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Remove removes a member from a circle we own. The group key is rotated, and
+// the new key is sealed for every remaining member that has joined, so the
+// removed member can't read anything posted from now on.
+func (cs *Circles) Remove(ctx context.Context, name string, member peer.ID) error {
+	if member == cs.self {
+		return errors.New("the owner can't be removed")
+	}
+	newKey, err := crypto.GenerateGroupKey()
+	if err != nil {
+		return err
+	}
+
+	cs.mu.Lock()
+	c, err := cs.owned(name)
+	if err != nil {
+		cs.mu.Unlock()
+		return err
+	}
+	i, ok := c.member(member.String())
+	if !ok {
+		cs.mu.Unlock()
+		return fmt.Errorf("%s is not a member of #%s", member, name)
+	}
+	c.members = append(c.members[:i:i], c.members[i+1:]...)
+
+	sealed := make(map[string][]byte, len(c.members))
+	for _, m := range c.members {
+		if len(m.BoxKey) != 32 {
+			// Members that haven't joined yet need a new invite
+			continue
+		}
+		var boxKey [32]byte
+		copy(boxKey[:], m.BoxKey)
+		if sealed[m.PeerID], err = crypto.Encrypt(newKey[:], &boxKey, cs.keyPair.PrivateKey); err != nil {
+			cs.mu.Unlock()
+			return err
+		}
+	}
+	// The update is sealed with the old key, which every remaining member has
+	oldEpoch := c.epoch
+	c.revision++
+	c.epoch++
+	if c.update, err = cs.signUpdate(c, sealed); err != nil {
+		cs.mu.Unlock()
+		return err
+	}
+	c.keys = map[int]*[32]byte{oldEpoch: c.keys[oldEpoch], c.epoch: newKey}
+	update := c.update
+	cs.mu.Unlock()
+
+	return cs.publish(ctx, name, oldEpoch, update)
+}
+
+// IsCircle reports whether a hashtag is a circle we are a member of.
+func (cs *Circles) IsCircle(hashtag string) bool {
+	if cs == nil {
+		return false
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	_, ok := cs.circles[hashtag]
+	return ok
+}
+
+// Members returns a circle's members, the owner first. Members that haven't
+// joined yet have no BoxKey.
+func (cs *Circles) Members(name string) []CircleMember {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	c, ok := cs.circles[name]
+	if !ok {
+		return nil
+	}
+	members := make([]CircleMember, len(c.members))
+	copy(members, c.members)
+	return members
+}
+
+// owned returns a circle we own. cs.mu must be held.
+func (cs *Circles) owned(name string) (*circle, error) {
+	c, ok := cs.circles[name]
+	if !ok {
+		return nil, fmt.Errorf("not a member of #%s", name)
+	}
+	if c.owner != cs.self.String() {
+		return nil, fmt.Errorf("only the owner of #%s can change its members", name)
+	}
+	return c, nil
+}
+
+// signUpdate signs the current member list of a circle we own, with the
+// rotated group key sealed for each member if keys is set. cs.mu must be held.
+func (cs *Circles) signUpdate(c *circle, keys map[string][]byte) (*Message, error) {
+	members := make([]CircleMember, len(c.members))
+	copy(members, c.members)
+	msg := &Message{
+		ID:        uuid.New().String(),
+		Author:    cs.self.String(),
+		Timestamp: time.Now(),
+		Type:      CircleMsg,
+		Circle:    &CircleUpdate{Name: c.name, Revision: c.revision, Epoch: c.epoch, Members: members, Keys: keys},
+	}
+	if err := msg.Sign(cs.keyPair); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// subscribe joins a circle's topic and applies the circle messages on it.
+// cs.mu must be held.
+func (cs *Circles) subscribe(c *circle) error {
+	topic, err := cs.psm.JoinTopic(GetTopicForHashtag(c.name))
+	if err != nil {
+		return err
+	}
+	sub, err := cs.psm.SubscribeToTopic(topic)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(cs.ctx)
+	c.cancel = func() {
+		cancel()
+		sub.Cancel()
+	}
+	go func() {
+		err := ReadSubscription(ctx, sub, cs.self, func(msg *Message, _ peer.ID) {
+			if msg.Type == CircleMsg {
+				cs.apply(c.name, msg)
+			}
+		})
+		log.Printf("Circles: Stopped reading #%s: %v\n", c.name, err)
+	}()
+	return nil
+}
+
+// apply handles a circle message that passed the validator: a new member list
+// from the owner, or a member's join.
+func (cs *Circles) apply(name string, msg *Message) {
+	cs.mu.Lock()
+	c, ok := cs.circles[name]
+	if !ok {
+		cs.mu.Unlock()
+		return
+	}
+
+	// A member joined; the owner records its keys and announces the list.
+	// The join is signed with the key the member signs its posts with.
+	if msg.Circle.Members == nil {
+		var update *Message
+		if i, ok := c.member(msg.Author); ok && c.owner == cs.self.String() && (!bytes.Equal(c.members[i].BoxKey, msg.Circle.BoxKey) || !bytes.Equal(c.members[i].SigningKey, msg.PublicKey)) {
+			c.members[i].BoxKey = msg.Circle.BoxKey
+			c.members[i].SigningKey = msg.PublicKey
+			c.revision++
+			var err error
+			if c.update, err = cs.signUpdate(c, nil); err != nil {
+				log.Printf("Circles: Failed to sign #%s member list: %v\n", name, err)
+			}
+			update = c.update
+		}
+		epoch, ctx := c.epoch, cs.ctx
+		cs.mu.Unlock()
+		if update != nil {
+			log.Printf("Circles: %s joined #%s\n", msg.Author, name)
+			if err := cs.publish(ctx, name, epoch, update); err != nil {
+				log.Printf("Circles: Failed to announce #%s member list: %v\n", name, err)
+			}
+		}
+		return
+	}
+
+	defer cs.mu.Unlock()
+	if msg.Circle.Revision <= c.revision {
+		return
+	}
+	c.revision = msg.Circle.Revision
+	c.members = msg.Circle.Members
+	c.update = msg
+	if _, ok := c.member(cs.self.String()); !ok {
+		log.Printf("Circles: Removed from #%s\n", name)
+		c.cancel()
+		delete(cs.circles, name)
+		return
+	}
+	if msg.Circle.Epoch > c.epoch {
+		sealed := msg.Circle.Keys[cs.self.String()]
+		owner, ok := c.member(c.owner)
+		if !ok || len(sealed) < 24 || len(c.members[owner].BoxKey) != 32 {
+			log.Printf("Circles: Key for #%s was rotated without a copy for us; ask for a new invite\n", name)
+			return
+		}
+		var ownerBox [32]byte
+		copy(ownerBox[:], c.members[owner].BoxKey)
+		data, ok := crypto.Decrypt(sealed, &ownerBox, cs.keyPair.PrivateKey)
+		if !ok || len(data) != 32 {
+			log.Printf("Circles: Failed to open the rotated key for #%s\n", name)
+			return
+		}
+		var key [32]byte
+		copy(key[:], data)
+		c.keys = map[int]*[32]byte{c.epoch: c.keys[c.epoch], msg.Circle.Epoch: &key}
+		c.epoch = msg.Circle.Epoch
+	}
+}
+
+// publish seals a message with the group key of the given epoch and
+// publishes it on the circle's topic.
+func (cs *Circles) publish(ctx context.Context, name string, epoch int, msg *Message) error {
+	data, err := EncodeMessage(msg, cs.cfg)
+	if err != nil {
+		return err
+	}
+	sealed, err := cs.sealEpoch(name, epoch, data)
+	if err != nil {
+		return err
+	}
+	topic, err := cs.psm.JoinTopic(GetTopicForHashtag(name))
+	if err != nil {
+		return err
+	}
+	return cs.psm.PublishMessage(ctx, topic, sealed)
+}
+
+// Seal seals an encoded message with the current group key of a circle.
+func (cs *Circles) Seal(name string, data []byte) ([]byte, error) {
+	cs.mu.Lock()
+	c, ok := cs.circles[name]
+	epoch := 0
+	if ok {
+		epoch = c.epoch
+	}
+	cs.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("not a member of #%s", name)
+	}
+	return cs.sealEpoch(name, epoch, data)
+}
+
+// sealEpoch seals data with the group key of the given epoch.
+func (cs *Circles) sealEpoch(name string, epoch int, data []byte) ([]byte, error) {
+	cs.mu.Lock()
+	var key *[32]byte
+	if c, ok := cs.circles[name]; ok {
+		key = c.keys[epoch]
+	}
+	cs.mu.Unlock()
+	if key == nil {
+		return nil, fmt.Errorf("no key for epoch %d of #%s", epoch, name)
+	}
+
+	sealed, err := crypto.SealGroup(data, key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, circleHeaderLength, circleHeaderLength+len(sealed))
+	header[0] = circleMarker
+	binary.BigEndian.PutUint32(header[1:], uint32(epoch))
+	return append(header, sealed...), nil
+}
+
+// open opens a payload on a circle's topic. isCircle is false for topics that
+// aren't circles we are a member of; ok is false if the payload isn't sealed
+// with one of the circle's keys.
+func (cs *Circles) open(topic string, data []byte) (opened []byte, isCircle, ok bool) {
+	if cs == nil || !strings.HasPrefix(topic, HashtagTopicPrefix) {
+		return nil, false, false
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	c, isCircle := cs.circles[strings.TrimPrefix(topic, HashtagTopicPrefix)]
+	if !isCircle {
+		return nil, false, false
+	}
+	if len(data) < circleHeaderLength || data[0] != circleMarker {
+		return nil, true, false
+	}
+	key := c.keys[int(binary.BigEndian.Uint32(data[1:circleHeaderLength]))]
+	if key == nil {
+		return nil, true, false
+	}
+	opened, ok = crypto.OpenGroup(data[circleHeaderLength:], key)
+	return opened, true, ok
+}
+
+// check applies the membership rules to a message on topic published by
+// origin: only members post in a circle, only the owner changes its members,
+// and circle messages stay on their circle's topic.
+func (cs *Circles) check(topic string, msg *Message, origin peer.ID) error {
+	var c *circle
+	if cs != nil && strings.HasPrefix(topic, HashtagTopicPrefix) {
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+		c = cs.circles[strings.TrimPrefix(topic, HashtagTopicPrefix)]
+	}
+	if c == nil {
+		if msg.Type == CircleMsg {
+			return fmt.Errorf("circle message on %s", topic)
+		}
+		return nil
+	}
+
+	i, ok := c.member(origin.String())
+	if !ok || msg.Author != origin.String() {
+		return fmt.Errorf("%s is not a member of #%s", origin, c.name)
+	}
+	if key := c.members[i].SigningKey; key != nil && msg.Type != CircleMsg && !bytes.Equal(msg.PublicKey, key) {
+		return fmt.Errorf("post in #%s not signed with %s's key", c.name, origin)
+	}
+	if msg.Type != CircleMsg {
+		return nil
+	}
+	if msg.Circle == nil || msg.Circle.Name != c.name {
+		return errors.New("circle message for another circle")
+	}
+	if msg.Circle.Members != nil {
+		if msg.Author != c.owner || !bytes.Equal(msg.PublicKey, c.ownerKey) {
+			return fmt.Errorf("member list of #%s not signed by its owner", c.name)
+		}
+		if len(msg.Circle.Members) > maxCircleMembers {
+			return fmt.Errorf("member list of #%s is too long", c.name)
+		}
+	} else if len(msg.Circle.BoxKey) != 32 {
+		return errors.New("join without an encryption key")
+	}
+	return nil
+}
+
+// hidden reports whether a post belongs to a circle that peer p isn't a
+// member of, so it must not be handed to p over history sync.
+func (cs *Circles) hidden(post *Message, p peer.ID) bool {
+	if cs == nil {
+		return false
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for _, tag := range post.Hashtags {
+		if c, ok := cs.circles[tag]; ok {
+			if _, member := c.member(p.String()); !member {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkAuthor makes sure a post on any of our circles' hashtags was written
+// by a member, signed with the key the member joined with. It is used for
+// posts received over history sync, which don't pass through the topic
+// validator, so the author's name alone proves nothing.
+func (cs *Circles) checkAuthor(post *Message) error {
+	if cs == nil {
+		return nil
+	}
+	if post.Type == CircleMsg {
+		return errors.New("circle messages aren't synced")
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for _, tag := range post.Hashtags {
+		if c, ok := cs.circles[tag]; ok {
+			i, member := c.member(post.Author)
+			if !member {
+				return fmt.Errorf("%s is not a member of #%s", post.Author, tag)
+			}
+			if !bytes.Equal(post.PublicKey, c.members[i].SigningKey) {
+				return fmt.Errorf("post in #%s not signed with %s's key", tag, post.Author)
+			}
+		}
+	}
+	return nil
+}
+
+// parseInvite decodes an invite token and checks the member list it carries.
+func parseInvite(token string) (*Message, *[32]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(token), InvitePrefix))
+	if err != nil || !strings.HasPrefix(strings.TrimSpace(token), InvitePrefix) {
+		return nil, nil, errors.New("not an invite token")
+	}
+	var invite InviteToken
+	if err := json.Unmarshal(data, &invite); err != nil {
+		return nil, nil, fmt.Errorf("invalid invite token: %w", err)
+	}
+	update := invite.Circle
+	if update == nil || update.Type != CircleMsg || update.Circle == nil || update.Circle.Members == nil ||
+		!circleNamePattern.MatchString(update.Circle.Name) || len(invite.Key) != 32 {
+		return nil, nil, errors.New("incomplete invite token")
+	}
+	if !update.VerifySignature() {
+		return nil, nil, errors.New("invite token has an invalid signature")
+	}
+	var key [32]byte
+	copy(key[:], invite.Key)
+	return update, &key, nil
+}
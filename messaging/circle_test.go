@@ -0,0 +1,257 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"socli/config"
+	"socli/crypto"
+	"socli/p2p"
+	"strings"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// circlePeer is a peer with its own circle manager on a mocknet.
+type circlePeer struct {
+	id      peer.ID
+	keyPair *crypto.KeyPair
+	circles *Circles
+}
+
+// newCirclePeers creates n unconnected peers with circle managers.
+func newCirclePeers(t *testing.T, ctx context.Context, n int) []*circlePeer {
+	t.Helper()
+	mn := mocknet.New()
+	t.Cleanup(func() { mn.Close() })
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+
+	peers := make([]*circlePeer, n)
+	for i := range peers {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatalf("Failed to create mock host: %v", err)
+		}
+		psm, err := p2p.NewPubSubManager(ctx, h, cfg)
+		if err != nil {
+			t.Fatalf("NewPubSubManager() error = %v, want nil", err)
+		}
+		keyPair, err := crypto.GenerateKeyPair()
+		if err != nil {
+			t.Fatalf("Failed to generate key pair: %v", err)
+		}
+		circles := NewCircles(psm, cfg, keyPair, h.ID())
+		circles.Start(ctx)
+		peers[i] = &circlePeer{id: h.ID(), keyPair: keyPair, circles: circles}
+	}
+	return peers
+}
+
+// TestCirclesCreate tests which names can be used for a circle.
+func TestCirclesCreate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	owner := newCirclePeers(t, ctx, 1)[0]
+
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"friends", false},
+		{"friends", true}, // Already exists
+		{"general", true},
+		{"", true},
+		{"two words", true},
+		{strings.Repeat("a", 65), true},
+	}
+	for _, tt := range tests {
+		if err := owner.circles.Create(tt.name); (err != nil) != tt.wantErr {
+			t.Errorf("Create(%q) error = %v, wantErr %t", tt.name, err, tt.wantErr)
+		}
+	}
+	if !owner.circles.IsCircle("friends") || owner.circles.IsCircle("general") {
+		t.Error("IsCircle() doesn't match the created circles")
+	}
+	if members := owner.circles.Members("friends"); len(members) != 1 || members[0].PeerID != owner.id.String() {
+		t.Errorf("Members() = %+v, want just the owner", members)
+	}
+}
+
+// TestCirclesInvite tests that an invite token only lets the invitee join,
+// and that only the owner can invite.
+func TestCirclesInvite(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	peers := newCirclePeers(t, ctx, 3)
+	owner, invitee, other := peers[0], peers[1], peers[2]
+
+	if err := owner.circles.Create("friends"); err != nil {
+		t.Fatalf("Create() error = %v, want nil", err)
+	}
+	token, err := owner.circles.Invite(ctx, "friends", invitee.id)
+	if err != nil {
+		t.Fatalf("Invite() error = %v, want nil", err)
+	}
+	if !strings.HasPrefix(token, InvitePrefix) {
+		t.Errorf("Invite() = %q, want a token starting with %q", token, InvitePrefix)
+	}
+
+	// Flipping a character breaks the encoding or the signature
+	tampered := token[:len(token)-8] + strings.Repeat("A", 8)
+	for _, bad := range []string{"", "socli-invite:not base64!", tampered} {
+		if _, err := invitee.circles.Join(bad); err == nil {
+			t.Errorf("Join(%q) error = nil, want an error", bad)
+		}
+	}
+	if _, err := other.circles.Join(token); err == nil {
+		t.Error("Join() by another peer error = nil, want an error")
+	}
+
+	name, err := invitee.circles.Join(token)
+	if err != nil || name != "friends" {
+		t.Fatalf("Join() = %q, %v, want friends, nil", name, err)
+	}
+	if !invitee.circles.IsCircle("friends") {
+		t.Error("IsCircle() = false after joining")
+	}
+	if members := invitee.circles.Members("friends"); len(members) != 2 || members[0].PeerID != owner.id.String() {
+		t.Errorf("Members() = %+v, want the owner and the invitee", members)
+	}
+	if _, err := invitee.circles.Invite(ctx, "friends", other.id); err == nil {
+		t.Error("Invite() by a member error = nil, want an error")
+	}
+	if err := invitee.circles.Remove(ctx, "friends", owner.id); err == nil {
+		t.Error("Remove() by a member error = nil, want an error")
+	}
+}
+
+// TestCirclesCheckAuthor tests that posts synced into a circle must be
+// signed with the key the member joined with, not just carry its name.
+func TestCirclesCheckAuthor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	peers := newCirclePeers(t, ctx, 3)
+	owner, member, outsider := peers[0], peers[1], peers[2]
+
+	if err := owner.circles.Create("friends"); err != nil {
+		t.Fatalf("Create() error = %v, want nil", err)
+	}
+	if _, err := owner.circles.Invite(ctx, "friends", member.id); err != nil {
+		t.Fatalf("Invite() error = %v, want nil", err)
+	}
+	newMessage := func(id string, keyPair *crypto.KeyPair, msgType MsgType, update *CircleUpdate) *Message {
+		msg := &Message{ID: id, Author: member.id.String(), Content: "Hi #friends", Hashtags: []string{"friends"}, Timestamp: time.Now(), Type: msgType, Circle: update}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		return msg
+	}
+	post := newMessage("post", member.keyPair, PostMsg, nil)
+	if err := owner.circles.checkAuthor(post); err == nil {
+		t.Error("checkAuthor() before the member joined = nil, want an error")
+	}
+
+	// The owner records the member's keys from its signed join
+	owner.circles.apply("friends", newMessage("join", member.keyPair, CircleMsg, &CircleUpdate{Name: "friends", BoxKey: member.keyPair.PublicKey[:]}))
+	if err := owner.circles.checkAuthor(post); err != nil {
+		t.Errorf("checkAuthor() of the member's post = %v, want nil", err)
+	}
+	if err := owner.circles.checkAuthor(newMessage("forged", outsider.keyPair, PostMsg, nil)); err == nil {
+		t.Error("checkAuthor() of a post signed by an outsider in the member's name = nil, want an error")
+	}
+}
+
+// TestValidatorCircleRules tests that circle topics only carry posts sealed
+// with the group key by members, and member lists signed by the owner.
+func TestValidatorCircleRules(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	peers := newCirclePeers(t, ctx, 4)
+	owner, member, outsider, impostor := peers[0], peers[1], peers[2], peers[3]
+
+	if err := owner.circles.Create("friends"); err != nil {
+		t.Fatalf("Create() error = %v, want nil", err)
+	}
+	token, err := owner.circles.Invite(ctx, "friends", member.id)
+	if err != nil {
+		t.Fatalf("Invite() error = %v, want nil", err)
+	}
+	if _, err := member.circles.Join(token); err != nil {
+		t.Fatalf("Join() error = %v, want nil", err)
+	}
+	// A circle of the same name with another key
+	if err := impostor.circles.Create("friends"); err != nil {
+		t.Fatalf("Create() error = %v, want nil", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+	newMessage := func(from *circlePeer, msgType MsgType, update *CircleUpdate) []byte {
+		msg := &Message{
+			ID:        "circle-rules-" + from.id.String() + string(msgType),
+			Author:    from.id.String(),
+			Content:   "Hi #friends",
+			Hashtags:  []string{"friends"},
+			Timestamp: time.Now(),
+			Type:      msgType,
+			Circle:    update,
+		}
+		if err := msg.Sign(from.keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		return data
+	}
+	seal := func(by *circlePeer, data []byte) []byte {
+		sealed, err := by.circles.Seal("friends", data)
+		if err != nil {
+			t.Fatalf("Seal() error = %v, want nil", err)
+		}
+		return sealed
+	}
+	post := newMessage(member, PostMsg, nil)
+	forgedList := &CircleUpdate{Name: "friends", Revision: 99, Epoch: 1, Members: []CircleMember{{PeerID: member.id.String()}}}
+
+	circleTopic, general := GetTopicForHashtag("friends"), GetTopicForHashtag("general")
+	tests := []struct {
+		name  string
+		from  *circlePeer
+		topic string
+		data  []byte
+		want  pubsub.ValidationResult
+	}{
+		{"SealedPost", member, circleTopic, seal(owner, post), pubsub.ValidationAccept},
+		{"UnsealedPost", member, circleTopic, post, pubsub.ValidationReject},
+		{"OtherCircleKey", member, circleTopic, seal(impostor, post), pubsub.ValidationIgnore},
+		{"PostFromOutsider", outsider, circleTopic, seal(owner, newMessage(outsider, PostMsg, nil)), pubsub.ValidationReject},
+		{"MemberListFromMember", member, circleTopic, seal(owner, newMessage(member, CircleMsg, forgedList)), pubsub.ValidationReject},
+		{"JoinWithoutKey", member, circleTopic, seal(owner, newMessage(member, CircleMsg, &CircleUpdate{Name: "friends"})), pubsub.ValidationReject},
+		{"CircleMessageOnPublicTopic", member, general, newMessage(member, CircleMsg, forgedList), pubsub.ValidationReject},
+	}
+
+	validator := NewValidator(cfg, owner.keyPair)
+	validator.SetCircles(owner.circles)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			psMsg := &pubsub.Message{Message: &pb.Message{Data: tt.data, Topic: &tt.topic, From: []byte(tt.from.id)}}
+			if got := validator.Validate(context.Background(), tt.from.id, psMsg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Peers outside the circle ignore its sealed posts instead of penalizing them
+	outsiderValidator := NewValidator(cfg, outsider.keyPair)
+	psMsg := &pubsub.Message{Message: &pb.Message{Data: seal(owner, post), Topic: &circleTopic, From: []byte(member.id)}}
+	if got := outsiderValidator.Validate(context.Background(), member.id, psMsg); got != pubsub.ValidationIgnore {
+		t.Errorf("Validate() by an outsider = %v, want ValidationIgnore", got)
+	}
+}
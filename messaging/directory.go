@@ -0,0 +1,194 @@
+package messaging
+
+import (
+	"context"
+	"log"
+	"socli/config"
+	"socli/crypto"
+	"socli/p2p"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// MinAnnounceInterval is the shortest time between two directory announcements
+// from one peer. Validators drop announcements that come in much faster.
+const MinAnnounceInterval = 10 * time.Second
+
+// announcementTTL is how many announce intervals an announcement stays in the
+// directory without being refreshed.
+const announcementTTL = 3
+
+// DirectoryEntry summarizes what the network announced about one hashtag.
+type DirectoryEntry struct {
+	Hashtag string
+	Peers   int // Peers that announced being subscribed
+	Posts   int // Highest recent post count reported by any of them
+}
+
+// Directory collects the hashtag announcements peers publish on the directory
+// topic, and publishes our own. Announcements are signed, plaintext and sent
+// at most once per MinAnnounceInterval.
+type Directory struct {
+	psm     p2p.PubSubManagerInterface
+	cfg     *config.Config
+	keyPair *crypto.KeyPair
+	self    peer.ID
+	topic   *pubsub.Topic
+
+	mu            sync.Mutex
+	announcements map[string]*Message // Latest announcement per signing key
+	received      map[string]time.Time
+	lastAnnounce  time.Time
+}
+
+// NewDirectory creates a directory that announces as self, signing with keyPair.
+func NewDirectory(psm p2p.PubSubManagerInterface, cfg *config.Config, keyPair *crypto.KeyPair, self peer.ID) *Directory {
+	return &Directory{
+		psm:           psm,
+		cfg:           cfg,
+		keyPair:       keyPair,
+		self:          self,
+		announcements: make(map[string]*Message),
+		received:      make(map[string]time.Time),
+	}
+}
+
+// Start joins the directory topic and collects announcements until ctx is done.
+func (d *Directory) Start(ctx context.Context) error {
+	topic, err := d.psm.JoinTopic(DirectoryTopic)
+	if err != nil {
+		return err
+	}
+	sub, err := d.psm.SubscribeToTopic(topic)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.topic = topic
+	d.mu.Unlock()
+
+	go func() {
+		err := ReadSubscription(ctx, sub, d.self, func(msg *Message, _ peer.ID) {
+			d.record(msg, time.Now())
+		})
+		log.Printf("Directory: Stopped reading announcements: %v\n", err)
+	}()
+	return nil
+}
+
+// Interval returns how often announcements should be sent, as configured but
+// never faster than MinAnnounceInterval.
+func (d *Directory) Interval() time.Duration {
+	if d.cfg.Directory.AnnounceInterval < MinAnnounceInterval {
+		return MinAnnounceInterval
+	}
+	return d.cfg.Directory.AnnounceInterval
+}
+
+// Announce publishes our subscribed hashtags and their activity. It does nothing
+// if announcements are disabled, and skips announcements that would come sooner
+// than MinAnnounceInterval after the previous one.
+func (d *Directory) Announce(ctx context.Context, topics []TopicActivity) error {
+	if !d.cfg.Directory.EnableAnnouncements {
+		return nil
+	}
+
+	d.mu.Lock()
+	topic := d.topic
+	now := time.Now()
+	if topic == nil || now.Sub(d.lastAnnounce) < MinAnnounceInterval {
+		d.mu.Unlock()
+		return nil
+	}
+	d.lastAnnounce = now
+	d.mu.Unlock()
+
+	if len(topics) > maxAnnouncedTopics {
+		topics = topics[:maxAnnouncedTopics]
+	}
+	msg := &Message{
+		ID:        uuid.New().String(),
+		Author:    d.self.String(),
+		Timestamp: now,
+		Type:      AnnounceMsg,
+		Topics:    topics,
+	}
+	if err := msg.Sign(d.keyPair); err != nil {
+		return err
+	}
+	// Our own announcement counts too, but pubsub won't deliver it back to us
+	d.record(msg, now)
+
+	// Announcements are not encrypted, since every peer has to read them
+	data, err := EncodeMessage(msg, d.cfg)
+	if err != nil {
+		return err
+	}
+	return d.psm.PublishMessage(ctx, topic, data)
+}
+
+// record stores an announcement, replacing older ones from the same author.
+func (d *Directory) record(msg *Message, now time.Time) {
+	if msg.Type != AnnounceMsg {
+		return
+	}
+	key := string(msg.PublicKey)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if previous, ok := d.announcements[key]; ok && previous.Timestamp.After(msg.Timestamp) {
+		return
+	}
+	d.announcements[key] = msg
+	d.received[key] = now
+}
+
+// Entries returns the hashtags announced recently, most popular first.
+// Announcements that weren't refreshed for a few intervals are dropped.
+func (d *Directory) Entries() []DirectoryEntry {
+	expiry := time.Now().Add(-announcementTTL * d.Interval())
+
+	d.mu.Lock()
+	byHashtag := make(map[string]*DirectoryEntry)
+	for key, msg := range d.announcements {
+		if d.received[key].Before(expiry) {
+			delete(d.announcements, key)
+			delete(d.received, key)
+			continue
+		}
+		for _, activity := range msg.Topics {
+			entry, ok := byHashtag[activity.Hashtag]
+			if !ok {
+				entry = &DirectoryEntry{Hashtag: activity.Hashtag}
+				byHashtag[activity.Hashtag] = entry
+			}
+			entry.Peers++
+			// Peers mostly see the same posts, so counts are not added up
+			if activity.Posts > entry.Posts {
+				entry.Posts = activity.Posts
+			}
+		}
+	}
+	d.mu.Unlock()
+
+	entries := make([]DirectoryEntry, 0, len(byHashtag))
+	for _, entry := range byHashtag {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Peers != entries[j].Peers {
+			return entries[i].Peers > entries[j].Peers
+		}
+		if entries[i].Posts != entries[j].Posts {
+			return entries[i].Posts > entries[j].Posts
+		}
+		return entries[i].Hashtag < entries[j].Hashtag
+	})
+	return entries
+}
@@ -0,0 +1,105 @@
+package messaging
+
+import (
+	"context"
+	"socli/config"
+	"socli/crypto"
+	"socli/p2p"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// TestDirectoryAnnounce tests that announcements reach other peers and are rate limited.
+func TestDirectoryAnnounce(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cfg := config.DefaultConfig()
+
+	mn := mocknet.New()
+	defer mn.Close()
+	hosts := make([]host.Host, 2)
+	directories := make([]*Directory, 2)
+	for i := range directories {
+		keyPair, err := crypto.GenerateKeyPair()
+		if err != nil {
+			t.Fatalf("Failed to generate key pair: %v", err)
+		}
+		if hosts[i], err = mn.GenPeer(); err != nil {
+			t.Fatalf("Failed to create mock host: %v", err)
+		}
+		psm, err := p2p.NewPubSubManager(ctx, hosts[i], cfg)
+		if err != nil {
+			t.Fatalf("NewPubSubManager() error = %v, want nil", err)
+		}
+		psm.SetMessageValidator(NewValidator(cfg, keyPair).Validate)
+		directories[i] = NewDirectory(psm, cfg, keyPair, hosts[i].ID())
+		if err := directories[i].Start(ctx); err != nil {
+			t.Fatalf("Start() error = %v, want nil", err)
+		}
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		t.Fatalf("Failed to connect peers: %v", err)
+	}
+
+	// Wait until the peers see each other on the directory topic
+	for _, d := range directories {
+		for len(d.topic.ListPeers()) == 0 {
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for directory peers")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	// Messages published right after peers meet can be lost while the
+	// gossip streams are still opening, so give them a moment
+	time.Sleep(500 * time.Millisecond)
+
+	if err := directories[1].Announce(ctx, []TopicActivity{{Hashtag: "general", Posts: 4}}); err != nil {
+		t.Fatalf("Announce() error = %v, want nil", err)
+	}
+	if err := directories[0].Announce(ctx, []TopicActivity{{Hashtag: "general", Posts: 7}, {Hashtag: "go", Posts: 1}}); err != nil {
+		t.Fatalf("Announce() error = %v, want nil", err)
+	}
+	// Announcing again right away is skipped instead of being sent
+	if err := directories[0].Announce(ctx, []TopicActivity{{Hashtag: "spam"}}); err != nil {
+		t.Fatalf("Announce() error = %v, want nil", err)
+	}
+
+	want := []DirectoryEntry{{Hashtag: "general", Peers: 2, Posts: 7}, {Hashtag: "go", Peers: 1, Posts: 1}}
+	for {
+		entries := directories[1].Entries()
+		if len(entries) == len(want) && entries[0] == want[0] && entries[1] == want[1] {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Entries() = %+v, want %+v", entries, want)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// TestDirectoryEntriesExpire tests that announcements that aren't refreshed are dropped.
+func TestDirectoryEntriesExpire(t *testing.T) {
+	cfg := config.DefaultConfig()
+	d := NewDirectory(nil, cfg, nil, "")
+
+	now := time.Now()
+	d.record(&Message{Type: AnnounceMsg, PublicKey: []byte("fresh"), Timestamp: now, Topics: []TopicActivity{{Hashtag: "go"}}}, now)
+	stale := now.Add(-(announcementTTL + 1) * d.Interval())
+	d.record(&Message{Type: AnnounceMsg, PublicKey: []byte("stale"), Timestamp: stale, Topics: []TopicActivity{{Hashtag: "rust"}}}, stale)
+	// An older announcement doesn't replace a newer one from the same author
+	d.record(&Message{Type: AnnounceMsg, PublicKey: []byte("fresh"), Timestamp: now.Add(-time.Minute), Topics: []TopicActivity{{Hashtag: "old"}}}, now)
+
+	entries := d.Entries()
+	if len(entries) != 1 || entries[0].Hashtag != "go" || entries[0].Peers != 1 {
+		t.Errorf("Entries() = %+v, want only #go with one peer", entries)
+	}
+}
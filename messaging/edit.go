@@ -0,0 +1,38 @@
+package messaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// PostLookup finds stored posts by ID, e.g. the post an edit revises.
+type PostLookup interface {
+	GetPost(id string) (*Message, bool)
+}
+
+// CheckEdit reports whether an edit may revise a post: only posts, replies
+// and shares can be edited, only with the key and author that signed them,
+// and only after they were written. The edit of an expiring post must expire
+// with it, so the new text doesn't outlive the post.
+func CheckEdit(original, edit *Message) error {
+	if original.Type != PostMsg && original.Type != ReplyMsg && original.Type != ShareMsg {
+		return fmt.Errorf("can't edit a message of type %q", original.Type)
+	}
+	if !sameSigner(original, edit) {
+		return errors.New("edit not signed by the post's author")
+	}
+	if edit.Timestamp.Before(original.Timestamp) {
+		return errors.New("edit is older than the post")
+	}
+	if original.ExpiresAt != nil && (edit.ExpiresAt == nil || edit.ExpiresAt.After(*original.ExpiresAt)) {
+		return errors.New("edit outlives the expiring post")
+	}
+	return nil
+}
+
+// sameSigner reports whether two messages claim the same author and are
+// signed with the same key.
+func sameSigner(a, b *Message) bool {
+	return bytes.Equal(a.PublicKey, b.PublicKey) && a.Author == b.Author
+}
@@ -0,0 +1,67 @@
+package messaging
+
+import (
+	"socli/crypto"
+	"testing"
+	"time"
+)
+
+// postMap is a PostLookup backed by a map.
+type postMap map[string]*Message
+
+func (p postMap) GetPost(id string) (*Message, bool) {
+	post, ok := p[id]
+	return post, ok
+}
+
+// TestCheckEdit tests that only the author of a post can edit it.
+func TestCheckEdit(t *testing.T) {
+	author, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	other, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	now := time.Now()
+	sign := func(msg *Message, keyPair *crypto.KeyPair) *Message {
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		return msg
+	}
+	original := sign(&Message{ID: "post", Author: "alice", Content: "helo", Hashtags: []string{"general"}, Timestamp: now, Type: PostMsg}, author)
+	reaction := sign(&Message{ID: "reaction", Author: "alice", Content: "🎉", Hashtags: []string{"general"}, Timestamp: now, Type: ReactionMsg, ReplyTo: "post"}, author)
+	newEdit := func(author string, keyPair *crypto.KeyPair, timestamp time.Time) *Message {
+		return sign(&Message{ID: "edit", Author: author, Content: "hello", Hashtags: []string{"general"}, Timestamp: timestamp, Type: EditMsg, ReplyTo: "post"}, keyPair)
+	}
+	expiresAt, later := now.Add(time.Hour), now.Add(2*time.Hour)
+	expiring := sign(&Message{ID: "post", Author: "alice", Content: "helo", Hashtags: []string{"general"}, Timestamp: now, Type: PostMsg, ExpiresAt: &expiresAt}, author)
+	newExpiringEdit := func(expiresAt *time.Time) *Message {
+		return sign(&Message{ID: "edit", Author: "alice", Content: "hello", Hashtags: []string{"general"}, Timestamp: now.Add(time.Minute), Type: EditMsg, ReplyTo: "post", ExpiresAt: expiresAt}, author)
+	}
+
+	tests := []struct {
+		name     string
+		original *Message
+		edit     *Message
+		wantErr  bool
+	}{
+		{"Author", original, newEdit("alice", author, now.Add(time.Minute)), false},
+		{"OtherKey", original, newEdit("alice", other, now.Add(time.Minute)), true},
+		{"OtherAuthor", original, newEdit("mallory", author, now.Add(time.Minute)), true},
+		{"BeforePost", original, newEdit("alice", author, now.Add(-time.Minute)), true},
+		{"Reaction", reaction, newEdit("alice", author, now.Add(time.Minute)), true},
+		{"ExpiresWithPost", expiring, newExpiringEdit(&expiresAt), false},
+		{"NeverExpires", expiring, newExpiringEdit(nil), true},
+		{"OutlivesPost", expiring, newExpiringEdit(&later), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckEdit(tt.original, tt.edit); (err != nil) != tt.wantErr {
+				t.Errorf("CheckEdit() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"log"
+	"socli/p2p"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// MinSchemaVersion is the oldest message schema we still exchange messages
+// with. Peers below it are reported as incompatible.
+const MinSchemaVersion = 1
+
+// Features lists the optional protocols and message types this build supports.
+// It is sent in our hello so peers know what they can ask us for.
+var Features = []string{"sync", "directory", "presence", "blob", WireBinary, "circles", "shares", "reactions", "edits", "mentions", "polls", "expiry"}
+
+// Compatibility says whether we can talk to a peer.
+type Compatibility int
+
+const (
+	// Compatible peers use our message schema.
+	Compatible Compatibility = iota
+	// PeerNewer peers use a newer schema. We can read their posts until they
+	// use new fields, which our validator then drops.
+	PeerNewer
+	// PeerIncompatible peers use a schema older than MinSchemaVersion, or
+	// don't speak the hello protocol at all.
+	PeerIncompatible
+)
+
+// String returns a short description for the UI.
+func (c Compatibility) String() string {
+	switch c {
+	case Compatible:
+		return "compatible"
+	case PeerNewer:
+		return "newer version"
+	default:
+		return "incompatible"
+	}
+}
+
+// PeerVersion is what a peer told us about its socli build.
+type PeerVersion struct {
+	Version  string
+	Schema   int
+	Features []string
+	Compat   Compatibility
+}
+
+// Supports reports whether the peer announced the given feature.
+func (v PeerVersion) Supports(feature string) bool {
+	for _, f := range v.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Handshake exchanges hellos with connected peers and remembers their versions.
+type Handshake struct {
+	host  host.Host
+	hello p2p.Hello
+
+	mu    sync.Mutex
+	peers map[peer.ID]PeerVersion
+	// onHello is called with the version of every peer that says hello to us.
+	onHello func(peer.ID, PeerVersion)
+}
+
+// NewHandshake answers hellos on h, announcing appVersion with our schema and
+// features. Hellos from peers that contact us first are recorded as well.
+func NewHandshake(h host.Host, appVersion string) *Handshake {
+	hs := &Handshake{
+		host:  h,
+		hello: p2p.Hello{Version: appVersion, Schema: SchemaVersion, Features: Features},
+		peers: make(map[peer.ID]PeerVersion),
+	}
+	p2p.RegisterHelloHandler(h, hs.hello, func(from peer.ID, hello p2p.Hello) {
+		version := hs.record(from, hello)
+		hs.mu.Lock()
+		onHello := hs.onHello
+		hs.mu.Unlock()
+		if onHello != nil {
+			onHello(from, version)
+		}
+	})
+	return hs
+}
+
+// SetHelloCallback sets the function called with the version of every peer
+// that says hello to us, so peers we didn't check ourselves can be warned
+// about too.
+func (hs *Handshake) SetHelloCallback(callback func(peer.ID, PeerVersion)) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.onHello = callback
+}
+
+// Check says hello to peer p and returns its version. Peers that don't speak
+// the hello protocol run a build from before versioning and are reported as
+// incompatible.
+func (hs *Handshake) Check(ctx context.Context, p peer.ID) (PeerVersion, error) {
+	hello, err := p2p.SayHello(ctx, hs.host, p, hs.hello)
+	if errors.Is(err, p2p.ErrHelloNotSupported) {
+		version := PeerVersion{Version: "unknown", Compat: PeerIncompatible}
+		log.Printf("Handshake: Peer %s runs a socli build from before versioning\n", p)
+		hs.mu.Lock()
+		hs.peers[p] = version
+		hs.mu.Unlock()
+		return version, nil
+	}
+	if err != nil {
+		return PeerVersion{}, err
+	}
+	return hs.record(p, hello), nil
+}
+
+// Lookup returns the version of peer p, if we exchanged hellos with it.
+func (hs *Handshake) Lookup(p peer.ID) (PeerVersion, bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	version, ok := hs.peers[p]
+	return version, ok
+}
+
+// record stores the hello of peer p and returns the resulting version.
+func (hs *Handshake) record(p peer.ID, hello p2p.Hello) PeerVersion {
+	version := PeerVersion{
+		Version:  hello.Version,
+		Schema:   hello.Schema,
+		Features: hello.Features,
+		Compat:   compatibility(hello.Schema),
+	}
+	if version.Compat != Compatible {
+		log.Printf("Handshake: Peer %s runs %s with schema %d (%s)\n", p, hello.Version, hello.Schema, version.Compat)
+	}
+
+	hs.mu.Lock()
+	hs.peers[p] = version
+	hs.mu.Unlock()
+	return version
+}
+
+// compatibility compares a peer's message schema with ours.
+func compatibility(schema int) Compatibility {
+	switch {
+	case schema > SchemaVersion:
+		return PeerNewer
+	case schema < MinSchemaVersion:
+		return PeerIncompatible
+	default:
+		return Compatible
+	}
+}
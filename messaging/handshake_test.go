@@ -0,0 +1,103 @@
+package messaging
+
+import (
+	"context"
+	"socli/p2p"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// TestHandshakeCheck tests that both sides of a hello learn each other's
+// version, and that peers without the hello protocol count as incompatible.
+func TestHandshakeCheck(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mn := mocknet.New()
+	defer mn.Close()
+	a, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	b, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	legacy, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	// Give the legacy peer some other protocol, so identify has something to report
+	legacy.SetStreamHandler(p2p.SyncProtocolID, func(s network.Stream) { s.Close() })
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		t.Fatalf("Failed to connect peers: %v", err)
+	}
+
+	hsA := NewHandshake(a, "1.0.0")
+	hsB := NewHandshake(b, "1.1.0")
+	hellos := make(chan PeerVersion, 2)
+	hsB.SetHelloCallback(func(p peer.ID, version PeerVersion) {
+		if p == a.ID() {
+			hellos <- version
+		}
+	})
+
+	got, err := hsA.Check(ctx, b.ID())
+	if err != nil {
+		t.Fatalf("Check() error = %v, want nil", err)
+	}
+	if got.Version != "1.1.0" || got.Schema != SchemaVersion || got.Compat != Compatible {
+		t.Errorf("Check() = %+v, want version 1.1.0, schema %d, compatible", got, SchemaVersion)
+	}
+	if len(got.Features) != len(Features) {
+		t.Errorf("Check() features = %v, want %v", got.Features, Features)
+	}
+	// The answering side records the hello it received
+	if got, ok := hsB.Lookup(a.ID()); !ok || got.Version != "1.0.0" {
+		t.Errorf("Lookup() = %+v, %t, want version 1.0.0", got, ok)
+	}
+	if got := <-hellos; got.Version != "1.0.0" || got.Compat != Compatible {
+		t.Errorf("Hello callback got %+v, want version 1.0.0, compatible", got)
+	}
+
+	// A newer peer that says hello first is reported to the callback
+	hsA.hello.Schema = SchemaVersion + 1
+	if _, err := hsA.Check(ctx, b.ID()); err != nil {
+		t.Fatalf("Check() error = %v, want nil", err)
+	}
+	if got := <-hellos; got.Compat != PeerNewer {
+		t.Errorf("Hello callback got compat %v from a newer peer, want %v", got.Compat, PeerNewer)
+	}
+
+	got, err = hsA.Check(ctx, legacy.ID())
+	if err != nil {
+		t.Fatalf("Check() error = %v, want nil for a legacy peer", err)
+	}
+	if got.Compat != PeerIncompatible {
+		t.Errorf("Check() compat = %v, want %v", got.Compat, PeerIncompatible)
+	}
+}
+
+// TestCompatibility tests how peer schemas compare with ours.
+func TestCompatibility(t *testing.T) {
+	tests := []struct {
+		schema int
+		want   Compatibility
+	}{
+		{SchemaVersion, Compatible},
+		{SchemaVersion + 1, PeerNewer},
+		{MinSchemaVersion - 1, PeerIncompatible},
+	}
+	for _, tt := range tests {
+		if got := compatibility(tt.schema); got != tt.want {
+			t.Errorf("compatibility(%d) = %v, want %v", tt.schema, got, tt.want)
+		}
+	}
+}
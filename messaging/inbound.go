@@ -0,0 +1,34 @@
+package messaging
+
+import (
+	"context"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ReadSubscription passes every message received on sub to deliver, along
+// with the peer that published it, until ctx is done or the subscription is
+// cancelled, returning the error that stopped it.
+// Messages were already decoded and checked by the topic validator, so only
+// valid messages are delivered. Messages published by self are skipped.
+func ReadSubscription(ctx context.Context, sub *pubsub.Subscription, self peer.ID, deliver func(msg *Message, origin peer.ID)) error {
+	for {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Don't display our own messages
+		if msg.ReceivedFrom == self {
+			continue
+		}
+
+		decoded, ok := msg.ValidatorData.(*Message)
+		if !ok {
+			// Topics joined without the socli validator carry no decoded message
+			continue
+		}
+		deliver(decoded, msg.GetFrom())
+	}
+}
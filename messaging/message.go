@@ -1,6 +1,16 @@
 package messaging
 
-import "time"
+import (
+	"slices"
+	"time"
+)
+
+// SchemaVersion is the newest version of the message format this build
+// reads and writes. It goes up whenever messages gain fields or change
+// meaning, since older builds can't verify the signature of fields they
+// don't know. Each message is stamped with the oldest schema that has all
+// the fields it uses, so older builds still read the others.
+const SchemaVersion = 9
 
 // MsgType defines the type of a message.
 type MsgType string
@@ -12,16 +22,141 @@ const (
 	ReplyMsg MsgType = "reply"
 	// ShareMsg is a share of another post.
 	ShareMsg MsgType = "share"
+	// AnnounceMsg announces a peer's subscribed hashtags on the directory topic.
+	AnnounceMsg MsgType = "announce"
+	// PresenceMsg is a heartbeat carrying a peer's status on the presence topic.
+	PresenceMsg MsgType = "presence"
+	// CircleMsg updates a circle's member list, or announces a joining member.
+	CircleMsg MsgType = "circle"
+	// ReactionMsg reacts to a post with an emoji or shortcode in Content.
+	ReactionMsg MsgType = "reaction"
+	// EditMsg replaces the content of a post with Content. Only the post's
+	// author can edit it.
+	EditMsg MsgType = "edit"
+	// PollMsg asks the question in Content and offers the answers in Poll.
+	PollMsg MsgType = "poll"
+	// VoteMsg votes for the option in Content on a poll. A later vote by the
+	// same voter replaces the earlier one.
+	VoteMsg MsgType = "vote"
+	// PollCloseMsg closes a poll. Only the poll's author can close it.
+	PollCloseMsg MsgType = "poll_close"
+)
+
+// PresenceStatus is the availability a peer shows to others.
+type PresenceStatus string
+
+const (
+	// StatusOnline means the peer is around.
+	StatusOnline PresenceStatus = "online"
+	// StatusAway means the peer is running socli but not at the keyboard.
+	StatusAway PresenceStatus = "away"
+	// StatusDND means the peer doesn't want to be disturbed.
+	StatusDND PresenceStatus = "dnd"
 )
 
+// ParsePresenceStatus turns user input into a presence status.
+func ParsePresenceStatus(s string) (PresenceStatus, bool) {
+	switch PresenceStatus(s) {
+	case StatusOnline, StatusAway, StatusDND:
+		return PresenceStatus(s), true
+	}
+	return "", false
+}
+
+// TopicActivity is one hashtag in a directory announcement, with the number
+// of posts the announcing peer has seen on it recently.
+type TopicActivity struct {
+	Hashtag string `json:"hashtag"`
+	Posts   int    `json:"posts"`
+}
+
+// Attachment describes a file shared with a post. Only its content address
+// travels in the message; peers fetch the chunks over the blob protocol.
+type Attachment struct {
+	Name   string   `json:"name"`
+	Size   int64    `json:"size"`   // In bytes
+	Hash   string   `json:"hash"`   // Hex SHA-256 of the whole file
+	Chunks []string `json:"chunks"` // Hex SHA-256 of each chunk, in order
+}
+
+// Poll holds the options of a PollMsg.
+type Poll struct {
+	Options []string `json:"options"`
+}
+
+// CircleMember is one member of a circle.
+type CircleMember struct {
+	PeerID     string `json:"peer_id"`
+	BoxKey     []byte `json:"box_key,omitempty"`     // Encryption key that rotated group keys are sealed for; unknown until the member joins
+	SigningKey []byte `json:"signing_key,omitempty"` // Key the member signs its posts with; unknown until the member joins
+}
+
+// CircleUpdate is carried by a CircleMsg. The circle's owner sends the member
+// list; a joining member sends only its BoxKey.
+type CircleUpdate struct {
+	Name     string            `json:"name"`
+	Revision int               `json:"revision,omitempty"` // Goes up with every change to the member list
+	Epoch    int               `json:"epoch,omitempty"`    // Goes up with every group key rotation
+	Members  []CircleMember    `json:"members,omitempty"`
+	Keys     map[string][]byte `json:"keys,omitempty"`    // The new group key sealed for each member's BoxKey, keyed by peer ID
+	BoxKey   []byte            `json:"box_key,omitempty"` // The joining member's encryption key
+}
+
 // Message represents a message sent over the p2p network.
 type Message struct {
-	ID        string    `json:"id"`
-	Author    string    `json:"author"`   // Peer ID
-	Content   string    `json:"content"`  // Markdown content
-	Hashtags  []string  `json:"hashtags"` // Extracted hashtags
-	Timestamp time.Time `json:"timestamp"`
-	Signature []byte    `json:"signature"` // Message signature
-	Type      MsgType   `json:"type"`      // Post, Reply, Share
-	ReplyTo   string    `json:"reply_to,omitempty"`
+	ID         string          `json:"id"`
+	Author     string          `json:"author"`   // Peer ID
+	Content    string          `json:"content"`  // Markdown content
+	Hashtags   []string        `json:"hashtags"` // Extracted hashtags
+	Timestamp  time.Time       `json:"timestamp"`
+	Signature  []byte          `json:"signature"`            // Message signature
+	PublicKey  []byte          `json:"public_key,omitempty"` // Ed25519 key that verifies Signature
+	Type       MsgType         `json:"type"`                 // Post, Reply, Share
+	ReplyTo    string          `json:"reply_to,omitempty"`   // The post replied to, reacted to or edited, or the poll voted on or closed
+	Topics     []TopicActivity `json:"topics,omitempty"`     // Announced hashtags, for AnnounceMsg
+	Status     PresenceStatus  `json:"status,omitempty"`     // For PresenceMsg; Content holds the status text
+	Attachment *Attachment     `json:"attachment,omitempty"` // File shared with a post
+	Version    int             `json:"version,omitempty"`    // Schema the message needs; 0 for builds from before versioning
+	Circle     *CircleUpdate   `json:"circle,omitempty"`     // Member list or join, for CircleMsg
+	Shared     *Message        `json:"shared,omitempty"`     // The original signed post, for ShareMsg; Content holds the comment
+	Mentions   []string        `json:"mentions,omitempty"`   // Peer IDs of the peers mentioned with @ in Content
+	Poll       *Poll           `json:"poll,omitempty"`       // The options, for PollMsg; Content holds the question
+	ExpiresAt  *time.Time      `json:"expires_at,omitempty"` // When every peer drops the message; nil if it doesn't expire
+}
+
+// Expired reports whether the message has an expiry time that has passed.
+func (m *Message) Expired(now time.Time) bool {
+	return m.ExpiresAt != nil && !now.Before(*m.ExpiresAt)
+}
+
+// schema returns the oldest schema version that has every field the message
+// uses. A share needs at least the schema of the post it embeds.
+func (m *Message) schema() int {
+	if m.Shared != nil {
+		own := *m
+		own.Shared = nil
+		return max(3, m.Shared.Version, own.schema())
+	}
+	if m.Circle != nil && slices.ContainsFunc(m.Circle.Members, func(member CircleMember) bool { return member.SigningKey != nil }) {
+		return 9
+	}
+	if m.ExpiresAt != nil {
+		return 8
+	}
+	if m.Type == PollMsg || m.Type == VoteMsg || m.Type == PollCloseMsg {
+		return 7
+	}
+	if len(m.Mentions) > 0 {
+		return 6
+	}
+	if m.Type == EditMsg {
+		return 5
+	}
+	if m.Type == ReactionMsg {
+		return 4
+	}
+	if m.Circle != nil {
+		return 2
+	}
+	return 1
 }
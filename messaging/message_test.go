@@ -51,4 +51,29 @@ func TestMessageCreation(t *testing.T) {
 	if msg.ReplyTo != "" {
 		t.Errorf("Message.ReplyTo = %s, want empty string", msg.ReplyTo)
 	}
-}
\ No newline at end of file
+}
+
+// TestMessageSchema tests the schema version messages are stamped with.
+func TestMessageSchema(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	mentioned := &Message{Type: PostMsg, Content: "Hi @peer", Mentions: []string{"peer"}}
+	mentioned.Version = mentioned.schema()
+	tests := []struct {
+		name string
+		msg  *Message
+		want int
+	}{
+		{"Post", &Message{Type: PostMsg}, 1},
+		{"Mentions", mentioned, 6},
+		{"ShareOfPost", &Message{Type: ShareMsg, Shared: &Message{Type: PostMsg, Version: 1}}, 3},
+		{"ShareOfPostWithMentions", &Message{Type: ShareMsg, Shared: mentioned}, 6},
+		{"ExpiringShareOfPostWithMentions", &Message{Type: ShareMsg, Shared: mentioned, ExpiresAt: &expires}, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.msg.schema(); got != tt.want {
+				t.Errorf("schema() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
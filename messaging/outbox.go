@@ -0,0 +1,244 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"socli/config"
+	"sync"
+	"time"
+)
+
+// DeliveryState is how far a message got on one of its topics.
+type DeliveryState string
+
+const (
+	// DeliveryWaiting means the message is held until the topic has peers,
+	// or until a failed publish is retried.
+	DeliveryWaiting DeliveryState = "waiting"
+	// DeliverySent means the message was published to at least one peer.
+	DeliverySent DeliveryState = "sent"
+	// DeliveryFailed means the outbox gave up on the topic after MaxWait, or
+	// once the message expired.
+	DeliveryFailed DeliveryState = "failed"
+)
+
+var (
+	// ErrNoPeers is why a message waits on a topic nobody is subscribed to yet.
+	ErrNoPeers = errors.New("no peers on the topic")
+	// ErrExpired is why a message that expired while waiting was given up on.
+	ErrExpired = errors.New("message expired")
+)
+
+// outboxSpreadTime is how long before validators consider a message too old
+// the outbox stops retrying it, to leave it time to spread.
+const outboxSpreadTime = 5 * time.Minute
+
+// Delivery is the delivery state of a message on one topic.
+type Delivery struct {
+	Topic    string // Topic name, e.g. "socli/hashtag/ops"
+	State    DeliveryState
+	Peers    int   // Peers subscribed to the topic when the message was published
+	Attempts int   // Publish attempts, not counting checks that found no peers
+	Err      error // Why the message is still waiting or was given up on
+}
+
+// outboxEntry is a message sent through the outbox and its deliveries.
+type outboxEntry struct {
+	queued     time.Time
+	expiresAt  *time.Time // When the message expires; nil if it doesn't
+	deliveries []Delivery
+	data       [][]byte // The encoded message for each delivery; nil once it is no longer waiting
+}
+
+// Outbox publishes our posts through the Broadcaster and tracks their
+// delivery on each topic. A message published on a topic nobody is
+// subscribed to is lost, so the outbox holds it until the topic has peers,
+// and retries failed publishes, until MaxWait has passed or the message
+// expired. A message is dropped from the outbox once it waits on no topic.
+type Outbox struct {
+	broadcaster   *Broadcaster
+	retryInterval time.Duration
+	maxWait       time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]*outboxEntry // Messages still waiting on a topic, keyed by message ID
+	reporter func(id string, deliveries []Delivery)
+}
+
+// NewOutbox creates an outbox that publishes through broadcaster.
+// Validators ignore messages older than maxMessageAge, and the last attempt
+// can come a retry interval after MaxWait, so MaxWait is capped to leave a
+// message time to spread before then.
+func NewOutbox(broadcaster *Broadcaster, cfg *config.Config) *Outbox {
+	return &Outbox{
+		broadcaster:   broadcaster,
+		retryInterval: cfg.Outbox.RetryInterval,
+		maxWait:       min(cfg.Outbox.MaxWait, maxMessageAge-cfg.Outbox.RetryInterval-outboxSpreadTime),
+		entries:       make(map[string]*outboxEntry),
+	}
+}
+
+// SetReporter sets the function called with all deliveries of a message
+// whenever a retry sends it on a topic or gives up on one.
+func (o *Outbox) SetReporter(reporter func(id string, deliveries []Delivery)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.reporter = reporter
+}
+
+// Start retries waiting deliveries every retry interval until ctx is done.
+func (o *Outbox) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(o.retryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				o.retry(ctx, now)
+			}
+		}
+	}()
+}
+
+// Send publishes a message on each of its topics and returns where it went.
+// Topics that have no peers yet or failed are left waiting for Start to
+// retry them. An error is only returned if the message couldn't be encoded.
+func (o *Outbox) Send(ctx context.Context, msg *Message) ([]Delivery, error) {
+	envelopes, err := o.broadcaster.envelopes(msg)
+	if err != nil {
+		return nil, err
+	}
+	entry := &outboxEntry{
+		queued:     time.Now(),
+		expiresAt:  msg.ExpiresAt,
+		deliveries: make([]Delivery, len(envelopes)),
+		data:       make([][]byte, len(envelopes)),
+	}
+	for i, env := range envelopes {
+		entry.deliveries[i] = Delivery{Topic: env.topic, State: DeliveryWaiting}
+		entry.data[i] = env.data
+		peers, err := o.broadcaster.publish(ctx, env.topic, env.data, true)
+		entry.record(i, peers, err)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if entry.waiting() {
+		o.entries[msg.ID] = entry
+	}
+	return slices.Clone(entry.deliveries), nil
+}
+
+// Deliveries returns the deliveries of a message still waiting in the
+// outbox on some topic, or nil if it isn't.
+func (o *Outbox) Deliveries(id string) []Delivery {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if entry, ok := o.entries[id]; ok {
+		return slices.Clone(entry.deliveries)
+	}
+	return nil
+}
+
+// record updates a waiting delivery with the result of publishing it.
+func (entry *outboxEntry) record(i, peers int, err error) {
+	d := &entry.deliveries[i]
+	if err == nil && peers == 0 {
+		d.Err = ErrNoPeers
+		return
+	}
+	d.Attempts++
+	d.Peers, d.Err = peers, err
+	if err != nil {
+		log.Printf("Outbox: Publishing to %s failed (attempt %d): %v\n", d.Topic, d.Attempts, err)
+		return
+	}
+	d.State = DeliverySent
+	entry.data[i] = nil
+}
+
+// waiting reports whether the message still waits on any topic.
+func (entry *outboxEntry) waiting() bool {
+	return slices.ContainsFunc(entry.deliveries, func(d Delivery) bool { return d.State == DeliveryWaiting })
+}
+
+// retry attempts every waiting delivery again, gives up on those that have
+// waited longer than MaxWait or whose message expired, drops the messages
+// that no longer wait on any topic, and reports the messages that changed.
+// Publishing may block, so it happens without the lock.
+func (o *Outbox) retry(ctx context.Context, now time.Time) {
+	type attempt struct {
+		id    string
+		entry *outboxEntry
+		i     int
+		topic string
+		data  []byte
+		peers int
+		err   error
+	}
+	var attempts []attempt
+	changed := make(map[string]bool)
+
+	o.mu.Lock()
+	for id, entry := range o.entries {
+		for i, d := range entry.deliveries {
+			if d.State != DeliveryWaiting {
+				continue
+			}
+			// Peers would drop an expired message anyway
+			if entry.expiresAt != nil && !now.Before(*entry.expiresAt) {
+				entry.deliveries[i].State, entry.deliveries[i].Err = DeliveryFailed, ErrExpired
+				entry.data[i] = nil
+				changed[id] = true
+				continue
+			}
+			attempts = append(attempts, attempt{id: id, entry: entry, i: i, topic: d.Topic, data: entry.data[i]})
+		}
+	}
+	o.mu.Unlock()
+
+	for i := range attempts {
+		a := &attempts[i]
+		a.peers, a.err = o.broadcaster.publish(ctx, a.topic, a.data, true)
+	}
+
+	type report struct {
+		id         string
+		deliveries []Delivery
+	}
+	var reports []report
+
+	o.mu.Lock()
+	for _, a := range attempts {
+		a.entry.record(a.i, a.peers, a.err)
+		d := &a.entry.deliveries[a.i]
+		if d.State == DeliveryWaiting && now.Sub(a.entry.queued) >= o.maxWait {
+			log.Printf("Outbox: Gave up on message ID %s on %s: %v\n", a.id, d.Topic, d.Err)
+			d.State = DeliveryFailed
+			a.entry.data[a.i] = nil
+		}
+		changed[a.id] = changed[a.id] || d.State != DeliveryWaiting
+	}
+	for id, entry := range o.entries {
+		if changed[id] {
+			reports = append(reports, report{id: id, deliveries: slices.Clone(entry.deliveries)})
+		}
+		if !entry.waiting() {
+			delete(o.entries, id)
+		}
+	}
+	reporter := o.reporter
+	o.mu.Unlock()
+
+	// The reporter may block, e.g. on a channel, so it is called without the lock
+	if reporter == nil {
+		return
+	}
+	for _, r := range reports {
+		reporter(r.id, r.deliveries)
+	}
+}
@@ -0,0 +1,140 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"socli/config"
+	"socli/crypto"
+	"sync"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+)
+
+// TestOutbox tests that posts wait for topics without peers, are sent once
+// peers arrive, and are given up on after MaxWait or once they expire.
+func TestOutbox(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Outbox.MaxWait = time.Minute
+
+	var mu sync.Mutex
+	peers := map[string][]peer.ID{GetTopicForHashtag("ops"): {test.RandPeerIDFatal(t), test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)}}
+	failing := map[string]bool{}
+	published := map[string]int{}
+	mockPSM := &mockPubSubManager{
+		listPeersFunc: func(topicName string) []peer.ID {
+			mu.Lock()
+			defer mu.Unlock()
+			return peers[topicName]
+		},
+		joinTopicFunc: func(topicName string) (*pubsub.Topic, error) {
+			if failing[topicName] {
+				return nil, errors.New("join topic error")
+			}
+			return nil, nil
+		},
+		publishMessageFunc: func(ctx context.Context, topic *pubsub.Topic, data []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			published[string(data)]++
+			return nil
+		},
+	}
+	outbox := NewOutbox(NewBroadcaster(mockPSM, cfg, keyPair), cfg)
+	type report struct {
+		id         string
+		deliveries []Delivery
+	}
+	var reports []report
+	outbox.SetReporter(func(id string, deliveries []Delivery) {
+		reports = append(reports, report{id, deliveries})
+	})
+
+	msg := &Message{ID: "post", Author: "alice", Content: "deploy at 5 #ops #dev", Hashtags: []string{"ops", "dev"}, Timestamp: time.Now(), Type: PostMsg}
+	deliveries, err := outbox.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("Send() = %+v, want a delivery per hashtag", deliveries)
+	}
+	if d := deliveries[0]; d.State != DeliverySent || d.Peers != 3 || d.Attempts != 1 {
+		t.Errorf("Delivery on #ops = %+v, want sent to 3 peers", d)
+	}
+	if d := deliveries[1]; d.State != DeliveryWaiting || !errors.Is(d.Err, ErrNoPeers) || d.Attempts != 0 {
+		t.Errorf("Delivery on #dev = %+v, want waiting for peers", d)
+	}
+	if len(published) != 1 {
+		t.Errorf("Published %d times, want once, only on #ops", len(published))
+	}
+
+	// Nothing changes while #dev has no peers
+	outbox.retry(context.Background(), time.Now())
+	if len(reports) != 0 {
+		t.Errorf("retry() reported %+v with no new peers, want nothing", reports)
+	}
+
+	// A peer subscribes to #dev, and the next retry sends the post there
+	mu.Lock()
+	peers[GetTopicForHashtag("dev")] = []peer.ID{test.RandPeerIDFatal(t)}
+	mu.Unlock()
+	outbox.retry(context.Background(), time.Now())
+	if len(reports) != 1 || reports[0].id != "post" || reports[0].deliveries[1].State != DeliverySent || reports[0].deliveries[1].Peers != 1 {
+		t.Fatalf("retry() reported %+v, want the post sent on #dev", reports)
+	}
+	if got := outbox.Deliveries("post"); got != nil {
+		t.Errorf("Deliveries() of a post sent on every topic = %+v, want nil", got)
+	}
+
+	// A topic that can't be joined is retried, and given up on after MaxWait
+	failing[GetTopicForHashtag("broken")] = true
+	broken := &Message{ID: "broken", Author: "alice", Content: "#broken", Hashtags: []string{"broken"}, Timestamp: time.Now(), Type: PostMsg}
+	deliveries, _ = outbox.Send(context.Background(), broken)
+	if d := deliveries[0]; d.State != DeliveryWaiting || d.Err == nil || d.Attempts != 1 {
+		t.Errorf("Delivery on #broken = %+v, want waiting after a failed attempt", d)
+	}
+	reports = nil
+	outbox.retry(context.Background(), time.Now().Add(2*time.Minute))
+	if len(reports) != 1 || reports[0].deliveries[0].State != DeliveryFailed || reports[0].deliveries[0].Attempts != 2 {
+		t.Errorf("retry() after MaxWait reported %+v, want #broken given up on", reports)
+	}
+	if got := outbox.Deliveries("broken"); got != nil {
+		t.Errorf("Deliveries() of a post given up on = %+v, want nil", got)
+	}
+
+	// A post that expires while waiting is given up on before MaxWait
+	expiresAt := time.Now().Add(30 * time.Second)
+	fleeting := &Message{ID: "fleeting", Author: "alice", Content: "#quiet", Hashtags: []string{"quiet"}, Timestamp: time.Now(), Type: PostMsg, ExpiresAt: &expiresAt}
+	outbox.Send(context.Background(), fleeting)
+	reports = nil
+	outbox.retry(context.Background(), expiresAt)
+	if len(reports) != 1 || reports[0].deliveries[0].State != DeliveryFailed || !errors.Is(reports[0].deliveries[0].Err, ErrExpired) {
+		t.Errorf("retry() after the post expired reported %+v, want #quiet given up on", reports)
+	}
+
+	if got := outbox.Deliveries("unknown"); got != nil {
+		t.Errorf("Deliveries() of an unknown message = %+v, want nil", got)
+	}
+}
+
+// TestOutboxMaxWait tests that MaxWait is capped so that a post is never
+// published when validators would consider it too old.
+func TestOutboxMaxWait(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Outbox.MaxWait = 2 * maxMessageAge
+	outbox := NewOutbox(NewBroadcaster(&mockPubSubManager{}, cfg, keyPair), cfg)
+	if latest := outbox.maxWait + cfg.Outbox.RetryInterval; latest >= maxMessageAge {
+		t.Errorf("Last attempt comes %v after sending, want it before %v", latest, maxMessageAge)
+	}
+}
@@ -0,0 +1,62 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+)
+
+const (
+	// MaxPollOptions is the most options a poll may offer.
+	MaxPollOptions = 10
+	// maxPollOptionLength is the longest option a poll may offer, in characters.
+	maxPollOptionLength = 80
+)
+
+// validate checks the options of a poll: at least two, none empty, too
+// long or repeated, so each vote names exactly one of them.
+func (p *Poll) validate() error {
+	if len(p.Options) < 2 || len(p.Options) > MaxPollOptions {
+		return fmt.Errorf("poll has %d options, want 2 to %d", len(p.Options), MaxPollOptions)
+	}
+	for i, option := range p.Options {
+		if option == "" || utf8.RuneCountInString(option) > maxPollOptionLength {
+			return fmt.Errorf("invalid poll option %q", option)
+		}
+		if slices.Contains(p.Options[:i], option) {
+			return fmt.Errorf("poll option %q is repeated", option)
+		}
+	}
+	return nil
+}
+
+// CheckVote reports whether a vote counts on a poll: it must name one of the
+// poll's options and be cast after the poll was created.
+func CheckVote(poll, vote *Message) error {
+	if poll.Type != PollMsg || poll.Poll == nil {
+		return fmt.Errorf("can't vote on a message of type %q", poll.Type)
+	}
+	if !slices.Contains(poll.Poll.Options, vote.Content) {
+		return fmt.Errorf("%q is not an option of the poll", vote.Content)
+	}
+	if vote.Timestamp.Before(poll.Timestamp) {
+		return errors.New("vote is older than the poll")
+	}
+	return nil
+}
+
+// CheckPollClose reports whether a message may close a poll: only the poll's
+// author can close it, with the key it was signed with.
+func CheckPollClose(poll, closing *Message) error {
+	if poll.Type != PollMsg {
+		return fmt.Errorf("can't close a message of type %q", poll.Type)
+	}
+	if !sameSigner(poll, closing) {
+		return errors.New("poll closed by someone other than its author")
+	}
+	if closing.Timestamp.Before(poll.Timestamp) {
+		return errors.New("poll closed before it was created")
+	}
+	return nil
+}
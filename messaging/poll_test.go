@@ -0,0 +1,89 @@
+package messaging
+
+import (
+	"socli/crypto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPollValidate tests which poll options are accepted.
+func TestPollValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []string
+		wantErr bool
+	}{
+		{"TwoOptions", []string{"yes", "no"}, false},
+		{"OneOption", []string{"yes"}, true},
+		{"TooMany", strings.Split("a b c d e f g h i j k", " "), true},
+		{"Empty", []string{"yes", ""}, true},
+		{"TooLong", []string{"yes", strings.Repeat("n", maxPollOptionLength+1)}, true},
+		{"Repeated", []string{"yes", "no", "yes"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poll := &Poll{Options: tt.options}
+			if err := poll.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestCheckVote tests that votes must name an option of a poll.
+func TestCheckVote(t *testing.T) {
+	now := time.Now()
+	poll := &Message{ID: "poll", Author: "alice", Content: "Deploy now?", Timestamp: now, Type: PollMsg, Poll: &Poll{Options: []string{"yes", "no"}}}
+	post := &Message{ID: "post", Author: "alice", Content: "Deploy now?", Timestamp: now, Type: PostMsg}
+
+	tests := []struct {
+		name    string
+		poll    *Message
+		vote    *Message
+		wantErr bool
+	}{
+		{"Option", poll, &Message{Content: "yes", Timestamp: now.Add(time.Minute)}, false},
+		{"NotAnOption", poll, &Message{Content: "maybe", Timestamp: now.Add(time.Minute)}, true},
+		{"BeforePoll", poll, &Message{Content: "yes", Timestamp: now.Add(-time.Minute)}, true},
+		{"NotAPoll", post, &Message{Content: "yes", Timestamp: now.Add(time.Minute)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckVote(tt.poll, tt.vote); (err != nil) != tt.wantErr {
+				t.Errorf("CheckVote() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestCheckPollClose tests that only the author of a poll can close it.
+func TestCheckPollClose(t *testing.T) {
+	author, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	other, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	now := time.Now()
+	poll := &Message{ID: "poll", Author: "alice", Content: "Deploy now?", Timestamp: now, Type: PollMsg, Poll: &Poll{Options: []string{"yes", "no"}}}
+	if err := poll.Sign(author); err != nil {
+		t.Fatalf("Sign() error = %v, want nil", err)
+	}
+	newClose := func(keyPair *crypto.KeyPair) *Message {
+		msg := &Message{ID: "close", Author: "alice", Timestamp: now.Add(time.Minute), Type: PollCloseMsg, ReplyTo: "poll"}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		return msg
+	}
+
+	if err := CheckPollClose(poll, newClose(author)); err != nil {
+		t.Errorf("CheckPollClose() by the author error = %v, want nil", err)
+	}
+	if err := CheckPollClose(poll, newClose(other)); err == nil {
+		t.Error("CheckPollClose() by another key error = nil, want an error")
+	}
+}
@@ -0,0 +1,247 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"log"
+	"socli/config"
+	"socli/crypto"
+	"socli/p2p"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// MinHeartbeatInterval is the shortest time between two presence heartbeats
+// from one peer. Validators drop heartbeats that come in much faster.
+const MinHeartbeatInterval = 5 * time.Second
+
+// ErrStatusTooSoon is returned by SetStatus when the previous heartbeat was
+// so recent that validators would drop the new one. The status is kept and
+// goes out with the next heartbeat.
+var ErrStatusTooSoon = errors.New("status changed too quickly; peers see it with the next heartbeat")
+
+const (
+	// heartbeatTTL is how many heartbeat intervals a peer stays online without
+	// sending a heartbeat.
+	heartbeatTTL = 3
+	// forgetPresenceAfter is how long a silent peer's last-seen time is kept.
+	forgetPresenceAfter = 24 * time.Hour
+)
+
+// PeerPresence is the last known presence of a peer.
+type PeerPresence struct {
+	Status   PresenceStatus
+	Text     string
+	LastSeen time.Time
+	Expired  bool // No heartbeat for a few intervals; the peer is probably gone
+}
+
+// Presence publishes our heartbeats on the presence topic and tracks the
+// heartbeats of other peers.
+type Presence struct {
+	psm     p2p.PubSubManagerInterface
+	cfg     *config.Config
+	keyPair *crypto.KeyPair
+	self    peer.ID
+
+	mu       sync.Mutex
+	topic    *pubsub.Topic
+	status   PresenceStatus
+	text     string
+	lastBeat time.Time
+	peers    map[string]PeerPresence // Keyed by peer ID
+}
+
+// NewPresence creates a presence tracker that sends heartbeats as self.
+// Our status starts as online.
+func NewPresence(psm p2p.PubSubManagerInterface, cfg *config.Config, keyPair *crypto.KeyPair, self peer.ID) *Presence {
+	return &Presence{
+		psm:     psm,
+		cfg:     cfg,
+		keyPair: keyPair,
+		self:    self,
+		status:  StatusOnline,
+		peers:   make(map[string]PeerPresence),
+	}
+}
+
+// Start joins the presence topic, tracks heartbeats and, if enabled, sends
+// our own every heartbeat interval until ctx is done.
+func (p *Presence) Start(ctx context.Context) error {
+	topic, err := p.psm.JoinTopic(PresenceTopic)
+	if err != nil {
+		return err
+	}
+	sub, err := p.psm.SubscribeToTopic(topic)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.topic = topic
+	p.mu.Unlock()
+
+	go func() {
+		err := ReadSubscription(ctx, sub, p.self, func(msg *Message, _ peer.ID) {
+			p.record(msg, time.Now())
+		})
+		log.Printf("Presence: Stopped reading heartbeats: %v\n", err)
+	}()
+
+	if p.cfg.Presence.EnableHeartbeats {
+		go p.heartbeatLoop(ctx)
+	}
+	return nil
+}
+
+// Interval returns how often heartbeats are sent, as configured but never
+// faster than MinHeartbeatInterval.
+func (p *Presence) Interval() time.Duration {
+	if p.cfg.Presence.HeartbeatInterval < MinHeartbeatInterval {
+		return MinHeartbeatInterval
+	}
+	return p.cfg.Presence.HeartbeatInterval
+}
+
+// heartbeatLoop sends a heartbeat every interval and forgets peers that have
+// been silent for a long time.
+func (p *Presence) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Heartbeat(ctx); err != nil {
+				log.Printf("Presence: Failed to send heartbeat: %v\n", err)
+			}
+			p.forget(time.Now())
+		}
+	}
+}
+
+// SetStatus changes our status and text, and announces it right away,
+// without waiting for MinHeartbeatInterval to pass. It returns
+// ErrStatusTooSoon if validators would still drop the announcement.
+func (p *Presence) SetStatus(ctx context.Context, status PresenceStatus, text string) error {
+	p.mu.Lock()
+	p.status = status
+	p.text = text
+	p.mu.Unlock()
+	return p.heartbeat(ctx, MinHeartbeatInterval/2)
+}
+
+// Status returns our own status and status text.
+func (p *Presence) Status() (PresenceStatus, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status, p.text
+}
+
+// Heartbeat publishes our current status. It does nothing if heartbeats are
+// disabled, and skips heartbeats that would come sooner than
+// MinHeartbeatInterval after the previous one.
+func (p *Presence) Heartbeat(ctx context.Context) error {
+	if err := p.heartbeat(ctx, MinHeartbeatInterval); !errors.Is(err, ErrStatusTooSoon) {
+		return err
+	}
+	return nil
+}
+
+// heartbeat publishes our current status, unless the previous heartbeat was
+// less than spacing ago, in which case it returns ErrStatusTooSoon.
+func (p *Presence) heartbeat(ctx context.Context, spacing time.Duration) error {
+	if !p.cfg.Presence.EnableHeartbeats {
+		return nil
+	}
+
+	p.mu.Lock()
+	topic := p.topic
+	now := time.Now()
+	if topic == nil {
+		p.mu.Unlock()
+		return nil
+	}
+	if now.Sub(p.lastBeat) < spacing {
+		p.mu.Unlock()
+		return ErrStatusTooSoon
+	}
+	p.lastBeat = now
+	msg := &Message{
+		ID:        uuid.New().String(),
+		Author:    p.self.String(),
+		Content:   p.text,
+		Timestamp: now,
+		Type:      PresenceMsg,
+		Status:    p.status,
+	}
+	p.mu.Unlock()
+
+	if err := msg.Sign(p.keyPair); err != nil {
+		return err
+	}
+	// Heartbeats are not encrypted, since every peer has to read them
+	data, err := EncodeMessage(msg, p.cfg)
+	if err != nil {
+		return err
+	}
+	return p.psm.PublishMessage(ctx, topic, data)
+}
+
+// record stores a heartbeat, unless a newer one from the same peer was seen.
+func (p *Presence) record(msg *Message, now time.Time) {
+	if msg.Type != PresenceMsg {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if previous, ok := p.peers[msg.Author]; ok && previous.LastSeen.After(msg.Timestamp) {
+		return
+	}
+	// LastSeen uses the sender's timestamp, so it doesn't depend on gossip delays
+	p.peers[msg.Author] = PeerPresence{Status: msg.Status, Text: msg.Content, LastSeen: msg.Timestamp}
+}
+
+// Lookup returns the last known presence of a peer. Peers whose heartbeats
+// stopped are reported as expired.
+func (p *Presence) Lookup(id string) (PeerPresence, bool) {
+	p.mu.Lock()
+	presence, ok := p.peers[id]
+	p.mu.Unlock()
+	if !ok {
+		return PeerPresence{}, false
+	}
+	presence.Expired = time.Since(presence.LastSeen) > heartbeatTTL*p.Interval()
+	return presence, true
+}
+
+// Peers returns the IDs of the peers we have heartbeats from, sorted, whether
+// or not we are connected to them directly.
+func (p *Presence) Peers() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]string, 0, len(p.peers))
+	for id := range p.peers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// forget drops peers that haven't sent a heartbeat for a long time.
+func (p *Presence) forget(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, presence := range p.peers {
+		if now.Sub(presence.LastSeen) > forgetPresenceAfter {
+			delete(p.peers, id)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"socli/config"
+	"socli/crypto"
+	"socli/p2p"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// TestPresenceHeartbeat tests that status changes reach other peers and that
+// heartbeats are rate limited.
+func TestPresenceHeartbeat(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cfg := config.DefaultConfig()
+	// Heartbeats are sent by hand below, so the timer shouldn't interfere
+	cfg.Presence.HeartbeatInterval = time.Hour
+
+	mn := mocknet.New()
+	defer mn.Close()
+	hosts := make([]host.Host, 2)
+	presences := make([]*Presence, 2)
+	for i := range presences {
+		keyPair, err := crypto.GenerateKeyPair()
+		if err != nil {
+			t.Fatalf("Failed to generate key pair: %v", err)
+		}
+		if hosts[i], err = mn.GenPeer(); err != nil {
+			t.Fatalf("Failed to create mock host: %v", err)
+		}
+		psm, err := p2p.NewPubSubManager(ctx, hosts[i], cfg)
+		if err != nil {
+			t.Fatalf("NewPubSubManager() error = %v, want nil", err)
+		}
+		psm.SetMessageValidator(NewValidator(cfg, keyPair).Validate)
+		presences[i] = NewPresence(psm, cfg, keyPair, hosts[i].ID())
+		if err := presences[i].Start(ctx); err != nil {
+			t.Fatalf("Start() error = %v, want nil", err)
+		}
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		t.Fatalf("Failed to connect peers: %v", err)
+	}
+
+	// Wait until the peers see each other on the presence topic
+	for _, p := range presences {
+		for len(p.topic.ListPeers()) == 0 {
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for presence peers")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	// Messages published right after peers meet can be lost while the
+	// gossip streams are still opening, so give them a moment
+	time.Sleep(500 * time.Millisecond)
+
+	if err := presences[0].SetStatus(ctx, StatusAway, "lunch"); err != nil {
+		t.Fatalf("SetStatus() error = %v, want nil", err)
+	}
+	// Changing the status again right away is kept locally but not sent
+	if err := presences[0].SetStatus(ctx, StatusDND, "focus"); !errors.Is(err, ErrStatusTooSoon) {
+		t.Fatalf("SetStatus() right after another error = %v, want ErrStatusTooSoon", err)
+	}
+	if status, text := presences[0].Status(); status != StatusDND || text != "focus" {
+		t.Errorf("Status() = %q, %q, want %q, %q", status, text, StatusDND, "focus")
+	}
+
+	for {
+		got, ok := presences[1].Lookup(hosts[0].ID().String())
+		if ok {
+			if got.Status != StatusAway || got.Text != "lunch" || got.Expired {
+				t.Errorf("Lookup() = %+v, want away with text \"lunch\"", got)
+			}
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for the heartbeat")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// TestPresenceLookupExpires tests that peers whose heartbeats stop are
+// reported as expired, and eventually forgotten.
+func TestPresenceLookupExpires(t *testing.T) {
+	cfg := config.DefaultConfig()
+	p := NewPresence(nil, cfg, nil, "")
+
+	now := time.Now()
+	p.record(&Message{Type: PresenceMsg, Author: "fresh", Status: StatusOnline, Timestamp: now}, now)
+	stale := now.Add(-(heartbeatTTL + 1) * p.Interval())
+	p.record(&Message{Type: PresenceMsg, Author: "stale", Status: StatusAway, Timestamp: stale}, now)
+	// An older heartbeat doesn't replace a newer one from the same peer
+	p.record(&Message{Type: PresenceMsg, Author: "fresh", Status: StatusDND, Timestamp: now.Add(-time.Minute)}, now)
+
+	if got, ok := p.Lookup("fresh"); !ok || got.Status != StatusOnline || got.Expired {
+		t.Errorf("Lookup(fresh) = %+v, %v, want online and not expired", got, ok)
+	}
+	if got, ok := p.Lookup("stale"); !ok || !got.Expired {
+		t.Errorf("Lookup(stale) = %+v, %v, want expired", got, ok)
+	}
+	if _, ok := p.Lookup("unknown"); ok {
+		t.Error("Lookup(unknown) found a peer that never sent a heartbeat")
+	}
+	if got := p.Peers(); len(got) != 2 || got[0] != "fresh" || got[1] != "stale" {
+		t.Errorf("Peers() = %v, want [fresh stale]", got)
+	}
+
+	p.forget(now.Add(forgetPresenceAfter))
+	if _, ok := p.Lookup("stale"); ok {
+		t.Error("Lookup(stale) still found the peer after it was forgotten")
+	}
+	if _, ok := p.Lookup("fresh"); !ok {
+		t.Error("Lookup(fresh) lost a peer that wasn't silent for long")
+	}
+}
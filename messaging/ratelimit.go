@@ -0,0 +1,205 @@
+package messaging
+
+import (
+	"encoding/hex"
+	"log"
+	"socli/config"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// maxRateLimitKeys is how many peers or authors a rate limiter tracks before
+// pruning the ones that are back to a full bucket.
+const maxRateLimitKeys = 4096
+
+// rateSeenTTL is how long Allow remembers its decision about a message, so
+// the copies delivered on its other topics get the same one.
+const rateSeenTTL = 2 * time.Minute
+
+// RateDecision is what a rate limiter decided about one message.
+type RateDecision int
+
+const (
+	// RateAllow lets the message through.
+	RateAllow RateDecision = iota
+	// RateThrottle drops the message because the sender is over its rate.
+	RateThrottle
+	// RateBlock drops the message because the sender kept exceeding its rate
+	// and is blocked for a while.
+	RateBlock
+)
+
+// bucket is the token bucket of one peer or author.
+type bucket struct {
+	tokens       float64
+	updated      time.Time
+	strikes      int // Throttled messages since the bucket was last full
+	blockedUntil time.Time
+}
+
+// RateLimiter is a set of token buckets, one per key. Every message takes a
+// token; buckets refill at rate tokens per second up to burst. Keys that keep
+// sending into an empty bucket are blocked for a while.
+type RateLimiter struct {
+	rate       float64
+	burst      float64
+	blockAfter int
+	blockFor   time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewRateLimiter creates a rate limiter. A key that gets blockAfter messages
+// throttled before its bucket refills completely is blocked for blockFor.
+// A rate of 0 or less disables the limiter; a blockAfter of 0 or less
+// disables blocking.
+func NewRateLimiter(rate float64, burst int, blockAfter int, blockFor time.Duration) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:       rate,
+		burst:      float64(burst),
+		blockAfter: blockAfter,
+		blockFor:   blockFor,
+		buckets:    make(map[string]*bucket),
+	}
+}
+
+// Take takes a token for a message from key at time now.
+func (l *RateLimiter) Take(key string, now time.Time) RateDecision {
+	if l == nil || l.rate <= 0 {
+		return RateAllow
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		l.prune(now)
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+	if now.Before(b.blockedUntil) {
+		return RateBlock
+	}
+	if !b.blockedUntil.IsZero() {
+		// The block is over, so start again with a full bucket
+		*b = bucket{tokens: l.burst, updated: now}
+	}
+
+	l.refill(b, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return RateAllow
+	}
+
+	b.strikes++
+	if l.blockAfter > 0 && b.strikes >= l.blockAfter {
+		b.blockedUntil = now.Add(l.blockFor)
+		log.Printf("RateLimiter: Blocking %s for %s after %d throttled messages\n", key, l.blockFor, b.strikes)
+		return RateBlock
+	}
+	return RateThrottle
+}
+
+// refill adds the tokens earned since the bucket was last updated. A bucket
+// that is full again forgets its strikes.
+func (l *RateLimiter) refill(b *bucket, now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * l.rate
+	}
+	b.updated = now
+	if b.tokens >= l.burst {
+		b.tokens = l.burst
+		b.strikes = 0
+	}
+}
+
+// prune forgets keys whose buckets are full again and that aren't blocked,
+// so the map doesn't grow forever.
+func (l *RateLimiter) prune(now time.Time) {
+	if len(l.buckets) < maxRateLimitKeys {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Before(b.blockedUntil) {
+			continue
+		}
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimits combines a per-peer and a per-author rate limiter, set up from
+// the rate_limit section of the config.
+type RateLimits struct {
+	peers   *RateLimiter
+	authors *RateLimiter
+
+	mu   sync.Mutex
+	seen map[string]seenDecision // Allow's decisions, keyed by message ID
+}
+
+// seenDecision is what Allow decided about a message, and when.
+type seenDecision struct {
+	allowed bool
+	at      time.Time
+}
+
+// NewRateLimits creates the rate limits configured in cfg. It returns nil when
+// rate limiting is disabled; a nil *RateLimits allows everything.
+func NewRateLimits(cfg *config.Config) *RateLimits {
+	if !cfg.RateLimit.Enabled {
+		return nil
+	}
+	limits := cfg.RateLimit
+	return &RateLimits{
+		peers:   NewRateLimiter(limits.PeerRate, limits.PeerBurst, limits.BlockAfter, limits.BlockDuration),
+		authors: NewRateLimiter(limits.AuthorRate, limits.AuthorBurst, limits.BlockAfter, limits.BlockDuration),
+		seen:    make(map[string]seenDecision),
+	}
+}
+
+// Take takes a token from the peer's and the author's bucket, and returns the
+// stricter decision. The author's bucket is only charged if the peer is allowed.
+func (r *RateLimits) Take(peerKey, authorKey string, now time.Time) RateDecision {
+	if r == nil {
+		return RateAllow
+	}
+	if decision := r.peers.Take(peerKey, now); decision != RateAllow {
+		return decision
+	}
+	return r.authors.Take(authorKey, now)
+}
+
+// Allow reports whether a delivered message is within the limits of the
+// peer that published it and its signing key. It is used in the inbound
+// pipeline. A post with several hashtags is delivered once per topic, so
+// each message is only charged once, and its copies get the same decision.
+func (r *RateLimits) Allow(origin peer.ID, msg *Message) bool {
+	if r == nil {
+		return true
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if seen, ok := r.seen[msg.ID]; ok && now.Sub(seen.at) < rateSeenTTL {
+		return seen.allowed
+	}
+	if len(r.seen) >= maxRateLimitKeys {
+		for id, seen := range r.seen {
+			if now.Sub(seen.at) >= rateSeenTTL {
+				delete(r.seen, id)
+			}
+		}
+	}
+	allowed := r.Take(origin.String(), hex.EncodeToString(msg.PublicKey), now) == RateAllow
+	r.seen[msg.ID] = seenDecision{allowed: allowed, at: now}
+	return allowed
+}
@@ -0,0 +1,99 @@
+package messaging
+
+import (
+	"socli/config"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// TestRateLimiterTake tests that buckets allow bursts, refill over time and
+// block keys that keep exceeding their rate.
+func TestRateLimiterTake(t *testing.T) {
+	start := time.Now()
+	limiter := NewRateLimiter(1, 3, 2, time.Minute)
+
+	steps := []struct {
+		name string
+		key  string
+		at   time.Duration
+		want RateDecision
+	}{
+		{"Burst1", "flooder", 0, RateAllow},
+		{"Burst2", "flooder", 0, RateAllow},
+		{"Burst3", "flooder", 0, RateAllow},
+		{"Empty", "flooder", 0, RateThrottle},
+		{"OtherKey", "quiet", 0, RateAllow},
+		{"Refilled", "flooder", time.Second, RateAllow},
+		{"SecondStrike", "flooder", time.Second, RateBlock},
+		{"Blocked", "flooder", 30 * time.Second, RateBlock},
+		{"BlockOver", "flooder", time.Minute + time.Second, RateAllow},
+	}
+	for _, step := range steps {
+		if got := limiter.Take(step.key, start.Add(step.at)); got != step.want {
+			t.Errorf("%s: Take(%q) = %v, want %v", step.name, step.key, got, step.want)
+		}
+	}
+}
+
+// TestRateLimiterStrikesReset tests that a key whose bucket refills completely
+// starts over without strikes.
+func TestRateLimiterStrikesReset(t *testing.T) {
+	start := time.Now()
+	limiter := NewRateLimiter(1, 1, 2, time.Minute)
+
+	limiter.Take("peer", start)
+	if got := limiter.Take("peer", start); got != RateThrottle {
+		t.Fatalf("Take() = %v, want RateThrottle", got)
+	}
+	// A full refill forgets the first strike, so the next one only throttles
+	limiter.Take("peer", start.Add(2*time.Second))
+	if got := limiter.Take("peer", start.Add(2*time.Second)); got != RateThrottle {
+		t.Errorf("Take() = %v, want RateThrottle after the bucket refilled", got)
+	}
+}
+
+// TestNewRateLimitsDisabled tests that disabled rate limits allow everything.
+func TestNewRateLimitsDisabled(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.RateLimit.Enabled = false
+	limits := NewRateLimits(cfg)
+	for i := 0; i < 1000; i++ {
+		if got := limits.Take("peer", "author", time.Now()); got != RateAllow {
+			t.Fatalf("Take() = %v, want RateAllow with rate limiting disabled", got)
+		}
+	}
+}
+
+// TestRateLimitsAllow tests that inbound posts are charged to the peer that
+// published them, and only once however many topics deliver them.
+func TestRateLimitsAllow(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.PeerRate = 0.001
+	cfg.RateLimit.PeerBurst = 2
+	limits := NewRateLimits(cfg)
+	origin := peer.ID("publisher")
+
+	first := &Message{ID: "first", Author: "alice", PublicKey: []byte("alice-key")}
+	for range 3 {
+		if !limits.Allow(origin, first) {
+			t.Fatal("Allow() = false for a copy of the first post, want true")
+		}
+	}
+	if !limits.Allow(origin, &Message{ID: "second", Author: "bob", PublicKey: []byte("bob-key")}) {
+		t.Error("Allow() = false for the second post, want true")
+	}
+	// Claiming another author doesn't get the publisher a fresh bucket
+	third := &Message{ID: "third", Author: "carol", PublicKey: []byte("carol-key")}
+	if limits.Allow(origin, third) {
+		t.Error("Allow() = true for a third post over the burst of 2, want false")
+	}
+	if limits.Allow(origin, third) {
+		t.Error("Allow() = true for a copy of a throttled post, want false")
+	}
+	if !limits.Allow(peer.ID("other"), &Message{ID: "fourth", Author: "publisher", PublicKey: []byte("other-key")}) {
+		t.Error("Allow() = false for another publisher, want true")
+	}
+}
@@ -0,0 +1,53 @@
+package messaging
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxReactionRunes is the longest emoji a reaction may carry. Emoji made of
+// several code points, like flags and skin tones, need more than one.
+const maxReactionRunes = 10
+
+// shortcodePattern matches a reaction given as a shortcode, e.g. ":tada:".
+var shortcodePattern = regexp.MustCompile(`^:[a-z0-9_+-]{1,32}:$`)
+
+// reactionShortcodes maps common shortcodes to their emoji, so reacting with
+// either counts as the same reaction.
+var reactionShortcodes = map[string]string{
+	":+1:":       "👍",
+	":thumbsup:": "👍",
+	":-1:":       "👎",
+	":heart:":    "❤️",
+	":tada:":     "🎉",
+	":eyes:":     "👀",
+	":laughing:": "😆",
+	":rocket:":   "🚀",
+	":fire:":     "🔥",
+	":100:":      "💯",
+}
+
+// NormalizeReaction returns the emoji for a known shortcode, and any other
+// reaction unchanged.
+func NormalizeReaction(reaction string) string {
+	if emoji, ok := reactionShortcodes[reaction]; ok {
+		return emoji
+	}
+	return reaction
+}
+
+// ValidReaction reports whether a reaction is a shortcode or a short emoji.
+// Letters, digits and spaces are rejected, so reactions can't carry text.
+func ValidReaction(reaction string) bool {
+	if shortcodePattern.MatchString(reaction) {
+		return true
+	}
+	if reaction == "" || utf8.RuneCountInString(reaction) > maxReactionRunes {
+		return false
+	}
+	return !strings.ContainsFunc(reaction, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) || unicode.IsControl(r)
+	})
+}
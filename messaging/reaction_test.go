@@ -0,0 +1,47 @@
+package messaging
+
+import "testing"
+
+// TestValidReaction tests which reactions are accepted.
+func TestValidReaction(t *testing.T) {
+	tests := []struct {
+		reaction string
+		want     bool
+	}{
+		{"👍", true},
+		{"❤️", true},
+		{"👍🏽", true},
+		{"🏳️‍🌈", true},
+		{":tada:", true},
+		{":+1:", true},
+		{"", false},
+		{"+1", false},
+		{"lol", false},
+		{"👍 nice", false},
+		{"🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉", false},
+		{":not a shortcode:", false},
+	}
+	for _, tt := range tests {
+		if got := ValidReaction(tt.reaction); got != tt.want {
+			t.Errorf("ValidReaction(%q) = %t, want %t", tt.reaction, got, tt.want)
+		}
+	}
+}
+
+// TestNormalizeReaction tests that known shortcodes become their emoji.
+func TestNormalizeReaction(t *testing.T) {
+	tests := []struct {
+		reaction string
+		want     string
+	}{
+		{":+1:", "👍"},
+		{":thumbsup:", "👍"},
+		{"👍", "👍"},
+		{":unknown:", ":unknown:"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeReaction(tt.reaction); got != tt.want {
+			t.Errorf("NormalizeReaction(%q) = %q, want %q", tt.reaction, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package messaging
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"socli/crypto"
+)
+
+// signingBytes returns the bytes covered by the message signature: the JSON
+// encoding of the message with the signature itself left out.
+func (m *Message) signingBytes() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = nil
+	return json.Marshal(&unsigned)
+}
+
+// Sign signs the message with the key pair and embeds the public key that
+// receivers need to verify it. It also stamps the message with the schema
+// version it needs. The message must not be modified afterwards.
+func (m *Message) Sign(keyPair *crypto.KeyPair) error {
+	m.Version = m.schema()
+	m.PublicKey = crypto.SigningPublicKey(keyPair.PrivateKey)[:]
+	data, err := m.signingBytes()
+	if err != nil {
+		return err
+	}
+	signature, err := crypto.SignMessage(data, keyPair.PrivateKey)
+	if err != nil {
+		return err
+	}
+	m.Signature = signature
+	return nil
+}
+
+// VerifySignature reports whether the message carries a valid signature
+// made with the private key matching its embedded public key.
+func (m *Message) VerifySignature() bool {
+	if len(m.PublicKey) != ed25519.PublicKeySize || len(m.Signature) != ed25519.SignatureSize {
+		return false
+	}
+	data, err := m.signingBytes()
+	if err != nil {
+		return false
+	}
+	var pubKey [32]byte
+	copy(pubKey[:], m.PublicKey)
+	return crypto.VerifyMessageSignature(data, m.Signature, &pubKey)
+}
@@ -0,0 +1,53 @@
+package messaging
+
+import (
+	"socli/crypto"
+	"testing"
+	"time"
+)
+
+// TestMessageSignAndVerify tests signing a message and verifying the signature.
+func TestMessageSignAndVerify(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	msg := &Message{
+		ID:        "signed-id",
+		Author:    "test-author",
+		Content:   "This is a signed message",
+		Hashtags:  []string{"test"},
+		Timestamp: time.Now(),
+		Type:      PostMsg,
+	}
+
+	// An unsigned message must not verify
+	if msg.VerifySignature() {
+		t.Error("VerifySignature() = true for an unsigned message, want false")
+	}
+
+	if err := msg.Sign(keyPair); err != nil {
+		t.Fatalf("Sign() error = %v, want nil", err)
+	}
+	if !msg.VerifySignature() {
+		t.Error("VerifySignature() = false for a freshly signed message, want true")
+	}
+
+	// Tampering with any field must invalidate the signature
+	msg.Hashtags = []string{"other"}
+	if msg.VerifySignature() {
+		t.Error("VerifySignature() = true after changing the hashtags, want false")
+	}
+	msg.Hashtags = []string{"test"}
+
+	// Replacing the public key must not make a forged signature valid
+	otherKeyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	msg.PublicKey = crypto.SigningPublicKey(otherKeyPair.PrivateKey)[:]
+	if msg.VerifySignature() {
+		t.Error("VerifySignature() = true with another public key, want false")
+	}
+}
@@ -0,0 +1,289 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"socli/config"
+	"socli/crypto"
+	"socli/p2p"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// maxFetchIDs is the most posts one request may ask for by ID.
+const maxFetchIDs = 32
+
+// HistoryStore is the part of the post store that answers history sync requests.
+type HistoryStore interface {
+	RecentPosts(hashtags []string, since time.Time, limit int) []*Message
+	GetPost(id string) (*Message, bool)
+}
+
+// HistorySync lets a peer that just joined, or just subscribed to a hashtag,
+// fetch recent posts from the memory stores of connected peers.
+type HistorySync struct {
+	host      host.Host
+	store     HistoryStore
+	cfg       *config.Config
+	validator *Validator
+}
+
+// NewHistorySync creates a history sync service and, if enabled in the config,
+// starts answering sync requests from other peers with posts from store.
+func NewHistorySync(h host.Host, store HistoryStore, cfg *config.Config, validator *Validator) *HistorySync {
+	s := &HistorySync{host: h, store: store, cfg: cfg, validator: validator}
+	if cfg.Sync.EnableHistorySync {
+		p2p.RegisterSyncHandler(h, s.serve)
+	}
+	return s
+}
+
+// serve answers a sync request, never going further back than the configured
+// window or returning more than the configured number of posts.
+func (s *HistorySync) serve(from peer.ID, req p2p.SyncRequest) []json.RawMessage {
+	since := req.Since
+	if oldest := time.Now().Add(-s.cfg.Sync.Window); since.Before(oldest) {
+		since = oldest
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > s.cfg.Sync.MaxPosts {
+		limit = s.cfg.Sync.MaxPosts
+	}
+
+	var posts []*Message
+	if len(req.IDs) > 0 {
+		posts = s.postsByID(req.IDs, since)
+	} else {
+		posts = s.store.RecentPosts(req.Hashtags, since, limit)
+	}
+	encoded := make([]json.RawMessage, 0, len(posts))
+	for _, post := range posts {
+		// A post only goes to peers that could read it as it was published:
+		// nobody else can read what was encrypted for our key, and only
+		// members can read a circle's posts
+		if s.encryptedForUs(post) {
+			continue
+		}
+		if s.validator.circles.hidden(post, from) {
+			continue
+		}
+		data, err := json.Marshal(post)
+		if err != nil {
+			log.Printf("Sync: Failed to encode post %s: %v\n", post.ID, err)
+			continue
+		}
+		encoded = append(encoded, data)
+	}
+
+	log.Printf("Sync: Sent %d posts to %s\n", len(encoded), from.String())
+	return encoded
+}
+
+// encryptedForUs reports whether a post was published encrypted for our key,
+// which is the case for every post signed with it while encryption is on,
+// whichever of our nodes wrote it. Circle posts are sealed with the circle's
+// key instead.
+func (s *HistorySync) encryptedForUs(post *Message) bool {
+	keyPair := s.validator.keyPair
+	if !s.cfg.Privacy.EncryptMessages || keyPair == nil {
+		return false
+	}
+	if !bytes.Equal(post.PublicKey, crypto.SigningPublicKey(keyPair.PrivateKey)[:]) {
+		return false
+	}
+	return !slices.ContainsFunc(post.Hashtags, s.validator.circles.IsCircle)
+}
+
+// postsByID returns the stored posts with the given IDs that are newer than
+// since, reading at most maxFetchIDs of the IDs.
+func (s *HistorySync) postsByID(ids []string, since time.Time) []*Message {
+	if len(ids) > maxFetchIDs {
+		ids = ids[:maxFetchIDs]
+	}
+	posts := make([]*Message, 0, len(ids))
+	for _, id := range ids {
+		if post, ok := s.store.GetPost(id); ok && !post.Timestamp.Before(since) {
+			posts = append(posts, post)
+		}
+	}
+	return posts
+}
+
+// Sync asks each of the peers for recent posts carrying any of the hashtags.
+// It returns the posts that pass verification, oldest first and without
+// duplicates. Peers that fail or don't support the protocol are skipped.
+func (s *HistorySync) Sync(ctx context.Context, peers []peer.ID, hashtags []string) []*Message {
+	if !s.cfg.Sync.EnableHistorySync || len(hashtags) == 0 {
+		return nil
+	}
+
+	wanted := make(map[string]bool, len(hashtags))
+	for _, tag := range hashtags {
+		wanted[tag] = true
+	}
+	req := p2p.SyncRequest{
+		Hashtags: hashtags,
+		Since:    time.Now().Add(-s.cfg.Sync.Window),
+		Limit:    s.cfg.Sync.MaxPosts,
+	}
+
+	var (
+		mu    sync.Mutex
+		posts = make(map[string]*Message)
+		wg    sync.WaitGroup
+	)
+	for _, p := range peers {
+		if p == s.host.ID() {
+			continue
+		}
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			encoded, err := p2p.RequestSync(ctx, s.host, p, req)
+			if err != nil {
+				log.Printf("Sync: Request to %s failed: %v\n", p.String(), err)
+				return
+			}
+			// Don't trust the peer to respect our limit
+			if len(encoded) > req.Limit {
+				encoded = encoded[len(encoded)-req.Limit:]
+			}
+
+			for _, data := range encoded {
+				msg, err := s.verify(data)
+				if err == nil && !hasHashtag(msg, wanted) {
+					err = fmt.Errorf("post %s has none of the requested hashtags", msg.ID)
+				}
+				if err != nil {
+					log.Printf("Sync: Dropping post from %s: %v\n", p.String(), err)
+					continue
+				}
+				mu.Lock()
+				if _, seen := posts[msg.ID]; !seen {
+					posts[msg.ID] = msg
+				}
+				mu.Unlock()
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	result := make([]*Message, 0, len(posts))
+	for _, post := range posts {
+		result = append(result, post)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result
+}
+
+// Fetch asks the peers for the posts with the given IDs, e.g. the missing
+// parents of replies. It returns the posts that pass verification, oldest
+// first. Peers from before fetching by ID answer with nothing.
+func (s *HistorySync) Fetch(ctx context.Context, peers []peer.ID, ids []string) []*Message {
+	if !s.cfg.Sync.EnableHistorySync || len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > maxFetchIDs {
+		ids = ids[:maxFetchIDs]
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	req := p2p.SyncRequest{
+		Since: time.Now().Add(-s.cfg.Sync.Window),
+		Limit: len(ids),
+		IDs:   ids,
+	}
+
+	var (
+		mu    sync.Mutex
+		posts = make(map[string]*Message)
+		wg    sync.WaitGroup
+	)
+	for _, p := range peers {
+		if p == s.host.ID() {
+			continue
+		}
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			encoded, err := p2p.RequestSync(ctx, s.host, p, req)
+			if err != nil {
+				log.Printf("Sync: Fetch from %s failed: %v\n", p.String(), err)
+				return
+			}
+			if len(encoded) > req.Limit {
+				encoded = encoded[:req.Limit]
+			}
+
+			for _, data := range encoded {
+				msg, err := s.verify(data)
+				if err == nil && !wanted[msg.ID] {
+					err = fmt.Errorf("post %s was not requested", msg.ID)
+				}
+				if err != nil {
+					log.Printf("Sync: Dropping fetched post from %s: %v\n", p.String(), err)
+					continue
+				}
+				mu.Lock()
+				if _, seen := posts[msg.ID]; !seen {
+					posts[msg.ID] = msg
+				}
+				mu.Unlock()
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	result := make([]*Message, 0, len(posts))
+	for _, post := range posts {
+		result = append(result, post)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result
+}
+
+// verify decodes a post received over sync and applies the same checks as the
+// topic validator, allowing posts as old as the sync window.
+func (s *HistorySync) verify(data []byte) (*Message, error) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	if err := s.validator.check(&msg, s.cfg.Sync.Window); err != nil {
+		return nil, err
+	}
+	// Sync has no authenticated origin, so the author's name is only
+	// trusted with the key it publishes with
+	if err := s.validator.signers.check(msg.Author, msg.PublicKey); err != nil {
+		return nil, err
+	}
+	if err := s.validator.circles.checkAuthor(&msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// hasHashtag reports whether a post carries any of the wanted hashtags.
+func hasHashtag(msg *Message, wanted map[string]bool) bool {
+	for _, tag := range msg.Hashtags {
+		if wanted[tag] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,289 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"socli/config"
+	"socli/crypto"
+	"socli/p2p"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// fakeHistoryStore serves a fixed list of posts, ignoring the request filters,
+// so tests can check that the requesting side verifies what it receives.
+type fakeHistoryStore struct {
+	posts []*Message
+}
+
+func (s *fakeHistoryStore) RecentPosts(hashtags []string, since time.Time, limit int) []*Message {
+	return s.posts
+}
+
+func (s *fakeHistoryStore) GetPost(id string) (*Message, bool) {
+	for _, post := range s.posts {
+		if post.ID == id {
+			return post, true
+		}
+	}
+	return nil, false
+}
+
+// recordingHistoryStore records the arguments of the last RecentPosts call.
+type recordingHistoryStore struct {
+	since time.Time
+	limit int
+}
+
+func (s *recordingHistoryStore) RecentPosts(hashtags []string, since time.Time, limit int) []*Message {
+	s.since, s.limit = since, limit
+	return nil
+}
+
+func (s *recordingHistoryStore) GetPost(id string) (*Message, bool) {
+	return nil, false
+}
+
+// wrongPostStore answers every request by ID with the same post.
+type wrongPostStore struct {
+	post *Message
+}
+
+func (s *wrongPostStore) RecentPosts(hashtags []string, since time.Time, limit int) []*Message {
+	return nil
+}
+
+func (s *wrongPostStore) GetPost(id string) (*Message, bool) {
+	return s.post, true
+}
+
+// TestHistorySync tests that synced posts are verified and merged without duplicates.
+func TestHistorySync(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+
+	newPost := func(id string, hashtags []string, age time.Duration) *Message {
+		msg := &Message{
+			ID:        id,
+			Author:    "test-author",
+			Content:   "Post " + id,
+			Hashtags:  hashtags,
+			Timestamp: time.Now().Add(-age),
+			Type:      PostMsg,
+		}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		return msg
+	}
+
+	shared := newPost("shared", []string{"general"}, 3*time.Hour)
+	tampered := newPost("tampered", []string{"general"}, time.Hour)
+	tampered.Content = "Not what was signed"
+	servers := []*fakeHistoryStore{
+		{posts: []*Message{
+			shared,
+			tampered,
+			newPost("other-tag", []string{"random"}, time.Hour),
+			newPost("too-old", []string{"general"}, 48*time.Hour),
+		}},
+		{posts: []*Message{
+			shared,
+			newPost("newest", []string{"general", "go"}, time.Minute),
+		}},
+	}
+
+	mn := mocknet.New()
+	defer mn.Close()
+	newHost := func() host.Host {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatalf("Failed to create mock host: %v", err)
+		}
+		return h
+	}
+
+	peers := make([]peer.ID, 0, len(servers))
+	for _, store := range servers {
+		h := newHost()
+		NewHistorySync(h, store, cfg, NewValidator(cfg, keyPair))
+		peers = append(peers, h.ID())
+	}
+	client := NewHistorySync(newHost(), &fakeHistoryStore{}, cfg, NewValidator(cfg, keyPair))
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	posts := client.Sync(ctx, peers, []string{"general"})
+
+	var ids []string
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	want := []string{"shared", "newest"}
+	if len(ids) != len(want) {
+		t.Fatalf("Sync() returned posts %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("Sync() returned posts %v, want %v (oldest first)", ids, want)
+			break
+		}
+	}
+
+	// Nothing is requested when sync is disabled
+	disabled := config.DefaultConfig()
+	disabled.Sync.EnableHistorySync = false
+	client.cfg = disabled
+	if posts := client.Sync(ctx, peers, []string{"general"}); len(posts) != 0 {
+		t.Errorf("Sync() with history sync disabled returned %d posts, want 0", len(posts))
+	}
+}
+
+// TestHistorySyncServeLimits tests that requests are clamped to the configured window and count.
+func TestHistorySyncServeLimits(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+	cfg.Sync.Window = time.Hour
+	cfg.Sync.MaxPosts = 2
+
+	store := &recordingHistoryStore{}
+	mn := mocknet.New()
+	defer mn.Close()
+	h, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	s := NewHistorySync(h, store, cfg, NewValidator(cfg, nil))
+
+	s.serve("", p2p.SyncRequest{Hashtags: []string{"general"}, Since: time.Now().Add(-24 * time.Hour), Limit: 1000})
+	if time.Since(store.since) > time.Hour+time.Minute {
+		t.Errorf("serve() asked the store for posts since %v, want at most an hour ago", store.since)
+	}
+	if store.limit != 2 {
+		t.Errorf("serve() asked the store for %d posts, want 2", store.limit)
+	}
+}
+
+// TestHistorySyncServeEncrypted tests that with encryption on, posts
+// encrypted for our key are never served, whoever's name they carry, while
+// everyone else's plaintext posts are.
+func TestHistorySyncServeEncrypted(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	other, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = true
+
+	mn := mocknet.New()
+	defer mn.Close()
+	h, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	newPost := func(id, author string, keyPair *crypto.KeyPair) *Message {
+		msg := &Message{ID: id, Author: author, Content: "Post " + id, Hashtags: []string{"general"}, Timestamp: time.Now(), Type: PostMsg}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		return msg
+	}
+	store := &fakeHistoryStore{posts: []*Message{
+		newPost("ours", h.ID().String(), keyPair),
+		// Written by another node running with our key
+		newPost("our-other-node", "other-node", keyPair),
+		newPost("theirs", "other-peer", other),
+	}}
+	s := NewHistorySync(h, store, cfg, NewValidator(cfg, keyPair))
+
+	served := s.serve("", p2p.SyncRequest{Hashtags: []string{"general"}})
+	if len(served) != 1 {
+		t.Fatalf("serve() returned %d posts, want only the other peer's", len(served))
+	}
+	var got Message
+	if err := json.Unmarshal(served[0], &got); err != nil || got.ID != "theirs" {
+		t.Errorf("serve() returned %s, want the post %q", served[0], "theirs")
+	}
+}
+
+// TestHistorySyncFetch tests that posts can be fetched by ID and that only
+// the requested posts are accepted.
+func TestHistorySyncFetch(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+
+	newPost := func(id string, age time.Duration) *Message {
+		msg := &Message{
+			ID:        id,
+			Author:    "test-author",
+			Content:   "Post " + id,
+			Hashtags:  []string{"general"},
+			Timestamp: time.Now().Add(-age),
+			Type:      PostMsg,
+		}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		return msg
+	}
+
+	mn := mocknet.New()
+	defer mn.Close()
+	newHost := func() host.Host {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatalf("Failed to create mock host: %v", err)
+		}
+		return h
+	}
+
+	server := newHost()
+	NewHistorySync(server, &fakeHistoryStore{posts: []*Message{
+		newPost("root", 2*time.Hour),
+		newPost("parent", time.Hour),
+		newPost("unrelated", time.Minute),
+	}}, cfg, NewValidator(cfg, keyPair))
+	client := NewHistorySync(newHost(), &fakeHistoryStore{}, cfg, NewValidator(cfg, keyPair))
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	posts := client.Fetch(ctx, []peer.ID{server.ID()}, []string{"parent", "root", "missing"})
+	if len(posts) != 2 || posts[0].ID != "root" || posts[1].ID != "parent" {
+		t.Fatalf("Fetch() returned %d posts, want root and parent, oldest first", len(posts))
+	}
+
+	// A peer answering with posts we didn't ask for is ignored
+	liar := newHost()
+	NewHistorySync(liar, &wrongPostStore{post: newPost("unrelated", time.Minute)}, cfg, NewValidator(cfg, keyPair))
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+	if posts := client.Fetch(ctx, []peer.ID{liar.ID()}, []string{"parent"}); len(posts) != 0 {
+		t.Errorf("Fetch() accepted %d posts that weren't requested, want 0", len(posts))
+	}
+	if posts := client.Fetch(ctx, []peer.ID{server.ID()}, []string{"missing"}); len(posts) != 0 {
+		t.Errorf("Fetch() of a missing post returned %d posts, want 0", len(posts))
+	}
+}
@@ -1,12 +1,45 @@
 package messaging
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
 	// HashtagTopicPrefix is the prefix for all hashtag-based topics.
 	HashtagTopicPrefix = "socli/hashtag/"
+	// MetaTopicPrefix is the prefix for topics carrying network metadata instead of posts.
+	MetaTopicPrefix = "socli/meta/"
+	// DirectoryTopic carries the hashtag announcements behind the topic directory.
+	DirectoryTopic = MetaTopicPrefix + "directory"
+	// PresenceTopic carries presence heartbeats.
+	PresenceTopic = MetaTopicPrefix + "presence"
+	// InboxTopicPrefix is the prefix for each peer's inbox topic, which carries
+	// the posts that mention the peer.
+	InboxTopicPrefix = MetaTopicPrefix + "inbox/"
 )
 
+// metaTopicTypes maps each meta topic to the only message type allowed on it.
+// Meta message types are not allowed on any other topic.
+var metaTopicTypes = map[string]MsgType{
+	DirectoryTopic: AnnounceMsg,
+	PresenceTopic:  PresenceMsg,
+}
+
+// GetInboxTopic returns the inbox topic of a peer.
+func GetInboxTopic(peerID string) string {
+	return InboxTopicPrefix + peerID
+}
+
+// inboxOwner returns the peer whose inbox a topic is, if it is an inbox topic.
+func inboxOwner(topic string) (string, bool) {
+	owner, ok := strings.CutPrefix(topic, InboxTopicPrefix)
+	if !ok || owner == "" {
+		return "", false
+	}
+	return owner, true
+}
+
 // GetTopicForHashtag returns the full topic string for a given hashtag.
 func GetTopicForHashtag(hashtag string) string {
 	return fmt.Sprintf("%s%s", HashtagTopicPrefix, hashtag)
@@ -46,4 +46,24 @@ func TestGetTopicForHashtag(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// TestInboxOwner tests finding the peer an inbox topic belongs to.
+func TestInboxOwner(t *testing.T) {
+	tests := []struct {
+		topic  string
+		owner  string
+		wantOK bool
+	}{
+		{GetInboxTopic("peer-a"), "peer-a", true},
+		{InboxTopicPrefix, "", false},
+		{GetTopicForHashtag("peer-a"), "", false},
+		{PresenceTopic, "", false},
+	}
+	for _, tt := range tests {
+		owner, ok := inboxOwner(tt.topic)
+		if owner != tt.owner || ok != tt.wantOK {
+			t.Errorf("inboxOwner(%q) = %q, %t, want %q, %t", tt.topic, owner, ok, tt.owner, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package messaging
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"socli/config"
+	"socli/crypto"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// maxClockSkew is how far in the future a message timestamp may be.
+	maxClockSkew = 5 * time.Minute
+	// maxMessageAge is how old a message may be when it arrives over pubsub.
+	maxMessageAge = time.Hour
+	// maxAnnouncedTopics is the most hashtags one directory announcement may list.
+	maxAnnouncedTopics = 50
+	// maxHashtagLength is the longest hashtag accepted in an announcement.
+	maxHashtagLength = 64
+	// maxMessageIDLength is the longest message ID a reply may refer to.
+	maxMessageIDLength = 128
+	// MaxMentions is the most peers one message may mention.
+	MaxMentions = 10
+	// maxStatusTextLength is the longest status text a presence heartbeat may carry.
+	maxStatusTextLength = 80
+	// maxSpacingKeys is how many authors a spacing limiter tracks before pruning.
+	maxSpacingKeys = 1024
+)
+
+// errUnreadable is returned by DecodeMessage when the payload is neither
+// valid JSON nor encrypted for our key.
+var errUnreadable = errors.New("message payload could not be decoded")
+
+// Validator checks messages on socli topics before GossipSub forwards them.
+// Rejected messages are never propagated and count as invalid deliveries
+// against the sending peer's score.
+type Validator struct {
+	cfg     *config.Config
+	keyPair *crypto.KeyPair
+	now     func() time.Time // Overridable for tests
+
+	// announceSpacing limits how often one author's directory announcements are relayed.
+	announceSpacing *spacing
+	// heartbeatSpacing limits how often one peer's presence heartbeats are relayed.
+	heartbeatSpacing *spacing
+	// limits are the per-peer and per-author token buckets; nil when disabled.
+	limits *RateLimits
+	// circles opens and checks posts on the circles we are a member of; may be nil.
+	circles *Circles
+	// posts finds the posts that edits revise; may be nil.
+	posts PostLookup
+	// signers binds each author to the signing key it first published with.
+	signers *signers
+}
+
+// NewValidator creates a validator that decodes messages with the given key pair.
+func NewValidator(cfg *config.Config, keyPair *crypto.KeyPair) *Validator {
+	return &Validator{
+		cfg:     cfg,
+		keyPair: keyPair,
+		now:     time.Now,
+		// Allow for gossip delays bunching up announcements sent at the minimum interval
+		announceSpacing:  newSpacing(MinAnnounceInterval / 2),
+		heartbeatSpacing: newSpacing(MinHeartbeatInterval / 2),
+		limits:           NewRateLimits(cfg),
+		signers:          newSigners(),
+	}
+}
+
+// SetCircles makes the validator open posts on our circles' topics with
+// their group keys and reject posts from non-members.
+func (v *Validator) SetCircles(circles *Circles) {
+	v.circles = circles
+}
+
+// SetPosts makes the validator reject edits of stored posts that aren't
+// signed by the post's author. Edits of posts we don't have are accepted,
+// and checked again by the store when the post arrives.
+func (v *Validator) SetPosts(posts PostLookup) {
+	v.posts = posts
+}
+
+// Validate implements pubsub.ValidatorEx. Accepted messages carry the decoded
+// *Message in ValidatorData, so subscribers don't have to decode them again.
+func (v *Validator) Validate(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	origin := peer.ID(msg.GetFrom())
+	data := msg.Data
+	if opened, isCircle, ok := v.circles.open(msg.GetTopic(), data); isCircle {
+		switch {
+		case ok:
+			data = opened
+		case len(data) > 0 && data[0] == circleMarker:
+			// Sealed with a key we don't have, e.g. one rotated after we were removed
+			return pubsub.ValidationIgnore
+		default:
+			log.Printf("Validator: Rejecting unsealed message on circle topic %s from %s", msg.GetTopic(), origin.String())
+			return pubsub.ValidationReject
+		}
+	}
+
+	decoded, err := DecodeMessage(data, v.cfg, v.keyPair)
+	if err != nil {
+		if errors.Is(err, errSealed) {
+			// Posts of a circle we aren't a member of are none of our business
+			return pubsub.ValidationIgnore
+		}
+		if errors.Is(err, errUnreadable) && v.cfg.Privacy.EncryptMessages {
+			// Without a key of its own, as on a headless node, every encrypted
+			// post is opaque. Relay it within its origin's rate limit, so
+			// encrypted posts still cross the node.
+			if v.keyPair == nil {
+				if v.limits.Take(origin.String(), origin.String(), v.now()) != RateAllow {
+					return pubsub.ValidationIgnore
+				}
+				return pubsub.ValidationAccept
+			}
+			// With encryption on, a payload encrypted for another key is indistinguishable
+			// from garbage. Don't forward it, but don't penalize the sender either.
+			return pubsub.ValidationIgnore
+		}
+		log.Printf("Validator: Rejecting message from %s: %v", from.String(), err)
+		return pubsub.ValidationReject
+	}
+
+	// A newer schema may carry fields we can't verify the signature of, so
+	// don't relay it, but don't penalize the newer peer either
+	if decoded.Version > SchemaVersion {
+		log.Printf("Validator: Ignoring message %s from %s with newer schema %d", decoded.ID, from.String(), decoded.Version)
+		return pubsub.ValidationIgnore
+	}
+	// An expired message is dropped without relaying it. It may just have
+	// been delayed on the way, so the sender isn't penalized.
+	if decoded.Expired(v.now()) {
+		return pubsub.ValidationIgnore
+	}
+	// The embedded key only proves who signed the message, not who it is
+	// from. GossipSub signs every message with the origin's host key, so a
+	// peer may only publish under its own peer ID.
+	if decoded.Author != origin.String() {
+		log.Printf("Validator: Rejecting message %s by %s published by %s", decoded.ID, decoded.Author, origin.String())
+		return pubsub.ValidationReject
+	}
+	if err := v.checkTopic(decoded, msg); err != nil {
+		log.Printf("Validator: Rejecting message %s from %s: %v", decoded.ID, from.String(), err)
+		return pubsub.ValidationReject
+	}
+	if err := v.check(decoded, maxMessageAge); err != nil {
+		log.Printf("Validator: Rejecting message %s from %s: %v", decoded.ID, from.String(), err)
+		return pubsub.ValidationReject
+	}
+	// One peer, one key: otherwise a peer could mint keys to vote or react
+	// once per key
+	if err := v.signers.bind(decoded.Author, decoded.PublicKey); err != nil {
+		log.Printf("Validator: Rejecting message %s from %s: %v", decoded.ID, from.String(), err)
+		return pubsub.ValidationReject
+	}
+	if err := v.circles.check(msg.GetTopic(), decoded, origin); err != nil {
+		log.Printf("Validator: Rejecting message %s from %s: %v", decoded.ID, from.String(), err)
+		return pubsub.ValidationReject
+	}
+
+	// Too frequent announcements are dropped, but not penalized, since
+	// gossip delays can bunch up announcements that were sent far enough apart
+	if decoded.Type == AnnounceMsg && !v.announceSpacing.allow(string(decoded.PublicKey), v.now()) {
+		return pubsub.ValidationIgnore
+	}
+	if decoded.Type == PresenceMsg && !v.heartbeatSpacing.allow(decoded.Author, v.now()) {
+		return pubsub.ValidationIgnore
+	}
+
+	// Flood protection per publishing peer and per signing key. Only the
+	// signed origin is limited, not the peer that forwarded the message,
+	// since mesh peers relay everyone's posts.
+	switch v.limits.Take(origin.String(), hex.EncodeToString(decoded.PublicKey), v.now()) {
+	case RateThrottle:
+		return pubsub.ValidationIgnore
+	case RateBlock:
+		// Penalize a blocked peer that sends to us directly, but not the
+		// peers that relay its messages
+		if from == origin {
+			return pubsub.ValidationReject
+		}
+		return pubsub.ValidationIgnore
+	}
+
+	msg.ValidatorData = decoded
+	return pubsub.ValidationAccept
+}
+
+// check applies the content rules to a decoded message.
+// maxAge is how old the message may be; history sync allows older posts than pubsub.
+func (v *Validator) check(msg *Message, maxAge time.Duration) error {
+	if msg.ID == "" {
+		return errors.New("missing message ID")
+	}
+	if msg.Version > SchemaVersion {
+		return fmt.Errorf("message schema %d is newer than ours (%d)", msg.Version, SchemaVersion)
+	}
+
+	if maxLength := v.cfg.UI.MaxPostLength; maxLength > 0 && utf8.RuneCountInString(msg.Content) > maxLength {
+		return fmt.Errorf("content is %d characters, limit is %d", utf8.RuneCountInString(msg.Content), maxLength)
+	}
+
+	now := v.now()
+	if msg.Timestamp.After(now.Add(maxClockSkew)) {
+		return fmt.Errorf("timestamp %s is in the future", msg.Timestamp.Format(time.RFC3339))
+	}
+	if msg.Timestamp.Before(now.Add(-maxAge)) {
+		return fmt.Errorf("timestamp %s is too old", msg.Timestamp.Format(time.RFC3339))
+	}
+	if msg.ExpiresAt != nil && !msg.ExpiresAt.After(msg.Timestamp) {
+		return errors.New("message expires before it was sent")
+	}
+	if msg.Expired(now) {
+		return fmt.Errorf("message expired at %s", msg.ExpiresAt.Format(time.RFC3339))
+	}
+
+	if msg.Attachment != nil {
+		if err := msg.Attachment.validate(v.cfg.Attachments.MaxSize); err != nil {
+			return err
+		}
+	}
+	if (msg.Type == ReplyMsg || msg.Type == ReactionMsg || msg.Type == EditMsg || msg.Type == VoteMsg || msg.Type == PollCloseMsg) && (msg.ReplyTo == "" || msg.ReplyTo == msg.ID) {
+		return fmt.Errorf("%s without a parent", msg.Type)
+	}
+	if msg.Type == ReactionMsg && (!ValidReaction(msg.Content) || msg.Attachment != nil) {
+		return fmt.Errorf("invalid reaction %q", msg.Content)
+	}
+	if len(msg.ReplyTo) > maxMessageIDLength {
+		return fmt.Errorf("reply_to is longer than %d characters", maxMessageIDLength)
+	}
+	if msg.Type == ShareMsg || msg.Shared != nil {
+		if err := v.checkShared(msg); err != nil {
+			return err
+		}
+	}
+	if msg.Type == EditMsg {
+		if err := v.checkEdit(msg); err != nil {
+			return err
+		}
+	}
+	if msg.Type == PollMsg || msg.Type == VoteMsg || msg.Type == PollCloseMsg || msg.Poll != nil {
+		if err := v.checkPoll(msg); err != nil {
+			return err
+		}
+	}
+	if len(msg.Mentions) > MaxMentions {
+		return fmt.Errorf("message mentions %d peers, limit is %d", len(msg.Mentions), MaxMentions)
+	}
+	for _, mention := range msg.Mentions {
+		if _, err := peer.Decode(mention); err != nil {
+			return fmt.Errorf("invalid mention %q", mention)
+		}
+	}
+
+	if !msg.VerifySignature() {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
+
+// checkShared applies the content rules to the post embedded in a share.
+// The original may be older than messages we otherwise accept, but it must
+// carry its author's valid signature.
+func (v *Validator) checkShared(msg *Message) error {
+	shared := msg.Shared
+	switch {
+	case msg.Type != ShareMsg:
+		return fmt.Errorf("message type %q can't embed a post", msg.Type)
+	case shared == nil:
+		return errors.New("share without a shared post")
+	case shared.Type != PostMsg && shared.Type != ReplyMsg:
+		return fmt.Errorf("can't share a message of type %q", shared.Type)
+	case shared.ID == "" || shared.ID == msg.ID || len(shared.ID) > maxMessageIDLength:
+		return fmt.Errorf("invalid shared post ID %q", shared.ID)
+	case shared.Version > SchemaVersion:
+		return fmt.Errorf("shared post schema %d is newer than ours (%d)", shared.Version, SchemaVersion)
+	case shared.Circle != nil:
+		return errors.New("shared post carries a circle update")
+	case shared.ExpiresAt != nil:
+		return errors.New("expiring posts can't be shared")
+	}
+
+	if maxLength := v.cfg.UI.MaxPostLength; maxLength > 0 && utf8.RuneCountInString(shared.Content) > maxLength {
+		return fmt.Errorf("shared post is %d characters, limit is %d", utf8.RuneCountInString(shared.Content), maxLength)
+	}
+	if shared.Timestamp.After(v.now().Add(maxClockSkew)) {
+		return fmt.Errorf("shared post timestamp %s is in the future", shared.Timestamp.Format(time.RFC3339))
+	}
+	if shared.Attachment != nil {
+		if err := shared.Attachment.validate(v.cfg.Attachments.MaxSize); err != nil {
+			return err
+		}
+	}
+	if !shared.VerifySignature() {
+		return errors.New("invalid signature on shared post")
+	}
+	return v.signers.check(shared.Author, shared.PublicKey)
+}
+
+// checkEdit checks an edit against the post it revises, if we have it.
+func (v *Validator) checkEdit(msg *Message) error {
+	if msg.Content == "" || msg.Attachment != nil || msg.Shared != nil {
+		return errors.New("an edit only replaces a post's text")
+	}
+	if v.posts == nil {
+		return nil
+	}
+	if original, ok := v.posts.GetPost(msg.ReplyTo); ok {
+		return CheckEdit(original, msg)
+	}
+	return nil
+}
+
+// checkPoll checks polls, votes and closings. Votes and closings are also
+// checked against their poll, if we have it.
+func (v *Validator) checkPoll(msg *Message) error {
+	if msg.Attachment != nil || msg.Shared != nil {
+		return fmt.Errorf("%s can't carry an attachment or a shared post", msg.Type)
+	}
+	switch msg.Type {
+	case PollMsg:
+		if msg.Poll == nil || msg.Content == "" {
+			return errors.New("poll without a question or options")
+		}
+		return msg.Poll.validate()
+	case VoteMsg, PollCloseMsg:
+		if msg.Poll != nil {
+			return fmt.Errorf("%s can't carry poll options", msg.Type)
+		}
+	default:
+		return fmt.Errorf("message type %q can't carry poll options", msg.Type)
+	}
+	if v.posts == nil {
+		return nil
+	}
+	poll, ok := v.posts.GetPost(msg.ReplyTo)
+	if !ok {
+		return nil
+	}
+	if msg.Type == VoteMsg {
+		return CheckVote(poll, msg)
+	}
+	return CheckPollClose(poll, msg)
+}
+
+// checkTopic makes sure meta topics only carry their own message type, and
+// that meta messages don't appear on post topics.
+func (v *Validator) checkTopic(msg *Message, psMsg *pubsub.Message) error {
+	topic := psMsg.GetTopic()
+	if want, ok := metaTopicTypes[topic]; ok {
+		if msg.Type != want {
+			return fmt.Errorf("message type %q not allowed on %s", msg.Type, topic)
+		}
+	} else {
+		for _, metaType := range metaTopicTypes {
+			if msg.Type == metaType {
+				return fmt.Errorf("message type %q not allowed on %s", msg.Type, topic)
+			}
+		}
+	}
+
+	// An inbox only takes posts that mention its owner, so nobody can fill
+	// it with posts meant for someone else
+	if owner, ok := inboxOwner(topic); ok && !slices.Contains(msg.Mentions, owner) {
+		return fmt.Errorf("message on %s doesn't mention %s", topic, owner)
+	}
+
+	if msg.Type == AnnounceMsg {
+		if len(msg.Topics) > maxAnnouncedTopics {
+			return fmt.Errorf("announcement lists %d hashtags, limit is %d", len(msg.Topics), maxAnnouncedTopics)
+		}
+		for _, activity := range msg.Topics {
+			if activity.Hashtag == "" || len(activity.Hashtag) > maxHashtagLength || activity.Posts < 0 {
+				return fmt.Errorf("invalid announced hashtag %q", activity.Hashtag)
+			}
+		}
+	}
+	if msg.Type == PresenceMsg {
+		if _, ok := ParsePresenceStatus(string(msg.Status)); !ok {
+			return fmt.Errorf("invalid presence status %q", msg.Status)
+		}
+		if utf8.RuneCountInString(msg.Content) > maxStatusTextLength {
+			return fmt.Errorf("status text is longer than %d characters", maxStatusTextLength)
+		}
+	}
+	return nil
+}
+
+// spacing enforces a minimum gap between messages with the same key.
+type spacing struct {
+	mu   sync.Mutex
+	gap  time.Duration
+	last map[string]time.Time
+}
+
+// newSpacing creates a spacing limiter with the given minimum gap.
+func newSpacing(gap time.Duration) *spacing {
+	return &spacing{gap: gap, last: make(map[string]time.Time)}
+}
+
+// allow reports whether a message with key may pass at time now, and records it if so.
+func (s *spacing) allow(key string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.last[key]; ok && now.Sub(last) < s.gap {
+		return false
+	}
+	s.last[key] = now
+
+	// Forget keys that can no longer be limited, so the map doesn't grow forever
+	if len(s.last) > maxSpacingKeys {
+		for k, last := range s.last {
+			if now.Sub(last) >= s.gap {
+				delete(s.last, k)
+			}
+		}
+	}
+	return true
+}
+
+// signers remembers the signing key of each author seen over pubsub, where
+// the author is the authenticated origin, so messages that only carry an
+// author's name, like synced or shared posts, can't claim it with another key.
+type signers struct {
+	mu   sync.Mutex
+	keys map[string]string // Signing keys keyed by author
+}
+
+// newSigners creates an empty author to key binding.
+func newSigners() *signers {
+	return &signers{keys: make(map[string]string)}
+}
+
+// bind records the key an author published with, or returns an error if the
+// author already published with another key.
+func (s *signers) bind(author string, publicKey []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if known, ok := s.keys[author]; ok && known != string(publicKey) {
+		return fmt.Errorf("%s signed with a different key before", author)
+	}
+	s.keys[author] = string(publicKey)
+	return nil
+}
+
+// check returns an error if the author is known to sign with another key.
+// Authors we haven't seen publish yet can't be checked.
+func (s *signers) check(author string, publicKey []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if known, ok := s.keys[author]; ok && known != string(publicKey) {
+		return fmt.Errorf("not signed with the key %s publishes with", author)
+	}
+	return nil
+}
+
+// DecodeMessage turns raw pubsub data into a Message, decrypting it first if
+// encryption is enabled and the payload was encrypted for our key. Both the
+// JSON and the binary wire format are accepted, told apart by the first byte.
+func DecodeMessage(data []byte, cfg *config.Config, keyPair *crypto.KeyPair) (*Message, error) {
+	if cfg.Privacy.EncryptMessages && keyPair != nil && len(data) > 24 {
+		if decrypted, ok := crypto.Decrypt(data, keyPair.PublicKey, keyPair.PrivateKey); ok {
+			data = decrypted
+		}
+	}
+
+	if len(data) > 0 && data[0] == circleMarker {
+		return nil, errSealed
+	}
+	if len(data) > 0 && data[0] == WireBinaryV1 {
+		msg, err := decodeBinary(data)
+		if err != nil {
+			// Payloads encrypted for another key may start with the same byte
+			return nil, fmt.Errorf("%w: %v", errUnreadable, err)
+		}
+		return msg, nil
+	}
+
+	if !json.Valid(data) {
+		return nil, errUnreadable
+	}
+
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
@@ -0,0 +1,776 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"socli/config"
+	"socli/crypto"
+	"strings"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+)
+
+// testAuthor is the peer that publishes the test messages.
+var testAuthor = peer.ID("test-author")
+
+// TestValidatorValidate tests that the validator accepts signed messages and
+// rejects forged ones, including those published under another peer's name.
+func TestValidatorValidate(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+	validator := NewValidator(cfg, keyPair)
+
+	newPubSubMessage := func(msg *Message) *pubsub.Message {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		return &pubsub.Message{Message: &pb.Message{Data: data, From: []byte(testAuthor)}}
+	}
+
+	msg := &Message{
+		ID:        "validator-id",
+		Author:    testAuthor.String(),
+		Content:   "This is a test message",
+		Hashtags:  []string{"test"},
+		Timestamp: time.Now(),
+		Type:      PostMsg,
+	}
+	if err := msg.Sign(keyPair); err != nil {
+		t.Fatalf("Sign() error = %v, want nil", err)
+	}
+
+	t.Run("ValidSignature", func(t *testing.T) {
+		if got := validator.Validate(context.Background(), "", newPubSubMessage(msg)); got != pubsub.ValidationAccept {
+			t.Errorf("Validate() = %v, want ValidationAccept", got)
+		}
+	})
+
+	t.Run("TamperedContent", func(t *testing.T) {
+		tampered := *msg
+		tampered.Content = "This is not what was signed"
+		if got := validator.Validate(context.Background(), "", newPubSubMessage(&tampered)); got != pubsub.ValidationReject {
+			t.Errorf("Validate() = %v, want ValidationReject", got)
+		}
+	})
+
+	t.Run("Unsigned", func(t *testing.T) {
+		unsigned := *msg
+		unsigned.Signature = nil
+		unsigned.PublicKey = nil
+		if got := validator.Validate(context.Background(), "", newPubSubMessage(&unsigned)); got != pubsub.ValidationReject {
+			t.Errorf("Validate() = %v, want ValidationReject", got)
+		}
+	})
+
+	t.Run("SpoofedAuthor", func(t *testing.T) {
+		// Validly signed, but under the name of a peer that didn't publish it
+		spoofed := *msg
+		spoofed.Author = peer.ID("someone-else").String()
+		if err := spoofed.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		if got := validator.Validate(context.Background(), "", newPubSubMessage(&spoofed)); got != pubsub.ValidationReject {
+			t.Errorf("Validate() = %v, want ValidationReject", got)
+		}
+	})
+
+	t.Run("DecodedMessageAttached", func(t *testing.T) {
+		psMsg := newPubSubMessage(msg)
+		validator.Validate(context.Background(), "", psMsg)
+		decoded, ok := psMsg.ValidatorData.(*Message)
+		if !ok {
+			t.Fatalf("ValidatorData = %T, want *Message", psMsg.ValidatorData)
+		}
+		if decoded.ID != msg.ID || decoded.Content != msg.Content {
+			t.Errorf("ValidatorData = %+v, want %+v", decoded, msg)
+		}
+	})
+}
+
+// TestValidatorRejectsMalformed tests that malformed, oversized and stale messages are rejected.
+func TestValidatorRejectsMalformed(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+	validator := NewValidator(cfg, keyPair)
+
+	signed := func(content string, timestamp time.Time) []byte {
+		msg := &Message{
+			ID:        "malformed-id",
+			Author:    testAuthor.String(),
+			Content:   content,
+			Timestamp: timestamp,
+			Type:      PostMsg,
+		}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want pubsub.ValidationResult
+	}{
+		{"InvalidJSON", []byte("not json at all"), pubsub.ValidationReject},
+		{"WrongShape", []byte(`{"timestamp": 42}`), pubsub.ValidationReject},
+		{"TooLong", signed(strings.Repeat("a", cfg.UI.MaxPostLength+1), time.Now()), pubsub.ValidationReject},
+		{"MaxLength", signed(strings.Repeat("é", cfg.UI.MaxPostLength), time.Now()), pubsub.ValidationAccept},
+		{"FromTheFuture", signed("hello", time.Now().Add(time.Hour)), pubsub.ValidationReject},
+		{"TooOld", signed("hello", time.Now().Add(-2*time.Hour)), pubsub.ValidationReject},
+		{"SlightSkew", signed("hello", time.Now().Add(time.Minute)), pubsub.ValidationAccept},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			psMsg := &pubsub.Message{Message: &pb.Message{Data: tt.data, From: []byte(testAuthor)}}
+			if got := validator.Validate(context.Background(), "", psMsg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// With encryption on, unreadable data may be a payload encrypted for
+	// someone else, so it is ignored instead of rejected.
+	t.Run("UnreadableWithEncryption", func(t *testing.T) {
+		encCfg := config.DefaultConfig()
+		encCfg.Privacy.EncryptMessages = true
+		psMsg := &pubsub.Message{Message: &pb.Message{Data: []byte("opaque ciphertext for another key"), From: []byte(testAuthor)}}
+		if got := NewValidator(encCfg, keyPair).Validate(context.Background(), "", psMsg); got != pubsub.ValidationIgnore {
+			t.Errorf("Validate() = %v, want ValidationIgnore", got)
+		}
+		// A headless node has no key to read any of them with, so it relays them
+		if got := NewValidator(encCfg, nil).Validate(context.Background(), "", psMsg); got != pubsub.ValidationAccept {
+			t.Errorf("Validate() without a key = %v, want ValidationAccept", got)
+		}
+	})
+}
+
+// TestValidatorTopicRules tests that meta topics only carry their own message
+// type and that announcements are rate limited per author.
+func TestValidatorTopicRules(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+
+	newPubSubMessage := func(msgType MsgType, topics []TopicActivity, topic string) *pubsub.Message {
+		msg := &Message{
+			ID:        "topic-rules-" + string(msgType),
+			Author:    testAuthor.String(),
+			Timestamp: time.Now(),
+			Type:      msgType,
+			Topics:    topics,
+		}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		return &pubsub.Message{Message: &pb.Message{Data: data, Topic: &topic, From: []byte(testAuthor)}}
+	}
+	general := GetTopicForHashtag("general")
+	announced := []TopicActivity{{Hashtag: "general", Posts: 3}}
+	tooMany := make([]TopicActivity, maxAnnouncedTopics+1)
+	for i := range tooMany {
+		tooMany[i] = TopicActivity{Hashtag: "tag"}
+	}
+
+	tests := []struct {
+		name string
+		msg  *pubsub.Message
+		want pubsub.ValidationResult
+	}{
+		{"PostOnHashtagTopic", newPubSubMessage(PostMsg, nil, general), pubsub.ValidationAccept},
+		{"PostOnDirectoryTopic", newPubSubMessage(PostMsg, nil, DirectoryTopic), pubsub.ValidationReject},
+		{"AnnouncementOnHashtagTopic", newPubSubMessage(AnnounceMsg, announced, general), pubsub.ValidationReject},
+		{"TooManyHashtags", newPubSubMessage(AnnounceMsg, tooMany, DirectoryTopic), pubsub.ValidationReject},
+		{"EmptyHashtag", newPubSubMessage(AnnounceMsg, []TopicActivity{{Hashtag: ""}}, DirectoryTopic), pubsub.ValidationReject},
+		{"Announcement", newPubSubMessage(AnnounceMsg, announced, DirectoryTopic), pubsub.ValidationAccept},
+		// The same author announcing again right away is dropped without a penalty
+		{"AnnouncementTooSoon", newPubSubMessage(AnnounceMsg, announced, DirectoryTopic), pubsub.ValidationIgnore},
+	}
+
+	validator := NewValidator(cfg, keyPair)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validator.Validate(context.Background(), "", tt.msg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidatorPresenceRules tests that heartbeats must carry a valid status,
+// come from the peer they describe and are rate limited per peer.
+func TestValidatorPresenceRules(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+	origin := peer.ID("heartbeat-origin")
+
+	newHeartbeat := func(id string, author peer.ID, status PresenceStatus, text string) *pubsub.Message {
+		msg := &Message{
+			ID:        id,
+			Author:    author.String(),
+			Content:   text,
+			Timestamp: time.Now(),
+			Type:      PresenceMsg,
+			Status:    status,
+		}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		topic := PresenceTopic
+		return &pubsub.Message{Message: &pb.Message{Data: data, From: []byte(origin), Topic: &topic}}
+	}
+
+	tests := []struct {
+		name string
+		msg  *pubsub.Message
+		want pubsub.ValidationResult
+	}{
+		{"UnknownStatus", newHeartbeat("unknown-status", origin, "busy", ""), pubsub.ValidationReject},
+		{"StatusTextTooLong", newHeartbeat("long-text", origin, StatusAway, strings.Repeat("z", maxStatusTextLength+1)), pubsub.ValidationReject},
+		{"SpoofedAuthor", newHeartbeat("spoofed", peer.ID("someone-else"), StatusOnline, ""), pubsub.ValidationReject},
+		{"Heartbeat", newHeartbeat("heartbeat", origin, StatusAway, "lunch"), pubsub.ValidationAccept},
+		// The same peer sending another heartbeat right away is dropped without a penalty
+		{"HeartbeatTooSoon", newHeartbeat("heartbeat-again", origin, StatusOnline, ""), pubsub.ValidationIgnore},
+	}
+
+	validator := NewValidator(cfg, keyPair)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validator.Validate(context.Background(), "", tt.msg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidatorSchemaVersion tests that messages with a newer schema are
+// dropped without penalizing the peer that sent them.
+func TestValidatorSchemaVersion(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+
+	newPubSubMessage := func(id string, version int) *pubsub.Message {
+		msg := &Message{ID: id, Author: testAuthor.String(), Content: "hello #general", Timestamp: time.Now(), Type: PostMsg}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		// A newer peer would have signed its own version, but the
+		// validator must not get as far as checking the signature
+		if version != 0 {
+			msg.Version = version
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		topic := GetTopicForHashtag("general")
+		return &pubsub.Message{Message: &pb.Message{Data: data, Topic: &topic, From: []byte(testAuthor)}}
+	}
+
+	tests := []struct {
+		name string
+		msg  *pubsub.Message
+		want pubsub.ValidationResult
+	}{
+		{"SignedSchema", newPubSubMessage("signed", 0), pubsub.ValidationAccept},
+		{"NewerSchema", newPubSubMessage("newer", SchemaVersion+1), pubsub.ValidationIgnore},
+	}
+
+	validator := NewValidator(cfg, keyPair)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validator.Validate(context.Background(), "", tt.msg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidatorShareRules tests that shares must embed a post carrying its
+// author's valid signature.
+func TestValidatorShareRules(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+
+	original := func(id string, msgType MsgType, age time.Duration) *Message {
+		msg := &Message{ID: id, Author: "original-author", Content: "original #backend", Hashtags: []string{"backend"}, Timestamp: time.Now().Add(-age), Type: msgType}
+		if msgType == ReplyMsg {
+			msg.ReplyTo = "parent"
+		}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		return msg
+	}
+	newShare := func(id string, msgType MsgType, shared *Message) *pubsub.Message {
+		msg := &Message{ID: id, Author: testAuthor.String(), Content: "worth a look #frontend", Hashtags: []string{"frontend"}, Timestamp: time.Now(), Type: msgType, Shared: shared}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		topic := GetTopicForHashtag("frontend")
+		return &pubsub.Message{Message: &pb.Message{Data: data, Topic: &topic, From: []byte(testAuthor)}}
+	}
+
+	tampered := original("tampered", PostMsg, time.Minute)
+	tampered.Content = "Not what was signed"
+	nested := &Message{ID: "nested", Author: testAuthor.String(), Timestamp: time.Now(), Type: ShareMsg, Shared: original("inner", PostMsg, time.Minute)}
+	if err := nested.Sign(keyPair); err != nil {
+		t.Fatalf("Sign() error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name string
+		msg  *pubsub.Message
+		want pubsub.ValidationResult
+	}{
+		{"Share", newShare("share", ShareMsg, original("post", PostMsg, time.Minute)), pubsub.ValidationAccept},
+		{"SharedReply", newShare("shared-reply", ShareMsg, original("reply", ReplyMsg, time.Minute)), pubsub.ValidationAccept},
+		// Only the share needs to be recent, not the post it surfaces
+		{"OldOriginal", newShare("old-original", ShareMsg, original("old", PostMsg, 48*time.Hour)), pubsub.ValidationAccept},
+		{"TamperedOriginal", newShare("tampered-original", ShareMsg, tampered), pubsub.ValidationReject},
+		{"WithoutPost", newShare("without-post", ShareMsg, nil), pubsub.ValidationReject},
+		{"SharedShare", newShare("shared-share", ShareMsg, nested), pubsub.ValidationReject},
+		{"SharingItself", newShare("post", ShareMsg, original("post", PostMsg, time.Minute)), pubsub.ValidationReject},
+		{"PostEmbeddingPost", newShare("post-embedding", PostMsg, original("embedded", PostMsg, time.Minute)), pubsub.ValidationReject},
+	}
+
+	validator := NewValidator(cfg, keyPair)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validator.Validate(context.Background(), "", tt.msg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidatorReactionRules tests that reactions must name a post and carry
+// nothing but an emoji or shortcode.
+func TestValidatorReactionRules(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+
+	newReaction := func(id, replyTo, reaction string) *pubsub.Message {
+		msg := &Message{ID: id, Author: testAuthor.String(), Content: reaction, Hashtags: []string{"general"}, Timestamp: time.Now(), Type: ReactionMsg, ReplyTo: replyTo}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		topic := GetTopicForHashtag("general")
+		return &pubsub.Message{Message: &pb.Message{Data: data, Topic: &topic, From: []byte(testAuthor)}}
+	}
+
+	tests := []struct {
+		name string
+		msg  *pubsub.Message
+		want pubsub.ValidationResult
+	}{
+		{"Emoji", newReaction("emoji", "post", "🎉"), pubsub.ValidationAccept},
+		{"Shortcode", newReaction("shortcode", "post", ":+1:"), pubsub.ValidationAccept},
+		{"Text", newReaction("text", "post", "great post"), pubsub.ValidationReject},
+		{"WithoutPost", newReaction("without-post", "", "🎉"), pubsub.ValidationReject},
+	}
+
+	validator := NewValidator(cfg, keyPair)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validator.Validate(context.Background(), "", tt.msg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidatorEditRules tests that edits must name a post, only carry text,
+// and are rejected when they don't come from the author of a stored post.
+func TestValidatorEditRules(t *testing.T) {
+	author, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	other, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+
+	original := &Message{ID: "post", Author: testAuthor.String(), Content: "helo", Hashtags: []string{"general"}, Timestamp: time.Now().Add(-time.Minute), Type: PostMsg}
+	if err := original.Sign(author); err != nil {
+		t.Fatalf("Sign() error = %v, want nil", err)
+	}
+	newEdit := func(id, replyTo, content string, keyPair *crypto.KeyPair) *pubsub.Message {
+		msg := &Message{ID: id, Author: testAuthor.String(), Content: content, Hashtags: []string{"general"}, Timestamp: time.Now(), Type: EditMsg, ReplyTo: replyTo}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		topic := GetTopicForHashtag("general")
+		return &pubsub.Message{Message: &pb.Message{Data: data, Topic: &topic, From: []byte(testAuthor)}}
+	}
+
+	tests := []struct {
+		name string
+		msg  *pubsub.Message
+		want pubsub.ValidationResult
+	}{
+		{"Author", newEdit("author", "post", "hello", author), pubsub.ValidationAccept},
+		{"OtherKey", newEdit("other-key", "post", "hello", other), pubsub.ValidationReject},
+		{"UnknownPost", newEdit("unknown-post", "unknown", "hello", author), pubsub.ValidationAccept},
+		{"Empty", newEdit("empty", "post", "", author), pubsub.ValidationReject},
+		{"WithoutPost", newEdit("without-post", "", "hello", author), pubsub.ValidationReject},
+	}
+
+	validator := NewValidator(cfg, author)
+	validator.SetPosts(postMap{original.ID: original})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validator.Validate(context.Background(), "", tt.msg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidatorMentionRules tests that mentions must be peer IDs, and that an
+// inbox only takes posts that mention its owner.
+func TestValidatorMentionRules(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+	alice, bob := test.RandPeerIDFatal(t).String(), test.RandPeerIDFatal(t).String()
+
+	newPubSubMessage := func(id, topic string, mentions ...string) *pubsub.Message {
+		msg := &Message{ID: id, Author: testAuthor.String(), Content: "hello", Hashtags: []string{"general"}, Timestamp: time.Now(), Type: PostMsg, Mentions: mentions}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		return &pubsub.Message{Message: &pb.Message{Data: data, Topic: &topic, From: []byte(testAuthor)}}
+	}
+	tooMany := make([]string, MaxMentions+1)
+	for i := range tooMany {
+		tooMany[i] = alice
+	}
+
+	tests := []struct {
+		name string
+		msg  *pubsub.Message
+		want pubsub.ValidationResult
+	}{
+		{"Mention", newPubSubMessage("mention", GetTopicForHashtag("general"), alice), pubsub.ValidationAccept},
+		{"NotAPeerID", newPubSubMessage("not-a-peer-id", GetTopicForHashtag("general"), "alice"), pubsub.ValidationReject},
+		{"TooMany", newPubSubMessage("too-many", GetTopicForHashtag("general"), tooMany...), pubsub.ValidationReject},
+		{"Inbox", newPubSubMessage("inbox", GetInboxTopic(alice), bob, alice), pubsub.ValidationAccept},
+		{"OtherInbox", newPubSubMessage("other-inbox", GetInboxTopic(bob), alice), pubsub.ValidationReject},
+		{"InboxWithoutMention", newPubSubMessage("inbox-without-mention", GetInboxTopic(alice)), pubsub.ValidationReject},
+	}
+
+	validator := NewValidator(cfg, keyPair)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validator.Validate(context.Background(), "", tt.msg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidatorPollRules tests that polls need a question and distinct
+// options, and that votes and closings must fit the stored poll.
+func TestValidatorPollRules(t *testing.T) {
+	author, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	other, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+
+	poll := &Message{ID: "poll", Author: testAuthor.String(), Content: "Deploy now?", Hashtags: []string{"general"}, Timestamp: time.Now().Add(-time.Minute), Type: PollMsg, Poll: &Poll{Options: []string{"yes", "no"}}}
+	if err := poll.Sign(author); err != nil {
+		t.Fatalf("Sign() error = %v, want nil", err)
+	}
+	// The poll's author signs with author, and another peer with other
+	voter := peer.ID("voter")
+	newPubSubMessage := func(msg *Message, keyPair *crypto.KeyPair) *pubsub.Message {
+		origin := testAuthor
+		if keyPair == other {
+			origin = voter
+		}
+		msg.Author = origin.String()
+		msg.Hashtags = []string{"general"}
+		msg.Timestamp = time.Now()
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		topic := GetTopicForHashtag("general")
+		return &pubsub.Message{Message: &pb.Message{Data: data, Topic: &topic, From: []byte(origin)}}
+	}
+
+	tests := []struct {
+		name string
+		msg  *pubsub.Message
+		want pubsub.ValidationResult
+	}{
+		{"Poll", newPubSubMessage(&Message{ID: "new-poll", Content: "Lunch?", Type: PollMsg, Poll: &Poll{Options: []string{"pizza", "sushi"}}}, author), pubsub.ValidationAccept},
+		{"PollWithoutQuestion", newPubSubMessage(&Message{ID: "no-question", Type: PollMsg, Poll: &Poll{Options: []string{"pizza", "sushi"}}}, author), pubsub.ValidationReject},
+		{"PollWithOneOption", newPubSubMessage(&Message{ID: "one-option", Content: "Lunch?", Type: PollMsg, Poll: &Poll{Options: []string{"pizza"}}}, author), pubsub.ValidationReject},
+		{"PostWithOptions", newPubSubMessage(&Message{ID: "post-options", Content: "Lunch?", Type: PostMsg, Poll: &Poll{Options: []string{"pizza", "sushi"}}}, author), pubsub.ValidationReject},
+		{"Vote", newPubSubMessage(&Message{ID: "vote", Content: "yes", Type: VoteMsg, ReplyTo: "poll"}, other), pubsub.ValidationAccept},
+		{"VoteForUnknownOption", newPubSubMessage(&Message{ID: "unknown-option", Content: "maybe", Type: VoteMsg, ReplyTo: "poll"}, other), pubsub.ValidationReject},
+		{"VoteWithoutPoll", newPubSubMessage(&Message{ID: "without-poll", Content: "yes", Type: VoteMsg}, other), pubsub.ValidationReject},
+		{"Close", newPubSubMessage(&Message{ID: "close", Type: PollCloseMsg, ReplyTo: "poll"}, author), pubsub.ValidationAccept},
+		{"CloseByOtherKey", newPubSubMessage(&Message{ID: "close-other", Type: PollCloseMsg, ReplyTo: "poll"}, other), pubsub.ValidationReject},
+	}
+
+	validator := NewValidator(cfg, author)
+	validator.SetPosts(postMap{poll.ID: poll})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validator.Validate(context.Background(), "", tt.msg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidatorSignerBinding tests that an author keeps the signing key it
+// first published with, so it can't sign with extra keys, and messages that
+// only carry its name can't claim it with another key.
+func TestValidatorSignerBinding(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	minted, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+	validator := NewValidator(cfg, keyPair)
+
+	newMessage := func(id string, keyPair *crypto.KeyPair) *Message {
+		msg := &Message{ID: id, Author: testAuthor.String(), Content: "hello", Hashtags: []string{"general"}, Timestamp: time.Now(), Type: PostMsg}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		return msg
+	}
+	validate := func(msg *Message) pubsub.ValidationResult {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		topic := GetTopicForHashtag("general")
+		return validator.Validate(context.Background(), "", &pubsub.Message{Message: &pb.Message{Data: data, Topic: &topic, From: []byte(testAuthor)}})
+	}
+
+	if got := validate(newMessage("first", keyPair)); got != pubsub.ValidationAccept {
+		t.Errorf("Validate() of the first post = %v, want ValidationAccept", got)
+	}
+	if got := validate(newMessage("minted", minted)); got != pubsub.ValidationReject {
+		t.Errorf("Validate() with a second key = %v, want ValidationReject", got)
+	}
+
+	// History sync has no origin, so only the key tells a forgery apart
+	if err := validator.signers.check(testAuthor.String(), newMessage("synced", minted).PublicKey); err == nil {
+		t.Error("signers.check() of a post signed with another key = nil, want an error")
+	}
+	if err := validator.signers.check(peer.ID("stranger").String(), minted.PublicKey[:]); err != nil {
+		t.Errorf("signers.check() of an unknown author = %v, want nil", err)
+	}
+
+	// A share can't embed a post forged under a known author's name
+	share := &Message{ID: "share", Author: testAuthor.String(), Hashtags: []string{"general"}, Timestamp: time.Now(), Type: ShareMsg, Shared: newMessage("forged", minted)}
+	if err := share.Sign(keyPair); err != nil {
+		t.Fatalf("Sign() error = %v, want nil", err)
+	}
+	if got := validate(share); got != pubsub.ValidationReject {
+		t.Errorf("Validate() of a share of a forged post = %v, want ValidationReject", got)
+	}
+}
+
+// TestValidatorExpiryRules tests that expired posts are dropped without
+// penalty, both over pubsub and history sync, and can't be shared.
+func TestValidatorExpiryRules(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+	now := time.Now()
+
+	newMessage := func(id string, timestamp time.Time, ttl time.Duration) *Message {
+		expiresAt := timestamp.Add(ttl)
+		msg := &Message{ID: id, Author: testAuthor.String(), Content: "gone soon", Hashtags: []string{"general"}, Timestamp: timestamp, Type: PostMsg, ExpiresAt: &expiresAt}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		return msg
+	}
+	newPubSubMessage := func(msg *Message) *pubsub.Message {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		topic := GetTopicForHashtag("general")
+		return &pubsub.Message{Message: &pb.Message{Data: data, Topic: &topic, From: []byte(testAuthor)}}
+	}
+	share := &Message{ID: "share", Author: testAuthor.String(), Content: "look", Hashtags: []string{"general"}, Timestamp: now, Type: ShareMsg, Shared: newMessage("shared", now, 10*time.Minute)}
+	if err := share.Sign(keyPair); err != nil {
+		t.Fatalf("Sign() error = %v, want nil", err)
+	}
+	expired := newMessage("expired", now.Add(-20*time.Minute), 10*time.Minute)
+
+	tests := []struct {
+		name string
+		msg  *pubsub.Message
+		want pubsub.ValidationResult
+	}{
+		{"Expiring", newPubSubMessage(newMessage("expiring", now, 10*time.Minute)), pubsub.ValidationAccept},
+		{"Expired", newPubSubMessage(expired), pubsub.ValidationIgnore},
+		{"ExpiresBeforeSent", newPubSubMessage(newMessage("backwards", now.Add(30*time.Second), -10*time.Second)), pubsub.ValidationReject},
+		{"ShareOfExpiring", newPubSubMessage(share), pubsub.ValidationReject},
+	}
+
+	validator := NewValidator(cfg, keyPair)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validator.Validate(context.Background(), "", tt.msg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// History sync allows old posts, but not expired ones
+	if err := validator.check(expired, 24*time.Hour); err == nil {
+		t.Error("check() of an expired post = nil, want an error")
+	}
+}
+
+// TestValidatorRateLimits tests that a flooding peer is throttled, then
+// blocked, and only penalized for messages it sends directly.
+func TestValidatorRateLimits(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+	cfg.RateLimit.PeerRate = 1
+	cfg.RateLimit.PeerBurst = 2
+	cfg.RateLimit.BlockAfter = 2
+	origin := peer.ID("flooder")
+	relay := peer.ID("relay")
+
+	newPubSubMessage := func(id string) *pubsub.Message {
+		msg := &Message{ID: id, Author: origin.String(), Content: "spam #general", Timestamp: time.Now(), Type: PostMsg}
+		if err := msg.Sign(keyPair); err != nil {
+			t.Fatalf("Sign() error = %v, want nil", err)
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal message: %v", err)
+		}
+		topic := GetTopicForHashtag("general")
+		return &pubsub.Message{Message: &pb.Message{Data: data, From: []byte(origin), Topic: &topic}}
+	}
+
+	tests := []struct {
+		name string
+		from peer.ID
+		want pubsub.ValidationResult
+	}{
+		{"WithinBurst", origin, pubsub.ValidationAccept},
+		{"RelayedWithinBurst", relay, pubsub.ValidationAccept},
+		{"Throttled", origin, pubsub.ValidationIgnore},
+		{"Blocked", origin, pubsub.ValidationReject},
+		// Relays of a blocked peer's messages are dropped without a penalty
+		{"RelayedWhileBlocked", relay, pubsub.ValidationIgnore},
+	}
+
+	validator := NewValidator(cfg, keyPair)
+	now := time.Now()
+	validator.now = func() time.Time { return now }
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := newPubSubMessage(fmt.Sprintf("flood-%d", i))
+			if got := validator.Validate(context.Background(), tt.from, msg); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package messaging
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"socli/config"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Wire formats for the wire.format setting.
+const (
+	// WireJSON is the original encoding, readable by every socli build.
+	WireJSON = "json"
+	// WireBinary is CBOR behind a version byte, optionally compressed.
+	WireBinary = "binary"
+)
+
+// WireBinaryV1 is the leading byte of a message in the first binary format.
+// JSON messages always start with '{', so the two can be told apart.
+const WireBinaryV1 byte = 0x01
+
+const (
+	// wireFlagDeflate marks a binary payload compressed with DEFLATE.
+	wireFlagDeflate byte = 1 << 0
+	// maxInflatedBytes limits how large a compressed payload may become, so a
+	// small message can't expand into a huge one.
+	maxInflatedBytes = 1024 * 1024
+)
+
+// cborEncoding keeps timestamps as RFC 3339 strings with their zone offset,
+// so a decoded message re-encodes to the same JSON its signature covers.
+var cborEncoding, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+
+// EncodeMessage encodes a message in the wire format set in cfg. Binary
+// payloads larger than wire.compress_above bytes are compressed when that
+// makes them smaller.
+func EncodeMessage(msg *Message, cfg *config.Config) ([]byte, error) {
+	if cfg.Wire.Format != WireBinary {
+		return json.Marshal(msg)
+	}
+
+	payload, err := cborEncoding.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	flags := byte(0)
+	if cfg.Wire.CompressAbove > 0 && len(payload) > cfg.Wire.CompressAbove {
+		if compressed, err := deflate(payload); err == nil && len(compressed) < len(payload) {
+			payload = compressed
+			flags |= wireFlagDeflate
+		}
+	}
+	return append([]byte{WireBinaryV1, flags}, payload...), nil
+}
+
+// decodeBinary decodes a message in the binary wire format.
+func decodeBinary(data []byte) (*Message, error) {
+	if len(data) < 2 || data[0] != WireBinaryV1 {
+		return nil, errors.New("not a binary message")
+	}
+	flags, payload := data[1], data[2:]
+	if flags&^wireFlagDeflate != 0 {
+		return nil, fmt.Errorf("unknown wire flags %#x", flags)
+	}
+	if flags&wireFlagDeflate != 0 {
+		inflated, err := inflate(payload)
+		if err != nil {
+			return nil, err
+		}
+		payload = inflated
+	}
+
+	var msg Message
+	if err := cbor.Unmarshal(payload, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// deflate compresses data with DEFLATE.
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// inflate decompresses DEFLATE data of at most maxInflatedBytes.
+func inflate(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	inflated, err := io.ReadAll(io.LimitReader(r, maxInflatedBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(inflated) > maxInflatedBytes {
+		return nil, fmt.Errorf("compressed payload expands beyond %d bytes", maxInflatedBytes)
+	}
+	return inflated, nil
+}
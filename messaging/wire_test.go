@@ -0,0 +1,192 @@
+package messaging
+
+import (
+	"bytes"
+	"reflect"
+	"socli/config"
+	"socli/crypto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fullMessage returns a signed message with every field set, so the wire
+// tests notice fields the binary format loses.
+func fullMessage(t *testing.T) *Message {
+	t.Helper()
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	expiresAt := time.Date(2025, 3, 15, 15, 9, 26, 0, time.UTC)
+	msg := &Message{
+		ID:         "wire-test",
+		Author:     "test-author",
+		Content:    "hello **binary** #general #go",
+		Hashtags:   []string{"general", "go"},
+		Timestamp:  time.Date(2025, 3, 14, 15, 9, 26, 535897932, time.FixedZone("CET", 3600)),
+		Type:       PostMsg,
+		ReplyTo:    "parent-id",
+		Topics:     []TopicActivity{{Hashtag: "general", Posts: 3}},
+		Status:     StatusAway,
+		Attachment: &Attachment{Name: "notes.txt", Size: 5, Hash: strings.Repeat("a", 64), Chunks: []string{strings.Repeat("a", 64)}},
+		Circle: &CircleUpdate{
+			Name:     "incident",
+			Revision: 2,
+			Epoch:    1,
+			Members:  []CircleMember{{PeerID: "test-author", BoxKey: keyPair.PublicKey[:]}},
+			Keys:     map[string][]byte{"test-author": {1, 2, 3}},
+			BoxKey:   keyPair.PublicKey[:],
+		},
+		Shared: &Message{
+			ID:        "shared-post",
+			Author:    "original-author",
+			Content:   "the original",
+			Hashtags:  []string{"backend"},
+			Timestamp: time.Date(2025, 3, 13, 9, 0, 0, 0, time.UTC),
+			Type:      PostMsg,
+		},
+		Mentions:  []string{"mentioned-peer"},
+		Poll:      &Poll{Options: []string{"yes", "no"}},
+		ExpiresAt: &expiresAt,
+	}
+	if err := msg.Shared.Sign(keyPair); err != nil {
+		t.Fatalf("Sign() error = %v, want nil", err)
+	}
+	if err := msg.Sign(keyPair); err != nil {
+		t.Fatalf("Sign() error = %v, want nil", err)
+	}
+	// Make sure new Message fields get added above
+	v := reflect.ValueOf(*msg)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).IsZero() {
+			t.Fatalf("fullMessage() leaves Message.%s unset", v.Type().Field(i).Name)
+		}
+	}
+	return msg
+}
+
+// TestEncodeMessage tests that messages survive both wire formats with
+// their signatures intact, and that JSON stays the default.
+func TestEncodeMessage(t *testing.T) {
+	msg := fullMessage(t)
+
+	tests := []struct {
+		name          string
+		format        string
+		compressAbove int
+		wantFirst     byte
+		wantFlags     byte
+	}{
+		{"JSON", WireJSON, 0, '{', 0},
+		{"Binary", WireBinary, 0, WireBinaryV1, 0},
+		{"BinaryCompressed", WireBinary, 64, WireBinaryV1, wireFlagDeflate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Privacy.EncryptMessages = false
+			cfg.Wire.Format = tt.format
+			cfg.Wire.CompressAbove = tt.compressAbove
+
+			data, err := EncodeMessage(msg, cfg)
+			if err != nil {
+				t.Fatalf("EncodeMessage() error = %v, want nil", err)
+			}
+			if data[0] != tt.wantFirst {
+				t.Errorf("EncodeMessage() starts with %#x, want %#x", data[0], tt.wantFirst)
+			}
+			if tt.format == WireBinary && data[1] != tt.wantFlags {
+				t.Errorf("EncodeMessage() flags = %#x, want %#x", data[1], tt.wantFlags)
+			}
+
+			decoded, err := DecodeMessage(data, cfg, nil)
+			if err != nil {
+				t.Fatalf("DecodeMessage() error = %v, want nil", err)
+			}
+			if !decoded.VerifySignature() {
+				t.Error("VerifySignature() = false after decoding, want true")
+			}
+			if !reflect.DeepEqual(decoded.Attachment, msg.Attachment) || !decoded.Timestamp.Equal(msg.Timestamp) {
+				t.Errorf("DecodeMessage() = %+v, want %+v", decoded, msg)
+			}
+		})
+	}
+}
+
+// TestEncodeMessageSmaller tests that the binary format is smaller than JSON,
+// which base64-encodes the signature and public key.
+func TestEncodeMessageSmaller(t *testing.T) {
+	msg := fullMessage(t)
+	cfg := config.DefaultConfig()
+
+	cfg.Wire.Format = WireJSON
+	jsonData, err := EncodeMessage(msg, cfg)
+	if err != nil {
+		t.Fatalf("EncodeMessage() error = %v, want nil", err)
+	}
+	cfg.Wire.Format = WireBinary
+	binaryData, err := EncodeMessage(msg, cfg)
+	if err != nil {
+		t.Fatalf("EncodeMessage() error = %v, want nil", err)
+	}
+	if len(binaryData) >= len(jsonData) {
+		t.Errorf("binary message is %d bytes, want less than JSON's %d", len(binaryData), len(jsonData))
+	}
+}
+
+// TestEncodeMessageEncrypted tests that binary messages are decrypted before
+// their version byte is read.
+func TestEncodeMessageEncrypted(t *testing.T) {
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	msg := fullMessage(t)
+	cfg := config.DefaultConfig()
+	cfg.Wire.Format = WireBinary
+
+	data, err := EncodeMessage(msg, cfg)
+	if err != nil {
+		t.Fatalf("EncodeMessage() error = %v, want nil", err)
+	}
+	encrypted, err := crypto.Encrypt(data, keyPair.PublicKey, keyPair.PrivateKey)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v, want nil", err)
+	}
+	decoded, err := DecodeMessage(encrypted, cfg, keyPair)
+	if err != nil {
+		t.Fatalf("DecodeMessage() error = %v, want nil", err)
+	}
+	if decoded.ID != msg.ID {
+		t.Errorf("DecodeMessage() ID = %q, want %q", decoded.ID, msg.ID)
+	}
+}
+
+// TestDecodeBinaryMalformed tests that broken binary messages are unreadable.
+func TestDecodeBinaryMalformed(t *testing.T) {
+	bomb, err := deflate(bytes.Repeat([]byte{0}, maxInflatedBytes+1))
+	if err != nil {
+		t.Fatalf("deflate() error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"NoFlags", []byte{WireBinaryV1}},
+		{"UnknownFlags", []byte{WireBinaryV1, 0x80, 0xa0}},
+		{"NotCBOR", []byte{WireBinaryV1, 0, 0xff, 0xff}},
+		{"NotDeflate", []byte{WireBinaryV1, wireFlagDeflate, 0xff, 0xff}},
+		{"TooLargeInflated", append([]byte{WireBinaryV1, wireFlagDeflate}, bomb...)},
+	}
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeMessage(tt.data, cfg, nil); err == nil {
+				t.Error("DecodeMessage() error = nil, want an error")
+			}
+		})
+	}
+}
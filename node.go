@@ -0,0 +1,142 @@
+// node.go
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"socli/config"
+	"socli/internal"
+	"socli/messaging"
+	"socli/p2p"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// nodeStatusInterval is how often the headless node logs a status line.
+const nodeStatusInterval = time.Minute
+
+// runNode runs socli as a headless node: networking, DHT and pubsub without the TUI.
+// It is meant for always-on machines that keep the swarm reachable, optionally
+// acting as a relay and bootstrap peer for the team. It returns the exit code.
+func runNode(args []string) int {
+	fs := flag.NewFlagSet("node", flag.ExitOnError)
+	configPath := fs.String("config", internal.ConfigFileName, "Path to the configuration file")
+	relay := fs.Bool("relay", false, "Act as a circuit relay for peers behind NATs")
+	port := fs.Int("port", -1, "Port to listen on (overrides network.listen_port)")
+	identity := fs.String("identity", internal.DefaultNodeKeyFileName, "Path to the host key that keeps the peer ID stable")
+	topics := fs.String("topics", "general", "Comma-separated hashtags to join so gossip keeps flowing")
+	fs.Parse(args)
+
+	// There is no TUI to draw over, so logs go straight to stdout
+	log.SetOutput(os.Stdout)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Printf("Error loading configuration: %v\n", err)
+		return 1
+	}
+	if *relay {
+		cfg.Network.EnableRelayService = true
+	}
+	if *port >= 0 {
+		cfg.Network.ListenPort = *port
+	}
+	if *identity != "" {
+		cfg.Network.IdentityKeyPath = *identity
+	}
+
+	// Cancel everything on Ctrl+C or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	netManager, err := p2p.NewNetworkManager(cfg)
+	if err != nil {
+		log.Printf("Error creating network manager: %v\n", err)
+		return 1
+	}
+	defer func() {
+		if err := netManager.Close(); err != nil {
+			log.Printf("Error closing network manager: %v\n", err)
+		}
+	}()
+
+	// Answer hellos so peers know which version this node runs, and log
+	// peers that run a newer or incompatible one
+	handshake := messaging.NewHandshake(netManager.Host, Version)
+
+	netManager.SetPeerConnectedCallback(func(id peer.ID) {
+		log.Printf("Node: Peer connected: %s\n", id.String())
+		go handshake.Check(ctx, id)
+	})
+
+	if err := netManager.Start(ctx); err != nil {
+		log.Printf("Error starting network manager: %v\n", err)
+		return 1
+	}
+
+	psManager, err := p2p.NewPubSubManager(ctx, netManager.Host, cfg)
+	if err != nil {
+		log.Printf("Error creating pubsub manager: %v\n", err)
+		return 1
+	}
+	// The node has no socli key, so it checks the signatures of plaintext
+	// posts and relays encrypted ones unread
+	psManager.SetMessageValidator(messaging.NewValidator(cfg, nil).Validate)
+
+	// Join the topics so this node is part of their gossip mesh and forwards posts.
+	// The directory and presence topics are always joined, so announcements and
+	// heartbeats are relayed too.
+	// The node doesn't display anything, so messages are discarded.
+	topicNames := []string{messaging.DirectoryTopic, messaging.PresenceTopic}
+	for _, hashtag := range strings.Split(*topics, ",") {
+		hashtag = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(hashtag), "#"))
+		if hashtag != "" {
+			topicNames = append(topicNames, messaging.GetTopicForHashtag(hashtag))
+		}
+	}
+	for _, topicName := range topicNames {
+		topic, err := psManager.JoinTopic(topicName)
+		if err != nil {
+			log.Printf("Error joining topic %s: %v\n", topicName, err)
+			return 1
+		}
+		sub, err := psManager.SubscribeToTopic(topic)
+		if err != nil {
+			log.Printf("Error subscribing to topic %s: %v\n", topicName, err)
+			return 1
+		}
+		go func() {
+			for {
+				if _, err := sub.Next(ctx); err != nil {
+					return
+				}
+			}
+		}()
+		log.Printf("Node: Joined topic %s\n", topicName)
+	}
+
+	log.Printf("Node: Started with peer ID %s (relay service: %t)\n", netManager.Host.ID().String(), cfg.Network.EnableRelayService)
+	log.Println("Node: Add one of these addresses to bootstrap_peers or static_relays on your peers:")
+	for _, addr := range netManager.FullAddrs() {
+		log.Printf("  %s\n", addr.String())
+	}
+
+	ticker := time.NewTicker(nodeStatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Node: Received shutdown signal, shutting down...")
+			return 0
+		case <-ticker.C:
+			log.Printf("Node: %d peers connected, reachability %s, %d relay addresses\n",
+				len(netManager.Host.Network().Peers()), netManager.Reachability(), len(netManager.RelayAddrs()))
+		}
+	}
+}
@@ -3,47 +3,50 @@ package p2p
 import (
 	"context"
 	"log"
+	"time"
 
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+	drouting "github.com/libp2p/go-libp2p/p2p/discovery/routing"
+	dutil "github.com/libp2p/go-libp2p/p2p/discovery/util"
 )
 
-// discoveryNotifee handles peer discovery notifications.
-// It now also sends a notification to the TUI.
+const (
+	// rendezvousNamespace is the DHT key under which socli peers advertise themselves.
+	rendezvousNamespace = "socli-discovery"
+	// rendezvousInterval is how often we look for new peers under the rendezvous.
+	rendezvousInterval = time.Minute
+)
+
+// discoveryNotifee handles peer discovery notifications. The application
+// learns about the connections from the NetworkManager's connection events.
 type discoveryNotifee struct {
-	h             host.Host
-	peerConnected func(peer.ID) // Callback function to notify about connected peers
+	h host.Host
 }
 
-// HandlePeerFound connects to peers discovered via mDNS and notifies the application.
+// HandlePeerFound connects to peers discovered via mDNS.
 func (n *discoveryNotifee) HandlePeerFound(pi peer.AddrInfo) {
 	log.Printf("Discovery: Found new peer: %s\n", pi.ID.String()) // Changed log message
 	err := n.h.Connect(context.Background(), pi)
 	if err != nil {
 		log.Printf("Discovery: Error connecting to peer %s: %s\n", pi.ID.String(), err)
-		return // Don't notify if connection failed
+		return
 	}
 	log.Printf("Discovery: Successfully connected to peer: %s\n", pi.ID.String()) // Add success log
-
-	// Notify the application (e.g., TUI) about the newly connected peer
-	if n.peerConnected != nil {
-		n.peerConnected(pi.ID)
-	}
 }
 
 // setupMDNSDiscovery initializes mDNS for local peer discovery.
-// It accepts a callback for peer connection notifications.
-func setupMDNSDiscovery(ctx context.Context, h host.Host, onPeerConnected func(peer.ID)) error {
+func setupMDNSDiscovery(ctx context.Context, h host.Host) error {
 	// setup mDNS discovery
-	service := mdns.NewMdnsService(h, "socli-discovery", &discoveryNotifee{h: h, peerConnected: onPeerConnected})
+	service := mdns.NewMdnsService(h, "socli-discovery", &discoveryNotifee{h: h})
 	return service.Start()
 }
 
 // setupDHTDiscovery initializes the Kademlia DHT for global peer discovery.
-// It accepts a callback for peer connection notifications.
-func setupDHTDiscovery(ctx context.Context, h host.Host, onPeerConnected func(peer.ID)) (*dht.IpfsDHT, error) {
+func setupDHTDiscovery(ctx context.Context, h host.Host) (*dht.IpfsDHT, error) {
 	// Start a DHT, for use in peer discovery.
 	kademliaDHT, err := dht.New(ctx, h)
 	if err != nil {
@@ -56,8 +59,47 @@ func setupDHTDiscovery(ctx context.Context, h host.Host, onPeerConnected func(pe
 		return nil, err
 	}
 
-	// We're not connecting to any bootstrap peers here. In a real-world application,
-	// you would want to connect to a list of bootstrap peers.
+	// Bootstrap peers from the config are connected in NetworkManager.Start before
+	// the DHT is set up, so the routing table already has entries at this point.
+
+	// Advertise ourselves under the socli rendezvous and look for other socli peers.
+	// This is what lets a team find each other through a shared bootstrap node.
+	go discoverRendezvousPeers(ctx, h, drouting.NewRoutingDiscovery(kademliaDHT))
 
 	return kademliaDHT, nil
 }
+
+// discoverRendezvousPeers periodically advertises this host under the socli
+// rendezvous namespace and connects to any other peers found there.
+func discoverRendezvousPeers(ctx context.Context, h host.Host, rd *drouting.RoutingDiscovery) {
+	dutil.Advertise(ctx, rd, rendezvousNamespace)
+
+	ticker := time.NewTicker(rendezvousInterval)
+	defer ticker.Stop()
+	for {
+		peerChan, err := rd.FindPeers(ctx, rendezvousNamespace)
+		if err != nil {
+			log.Printf("Discovery: Error finding rendezvous peers: %s\n", err)
+		} else {
+			for pi := range peerChan {
+				if pi.ID == h.ID() || len(pi.Addrs) == 0 {
+					continue
+				}
+				if h.Network().Connectedness(pi.ID) == network.Connected {
+					continue
+				}
+				if err := h.Connect(ctx, pi); err != nil {
+					log.Printf("Discovery: Error connecting to rendezvous peer %s: %s\n", pi.ID.String(), err)
+					continue
+				}
+				log.Printf("Discovery: Connected to rendezvous peer: %s\n", pi.ID.String())
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"socli/config"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// favoriteDialTimeout bounds a single redial, including the DHT lookup.
+const favoriteDialTimeout = 30 * time.Second
+
+// FavoriteStatus describes a favorite peer and its reconnection state.
+type FavoriteStatus struct {
+	ID        peer.ID
+	Connected bool
+	Dialing   bool
+	Attempts  int       // Failed redials since the peer was last connected
+	NextDial  time.Time // When the next redial is due; zero while connected
+	LastErr   error
+}
+
+// favorite is a peer the Reconnector keeps connected.
+type favorite struct {
+	id       peer.ID
+	addrs    []ma.Multiaddr // From the config and the last connection
+	attempts int
+	next     time.Time
+	dialing  bool
+	lastErr  error
+}
+
+// Reconnector keeps us connected to favorite peers. It watches for
+// disconnects and redials favorites with exponential backoff, using the
+// addresses we last saw them on, the peerstore, and the DHT if available.
+type Reconnector struct {
+	h          host.Host
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu          sync.Mutex
+	routing     routing.PeerRouting // Used to look up new addresses; may be nil
+	onConnected func(peer.ID)
+	favorites   []*favorite // In the order they were added
+	wake        chan struct{}
+}
+
+// NewReconnector creates a reconnector for the favorites in the config.
+// Favorites are given as peer IDs or as full multiaddrs ending in /p2p/<id>.
+func NewReconnector(h host.Host, cfg *config.Config) (*Reconnector, error) {
+	r := &Reconnector{
+		h:          h,
+		minBackoff: cfg.Favorites.MinBackoff,
+		maxBackoff: cfg.Favorites.MaxBackoff,
+		wake:       make(chan struct{}, 1),
+	}
+	if r.minBackoff <= 0 {
+		r.minBackoff = time.Second
+	}
+	if r.maxBackoff < r.minBackoff {
+		r.maxBackoff = r.minBackoff
+	}
+	for _, s := range cfg.Favorites.Peers {
+		if _, err := r.Add(s); err != nil {
+			return nil, fmt.Errorf("%s: %w", s, err)
+		}
+	}
+	return r, nil
+}
+
+// SetRouting sets where to look up the addresses of favorites that can't be
+// reached on the ones we know, e.g. the DHT.
+func (r *Reconnector) SetRouting(pr routing.PeerRouting) {
+	r.mu.Lock()
+	r.routing = pr
+	r.mu.Unlock()
+}
+
+// SetConnectedCallback sets the function called after a favorite was redialed.
+func (r *Reconnector) SetConnectedCallback(callback func(peer.ID)) {
+	r.mu.Lock()
+	r.onConnected = callback
+	r.mu.Unlock()
+}
+
+// Add adds a favorite, given as a peer ID or a full multiaddr. Adding a
+// favorite again only adds its addresses. It returns the favorite's peer ID.
+func (r *Reconnector) Add(s string) (peer.ID, error) {
+	pi, err := parseFavorite(s)
+	if err != nil {
+		return "", err
+	}
+	if pi.ID == r.h.ID() {
+		return "", errors.New("can't add ourselves as a favorite")
+	}
+
+	r.mu.Lock()
+	if f := r.lookup(pi.ID); f != nil {
+		f.addrs = append(f.addrs, pi.Addrs...)
+	} else {
+		r.favorites = append(r.favorites, &favorite{id: pi.ID, addrs: pi.Addrs})
+	}
+	r.mu.Unlock()
+	r.poke()
+	return pi.ID, nil
+}
+
+// Remove stops keeping a peer connected. It reports whether it was a favorite.
+func (r *Reconnector) Remove(id peer.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, f := range r.favorites {
+		if f.id == id {
+			r.favorites = append(r.favorites[:i], r.favorites[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IsFavorite reports whether a peer is a favorite.
+func (r *Reconnector) IsFavorite(id peer.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookup(id) != nil
+}
+
+// Favorites returns the status of every favorite, in the order they were added.
+func (r *Reconnector) Favorites() []FavoriteStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]FavoriteStatus, 0, len(r.favorites))
+	for _, f := range r.favorites {
+		status := FavoriteStatus{
+			ID:        f.id,
+			Connected: r.h.Network().Connectedness(f.id) == network.Connected,
+			Dialing:   f.dialing,
+			Attempts:  f.attempts,
+			LastErr:   f.lastErr,
+		}
+		if !status.Connected {
+			status.NextDial = f.next
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Start watches for disconnects and redials favorites until ctx is done.
+// Favorites that aren't connected yet are dialed right away.
+func (r *Reconnector) Start(ctx context.Context) error {
+	sub, err := r.h.EventBus().Subscribe(new(event.EvtPeerConnectednessChanged))
+	if err != nil {
+		return err
+	}
+	go r.run(ctx, sub)
+	return nil
+}
+
+// run dials favorites when they are due, and reschedules them whenever one
+// connects or disconnects.
+func (r *Reconnector) run(ctx context.Context, sub event.Subscription) {
+	defer sub.Close()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+			if evt, ok := e.(event.EvtPeerConnectednessChanged); ok {
+				r.connectednessChanged(evt)
+			}
+		case <-r.wake:
+		case <-timer.C:
+		}
+		timer.Reset(r.dialDue(ctx))
+	}
+}
+
+// connectednessChanged remembers where a favorite was connected, and
+// schedules its first redial when it disconnects.
+func (r *Reconnector) connectednessChanged(evt event.EvtPeerConnectednessChanged) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.lookup(evt.Peer)
+	if f == nil {
+		return
+	}
+	switch evt.Connectedness {
+	case network.Connected:
+		// Peerstore addresses of disconnected peers expire, so keep our own copy
+		if addrs := r.h.Peerstore().Addrs(f.id); len(addrs) > 0 {
+			f.addrs = addrs
+		}
+		f.attempts = 0
+		f.lastErr = nil
+	case network.NotConnected:
+		log.Printf("Favorites: Lost connection to %s, redialing in %s\n", f.id, r.minBackoff)
+		f.attempts = 0
+		f.next = time.Now().Add(r.minBackoff)
+	}
+}
+
+// dialDue starts a redial for every disconnected favorite that is due, and
+// returns how long to wait until the next one is.
+func (r *Reconnector) dialDue(ctx context.Context) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	wait := r.maxBackoff
+	for _, f := range r.favorites {
+		if f.dialing || r.h.Network().Connectedness(f.id) == network.Connected {
+			continue
+		}
+		if until := f.next.Sub(now); until > 0 {
+			wait = min(wait, until)
+			continue
+		}
+		f.dialing = true
+		addrs := append([]ma.Multiaddr(nil), f.addrs...)
+		go r.dial(ctx, f.id, addrs, r.routing)
+	}
+	return wait
+}
+
+// dial tries to reconnect to a favorite on its known addresses, then on
+// addresses looked up with pr, and schedules the next attempt on failure.
+func (r *Reconnector) dial(ctx context.Context, id peer.ID, addrs []ma.Multiaddr, pr routing.PeerRouting) {
+	dialCtx, cancel := context.WithTimeout(ctx, favoriteDialTimeout)
+	defer cancel()
+	err := r.h.Connect(dialCtx, peer.AddrInfo{ID: id, Addrs: addrs})
+	if err != nil && pr != nil {
+		if pi, findErr := pr.FindPeer(dialCtx, id); findErr == nil {
+			err = r.h.Connect(dialCtx, pi)
+		}
+	}
+
+	r.mu.Lock()
+	var onConnected func(peer.ID)
+	if f := r.lookup(id); f != nil {
+		f.dialing = false
+		if err != nil {
+			f.attempts++
+			f.lastErr = err
+			f.next = time.Now().Add(r.backoff(f.attempts))
+			log.Printf("Favorites: Redialing %s failed (attempt %d), retrying in %s: %v\n", id, f.attempts, r.backoff(f.attempts), err)
+		} else {
+			f.attempts = 0
+			f.lastErr = nil
+			onConnected = r.onConnected
+			log.Printf("Favorites: Reconnected to %s\n", id)
+		}
+	}
+	r.mu.Unlock()
+
+	if onConnected != nil {
+		onConnected(id)
+	}
+	r.poke()
+}
+
+// backoff returns the wait after the given number of failed redials,
+// doubling from min_backoff up to max_backoff.
+func (r *Reconnector) backoff(attempts int) time.Duration {
+	d := r.minBackoff
+	for i := 1; i < attempts && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, r.maxBackoff)
+}
+
+// lookup returns a favorite by peer ID. r.mu must be held.
+func (r *Reconnector) lookup(id peer.ID) *favorite {
+	for _, f := range r.favorites {
+		if f.id == id {
+			return f
+		}
+	}
+	return nil
+}
+
+// poke makes the run loop reschedule without blocking.
+func (r *Reconnector) poke() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// parseFavorite parses a favorite given as a peer ID or a full multiaddr.
+func parseFavorite(s string) (peer.AddrInfo, error) {
+	if id, err := peer.Decode(s); err == nil {
+		return peer.AddrInfo{ID: id}, nil
+	}
+	pi, err := peer.AddrInfoFromString(s)
+	if err != nil {
+		return peer.AddrInfo{}, errors.New("not a peer ID or a multiaddr with /p2p/<id>")
+	}
+	return *pi, nil
+}
@@ -0,0 +1,182 @@
+package p2p
+
+import (
+	"context"
+	"socli/config"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// favoritesConfig returns a config with the given favorites and short backoffs.
+func favoritesConfig(peers ...string) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Favorites.Peers = peers
+	cfg.Favorites.MinBackoff = 10 * time.Millisecond
+	cfg.Favorites.MaxBackoff = 40 * time.Millisecond
+	return cfg
+}
+
+// waitFor polls cond until it holds or ctx is done.
+func waitFor(ctx context.Context, cond func() bool) bool {
+	for !cond() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	return true
+}
+
+// TestReconnectorRedial tests that a favorite is redialed after it drops,
+// and retried with backoff while it can't be reached.
+func TestReconnectorRedial(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mn := mocknet.New()
+	defer mn.Close()
+	a, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	b, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+
+	r, err := NewReconnector(a, favoritesConfig(b.ID().String()))
+	if err != nil {
+		t.Fatalf("NewReconnector() error = %v, want nil", err)
+	}
+	reconnected := make(chan peer.ID, 10)
+	r.SetConnectedCallback(func(id peer.ID) { reconnected <- id })
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v, want nil", err)
+	}
+
+	// Favorites are dialed on start
+	connected := func() bool { return a.Network().Connectedness(b.ID()) == network.Connected }
+	if !waitFor(ctx, connected) {
+		t.Fatal("Favorite wasn't dialed on start")
+	}
+	if got := <-reconnected; got != b.ID() {
+		t.Errorf("Callback got %s, want %s", got, b.ID())
+	}
+
+	// A dropped favorite is redialed
+	if err := mn.DisconnectPeers(a.ID(), b.ID()); err != nil {
+		t.Fatalf("Failed to disconnect peers: %v", err)
+	}
+	if !waitFor(ctx, connected) {
+		t.Fatal("Favorite wasn't redialed after a disconnect")
+	}
+
+	// An unreachable favorite is retried until it is back
+	if err := mn.UnlinkPeers(a.ID(), b.ID()); err != nil {
+		t.Fatalf("Failed to unlink peers: %v", err)
+	}
+	if err := mn.DisconnectPeers(a.ID(), b.ID()); err != nil {
+		t.Fatalf("Failed to disconnect peers: %v", err)
+	}
+	retrying := func() bool {
+		status := r.Favorites()
+		return len(status) == 1 && !status[0].Connected && status[0].Attempts >= 2 && status[0].LastErr != nil
+	}
+	if !waitFor(ctx, retrying) {
+		t.Fatalf("Favorites() = %+v, want failed attempts", r.Favorites())
+	}
+	if _, err := mn.LinkPeers(a.ID(), b.ID()); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+	if !waitFor(ctx, connected) {
+		t.Fatal("Favorite wasn't redialed once reachable")
+	}
+	if !waitFor(ctx, func() bool { return r.Favorites()[0].Attempts == 0 }) {
+		t.Errorf("Attempts = %d after reconnecting, want 0", r.Favorites()[0].Attempts)
+	}
+}
+
+// TestReconnectorBackoff tests that the wait doubles up to max_backoff.
+func TestReconnectorBackoff(t *testing.T) {
+	mn := mocknet.New()
+	defer mn.Close()
+	h, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	r, err := NewReconnector(h, favoritesConfig())
+	if err != nil {
+		t.Fatalf("NewReconnector() error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Millisecond},
+		{2, 20 * time.Millisecond},
+		{3, 40 * time.Millisecond},
+		{10, 40 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := r.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// TestReconnectorAdd tests the forms a favorite can be given in.
+func TestReconnectorAdd(t *testing.T) {
+	mn := mocknet.New()
+	defer mn.Close()
+	h, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	other, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+
+	if _, err := NewReconnector(h, favoritesConfig("not-a-peer")); err == nil {
+		t.Error("NewReconnector() with an invalid favorite error = nil, want an error")
+	}
+	r, err := NewReconnector(h, favoritesConfig())
+	if err != nil {
+		t.Fatalf("NewReconnector() error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    peer.ID
+		wantErr bool
+	}{
+		{"PeerID", other.ID().String(), other.ID(), false},
+		{"Multiaddr", other.Addrs()[0].String() + "/p2p/" + other.ID().String(), other.ID(), false},
+		{"MultiaddrWithoutID", other.Addrs()[0].String(), "", true},
+		{"Ourselves", h.ID().String(), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Add(tt.input)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Add(%q) = %s, %v, want %s, error %t", tt.input, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+	if favorites := r.Favorites(); len(favorites) != 1 || !r.IsFavorite(other.ID()) {
+		t.Errorf("Favorites() = %+v, want just %s", favorites, other.ID())
+	}
+	if !r.Remove(other.ID()) || r.IsFavorite(other.ID()) || r.Remove(other.ID()) {
+		t.Error("Remove() didn't remove the favorite exactly once")
+	}
+}
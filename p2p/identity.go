@@ -0,0 +1,34 @@
+package p2p
+
+import (
+	"crypto/rand"
+	"os"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// loadOrCreateIdentity loads the libp2p host key stored at path, generating and
+// saving a new Ed25519 key if the file doesn't exist yet. A persistent host key
+// keeps the peer ID stable across restarts, which bootstrap and relay nodes need.
+func loadOrCreateIdentity(path string) (crypto.PrivKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return crypto.UnmarshalPrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err = crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
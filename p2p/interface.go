@@ -4,6 +4,7 @@ import (
 	"context"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // PubSubManagerInterface defines the interface for PubSubManager.
@@ -12,4 +13,5 @@ type PubSubManagerInterface interface {
 	JoinTopic(topicName string) (*pubsub.Topic, error)
 	PublishMessage(ctx context.Context, topic *pubsub.Topic, data []byte) error
 	SubscribeToTopic(topic *pubsub.Topic) (*pubsub.Subscription, error)
+	ListPeers(topicName string) []peer.ID
 }
\ No newline at end of file
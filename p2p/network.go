@@ -2,19 +2,27 @@ package p2p
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"socli/config"
+	"sync"
+	"sync/atomic"
 
+	"github.com/libp2p/go-libp2p"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
+	quic "github.com/libp2p/go-libp2p/p2p/transport/quic"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
-	"github.com/libp2p/go-libp2p"
+	ma "github.com/multiformats/go-multiaddr"
 )
 
 // Define function types for discovery setup to enable mocking
-type setupMDNSDiscoveryFunc func(ctx context.Context, h host.Host, onPeerConnected func(peer.ID)) error
-type setupDHTDiscoveryFunc func(ctx context.Context, h host.Host, onPeerConnected func(peer.ID)) (*dht.IpfsDHT, error)
+type setupMDNSDiscoveryFunc func(ctx context.Context, h host.Host) error
+type setupDHTDiscoveryFunc func(ctx context.Context, h host.Host) (*dht.IpfsDHT, error)
 
 // NetworkManager handles the libp2p host and networking functionality.
 type NetworkManager struct {
@@ -23,49 +31,206 @@ type NetworkManager struct {
 	// Functions for setting up discovery, to allow mocking
 	setupMDNS setupMDNSDiscoveryFunc
 	setupDHT  setupDHTDiscoveryFunc
-	// onPeerConnected is a callback function to notify when a new peer is connected.
-	// This is set by the application (e.g., in main.go) to link discovery to the app logic (like TUI).
+	// reachability is the latest result reported by AutoNAT.
+	mu           sync.RWMutex
+	reachability network.Reachability
+	reachSub     event.Subscription
+	// onPeerConnected is called for every peer we get connected to, whether
+	// we dialed it or it dialed us. It may be set at any time.
 	onPeerConnected func(peer.ID)
+	connSub         event.Subscription
+
+	// dht is the Kademlia DHT started by Start, if enabled.
+	dht *dht.IpfsDHT
+
+	// Favorites keeps us connected to the favorite peers.
+	Favorites *Reconnector
 }
 
 // NewNetworkManager creates and initializes a new libp2p host.
+// The host is configured for NAT traversal: AutoNAT determines whether we are
+// publicly reachable, DCUtR hole punching upgrades relayed connections to
+// direct ones, and the relay v2 client lets NATed peers be reached through
+// relays. If enabled in the config, the node also acts as a relay server.
 func NewNetworkManager(cfg *config.Config) (*NetworkManager, error) {
-	// Create a new libp2p host
-	h, err := libp2p.New(
+	staticRelays, err := parseAddrInfos(cfg.Network.StaticRelays)
+	if err != nil {
+		return nil, fmt.Errorf("invalid static relay: %w", err)
+	}
+
+	// The relay peer source needs the host, which doesn't exist until libp2p.New
+	// returns. AutoRelay may already ask for peers from its own goroutine by
+	// then, so the host is handed over atomically.
+	var relayHost atomic.Pointer[host.Host]
+
+	opts := []libp2p.Option{
 		// Use the options constructor to configure the host
-		libp2p.ListenAddrStrings("/ip4/0.0.0.0/tcp/0"),
+		libp2p.ListenAddrStrings(
+			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", cfg.Network.ListenPort),
+			fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic-v1", cfg.Network.ListenPort),
+		),
 		libp2p.Transport(tcp.NewTCPTransport),
+		// QUIC gives hole punching a much better chance than TCP alone
+		libp2p.Transport(quic.NewTransport),
 		libp2p.Security(noise.ID, noise.New),
 		libp2p.DefaultMuxers,
-	)
+		// Ask the router for a port mapping (UPnP / NAT-PMP) where available
+		libp2p.NATPortMap(),
+		// Help other peers find out whether they are reachable
+		libp2p.EnableNATService(),
+		libp2p.EnableRelay(),
+		libp2p.EnableHolePunching(),
+	}
+
+	if len(staticRelays) > 0 {
+		opts = append(opts, libp2p.EnableAutoRelayWithStaticRelays(staticRelays))
+	} else {
+		// Without configured relays, use the peers we are connected to as candidates.
+		// Teammates running with enable_relay_service will be picked up this way.
+		opts = append(opts, libp2p.EnableAutoRelayWithPeerSource(func(ctx context.Context, num int) <-chan peer.AddrInfo {
+			var h host.Host
+			if p := relayHost.Load(); p != nil {
+				h = *p
+			}
+			return connectedPeerSource(ctx, h, num)
+		}))
+	}
+
+	if cfg.Network.EnableRelayService {
+		opts = append(opts, libp2p.EnableRelayService())
+	}
+
+	if cfg.Network.IdentityKeyPath != "" {
+		priv, err := loadOrCreateIdentity(cfg.Network.IdentityKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading identity key: %w", err)
+		}
+		opts = append(opts, libp2p.Identity(priv))
+	}
+
+	// Create a new libp2p host
+	h, err := libp2p.New(opts...)
 	if err != nil {
 		return nil, err
 	}
+	relayHost.Store(&h)
 
-	return &NetworkManager{
-		Host:      h,
-		cfg:       cfg,
-		setupMDNS: setupMDNSDiscovery, // Use the real function by default
-		setupDHT:  setupDHTDiscovery,   // Use the real function by default
-		// onPeerConnected will be set later by the application
-	}, nil
+	return NewNetworkManagerWithHost(h, cfg)
 }
 
-// SetPeerConnectedCallback sets the callback function to be called when a peer is connected.
+// NewNetworkManagerWithHost creates a NetworkManager around a host that was
+// created elsewhere, such as a mocknet host in tests. The host is closed if
+// the NetworkManager can't be set up.
+func NewNetworkManagerWithHost(h host.Host, cfg *config.Config) (*NetworkManager, error) {
+	nm := &NetworkManager{
+		Host:         h,
+		cfg:          cfg,
+		setupMDNS:    setupMDNSDiscovery, // Use the real function by default
+		setupDHT:     setupDHTDiscovery,  // Use the real function by default
+		reachability: network.ReachabilityUnknown,
+	}
+
+	var err error
+	nm.Favorites, err = NewReconnector(h, cfg)
+	if err != nil {
+		h.Close()
+		return nil, fmt.Errorf("invalid favorite: %w", err)
+	}
+
+	// Track reachability changes reported by AutoNAT
+	sub, err := h.EventBus().Subscribe(new(event.EvtLocalReachabilityChanged))
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+	nm.reachSub = sub
+	go nm.watchReachability(sub)
+
+	// Tell the application about every new connection, including inbound
+	// ones and those made by discovery after startup
+	connSub, err := h.EventBus().Subscribe(new(event.EvtPeerConnectednessChanged))
+	if err != nil {
+		sub.Close()
+		h.Close()
+		return nil, err
+	}
+	nm.connSub = connSub
+	go nm.watchConnections(connSub)
+
+	return nm, nil
+}
+
+// watchConnections calls the peer connected callback for every peer that
+// becomes connected, until the subscription is closed.
+func (nm *NetworkManager) watchConnections(sub event.Subscription) {
+	for e := range sub.Out() {
+		evt, ok := e.(event.EvtPeerConnectednessChanged)
+		if !ok || evt.Connectedness != network.Connected {
+			continue
+		}
+		nm.mu.RLock()
+		callback := nm.onPeerConnected
+		nm.mu.RUnlock()
+		if callback != nil {
+			callback(evt.Peer)
+		}
+	}
+}
+
+// watchReachability records every reachability change until the subscription is closed.
+func (nm *NetworkManager) watchReachability(sub event.Subscription) {
+	for e := range sub.Out() {
+		evt, ok := e.(event.EvtLocalReachabilityChanged)
+		if !ok {
+			continue
+		}
+		log.Printf("Network: Reachability changed to %s\n", evt.Reachability)
+		nm.mu.Lock()
+		nm.reachability = evt.Reachability
+		nm.mu.Unlock()
+	}
+}
+
+// SetPeerConnectedCallback sets the callback function to be called when a peer
+// is connected. It is called for connections made before Start as well as
+// after, and for peers that dialed us.
 func (nm *NetworkManager) SetPeerConnectedCallback(callback func(peer.ID)) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
 	nm.onPeerConnected = callback
 }
 
 // Start begins the networking operations like peer discovery.
 func (nm *NetworkManager) Start(ctx context.Context) error {
+	// Connect to bootstrap peers first so the DHT has someone to talk to
+	if len(nm.cfg.Network.BootstrapPeers) > 0 {
+		bootstrapPeers, err := parseAddrInfos(nm.cfg.Network.BootstrapPeers)
+		if err != nil {
+			return fmt.Errorf("invalid bootstrap peer: %w", err)
+		}
+		nm.connectBootstrapPeers(ctx, bootstrapPeers)
+	}
+
 	if nm.cfg.Network.EnableMDNS {
-		if err := nm.setupMDNS(ctx, nm.Host, nm.onPeerConnected); err != nil {
+		if err := nm.setupMDNS(ctx, nm.Host); err != nil {
 			return err
 		}
 	}
 
 	if nm.cfg.Network.EnableDHT {
-		if _, err := nm.setupDHT(ctx, nm.Host, nm.onPeerConnected); err != nil {
+		kademliaDHT, err := nm.setupDHT(ctx, nm.Host)
+		if err != nil {
+			return err
+		}
+		nm.dht = kademliaDHT
+	}
+
+	// Redial favorites when they drop, looking them up in the DHT if needed
+	if nm.Favorites != nil {
+		if nm.dht != nil {
+			nm.Favorites.SetRouting(nm.dht)
+		}
+		if err := nm.Favorites.Start(ctx); err != nil {
 			return err
 		}
 	}
@@ -73,7 +238,108 @@ func (nm *NetworkManager) Start(ctx context.Context) error {
 	return nil
 }
 
-// Close shuts down the libp2p host.
+// connectBootstrapPeers dials all bootstrap peers in parallel.
+// Failures are logged but not fatal, since the remaining peers may still be reachable.
+func (nm *NetworkManager) connectBootstrapPeers(ctx context.Context, peers []peer.AddrInfo) {
+	var wg sync.WaitGroup
+	for _, pi := range peers {
+		if pi.ID == nm.Host.ID() {
+			continue
+		}
+		wg.Add(1)
+		go func(pi peer.AddrInfo) {
+			defer wg.Done()
+			if err := nm.Host.Connect(ctx, pi); err != nil {
+				log.Printf("Network: Error connecting to bootstrap peer %s: %s\n", pi.ID.String(), err)
+				return
+			}
+			log.Printf("Network: Connected to bootstrap peer: %s\n", pi.ID.String())
+		}(pi)
+	}
+	wg.Wait()
+}
+
+// Reachability returns whether AutoNAT considers this node publicly reachable.
+func (nm *NetworkManager) Reachability() network.Reachability {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+	return nm.reachability
+}
+
+// RelayAddrs returns the circuit relay addresses the host is currently advertising.
+func (nm *NetworkManager) RelayAddrs() []ma.Multiaddr {
+	return filterRelayAddrs(nm.Host.Addrs())
+}
+
+// FullAddrs returns the host's listen addresses with the peer ID appended,
+// in the form other peers can use as bootstrap_peers or static_relays.
+func (nm *NetworkManager) FullAddrs() []ma.Multiaddr {
+	addrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: nm.Host.ID(), Addrs: nm.Host.Addrs()})
+	if err != nil {
+		return nil
+	}
+	return addrs
+}
+
+// Close shuts down the DHT and the libp2p host.
 func (nm *NetworkManager) Close() error {
+	if nm.dht != nil {
+		nm.dht.Close()
+		nm.dht = nil
+	}
+	if nm.reachSub != nil {
+		nm.reachSub.Close()
+		nm.reachSub = nil
+	}
+	if nm.connSub != nil {
+		nm.connSub.Close()
+		nm.connSub = nil
+	}
 	return nm.Host.Close()
-}
\ No newline at end of file
+}
+
+// filterRelayAddrs returns only the addresses that go through a circuit relay.
+func filterRelayAddrs(addrs []ma.Multiaddr) []ma.Multiaddr {
+	relayAddrs := make([]ma.Multiaddr, 0)
+	for _, addr := range addrs {
+		if _, err := addr.ValueForProtocol(ma.P_CIRCUIT); err == nil {
+			relayAddrs = append(relayAddrs, addr)
+		}
+	}
+	return relayAddrs
+}
+
+// parseAddrInfos converts a list of multiaddr strings (including /p2p/<id>) to AddrInfos.
+func parseAddrInfos(addrs []string) ([]peer.AddrInfo, error) {
+	infos := make([]peer.AddrInfo, 0, len(addrs))
+	for _, s := range addrs {
+		pi, err := peer.AddrInfoFromString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s, err)
+		}
+		infos = append(infos, *pi)
+	}
+	return infos, nil
+}
+
+// connectedPeerSource offers currently connected peers as relay candidates.
+// AutoRelay checks on its own whether a candidate actually supports relaying.
+// It offers nothing while h is nil, before the host has been created.
+func connectedPeerSource(ctx context.Context, h host.Host, num int) <-chan peer.AddrInfo {
+	out := make(chan peer.AddrInfo, num)
+	defer close(out)
+	if h == nil {
+		return out
+	}
+	for _, id := range h.Network().Peers() {
+		if len(out) >= num {
+			break
+		}
+		select {
+		case out <- h.Peerstore().PeerInfo(id):
+		case <-ctx.Done():
+			return out
+		}
+	}
+	return out
+}
@@ -2,8 +2,10 @@ package p2p
 
 import (
 	"context"
+	"path/filepath"
 	"socli/config"
 	"testing"
+	"time"
 
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	"github.com/libp2p/go-libp2p/core/connmgr"
@@ -13,6 +15,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/core/protocol"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
 	ma "github.com/multiformats/go-multiaddr"
 )
 
@@ -95,14 +98,14 @@ func (m *mockHost) EventBus() event.Bus {
 }
 
 // mockSetupMDNSDiscovery is a mock implementation of setupMDNSDiscoveryFunc for testing.
-func mockSetupMDNSDiscovery(ctx context.Context, h host.Host, onPeerConnected func(peer.ID)) error {
+func mockSetupMDNSDiscovery(ctx context.Context, h host.Host) error {
 	// In a real test, we might record that this function was called.
 	// For now, we'll just return nil to simulate success.
 	return nil
 }
 
 // mockSetupDHTDiscovery is a mock implementation of setupDHTDiscoveryFunc for testing.
-func mockSetupDHTDiscovery(ctx context.Context, h host.Host, onPeerConnected func(peer.ID)) (*dht.IpfsDHT, error) {
+func mockSetupDHTDiscovery(ctx context.Context, h host.Host) (*dht.IpfsDHT, error) {
 	// In a real test, we might record that this function was called and return a mock DHT.
 	// For now, we'll just return nil, nil to simulate success.
 	return nil, nil
@@ -121,12 +124,12 @@ func TestNetworkManagerStart(t *testing.T) {
 	dhtCalled := false
 
 	// Create mock setup functions that record if they are called
-	mockSetupMDNS := func(ctx context.Context, h host.Host, onPeerConnected func(peer.ID)) error {
+	mockSetupMDNS := func(ctx context.Context, h host.Host) error {
 		mdnsCalled = true
 		return nil
 	}
 
-	mockSetupDHT := func(ctx context.Context, h host.Host, onPeerConnected func(peer.ID)) (*dht.IpfsDHT, error) {
+	mockSetupDHT := func(ctx context.Context, h host.Host) (*dht.IpfsDHT, error) {
 		dhtCalled = true
 		return nil, nil
 	}
@@ -216,4 +219,117 @@ func TestNetworkManagerStart(t *testing.T) {
 	if dhtCalled {
 		t.Error("setupDHTDiscovery was called when both MDNS and DHT were disabled")
 	}
-}
\ No newline at end of file
+}
+
+// TestNetworkManagerPeerConnected tests that the peer connected callback is
+// called for peers we dial and for peers that dial us, after Start.
+func TestNetworkManagerPeerConnected(t *testing.T) {
+	mn := mocknet.New()
+	defer mn.Close()
+	var hosts []host.Host
+	for range 3 {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatalf("Failed to create mock host: %v", err)
+		}
+		hosts = append(hosts, h)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Network.EnableMDNS = false
+	cfg.Network.EnableDHT = false
+	nm, err := NewNetworkManagerWithHost(hosts[0], cfg)
+	if err != nil {
+		t.Fatalf("NewNetworkManagerWithHost() error = %v, want nil", err)
+	}
+	defer nm.Close()
+	if err := nm.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v, want nil", err)
+	}
+
+	connected := make(chan peer.ID, 2)
+	nm.SetPeerConnectedCallback(func(id peer.ID) { connected <- id })
+
+	// One peer we dial and one that dials us
+	if _, err := mn.ConnectPeers(hosts[0].ID(), hosts[1].ID()); err != nil {
+		t.Fatalf("Failed to connect peers: %v", err)
+	}
+	if _, err := mn.ConnectPeers(hosts[2].ID(), hosts[0].ID()); err != nil {
+		t.Fatalf("Failed to connect peers: %v", err)
+	}
+
+	seen := make(map[peer.ID]bool)
+	for len(seen) < 2 {
+		select {
+		case id := <-connected:
+			seen[id] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Peer connected callback called for %v, want %s and %s", seen, hosts[1].ID(), hosts[2].ID())
+		}
+	}
+	if !seen[hosts[1].ID()] || !seen[hosts[2].ID()] {
+		t.Errorf("Peer connected callback called for %v, want %s and %s", seen, hosts[1].ID(), hosts[2].ID())
+	}
+}
+
+// TestFilterRelayAddrs tests that only circuit relay addresses are reported as relay addresses.
+func TestFilterRelayAddrs(t *testing.T) {
+	direct := ma.StringCast("/ip4/192.168.1.10/tcp/4001")
+	relayed := ma.StringCast("/ip4/203.0.113.5/tcp/4001/p2p/12D3KooWQYhTNQdmr3ArTeUHRYzFg94BKyTkoWBDWez9kSCVe2Xo/p2p-circuit")
+
+	got := filterRelayAddrs([]ma.Multiaddr{direct, relayed})
+	if len(got) != 1 {
+		t.Fatalf("filterRelayAddrs() returned %d addresses, want 1", len(got))
+	}
+	if !got[0].Equal(relayed) {
+		t.Errorf("filterRelayAddrs() = %s, want %s", got[0], relayed)
+	}
+
+	if got := filterRelayAddrs(nil); len(got) != 0 {
+		t.Errorf("filterRelayAddrs(nil) returned %d addresses, want 0", len(got))
+	}
+}
+
+// TestConnectedPeerSourceWithoutHost tests that no relay candidates are
+// offered before the host exists.
+func TestConnectedPeerSourceWithoutHost(t *testing.T) {
+	for info := range connectedPeerSource(context.Background(), nil, 4) {
+		t.Errorf("connectedPeerSource() offered %s without a host, want nothing", info.ID)
+	}
+}
+
+// TestParseAddrInfos tests parsing of relay and bootstrap peer addresses from the config.
+func TestParseAddrInfos(t *testing.T) {
+	infos, err := parseAddrInfos([]string{"/ip4/203.0.113.5/tcp/4001/p2p/12D3KooWQYhTNQdmr3ArTeUHRYzFg94BKyTkoWBDWez9kSCVe2Xo"})
+	if err != nil {
+		t.Fatalf("parseAddrInfos() error = %v, want nil", err)
+	}
+	if len(infos) != 1 || len(infos[0].Addrs) != 1 {
+		t.Fatalf("parseAddrInfos() = %v, want one peer with one address", infos)
+	}
+
+	// An address without a peer ID cannot be dialed as a relay
+	if _, err := parseAddrInfos([]string{"/ip4/203.0.113.5/tcp/4001"}); err == nil {
+		t.Error("parseAddrInfos() with missing peer ID returned nil error")
+	}
+}
+
+// TestLoadOrCreateIdentity tests that the host key is generated once and then reused.
+func TestLoadOrCreateIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.key")
+
+	first, err := loadOrCreateIdentity(path)
+	if err != nil {
+		t.Fatalf("loadOrCreateIdentity() error = %v, want nil", err)
+	}
+	second, err := loadOrCreateIdentity(path)
+	if err != nil {
+		t.Fatalf("loadOrCreateIdentity() on existing file error = %v, want nil", err)
+	}
+	if !first.Equals(second) {
+		t.Error("loadOrCreateIdentity() returned a different key on the second call, want the saved key")
+	}
+}
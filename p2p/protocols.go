@@ -1,3 +1,281 @@
 package p2p
 
-// This file is intentionally left blank for now.
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// SyncProtocolID is the stream protocol peers use to ask each other for recent posts.
+const SyncProtocolID = protocol.ID("/socli/sync/1.0.0")
+
+const (
+	// syncTimeout bounds a whole sync exchange with one peer.
+	syncTimeout = 10 * time.Second
+	// maxSyncRequestBytes limits how much of a request a server reads.
+	maxSyncRequestBytes = 64 * 1024
+	// maxSyncResponseBytes limits how much of a response a client reads.
+	maxSyncResponseBytes = 16 * 1024 * 1024
+)
+
+// SyncRequest asks a peer for its recent posts on a set of hashtags, or for
+// specific posts by ID.
+type SyncRequest struct {
+	Hashtags []string  `json:"hashtags"`
+	Since    time.Time `json:"since"`
+	Limit    int       `json:"limit"`
+	IDs      []string  `json:"ids,omitempty"` // Posts to fetch, e.g. the parents of replies; Hashtags are ignored if set
+}
+
+// SyncResponse carries the encoded posts matching a SyncRequest, oldest first.
+type SyncResponse struct {
+	Posts []json.RawMessage `json:"posts"`
+}
+
+// SyncHandler answers a sync request from a remote peer with encoded posts.
+// The handler is responsible for enforcing its own window and count limits.
+type SyncHandler func(from peer.ID, req SyncRequest) []json.RawMessage
+
+// RegisterSyncHandler serves the history sync protocol on the host.
+func RegisterSyncHandler(h host.Host, handler SyncHandler) {
+	h.SetStreamHandler(SyncProtocolID, func(s network.Stream) {
+		defer s.Close()
+		s.SetDeadline(time.Now().Add(syncTimeout))
+
+		var req SyncRequest
+		if err := json.NewDecoder(io.LimitReader(s, maxSyncRequestBytes)).Decode(&req); err != nil {
+			log.Printf("Sync: Invalid request from %s: %v\n", s.Conn().RemotePeer(), err)
+			s.Reset()
+			return
+		}
+
+		resp := SyncResponse{Posts: handler(s.Conn().RemotePeer(), req)}
+		if err := json.NewEncoder(s).Encode(resp); err != nil {
+			log.Printf("Sync: Failed to send response to %s: %v\n", s.Conn().RemotePeer(), err)
+			s.Reset()
+		}
+	})
+}
+
+// RequestSync asks peer p for recent posts and returns the encoded posts it sent.
+// The posts come from an untrusted peer and must be verified by the caller.
+func RequestSync(ctx context.Context, h host.Host, p peer.ID, req SyncRequest) ([]json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+
+	s, err := h.NewStream(ctx, p, SyncProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(s).Encode(req); err != nil {
+		s.Reset()
+		return nil, fmt.Errorf("sending sync request: %w", err)
+	}
+	// Signal the end of the request so the server can answer
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	var resp SyncResponse
+	if err := json.NewDecoder(io.LimitReader(s, maxSyncResponseBytes)).Decode(&resp); err != nil {
+		s.Reset()
+		return nil, fmt.Errorf("reading sync response: %w", err)
+	}
+	return resp.Posts, nil
+}
+
+// BlobProtocolID is the stream protocol peers use to fetch attachment chunks
+// from each other.
+const BlobProtocolID = protocol.ID("/socli/blob/1.0.0")
+
+const (
+	// MaxBlobChunkBytes is the largest chunk a blob is split into, and the
+	// largest chunk a client accepts.
+	MaxBlobChunkBytes = 64 * 1024
+	// blobTimeout bounds fetching one chunk from one peer.
+	blobTimeout = 10 * time.Second
+	// maxBlobRequestBytes limits how much of a request a server reads.
+	maxBlobRequestBytes = 1024
+	// maxBlobResponseBytes limits how much of a response a client reads. The
+	// chunk is base64 encoded in JSON, so allow for the overhead.
+	maxBlobResponseBytes = 2 * MaxBlobChunkBytes
+)
+
+// BlobRequest asks a peer for one chunk by its content address.
+type BlobRequest struct {
+	Hash string `json:"hash"`
+}
+
+// BlobResponse carries the requested chunk, if the peer has it.
+type BlobResponse struct {
+	Found bool   `json:"found"`
+	Data  []byte `json:"data,omitempty"`
+}
+
+// BlobHandler looks up a chunk requested by a remote peer.
+type BlobHandler func(from peer.ID, hash string) ([]byte, bool)
+
+// RegisterBlobHandler serves the blob protocol on the host.
+func RegisterBlobHandler(h host.Host, handler BlobHandler) {
+	h.SetStreamHandler(BlobProtocolID, func(s network.Stream) {
+		defer s.Close()
+		s.SetDeadline(time.Now().Add(blobTimeout))
+
+		var req BlobRequest
+		if err := json.NewDecoder(io.LimitReader(s, maxBlobRequestBytes)).Decode(&req); err != nil {
+			log.Printf("Blob: Invalid request from %s: %v\n", s.Conn().RemotePeer(), err)
+			s.Reset()
+			return
+		}
+
+		var resp BlobResponse
+		resp.Data, resp.Found = handler(s.Conn().RemotePeer(), req.Hash)
+		if err := json.NewEncoder(s).Encode(resp); err != nil {
+			log.Printf("Blob: Failed to send response to %s: %v\n", s.Conn().RemotePeer(), err)
+			s.Reset()
+		}
+	})
+}
+
+// ErrBlobNotFound is returned by RequestBlob when the peer doesn't have the chunk.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// RequestBlob asks peer p for the chunk with the given content address.
+// The data comes from an untrusted peer and must be verified by the caller.
+func RequestBlob(ctx context.Context, h host.Host, p peer.ID, hash string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, blobTimeout)
+	defer cancel()
+
+	s, err := h.NewStream(ctx, p, BlobProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(s).Encode(BlobRequest{Hash: hash}); err != nil {
+		s.Reset()
+		return nil, fmt.Errorf("sending blob request: %w", err)
+	}
+	// Signal the end of the request so the server can answer
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	var resp BlobResponse
+	if err := json.NewDecoder(io.LimitReader(s, maxBlobResponseBytes)).Decode(&resp); err != nil {
+		s.Reset()
+		return nil, fmt.Errorf("reading blob response: %w", err)
+	}
+	if !resp.Found {
+		return nil, ErrBlobNotFound
+	}
+	if len(resp.Data) > MaxBlobChunkBytes {
+		return nil, fmt.Errorf("chunk is larger than %d bytes", MaxBlobChunkBytes)
+	}
+	return resp.Data, nil
+}
+
+// HelloProtocolID is the stream protocol peers use to tell each other which
+// socli version, message schema and features they run.
+const HelloProtocolID = protocol.ID("/socli/hello/1.0.0")
+
+const (
+	// helloTimeout bounds a whole hello exchange with one peer.
+	helloTimeout = 10 * time.Second
+	// maxHelloBytes limits how much of a hello either side reads.
+	maxHelloBytes = 4 * 1024
+)
+
+// Hello describes the socli build a peer runs.
+type Hello struct {
+	Version  string   `json:"version"`  // Application version, e.g. "0.2.0"
+	Schema   int      `json:"schema"`   // Message schema version
+	Features []string `json:"features"` // Optional protocols and message types it supports
+}
+
+// ErrHelloNotSupported is returned by SayHello when the peer doesn't speak the
+// hello protocol, which means it runs a socli build from before versioning.
+var ErrHelloNotSupported = errors.New("peer does not support the hello protocol")
+
+// HelloHandler is told about the hello received from a remote peer.
+type HelloHandler func(from peer.ID, hello Hello)
+
+// RegisterHelloHandler answers hellos from other peers with ours, and passes
+// theirs to handler.
+func RegisterHelloHandler(h host.Host, ours Hello, handler HelloHandler) {
+	h.SetStreamHandler(HelloProtocolID, func(s network.Stream) {
+		defer s.Close()
+		s.SetDeadline(time.Now().Add(helloTimeout))
+
+		var theirs Hello
+		if err := json.NewDecoder(io.LimitReader(s, maxHelloBytes)).Decode(&theirs); err != nil {
+			log.Printf("Hello: Invalid hello from %s: %v\n", s.Conn().RemotePeer(), err)
+			s.Reset()
+			return
+		}
+		handler(s.Conn().RemotePeer(), theirs)
+
+		if err := json.NewEncoder(s).Encode(ours); err != nil {
+			log.Printf("Hello: Failed to answer %s: %v\n", s.Conn().RemotePeer(), err)
+			s.Reset()
+		}
+	})
+}
+
+// SayHello sends our hello to peer p and returns the one it answers with.
+func SayHello(ctx context.Context, h host.Host, p peer.ID, ours Hello) (Hello, error) {
+	ctx, cancel := context.WithTimeout(ctx, helloTimeout)
+	defer cancel()
+
+	s, err := h.NewStream(ctx, p, HelloProtocolID)
+	if err != nil {
+		// Opening a stream waits for identify, so the peerstore knows what the
+		// peer supports by now
+		if protocols, _ := h.Peerstore().GetProtocols(p); len(protocols) > 0 {
+			if supported, _ := h.Peerstore().SupportsProtocols(p, HelloProtocolID); len(supported) == 0 {
+				return Hello{}, ErrHelloNotSupported
+			}
+		}
+		return Hello{}, err
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(s).Encode(ours); err != nil {
+		s.Reset()
+		return Hello{}, fmt.Errorf("sending hello: %w", err)
+	}
+	// Signal the end of our hello so the peer can answer
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return Hello{}, err
+	}
+
+	var theirs Hello
+	if err := json.NewDecoder(io.LimitReader(s, maxHelloBytes)).Decode(&theirs); err != nil {
+		s.Reset()
+		return Hello{}, fmt.Errorf("reading hello: %w", err)
+	}
+	return theirs, nil
+}
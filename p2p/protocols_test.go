@@ -0,0 +1,142 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// TestRequestSync tests a sync request and response round trip between two hosts.
+func TestRequestSync(t *testing.T) {
+	mn := mocknet.New()
+	defer mn.Close()
+	server, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	client, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+
+	var gotFrom peer.ID
+	var gotReq SyncRequest
+	RegisterSyncHandler(server, func(from peer.ID, req SyncRequest) []json.RawMessage {
+		gotFrom, gotReq = from, req
+		return []json.RawMessage{json.RawMessage(`{"id":"1"}`), json.RawMessage(`{"id":"2"}`)}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := SyncRequest{Hashtags: []string{"general"}, Since: time.Now().Add(-time.Hour).UTC(), Limit: 10}
+	posts, err := RequestSync(ctx, client, server.ID(), req)
+	if err != nil {
+		t.Fatalf("RequestSync() error = %v, want nil", err)
+	}
+
+	if len(posts) != 2 || string(posts[0]) != `{"id":"1"}` || string(posts[1]) != `{"id":"2"}` {
+		t.Errorf("RequestSync() = %s, want the two posts sent by the handler", posts)
+	}
+	if gotFrom != client.ID() {
+		t.Errorf("Handler saw request from %s, want %s", gotFrom, client.ID())
+	}
+	if len(gotReq.Hashtags) != 1 || gotReq.Hashtags[0] != "general" || gotReq.Limit != 10 || !gotReq.Since.Equal(req.Since) {
+		t.Errorf("Handler got request %+v, want %+v", gotReq, req)
+	}
+
+	// A peer without the protocol returns an error instead of hanging
+	if _, err := RequestSync(ctx, server, client.ID(), req); err == nil {
+		t.Error("RequestSync() to a peer without the protocol error = nil, want an error")
+	}
+}
+
+// TestRequestBlob tests fetching a chunk that the peer has and one it doesn't.
+func TestRequestBlob(t *testing.T) {
+	mn := mocknet.New()
+	defer mn.Close()
+	server, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	client, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+
+	RegisterBlobHandler(server, func(from peer.ID, hash string) ([]byte, bool) {
+		if hash == "known" {
+			return []byte("chunk data"), true
+		}
+		return nil, false
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	data, err := RequestBlob(ctx, client, server.ID(), "known")
+	if err != nil {
+		t.Fatalf("RequestBlob() error = %v, want nil", err)
+	}
+	if string(data) != "chunk data" {
+		t.Errorf("RequestBlob() = %q, want %q", data, "chunk data")
+	}
+
+	if _, err := RequestBlob(ctx, client, server.ID(), "unknown"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("RequestBlob() for a missing chunk error = %v, want ErrBlobNotFound", err)
+	}
+}
+
+// TestSayHello tests the hello exchange, and that peers without the protocol
+// are recognized.
+func TestSayHello(t *testing.T) {
+	mn := mocknet.New()
+	defer mn.Close()
+	server, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	client, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+
+	serverHello := Hello{Version: "1.1.0", Schema: 2, Features: []string{"sync"}}
+	clientHello := Hello{Version: "1.0.0", Schema: 1}
+	received := make(chan Hello, 1)
+	RegisterHelloHandler(server, serverHello, func(from peer.ID, hello Hello) {
+		if from == client.ID() {
+			received <- hello
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got, err := SayHello(ctx, client, server.ID(), clientHello)
+	if err != nil {
+		t.Fatalf("SayHello() error = %v, want nil", err)
+	}
+	if got.Version != serverHello.Version || got.Schema != serverHello.Schema || len(got.Features) != 1 {
+		t.Errorf("SayHello() = %+v, want %+v", got, serverHello)
+	}
+	if hello := <-received; hello.Version != clientHello.Version || hello.Schema != clientHello.Schema {
+		t.Errorf("Handler got %+v, want %+v", hello, clientHello)
+	}
+
+	// The client doesn't serve the protocol, like a build from before versioning
+	if _, err := SayHello(ctx, server, client.ID(), serverHello); !errors.Is(err, ErrHelloNotSupported) {
+		t.Errorf("SayHello() to a peer without the protocol error = %v, want ErrHelloNotSupported", err)
+	}
+}
@@ -2,31 +2,118 @@ package p2p
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"socli/config"
+	"sync"
+	"time"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // Ensure PubSubManager implements PubSubManagerInterface.
 var _ PubSubManagerInterface = (*PubSubManager)(nil)
 
+// scoreInspectInterval is how often the router reports peer scores to us.
+const scoreInspectInterval = 5 * time.Second
+
 // PubSubManager manages the GossipSub protocol for real-time messaging.
 type PubSubManager struct {
-	ps *pubsub.PubSub
+	ps  *pubsub.PubSub
+	cfg *config.Config
+
+	// topics caches joined topics, since pubsub only allows joining a topic once.
+	mu     sync.Mutex
+	topics map[string]*pubsub.Topic
+	// validator is the application-level validator run after the built-in checks.
+	validator pubsub.ValidatorEx
+
+	scoresMu sync.RWMutex
+	scores   map[peer.ID]float64
+}
+
+// contentMessageID derives the pubsub message ID from the topic and payload
+// instead of the publisher and sequence number, so the same post republished
+// or relayed by several peers is only delivered once per topic.
+// The topic is part of the hash because the seen-messages cache is shared by
+// all topics, and a post published to several hashtags must reach each of them.
+func contentMessageID(pmsg *pb.Message) string {
+	h := sha256.New()
+	h.Write([]byte(pmsg.GetTopic()))
+	h.Write([]byte{0})
+	h.Write(pmsg.GetData())
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // NewPubSubManager creates a new GossipSub router.
-func NewPubSubManager(ctx context.Context, h host.Host) (*PubSubManager, error) {
-	ps, err := pubsub.NewGossipSub(ctx, h)
+// If peer scoring is enabled in the config, misbehaving peers are penalized
+// according to the configured score parameters and thresholds. Extra router
+// options, such as tracers used by the simulator, are applied last.
+func NewPubSubManager(ctx context.Context, h host.Host, cfg *config.Config, extra ...pubsub.Option) (*PubSubManager, error) {
+	psm := &PubSubManager{
+		cfg:    cfg,
+		topics: make(map[string]*pubsub.Topic),
+		scores: make(map[peer.ID]float64),
+	}
+
+	opts := []pubsub.Option{pubsub.WithMessageIdFn(contentMessageID)}
+	if cfg.PubSub.EnablePeerScoring {
+		opts = append(opts,
+			pubsub.WithPeerScore(peerScoreParams(cfg), peerScoreThresholds(cfg)),
+			pubsub.WithPeerScoreInspect(psm.updateScores, scoreInspectInterval),
+		)
+	}
+	opts = append(opts, extra...)
+
+	ps, err := pubsub.NewGossipSub(ctx, h, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return &PubSubManager{ps: ps}, nil
+	psm.ps = ps
+	return psm, nil
+}
+
+// SetMessageValidator sets an application-level validator that runs on every
+// topic joined afterwards. Call it before joining any topics.
+func (psm *PubSubManager) SetMessageValidator(v pubsub.ValidatorEx) {
+	psm.mu.Lock()
+	defer psm.mu.Unlock()
+	psm.validator = v
 }
 
 // JoinTopic subscribes to a given topic (hashtag).
+// Joining a topic that was already joined returns the existing handle.
 func (psm *PubSubManager) JoinTopic(topicName string) (*pubsub.Topic, error) {
-	return psm.ps.Join(topicName)
+	psm.mu.Lock()
+	defer psm.mu.Unlock()
+
+	if topic, ok := psm.topics[topicName]; ok {
+		return topic, nil
+	}
+
+	// Register the validator before joining so no message slips through unchecked
+	if err := psm.ps.RegisterTopicValidator(topicName, psm.validate); err != nil {
+		return nil, err
+	}
+
+	topic, err := psm.ps.Join(topicName)
+	if err != nil {
+		psm.ps.UnregisterTopicValidator(topicName)
+		return nil, err
+	}
+
+	if psm.cfg.PubSub.EnablePeerScoring {
+		if err := topic.SetScoreParams(topicScoreParams(psm.cfg, topicName)); err != nil {
+			log.Printf("Warning: Could not set score parameters for topic %s: %v", topicName, err)
+		}
+	}
+
+	psm.topics[topicName] = topic
+	return topic, nil
 }
 
 // PublishMessage broadcasts a message to a topic.
@@ -38,3 +125,45 @@ func (psm *PubSubManager) PublishMessage(ctx context.Context, topic *pubsub.Topi
 func (psm *PubSubManager) SubscribeToTopic(topic *pubsub.Topic) (*pubsub.Subscription, error) {
 	return topic.Subscribe()
 }
+
+// ListPeers returns the connected peers we know to be subscribed to a topic.
+// A message published while there are none reaches nobody.
+func (psm *PubSubManager) ListPeers(topicName string) []peer.ID {
+	return psm.ps.ListPeers(topicName)
+}
+
+// PeerScores returns the most recent GossipSub score of each known peer.
+// The map is empty if peer scoring is disabled.
+func (psm *PubSubManager) PeerScores() map[peer.ID]float64 {
+	psm.scoresMu.RLock()
+	defer psm.scoresMu.RUnlock()
+	scores := make(map[peer.ID]float64, len(psm.scores))
+	for id, score := range psm.scores {
+		scores[id] = score
+	}
+	return scores
+}
+
+// updateScores is called periodically by the router with a snapshot of all peer scores.
+func (psm *PubSubManager) updateScores(scores map[peer.ID]float64) {
+	psm.scoresMu.Lock()
+	defer psm.scoresMu.Unlock()
+	psm.scores = scores
+}
+
+// validate is registered on every joined topic. Messages it rejects count as
+// invalid deliveries and lower the score of the peer that sent them.
+func (psm *PubSubManager) validate(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	if maxSize := psm.cfg.PubSub.MaxMessageSize; maxSize > 0 && len(msg.Data) > maxSize {
+		log.Printf("PubSub: Rejecting oversized message (%d bytes) from %s", len(msg.Data), from.String())
+		return pubsub.ValidationReject
+	}
+
+	psm.mu.Lock()
+	validator := psm.validator
+	psm.mu.Unlock()
+	if validator != nil {
+		return validator(ctx, from, msg)
+	}
+	return pubsub.ValidationAccept
+}
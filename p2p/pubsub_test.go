@@ -0,0 +1,187 @@
+package p2p
+
+import (
+	"context"
+	"socli/config"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// newTestPubSubManager creates a PubSubManager on an in-memory mock network host.
+func newTestPubSubManager(t *testing.T, cfg *config.Config) *PubSubManager {
+	t.Helper()
+	mn := mocknet.New()
+	t.Cleanup(func() { mn.Close() })
+	h, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	psm, err := NewPubSubManager(ctx, h, cfg)
+	if err != nil {
+		t.Fatalf("NewPubSubManager() error = %v, want nil", err)
+	}
+	return psm
+}
+
+// TestPubSubManagerJoinTopic tests that topics can be joined repeatedly with the default score parameters.
+func TestPubSubManagerJoinTopic(t *testing.T) {
+	psm := newTestPubSubManager(t, config.DefaultConfig())
+
+	first, err := psm.JoinTopic("socli/hashtag/test")
+	if err != nil {
+		t.Fatalf("JoinTopic() error = %v, want nil", err)
+	}
+
+	// Joining again must return the same topic instead of failing
+	second, err := psm.JoinTopic("socli/hashtag/test")
+	if err != nil {
+		t.Fatalf("JoinTopic() on an already joined topic error = %v, want nil", err)
+	}
+	if first != second {
+		t.Error("JoinTopic() returned a different topic handle for an already joined topic")
+	}
+}
+
+// TestPubSubManagerValidate tests the built-in size check and the application validator hook.
+func TestPubSubManagerValidate(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.PubSub.MaxMessageSize = 16
+	psm := newTestPubSubManager(t, cfg)
+
+	small := &pubsub.Message{Message: &pb.Message{Data: []byte("small")}}
+	large := &pubsub.Message{Message: &pb.Message{Data: make([]byte, 17)}}
+
+	if got := psm.validate(context.Background(), "", small); got != pubsub.ValidationAccept {
+		t.Errorf("validate() for a small message = %v, want ValidationAccept", got)
+	}
+	if got := psm.validate(context.Background(), "", large); got != pubsub.ValidationReject {
+		t.Errorf("validate() for an oversized message = %v, want ValidationReject", got)
+	}
+
+	// The application validator runs after the size check
+	psm.SetMessageValidator(func(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+		return pubsub.ValidationIgnore
+	})
+	if got := psm.validate(context.Background(), "", small); got != pubsub.ValidationIgnore {
+		t.Errorf("validate() with an application validator = %v, want ValidationIgnore", got)
+	}
+}
+
+// TestContentMessageID tests that message IDs depend only on topic and payload.
+func TestContentMessageID(t *testing.T) {
+	topicA, topicB := "socli/hashtag/a", "socli/hashtag/b"
+	data := []byte("signed post")
+
+	// Different publishers and sequence numbers don't change the ID
+	first := contentMessageID(&pb.Message{Topic: &topicA, Data: data, From: []byte("peer1"), Seqno: []byte{1}})
+	second := contentMessageID(&pb.Message{Topic: &topicA, Data: data, From: []byte("peer2"), Seqno: []byte{2}})
+	if first != second {
+		t.Errorf("contentMessageID() = %s and %s for the same topic and data, want equal", first, second)
+	}
+
+	// The same post on another topic must not be treated as already seen
+	if other := contentMessageID(&pb.Message{Topic: &topicB, Data: data}); other == first {
+		t.Error("contentMessageID() is the same for different topics, want different")
+	}
+
+	if other := contentMessageID(&pb.Message{Topic: &topicA, Data: []byte("another post")}); other == first {
+		t.Error("contentMessageID() is the same for different data, want different")
+	}
+}
+
+// TestPubSubManagerMultiTopicDelivery tests that a post published to several
+// topics reaches a peer on each topic, and that republishing it is deduplicated.
+func TestPubSubManagerMultiTopicDelivery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mn := mocknet.New()
+	defer mn.Close()
+	managers := make([]*PubSubManager, 2)
+	for i := range managers {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatalf("Failed to create mock host: %v", err)
+		}
+		if managers[i], err = NewPubSubManager(ctx, h, config.DefaultConfig()); err != nil {
+			t.Fatalf("NewPubSubManager() error = %v, want nil", err)
+		}
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		t.Fatalf("Failed to connect peers: %v", err)
+	}
+
+	topicNames := []string{"socli/hashtag/a", "socli/hashtag/b"}
+	subs := make([]*pubsub.Subscription, len(topicNames))
+	pubTopics := make([]*pubsub.Topic, len(topicNames))
+	for i, name := range topicNames {
+		topic, err := managers[1].JoinTopic(name)
+		if err != nil {
+			t.Fatalf("JoinTopic() error = %v, want nil", err)
+		}
+		if subs[i], err = managers[1].SubscribeToTopic(topic); err != nil {
+			t.Fatalf("SubscribeToTopic() error = %v, want nil", err)
+		}
+		if pubTopics[i], err = managers[0].JoinTopic(name); err != nil {
+			t.Fatalf("JoinTopic() error = %v, want nil", err)
+		}
+	}
+
+	// Wait for the publisher to see the subscriber on both topics
+	for _, topic := range pubTopics {
+		for len(topic.ListPeers()) == 0 {
+			select {
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for topic peers")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	for _, name := range topicNames {
+		if peers := managers[0].ListPeers(name); len(peers) != 1 {
+			t.Errorf("ListPeers(%q) = %v, want just the subscriber", name, peers)
+		}
+	}
+	if peers := managers[0].ListPeers("socli/hashtag/nobody"); len(peers) != 0 {
+		t.Errorf("ListPeers() of a topic without subscribers = %v, want none", peers)
+	}
+
+	data := []byte("post tagged #a and #b")
+	for _, topic := range pubTopics {
+		if err := managers[0].PublishMessage(ctx, topic, data); err != nil {
+			t.Fatalf("PublishMessage() error = %v, want nil", err)
+		}
+	}
+	// Republishing the same payload yields the same ID and is dropped
+	if err := managers[0].PublishMessage(ctx, pubTopics[0], data); err != nil {
+		t.Fatalf("PublishMessage() error = %v, want nil", err)
+	}
+
+	for i, sub := range subs {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			t.Fatalf("Next() on %s error = %v, want nil", topicNames[i], err)
+		}
+		if string(msg.Data) != string(data) {
+			t.Errorf("Next() on %s data = %q, want %q", topicNames[i], msg.Data, data)
+		}
+	}
+
+	// No duplicate should follow on the first topic
+	shortCtx, shortCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer shortCancel()
+	if msg, err := subs[0].Next(shortCtx); err == nil {
+		t.Errorf("Next() returned duplicate message %q, want none", msg.Data)
+	}
+}
@@ -0,0 +1,74 @@
+package p2p
+
+import (
+	"socli/config"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// decayToZero is the value below which a decaying score counter is reset to zero.
+const decayToZero = 0.01
+
+// peerScoreParams builds the router-wide GossipSub score parameters from the config.
+// Topic parameters are set per topic when it is joined, see topicScoreParams.
+func peerScoreParams(cfg *config.Config) *pubsub.PeerScoreParams {
+	return &pubsub.PeerScoreParams{
+		Topics: make(map[string]*pubsub.TopicScoreParams),
+		// There is no application-specific reputation yet
+		AppSpecificScore:            func(peer.ID) float64 { return 0 },
+		AppSpecificWeight:           1,
+		IPColocationFactorWeight:    cfg.PubSub.IPColocationFactorWeight,
+		IPColocationFactorThreshold: cfg.PubSub.IPColocationFactorThreshold,
+		BehaviourPenaltyWeight:      cfg.PubSub.BehaviourPenaltyWeight,
+		BehaviourPenaltyDecay:       scoreDecay(cfg.PubSub.BehaviourPenaltyDecay, cfg.PubSub.DecayInterval),
+		DecayInterval:               cfg.PubSub.DecayInterval,
+		DecayToZero:                 decayToZero,
+		RetainScore:                 cfg.PubSub.RetainScore,
+	}
+}
+
+// peerScoreThresholds builds the GossipSub score thresholds from the config.
+func peerScoreThresholds(cfg *config.Config) *pubsub.PeerScoreThresholds {
+	t := cfg.PubSub.Thresholds
+	return &pubsub.PeerScoreThresholds{
+		GossipThreshold:             t.Gossip,
+		PublishThreshold:            t.Publish,
+		GraylistThreshold:           t.Graylist,
+		AcceptPXThreshold:           t.AcceptPX,
+		OpportunisticGraftThreshold: t.OpportunisticGraft,
+	}
+}
+
+// topicScoreParams returns the score parameters for a topic, falling back to
+// the default topic parameters if the topic has no entry of its own.
+func topicScoreParams(cfg *config.Config, topicName string) *pubsub.TopicScoreParams {
+	tc, ok := cfg.PubSub.TopicScores[topicName]
+	if !ok {
+		tc = cfg.PubSub.DefaultTopicScore
+	}
+	return &pubsub.TopicScoreParams{
+		TopicWeight:                    tc.TopicWeight,
+		TimeInMeshWeight:               tc.TimeInMeshWeight,
+		TimeInMeshQuantum:              tc.TimeInMeshQuantum,
+		TimeInMeshCap:                  tc.TimeInMeshCap,
+		FirstMessageDeliveriesWeight:   tc.FirstMessageDeliveriesWeight,
+		FirstMessageDeliveriesDecay:    scoreDecay(tc.FirstMessageDeliveriesDecay, cfg.PubSub.DecayInterval),
+		FirstMessageDeliveriesCap:      tc.FirstMessageDeliveriesCap,
+		InvalidMessageDeliveriesWeight: tc.InvalidMessageDeliveriesWeight,
+		InvalidMessageDeliveriesDecay:  scoreDecay(tc.InvalidMessageDeliveriesDecay, cfg.PubSub.DecayInterval),
+		// Mesh delivery rates are unpredictable for chat traffic, so they are not scored
+		MeshMessageDeliveriesWeight: 0,
+		MeshFailurePenaltyWeight:    0,
+	}
+}
+
+// scoreDecay converts "decays to zero after d" into the per-interval decay factor
+// GossipSub expects. A zero duration yields 0, which disables decay-based params.
+func scoreDecay(d, interval time.Duration) float64 {
+	if d <= 0 || interval <= 0 {
+		return 0
+	}
+	return pubsub.ScoreParameterDecayWithBase(d, interval, decayToZero)
+}
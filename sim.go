@@ -0,0 +1,263 @@
+// sim.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"socli/config"
+	"socli/internal/swarm"
+	"socli/messaging"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+// simSettings holds the parameters of a simulation run.
+type simSettings struct {
+	peers         int
+	degree        int
+	hashtags      []string
+	duration      time.Duration
+	rate          float64
+	latency       time.Duration
+	loss          float64
+	churn         float64
+	churnInterval time.Duration
+	drain         time.Duration
+	seed          int64
+}
+
+// runSim runs many socli peers in one process on a simulated network, publishes
+// synthetic posts through their Broadcasters and reports how well the posts
+// spread on each hashtag. It returns the exit code.
+func runSim(args []string) int {
+	fs := flag.NewFlagSet("sim", flag.ExitOnError)
+	peers := fs.Int("peers", 20, "Number of simulated peers")
+	degree := fs.Int("degree", 4, "Connections per peer")
+	topics := fs.String("topics", "general,go,ops", "Comma-separated hashtags every peer subscribes to")
+	duration := fs.Duration("duration", 20*time.Second, "How long to publish posts")
+	rate := fs.Float64("rate", 5, "Posts per second across the whole swarm")
+	latency := fs.Duration("latency", 20*time.Millisecond, "One-way latency of every link")
+	loss := fs.Float64("loss", 0, "Fraction of message copies lost on each link (0-1)")
+	churn := fs.Float64("churn", 0, "Fraction of peers offline at any time (0-1)")
+	churnInterval := fs.Duration("churn-interval", 5*time.Second, "How often a new set of peers goes offline")
+	drain := fs.Duration("drain", 5*time.Second, "How long to wait for late deliveries after publishing stops")
+	seed := fs.Int64("seed", 0, "Random seed; 0 picks one from the clock")
+	verbose := fs.Bool("v", false, "Show the peers' logs")
+	fs.Parse(args)
+
+	settings := simSettings{
+		peers:         *peers,
+		degree:        *degree,
+		duration:      *duration,
+		rate:          *rate,
+		latency:       *latency,
+		loss:          *loss,
+		churn:         *churn,
+		churnInterval: *churnInterval,
+		drain:         *drain,
+		seed:          *seed,
+	}
+	for _, hashtag := range strings.Split(*topics, ",") {
+		if hashtag = strings.TrimPrefix(strings.TrimSpace(hashtag), "#"); hashtag != "" {
+			settings.hashtags = append(settings.hashtags, hashtag)
+		}
+	}
+	if settings.peers < 2 || len(settings.hashtags) == 0 || settings.rate <= 0 ||
+		settings.loss < 0 || settings.loss >= 1 || settings.churn < 0 || settings.churn >= 1 {
+		fmt.Fprintf(os.Stderr, "Invalid simulation settings: need at least 2 peers, a hashtag, a positive rate, and loss and churn below 1\n")
+		return 1
+	}
+	if settings.seed == 0 {
+		settings.seed = time.Now().UnixNano()
+	}
+	// Every peer logs validation and sync events, which would drown the report
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	stats, err := simulate(ctx, settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Simulation failed: %v\n", err)
+		return 1
+	}
+	printSimReport(os.Stdout, settings, stats)
+	return 0
+}
+
+// simulate runs one simulation and returns the statistics per hashtag.
+func simulate(ctx context.Context, settings simSettings) ([]swarm.TopicStats, error) {
+	rng := rand.New(rand.NewSource(settings.seed))
+	recorder := swarm.NewRecorder()
+	// Every node drops messages with its own random source, seeded by its
+	// index, so a seed always gives each node the same sequence of losses
+	linkSeed := rng.Int63()
+
+	s, err := swarm.New(ctx, settings.peers,
+		swarm.WithLinkOptions(mocknet.LinkOptions{Latency: settings.latency}),
+		swarm.WithConfig(func(i int, cfg *config.Config) {
+			// Every peer has its own key, so encrypted posts would be unreadable
+			cfg.Privacy.EncryptMessages = false
+			// High publish rates would otherwise measure the rate limits, not propagation
+			cfg.RateLimit.Enabled = false
+		}),
+		swarm.WithPubSubOptions(func(i int) []pubsub.Option {
+			return []pubsub.Option{pubsub.WithRawTracer(recorder.Tracer()), swarm.LossyLinks(settings.loss, rand.New(rand.NewSource(linkSeed+int64(i))))}
+		}),
+		swarm.WithDeliveryHook(func(i int, hashtag string, msg *messaging.Message) {
+			recorder.Delivered(i, msg.ID, time.Now())
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	if err := s.ConnectRandom(settings.degree, rng); err != nil {
+		return nil, err
+	}
+	for _, hashtag := range settings.hashtags {
+		if err := s.SubscribeAll(hashtag); err != nil {
+			return nil, err
+		}
+		if err := s.WaitForMesh(ctx, hashtag); err != nil {
+			return nil, err
+		}
+	}
+
+	trafficCtx, cancel := context.WithTimeout(ctx, settings.duration)
+	defer cancel()
+	var wg sync.WaitGroup
+	if settings.churn > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runChurn(trafficCtx, s, settings, rand.New(rand.NewSource(rng.Int63())))
+		}()
+	}
+
+	// Publish at a steady rate from random online peers on random hashtags
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / settings.rate))
+	defer ticker.Stop()
+	for seq := 1; ; seq++ {
+		select {
+		case <-trafficCtx.Done():
+		case <-ticker.C:
+			publishSimPost(ctx, s, settings, rng, recorder, seq)
+			continue
+		}
+		break
+	}
+	wg.Wait()
+
+	// Bring everyone back and give late deliveries a chance
+	for i := range s.Nodes {
+		if err := s.Rejoin(i); err != nil {
+			return nil, err
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(settings.drain):
+	}
+	return recorder.Stats(messaging.GetTopicForHashtag), nil
+}
+
+// publishSimPost publishes one synthetic post from a random online peer and
+// records which peers should receive it.
+func publishSimPost(ctx context.Context, s *swarm.Swarm, settings simSettings, rng *rand.Rand, recorder *swarm.Recorder, seq int) {
+	var online []int
+	for i := range s.Nodes {
+		if !s.Isolated(i) {
+			online = append(online, i)
+		}
+	}
+	if len(online) == 0 {
+		return
+	}
+	author := online[rng.Intn(len(online))]
+	hashtag := settings.hashtags[rng.Intn(len(settings.hashtags))]
+
+	var expected []int
+	for _, i := range online {
+		if i != author {
+			expected = append(expected, i)
+		}
+	}
+
+	node := s.Nodes[author]
+	msg, err := node.NewPost(fmt.Sprintf("Simulated post %d #%s", seq, hashtag))
+	if err != nil {
+		log.Printf("Sim: Peer %d failed to sign a post: %v\n", author, err)
+		return
+	}
+	// Record before publishing, since deliveries can arrive before Publish returns
+	recorder.Published(msg.ID, hashtag, time.Now(), expected)
+	if err := node.Publish(ctx, msg); err != nil && ctx.Err() == nil {
+		log.Printf("Sim: Peer %d failed to publish: %v\n", author, err)
+	}
+}
+
+// runChurn takes a new random set of peers offline every churn interval,
+// bringing the previous set back, until ctx is done.
+func runChurn(ctx context.Context, s *swarm.Swarm, settings simSettings, rng *rand.Rand) {
+	offline := int(settings.churn*float64(settings.peers) + 0.5)
+	ticker := time.NewTicker(settings.churnInterval)
+	defer ticker.Stop()
+	for {
+		for i := range s.Nodes {
+			if err := s.Rejoin(i); err != nil {
+				log.Printf("Sim: Peer %d failed to rejoin: %v\n", i, err)
+			}
+		}
+		for _, i := range rng.Perm(settings.peers)[:offline] {
+			if err := s.Isolate(i); err != nil {
+				log.Printf("Sim: Peer %d failed to leave: %v\n", i, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// printSimReport writes the simulation settings and a table of statistics per hashtag.
+func printSimReport(w io.Writer, settings simSettings, stats []swarm.TopicStats) {
+	fmt.Fprintf(w, "Simulated %d peers (degree %d) for %s at %.1f posts/s\n", settings.peers, settings.degree, settings.duration, settings.rate)
+	fmt.Fprintf(w, "Latency %s, loss %.1f%%, churn %.0f%% every %s, seed %d\n\n",
+		settings.latency, settings.loss*100, settings.churn*100, settings.churnInterval, settings.seed)
+
+	fmt.Fprintf(w, "%-16s %6s %9s %10s %9s %9s %9s %9s\n", "Topic", "Posts", "Delivery", "Dups/recv", "p50", "p90", "p99", "max")
+	row := func(name string, s swarm.TopicStats) {
+		fmt.Fprintf(w, "%-16s %6d %8.1f%% %10.2f %9s %9s %9s %9s\n", name, s.Posts, s.DeliveryRatio()*100, s.DuplicateRate(),
+			roundLatency(s.Percentile(0.5)), roundLatency(s.Percentile(0.9)), roundLatency(s.Percentile(0.99)), roundLatency(s.Percentile(1)))
+	}
+	for _, s := range stats {
+		row("#"+s.Hashtag, s)
+	}
+	if len(stats) > 1 {
+		row("all", swarm.Total(stats))
+	}
+}
+
+// roundLatency rounds a latency for display.
+func roundLatency(d time.Duration) time.Duration {
+	return d.Round(100 * time.Microsecond)
+}
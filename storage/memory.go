@@ -1,53 +1,404 @@
 package storage
 
 import (
+	"context"
+	"log"
+	"slices"
 	"socli/messaging" // Import the messaging package for Message struct
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // MemoryStore provides in-memory storage for posts and peers.
 type MemoryStore struct {
-	posts map[string]*messaging.Message
-	peers map[peer.ID]peer.AddrInfo
-	mu    sync.RWMutex
+	posts     map[string]*messaging.Message
+	replies   map[string][]string                   // Reply IDs keyed by the ID of the post they reply to
+	reactions map[string]map[string]map[string]bool // Reacting signers (see signerKey) keyed by post ID and emoji
+	edits     map[string][]string                   // Edit IDs keyed by the ID of the post they edit
+	votes     map[string][]string                   // Vote and closing IDs keyed by the ID of their poll
+	peers     map[peer.ID]peer.AddrInfo
+	chunks    map[string][]byte // Attachment chunks keyed by content address
+	mu        sync.RWMutex
+}
+
+// ReactionCount is how many authors reacted to a post with an emoji.
+type ReactionCount struct {
+	Emoji string
+	Count int
 }
 
 // NewMemoryStore creates a new in-memory store.
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		posts: make(map[string]*messaging.Message),
-		peers: make(map[peer.ID]peer.AddrInfo),
+		posts:     make(map[string]*messaging.Message),
+		replies:   make(map[string][]string),
+		reactions: make(map[string]map[string]map[string]bool),
+		edits:     make(map[string][]string),
+		votes:     make(map[string][]string),
+		peers:     make(map[peer.ID]peer.AddrInfo),
+		chunks:    make(map[string][]byte),
 	}
 }
 
 // AddPost stores a new post in memory.
-func (s *MemoryStore) AddPost(post *messaging.Message) {
+// A post with the same ID is only stored once, since a post with several
+// hashtags arrives once per subscribed topic. It reports whether the post was new.
+// Reactions are counted towards the post they react to, once per signer and
+// emoji; they are kept for history sync but not listed by GetAllPosts.
+// Edits are kept the same way and listed by Revisions, and votes and poll
+// closings are counted by Tally.
+func (s *MemoryStore) AddPost(post *messaging.Message) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if _, exists := s.posts[post.ID]; exists {
+		return false
+	}
 	s.posts[post.ID] = post
+	if post.Type == messaging.ReplyMsg && post.ReplyTo != "" {
+		s.replies[post.ReplyTo] = append(s.replies[post.ReplyTo], post.ID)
+	}
+	if post.Type == messaging.ReactionMsg && post.ReplyTo != "" {
+		emoji := messaging.NormalizeReaction(post.Content)
+		if s.reactions[post.ReplyTo] == nil {
+			s.reactions[post.ReplyTo] = make(map[string]map[string]bool)
+		}
+		if s.reactions[post.ReplyTo][emoji] == nil {
+			s.reactions[post.ReplyTo][emoji] = make(map[string]bool)
+		}
+		s.reactions[post.ReplyTo][emoji][signerKey(post.Author, post.PublicKey)] = true
+	}
+	if post.Type == messaging.EditMsg && post.ReplyTo != "" {
+		s.edits[post.ReplyTo] = append(s.edits[post.ReplyTo], post.ID)
+	}
+	if (post.Type == messaging.VoteMsg || post.Type == messaging.PollCloseMsg) && post.ReplyTo != "" {
+		s.votes[post.ReplyTo] = append(s.votes[post.ReplyTo], post.ID)
+	}
+	return true
 }
 
-// GetPost retrieves a post by its ID.
+// GetPost retrieves a post by its ID. Expired posts aren't returned, even
+// before PurgeExpired removes them, so they are never synced to peers.
 func (s *MemoryStore) GetPost(id string) (*messaging.Message, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	post, found := s.posts[id]
+	if found && post.Expired(time.Now()) {
+		return nil, false
+	}
 	return post, found
 }
 
-// GetAllPosts returns all stored posts.
+// GetAllPosts returns all stored posts that haven't expired, oldest first.
+// Posts synced from peers arrive out of order, so the feed relies on this sorting.
 func (s *MemoryStore) GetAllPosts() []*messaging.Message {
+	now := time.Now()
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	posts := make([]*messaging.Message, 0, len(s.posts))
 	for _, post := range s.posts {
-		posts = append(posts, post)
+		switch {
+		case post.Type == messaging.ReactionMsg, post.Type == messaging.EditMsg, post.Type == messaging.VoteMsg, post.Type == messaging.PollCloseMsg:
+			// Shown as part of the post they refer to
+		case post.Expired(now):
+			// Waiting for PurgeExpired
+		default:
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].Timestamp.Before(posts[j].Timestamp)
+	})
+	return posts
+}
+
+// Replies returns the stored replies to a post, oldest first. Replies can
+// arrive before the post they reply to, so the parent need not be stored.
+func (s *MemoryStore) Replies(id string) []*messaging.Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	replies := make([]*messaging.Message, 0, len(s.replies[id]))
+	for _, replyID := range s.replies[id] {
+		replies = append(replies, s.posts[replyID])
+	}
+	sort.Slice(replies, func(i, j int) bool {
+		return replies[i].Timestamp.Before(replies[j].Timestamp)
+	})
+	return replies
+}
+
+// ReplyCount returns the number of stored direct replies to a post.
+func (s *MemoryStore) ReplyCount(id string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.replies[id])
+}
+
+// Reactions returns the reactions to a post, most frequent first.
+func (s *MemoryStore) Reactions(id string) []ReactionCount {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := make([]ReactionCount, 0, len(s.reactions[id]))
+	for emoji, authors := range s.reactions[id] {
+		counts = append(counts, ReactionCount{Emoji: emoji, Count: len(authors)})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Emoji < counts[j].Emoji
+	})
+	return counts
+}
+
+// HasReacted reports whether an author already reacted to a post with an
+// emoji, signing with the given key.
+func (s *MemoryStore) HasReacted(id, author string, publicKey []byte, reaction string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.reactions[id][messaging.NormalizeReaction(reaction)][signerKey(author, publicKey)]
+}
+
+// Revisions returns a post followed by its edits, oldest first, so the last
+// one holds the current content. Edits can arrive before the post, so they
+// are only checked against its author here; nil means the post isn't stored.
+func (s *MemoryStore) Revisions(id string) []*messaging.Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	original, ok := s.posts[id]
+	if !ok {
+		return nil
+	}
+	edits := make([]*messaging.Message, 0, len(s.edits[id]))
+	for _, editID := range s.edits[id] {
+		if edit := s.posts[editID]; messaging.CheckEdit(original, edit) == nil {
+			edits = append(edits, edit)
+		}
+	}
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Timestamp.Before(edits[j].Timestamp)
+	})
+	return append([]*messaging.Message{original}, edits...)
+}
+
+// PollTally is the state of a poll.
+type PollTally struct {
+	Options []string
+	Counts  []int             // Votes for each option
+	Votes   map[string]string // Each voter's current option, keyed by signerKey
+	Closed  bool
+}
+
+// Voters returns the number of voters.
+func (t PollTally) Voters() int {
+	return len(t.Votes)
+}
+
+// signerKey identifies the author of a vote or reaction by name and signing
+// key, so nobody can change or claim someone else's vote or reaction by
+// using their name.
+func signerKey(author string, publicKey []byte) string {
+	return author + "/" + string(publicKey)
+}
+
+// Tally counts the votes on a poll. Each voter's latest vote counts, and
+// votes cast after the poll's author closed it are ignored. Since a voter
+// chooses its vote's timestamp, so are votes that arrived after the closing,
+// however early they claim to be. It reports false if the poll isn't stored.
+func (s *MemoryStore) Tally(id string) (PollTally, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	poll, ok := s.posts[id]
+	if !ok || poll.Type != messaging.PollMsg || poll.Poll == nil {
+		return PollTally{}, false
+	}
+	tally := PollTally{
+		Options: poll.Poll.Options,
+		Counts:  make([]int, len(poll.Poll.Options)),
+		Votes:   make(map[string]string),
+	}
+
+	// s.votes is in the order the votes and closings arrived in
+	var closedAt time.Time
+	closedIndex := len(s.votes[id])
+	for i, voteID := range s.votes[id] {
+		closing := s.posts[voteID]
+		if closing.Type == messaging.PollCloseMsg && messaging.CheckPollClose(poll, closing) == nil {
+			if !tally.Closed || closing.Timestamp.Before(closedAt) {
+				tally.Closed, closedAt = true, closing.Timestamp
+			}
+			closedIndex = min(closedIndex, i)
+		}
+	}
+	latest := make(map[string]*messaging.Message)
+	for i, voteID := range s.votes[id] {
+		vote := s.posts[voteID]
+		if vote.Type != messaging.VoteMsg || messaging.CheckVote(poll, vote) != nil {
+			continue
+		}
+		if tally.Closed && (vote.Timestamp.After(closedAt) || i > closedIndex) {
+			continue
+		}
+		key := signerKey(vote.Author, vote.PublicKey)
+		if current, ok := latest[key]; !ok || vote.Timestamp.After(current.Timestamp) {
+			latest[key] = vote
+		}
+	}
+	for key, vote := range latest {
+		tally.Votes[key] = vote.Content
+		tally.Counts[slices.Index(tally.Options, vote.Content)]++
+	}
+	return tally, true
+}
+
+// VoteOf returns the option a voter currently votes for in a tally, or "".
+func (t PollTally) VoteOf(author string, publicKey []byte) string {
+	return t.Votes[signerKey(author, publicKey)]
+}
+
+// RecentPosts returns up to limit of the newest posts since the given time that
+// carry at least one of the hashtags and haven't expired, oldest first.
+func (s *MemoryStore) RecentPosts(hashtags []string, since time.Time, limit int) []*messaging.Message {
+	wanted := make(map[string]bool, len(hashtags))
+	for _, tag := range hashtags {
+		wanted[tag] = true
+	}
+
+	now := time.Now()
+	s.mu.RLock()
+	posts := make([]*messaging.Message, 0)
+	for _, post := range s.posts {
+		if post.Timestamp.Before(since) || post.Expired(now) {
+			continue
+		}
+		for _, tag := range post.Hashtags {
+			if wanted[tag] {
+				posts = append(posts, post)
+				break
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].Timestamp.Before(posts[j].Timestamp)
+	})
+	if limit >= 0 && len(posts) > limit {
+		posts = posts[len(posts)-limit:]
 	}
 	return posts
 }
 
+// PurgeExpired removes the posts that expired by now, along with the
+// reactions, edits, votes and closings that refer to them. It returns how
+// many messages it removed in all. Edits of an expiring post expire with it,
+// and so do the chunks of its attachment unless a remaining post shares them.
+func (s *MemoryStore) PurgeExpired(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := len(s.posts)
+	var purged []*messaging.Message
+	purge := func(post *messaging.Message) {
+		s.remove(post)
+		purged = append(purged, post)
+	}
+	for id, post := range s.posts {
+		if !post.Expired(now) {
+			continue
+		}
+		purge(post)
+		for _, refID := range slices.Concat(s.edits[id], s.votes[id]) {
+			if ref, ok := s.posts[refID]; ok {
+				purge(ref)
+			}
+		}
+		for _, ref := range s.posts {
+			if ref.Type == messaging.ReactionMsg && ref.ReplyTo == id {
+				purge(ref)
+			}
+		}
+		delete(s.edits, id)
+		delete(s.votes, id)
+		delete(s.reactions, id)
+	}
+	if len(purged) > 0 {
+		live := make(map[string]bool)
+		for _, post := range s.posts {
+			for _, hash := range attachmentChunks(post) {
+				live[hash] = true
+			}
+		}
+		for _, post := range purged {
+			for _, hash := range attachmentChunks(post) {
+				if !live[hash] {
+					delete(s.chunks, hash)
+				}
+			}
+		}
+	}
+	return stored - len(s.posts)
+}
+
+// attachmentChunks returns the chunks of the files a post carries, its own
+// and that of the post it shares.
+func attachmentChunks(post *messaging.Message) []string {
+	var chunks []string
+	if post.Attachment != nil {
+		chunks = append(chunks, post.Attachment.Chunks...)
+	}
+	if post.Shared != nil && post.Shared.Attachment != nil {
+		chunks = append(chunks, post.Shared.Attachment.Chunks...)
+	}
+	return chunks
+}
+
+// remove deletes a post and takes it off the replies, edits and votes of
+// the post it refers to. The caller must hold the write lock.
+func (s *MemoryStore) remove(post *messaging.Message) {
+	delete(s.posts, post.ID)
+	if post.ReplyTo == "" {
+		return
+	}
+	unlist := func(index map[string][]string) {
+		ids := slices.DeleteFunc(index[post.ReplyTo], func(id string) bool { return id == post.ID })
+		if len(ids) == 0 {
+			delete(index, post.ReplyTo)
+		} else {
+			index[post.ReplyTo] = ids
+		}
+	}
+	switch post.Type {
+	case messaging.ReplyMsg:
+		unlist(s.replies)
+	case messaging.EditMsg:
+		unlist(s.edits)
+	case messaging.VoteMsg, messaging.PollCloseMsg:
+		unlist(s.votes)
+	case messaging.ReactionMsg:
+		delete(s.reactions[post.ReplyTo][messaging.NormalizeReaction(post.Content)], signerKey(post.Author, post.PublicKey))
+	}
+}
+
+// StartPurging calls PurgeExpired every interval until ctx is done.
+func (s *MemoryStore) StartPurging(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if purged := s.PurgeExpired(now); purged > 0 {
+					log.Printf("Store: Purged %d expired messages\n", purged)
+				}
+			}
+		}
+	}()
+}
+
 // AddPeer stores a new peer in memory.
 func (s *MemoryStore) AddPeer(pi peer.AddrInfo) {
 	s.mu.Lock()
@@ -74,11 +425,32 @@ func (s *MemoryStore) GetAllPeers() []peer.AddrInfo {
 	return peers
 }
 
-// Clear removes all posts and peers from the store.
+// PutChunk stores an attachment chunk under its content address.
+// The caller is responsible for checking that hash matches data.
+func (s *MemoryStore) PutChunk(hash string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks[hash] = data
+}
+
+// GetChunk retrieves an attachment chunk by its content address.
+func (s *MemoryStore) GetChunk(hash string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.chunks[hash]
+	return data, ok
+}
+
+// Clear removes all posts, peers and attachment chunks from the store.
 // This satisfies the privacy.Privacy requirement for auto-clear.
 func (s *MemoryStore) Clear() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.posts = make(map[string]*messaging.Message)
+	s.replies = make(map[string][]string)
+	s.reactions = make(map[string]map[string]map[string]bool)
+	s.edits = make(map[string][]string)
+	s.votes = make(map[string][]string)
 	s.peers = make(map[peer.ID]peer.AddrInfo)
+	s.chunks = make(map[string][]byte)
 }
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotScheduled is returned for a scheduled post that isn't in the queue,
+// usually because it was published or cancelled in the meantime.
+var ErrNotScheduled = errors.New("no such scheduled post")
+
+// ScheduledPost is a post held back until At. It isn't signed until it is
+// published, so its timestamp is the time it actually went out.
+type ScheduledPost struct {
+	ID      string    `json:"id"`
+	Content string    `json:"content"`
+	At      time.Time `json:"at"`
+}
+
+// ScheduleQueue holds the posts waiting to be published. The queue lives in
+// memory unless it was given a path, in which case every change is written
+// to that file and the queue is reloaded from it on start.
+type ScheduleQueue struct {
+	posts map[string]ScheduledPost
+	path  string // Where the queue is persisted; "" keeps it in memory only
+	mu    sync.Mutex
+}
+
+// NewScheduleQueue creates a schedule queue. If path is not empty, the posts
+// saved there are loaded; a missing file is an empty queue.
+func NewScheduleQueue(path string) (*ScheduleQueue, error) {
+	q := &ScheduleQueue{posts: make(map[string]ScheduledPost), path: path}
+	if path == "" {
+		return q, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var posts []ScheduledPost
+	if err := json.Unmarshal(data, &posts); err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		q.posts[post.ID] = post
+	}
+	return q, nil
+}
+
+// Persistent reports whether the queue is saved to disk.
+func (q *ScheduleQueue) Persistent() bool {
+	return q.path != ""
+}
+
+// Add schedules a post for publication at the given time. If the queue
+// can't be saved, the post isn't scheduled.
+func (q *ScheduleQueue) Add(content string, at time.Time) (ScheduledPost, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	post := ScheduledPost{ID: uuid.New().String(), Content: content, At: at}
+	q.posts[post.ID] = post
+	if err := q.save(); err != nil {
+		delete(q.posts, post.ID)
+		return ScheduledPost{}, err
+	}
+	return post, nil
+}
+
+// Update changes the content and time of a scheduled post. If the queue
+// can't be saved, the post is left as it was.
+func (q *ScheduleQueue) Update(id, content string, at time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	old, ok := q.posts[id]
+	if !ok {
+		return ErrNotScheduled
+	}
+	q.posts[id] = ScheduledPost{ID: id, Content: content, At: at}
+	if err := q.save(); err != nil {
+		q.posts[id] = old
+		return err
+	}
+	return nil
+}
+
+// Cancel removes a scheduled post from the queue. If the queue can't be
+// saved, the post stays scheduled.
+func (q *ScheduleQueue) Cancel(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	old, ok := q.posts[id]
+	if !ok {
+		return ErrNotScheduled
+	}
+	delete(q.posts, id)
+	if err := q.save(); err != nil {
+		q.posts[id] = old
+		return err
+	}
+	return nil
+}
+
+// Get returns a scheduled post by ID.
+func (q *ScheduleQueue) Get(id string) (ScheduledPost, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	post, ok := q.posts[id]
+	return post, ok
+}
+
+// Pending returns the scheduled posts, the next one due first.
+func (q *ScheduleQueue) Pending() []ScheduledPost {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.sorted()
+}
+
+// Due removes the posts scheduled at or before now from the queue and
+// returns them, oldest first. Posts that fell due while socli wasn't running
+// are returned on the first call. If the queue can't be saved, the posts stay
+// queued and none are returned, so they aren't published again after a restart.
+func (q *ScheduleQueue) Due(now time.Time) ([]ScheduledPost, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []ScheduledPost
+	for _, post := range q.sorted() {
+		if post.At.After(now) {
+			break
+		}
+		due = append(due, post)
+		delete(q.posts, post.ID)
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+	if err := q.save(); err != nil {
+		for _, post := range due {
+			q.posts[post.ID] = post
+		}
+		return nil, err
+	}
+	return due, nil
+}
+
+// sorted returns the posts ordered by the time they are due. The caller must hold q.mu.
+func (q *ScheduleQueue) sorted() []ScheduledPost {
+	posts := make([]ScheduledPost, 0, len(q.posts))
+	for _, post := range q.posts {
+		posts = append(posts, post)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].At.Equal(posts[j].At) {
+			return posts[i].At.Before(posts[j].At)
+		}
+		return posts[i].ID < posts[j].ID
+	})
+	return posts
+}
+
+// save writes the queue to its file, if it has one. The file is only
+// readable by us, since it holds posts that haven't been published yet.
+// It is written to a temporary file that then replaces it, so a failed save
+// never leaves a truncated queue behind. The caller must hold q.mu.
+func (q *ScheduleQueue) save() error {
+	if q.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(q.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	// CreateTemp makes the file readable by us only
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), q.path)
+}
@@ -1,7 +1,12 @@
 package storage
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
 	"socli/messaging"
+	"strings"
 	"testing"
 	"time"
 
@@ -159,6 +164,341 @@ func TestMemoryStorePeers(t *testing.T) {
 	}
 }
 
+// TestMemoryStoreAddPostDuplicate tests that a post arriving on several topics is stored once.
+func TestMemoryStoreAddPostDuplicate(t *testing.T) {
+	store := NewMemoryStore()
+	post := &messaging.Message{
+		ID:        "multi-tag",
+		Author:    "author1",
+		Content:   "Posted to #go #p2p #cli",
+		Hashtags:  []string{"go", "p2p", "cli"},
+		Timestamp: time.Now(),
+		Type:      messaging.PostMsg,
+	}
+
+	if !store.AddPost(post) {
+		t.Error("AddPost() = false for a new post, want true")
+	}
+	// A second copy, as delivered on another hashtag topic
+	duplicate := *post
+	if store.AddPost(&duplicate) {
+		t.Error("AddPost() = true for a duplicate post, want false")
+	}
+
+	if got := len(store.GetAllPosts()); got != 1 {
+		t.Errorf("GetAllPosts() returned %d posts, want 1", got)
+	}
+	if got, _ := store.GetPost("multi-tag"); got != post {
+		t.Error("GetPost() returned the duplicate, want the first copy")
+	}
+}
+
+// TestMemoryStoreRecentPosts tests filtering recent posts by hashtag, time and count.
+func TestMemoryStoreRecentPosts(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	posts := []*messaging.Message{
+		{ID: "old", Hashtags: []string{"go"}, Timestamp: now.Add(-48 * time.Hour)},
+		{ID: "first", Hashtags: []string{"go"}, Timestamp: now.Add(-3 * time.Hour)},
+		{ID: "other", Hashtags: []string{"rust"}, Timestamp: now.Add(-2 * time.Hour)},
+		{ID: "second", Hashtags: []string{"cli", "go"}, Timestamp: now.Add(-time.Hour)},
+		{ID: "third", Hashtags: []string{"p2p"}, Timestamp: now},
+	}
+	for _, post := range posts {
+		store.AddPost(post)
+	}
+
+	tests := []struct {
+		name     string
+		hashtags []string
+		limit    int
+		want     []string
+	}{
+		{"SingleHashtag", []string{"go"}, 10, []string{"first", "second"}},
+		{"SeveralHashtags", []string{"go", "p2p"}, 10, []string{"first", "second", "third"}},
+		{"LimitKeepsNewest", []string{"go", "p2p"}, 2, []string{"second", "third"}},
+		{"UnknownHashtag", []string{"java"}, 10, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := store.RecentPosts(tt.hashtags, now.Add(-24*time.Hour), tt.limit)
+			var ids []string
+			for _, post := range got {
+				ids = append(ids, post.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("RecentPosts() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+// TestMemoryStoreReplies tests that replies are indexed by the post they reply to.
+func TestMemoryStoreReplies(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	posts := []*messaging.Message{
+		{ID: "root", Type: messaging.PostMsg, Timestamp: now.Add(-3 * time.Hour)},
+		{ID: "second", Type: messaging.ReplyMsg, ReplyTo: "root", Timestamp: now.Add(-time.Hour)},
+		{ID: "first", Type: messaging.ReplyMsg, ReplyTo: "root", Timestamp: now.Add(-2 * time.Hour)},
+		{ID: "nested", Type: messaging.ReplyMsg, ReplyTo: "first", Timestamp: now},
+		{ID: "orphan", Type: messaging.ReplyMsg, ReplyTo: "unknown", Timestamp: now},
+	}
+	for _, post := range posts {
+		store.AddPost(post)
+	}
+	// A duplicate is not counted twice
+	store.AddPost(&messaging.Message{ID: "first", Type: messaging.ReplyMsg, ReplyTo: "root"})
+
+	tests := []struct {
+		id   string
+		want []string
+	}{
+		{"root", []string{"first", "second"}},
+		{"first", []string{"nested"}},
+		{"unknown", []string{"orphan"}},
+		{"nested", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			var ids []string
+			for _, post := range store.Replies(tt.id) {
+				ids = append(ids, post.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Replies(%q) = %v, want %v", tt.id, ids, tt.want)
+			}
+			if got := store.ReplyCount(tt.id); got != len(tt.want) {
+				t.Errorf("ReplyCount(%q) = %d, want %d", tt.id, got, len(tt.want))
+			}
+		})
+	}
+
+	store.Clear()
+	if got := store.ReplyCount("root"); got != 0 {
+		t.Errorf("ReplyCount() after Clear() = %d, want 0", got)
+	}
+}
+
+// TestMemoryStoreReactions tests that reactions are counted once per author
+// and emoji, and kept out of the feed.
+func TestMemoryStoreReactions(t *testing.T) {
+	store := NewMemoryStore()
+	store.AddPost(&messaging.Message{ID: "post", Type: messaging.PostMsg, Timestamp: time.Now()})
+	reactions := []struct{ id, author, key, emoji string }{
+		{"r1", "alice", "alice-key", "👍"},
+		{"r2", "bob", "bob-key", "👍"},
+		{"r3", "alice", "alice-key", ":+1:"}, // The same reaction as a shortcode
+		{"r4", "alice", "alice-key", "🎉"},
+		{"r5", "carol", "carol-key", "👀"},
+		{"r6", "bob", "bob-key", "👍"},
+		{"r7", "alice", "mallory-key", "👀"}, // Claiming alice's name doesn't claim her reactions
+	}
+	for _, r := range reactions {
+		store.AddPost(&messaging.Message{ID: r.id, Author: r.author, PublicKey: []byte(r.key), Content: r.emoji, Type: messaging.ReactionMsg, ReplyTo: "post", Timestamp: time.Now()})
+	}
+
+	want := []ReactionCount{{"👀", 2}, {"👍", 2}, {"🎉", 1}}
+	if got := store.Reactions("post"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Reactions() = %v, want %v", got, want)
+	}
+	if !store.HasReacted("post", "alice", []byte("alice-key"), ":thumbsup:") || store.HasReacted("post", "carol", []byte("carol-key"), "👍") {
+		t.Error("HasReacted() doesn't match the stored reactions")
+	}
+	if store.HasReacted("post", "alice", []byte("alice-key"), "👀") {
+		t.Error("HasReacted() = true for a reaction signed with another key")
+	}
+	if got := len(store.GetAllPosts()); got != 1 {
+		t.Errorf("GetAllPosts() returned %d posts, want just the post, not its reactions", got)
+	}
+
+	store.Clear()
+	if got := store.Reactions("post"); len(got) != 0 {
+		t.Errorf("Reactions() after Clear() = %v, want none", got)
+	}
+}
+
+// TestMemoryStoreRevisions tests that a post's edits are listed in order and
+// that edits by anyone but its author are left out.
+func TestMemoryStoreRevisions(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	key := []byte("alice-key")
+	if got := store.Revisions("post"); got != nil {
+		t.Errorf("Revisions() of an unknown post = %v, want nil", got)
+	}
+
+	// The second edit arrives first
+	store.AddPost(&messaging.Message{ID: "e2", Author: "alice", PublicKey: key, Content: "v3", Type: messaging.EditMsg, ReplyTo: "post", Timestamp: now.Add(2 * time.Minute)})
+	store.AddPost(&messaging.Message{ID: "post", Author: "alice", PublicKey: key, Content: "v1", Type: messaging.PostMsg, Timestamp: now})
+	store.AddPost(&messaging.Message{ID: "e1", Author: "alice", PublicKey: key, Content: "v2", Type: messaging.EditMsg, ReplyTo: "post", Timestamp: now.Add(time.Minute)})
+	store.AddPost(&messaging.Message{ID: "forged", Author: "alice", PublicKey: []byte("mallory-key"), Content: "pwned", Type: messaging.EditMsg, ReplyTo: "post", Timestamp: now.Add(3 * time.Minute)})
+
+	var got []string
+	for _, revision := range store.Revisions("post") {
+		got = append(got, revision.Content)
+	}
+	if want := []string{"v1", "v2", "v3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Revisions() = %v, want %v", got, want)
+	}
+	if got := len(store.GetAllPosts()); got != 1 {
+		t.Errorf("GetAllPosts() returned %d posts, want just the post, not its edits", got)
+	}
+
+	store.Clear()
+	if got := store.Revisions("post"); got != nil {
+		t.Errorf("Revisions() after Clear() = %v, want nil", got)
+	}
+}
+
+// TestMemoryStoreTally tests that each voter's latest vote counts until the
+// poll's author closes it.
+func TestMemoryStoreTally(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	if _, ok := store.Tally("poll"); ok {
+		t.Error("Tally() of an unknown poll reported ok")
+	}
+
+	store.AddPost(&messaging.Message{ID: "poll", Author: "alice", PublicKey: []byte("alice-key"), Content: "Deploy now?", Type: messaging.PollMsg, Poll: &messaging.Poll{Options: []string{"yes", "no"}}, Timestamp: now})
+	votes := []struct {
+		id, author, key, option string
+		after                   time.Duration
+	}{
+		{"v1", "bob", "bob-key", "yes", time.Minute},
+		{"v2", "carol", "carol-key", "no", time.Minute},
+		{"v3", "bob", "bob-key", "no", 2 * time.Minute},      // Bob changes their vote
+		{"v4", "bob", "mallory-key", "yes", 3 * time.Minute}, // Someone else claiming to be Bob
+		{"v5", "dave", "dave-key", "maybe", time.Minute},     // Not an option
+	}
+	for _, v := range votes {
+		store.AddPost(&messaging.Message{ID: v.id, Author: v.author, PublicKey: []byte(v.key), Content: v.option, Type: messaging.VoteMsg, ReplyTo: "poll", Timestamp: now.Add(v.after)})
+	}
+
+	tally, ok := store.Tally("poll")
+	if !ok {
+		t.Fatal("Tally() reported the poll as unknown")
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(tally.Counts, want) || tally.Voters() != 3 || tally.Closed {
+		t.Errorf("Tally() = %+v, want counts %v from 3 open votes", tally, want)
+	}
+	if got := tally.VoteOf("bob", []byte("bob-key")); got != "no" {
+		t.Errorf("VoteOf() = %q, want the changed vote", got)
+	}
+	if got := len(store.GetAllPosts()); got != 1 {
+		t.Errorf("GetAllPosts() returned %d posts, want just the poll, not its votes", got)
+	}
+
+	// Only the author can close the poll, and later votes don't count
+	store.AddPost(&messaging.Message{ID: "forged-close", Author: "alice", PublicKey: []byte("mallory-key"), Type: messaging.PollCloseMsg, ReplyTo: "poll", Timestamp: now.Add(4 * time.Minute)})
+	if tally, _ := store.Tally("poll"); tally.Closed {
+		t.Error("Tally() reports the poll closed by another key")
+	}
+	store.AddPost(&messaging.Message{ID: "close", Author: "alice", PublicKey: []byte("alice-key"), Type: messaging.PollCloseMsg, ReplyTo: "poll", Timestamp: now.Add(5 * time.Minute)})
+	store.AddPost(&messaging.Message{ID: "late", Author: "carol", PublicKey: []byte("carol-key"), Content: "yes", Type: messaging.VoteMsg, ReplyTo: "poll", Timestamp: now.Add(6 * time.Minute)})
+	// A vote that arrives after the closing doesn't count, even if it claims
+	// to have been cast before
+	store.AddPost(&messaging.Message{ID: "backdated", Author: "dave", PublicKey: []byte("dave-key"), Content: "yes", Type: messaging.VoteMsg, ReplyTo: "poll", Timestamp: now.Add(time.Minute)})
+	tally, _ = store.Tally("poll")
+	if want := []int{1, 2}; !tally.Closed || !reflect.DeepEqual(tally.Counts, want) {
+		t.Errorf("Tally() after closing = %+v, want closed with counts %v", tally, want)
+	}
+}
+
+// TestMemoryStorePurgeExpired tests that expired posts are hidden at once
+// and purged along with everything that refers to them.
+func TestMemoryStorePurgeExpired(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	expired, later := now.Add(-time.Minute), now.Add(time.Hour)
+	store.AddPost(&messaging.Message{ID: "parent", Content: "stays", Hashtags: []string{"go"}, Type: messaging.PostMsg, Timestamp: now.Add(-time.Hour)})
+	store.AddPost(&messaging.Message{ID: "gone", Content: "gone", Hashtags: []string{"go"}, Type: messaging.ReplyMsg, ReplyTo: "parent", Timestamp: now.Add(-10 * time.Minute), ExpiresAt: &expired})
+	store.AddPost(&messaging.Message{ID: "edit", Content: "still gone", Hashtags: []string{"go"}, Type: messaging.EditMsg, ReplyTo: "gone", Timestamp: now.Add(-5 * time.Minute), ExpiresAt: &expired})
+	store.AddPost(&messaging.Message{ID: "like", Author: "bob", Content: "👍", Hashtags: []string{"go"}, Type: messaging.ReactionMsg, ReplyTo: "gone", Timestamp: now.Add(-5 * time.Minute)})
+	store.AddPost(&messaging.Message{ID: "soon", Content: "soon", Hashtags: []string{"go"}, Type: messaging.PostMsg, Timestamp: now, ExpiresAt: &later})
+
+	// Hidden before the purge, so they are never shown or synced
+	if _, ok := store.GetPost("gone"); ok {
+		t.Error("GetPost() found an expired post, want it hidden")
+	}
+	var ids []string
+	for _, post := range store.GetAllPosts() {
+		ids = append(ids, post.ID)
+	}
+	if want := []string{"parent", "soon"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("GetAllPosts() = %v, want %v", ids, want)
+	}
+	if got := store.RecentPosts([]string{"go"}, now.Add(-24*time.Hour), 10); len(got) != 3 {
+		t.Errorf("RecentPosts() returned %d posts, want the parent, the reaction and the unexpired post", len(got))
+	}
+
+	if got := store.PurgeExpired(now); got != 3 {
+		t.Errorf("PurgeExpired() = %d, want the post, its edit and the reaction to it", got)
+	}
+	for _, id := range []string{"gone", "edit", "like"} {
+		if _, ok := store.posts[id]; ok {
+			t.Errorf("Post %q is still stored after PurgeExpired()", id)
+		}
+	}
+	if got := store.ReplyCount("parent"); got != 0 {
+		t.Errorf("ReplyCount() = %d after PurgeExpired(), want 0", got)
+	}
+	if got := store.Reactions("gone"); len(got) != 0 {
+		t.Errorf("Reactions() = %v after PurgeExpired(), want none", got)
+	}
+	if _, ok := store.GetPost("soon"); !ok {
+		t.Error("PurgeExpired() removed a post that hasn't expired yet")
+	}
+
+	if got := store.PurgeExpired(later); got != 1 {
+		t.Errorf("PurgeExpired() an hour later = %d, want 1", got)
+	}
+}
+
+// TestMemoryStorePurgeExpiredChunks tests that purging a post drops the
+// chunks of its attachment, except those a remaining post still uses.
+func TestMemoryStorePurgeExpiredChunks(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	for _, hash := range []string{"only", "both", "kept"} {
+		store.PutChunk(hash, []byte(hash))
+	}
+	store.AddPost(&messaging.Message{ID: "gone", Hashtags: []string{"go"}, Type: messaging.PostMsg, Timestamp: now.Add(-time.Hour), ExpiresAt: &expired,
+		Attachment: &messaging.Attachment{Name: "a.txt", Chunks: []string{"only", "both"}}})
+	store.AddPost(&messaging.Message{ID: "share", Hashtags: []string{"go"}, Type: messaging.ShareMsg, Timestamp: now,
+		Shared: &messaging.Message{ID: "original", Attachment: &messaging.Attachment{Name: "b.txt", Chunks: []string{"both", "kept"}}}})
+
+	store.PurgeExpired(now)
+	if _, found := store.GetChunk("only"); found {
+		t.Error("GetChunk() found a chunk of a purged post")
+	}
+	for _, hash := range []string{"both", "kept"} {
+		if _, found := store.GetChunk(hash); !found {
+			t.Errorf("GetChunk(%q) found nothing, want the chunk a remaining share uses", hash)
+		}
+	}
+}
+
+// TestMemoryStoreChunks tests storing and retrieving attachment chunks.
+func TestMemoryStoreChunks(t *testing.T) {
+	store := NewMemoryStore()
+
+	if _, found := store.GetChunk("missing"); found {
+		t.Error("GetChunk() found a chunk that was never stored")
+	}
+	store.PutChunk("abc", []byte("chunk"))
+	if data, found := store.GetChunk("abc"); !found || string(data) != "chunk" {
+		t.Errorf("GetChunk() = %q, %v, want %q, true", data, found, "chunk")
+	}
+
+	store.Clear()
+	if _, found := store.GetChunk("abc"); found {
+		t.Error("Chunk should not be found after clear")
+	}
+}
+
 // TestMemoryStoreClear tests the Clear method.
 func TestMemoryStoreClear(t *testing.T) {
 	store := NewMemoryStore()
@@ -216,4 +556,125 @@ func TestMemoryStoreClear(t *testing.T) {
 	if len(allPeers) != 0 {
 		t.Errorf("GetAllPeers() returned %d peers after clear, want 0", len(allPeers))
 	}
-}
\ No newline at end of file
+}
+
+// TestScheduleQueue tests scheduling, editing, cancelling and publishing
+// scheduled posts, in memory and persisted.
+func TestScheduleQueue(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "scheduled.json")
+	queue, err := NewScheduleQueue(path)
+	if err != nil {
+		t.Fatalf("NewScheduleQueue() error = %v", err)
+	}
+	if !queue.Persistent() {
+		t.Error("Persistent() = false for a queue with a path")
+	}
+
+	later, err := queue.Add("later #team", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	soon, _ := queue.Add("soon", now.Add(time.Minute))
+	cancelled, _ := queue.Add("never", now.Add(2*time.Minute))
+
+	if err := queue.Cancel(cancelled.ID); err != nil {
+		t.Errorf("Cancel() error = %v", err)
+	}
+	if err := queue.Cancel(cancelled.ID); !errors.Is(err, ErrNotScheduled) {
+		t.Errorf("Cancel() of a cancelled post error = %v, want ErrNotScheduled", err)
+	}
+	if err := queue.Update(later.ID, "standup #team", now.Add(30*time.Minute)); err != nil {
+		t.Errorf("Update() error = %v", err)
+	}
+	if err := queue.Update("missing", "text", now); !errors.Is(err, ErrNotScheduled) {
+		t.Errorf("Update() of an unknown post error = %v, want ErrNotScheduled", err)
+	}
+
+	pending := queue.Pending()
+	if len(pending) != 2 || pending[0].ID != soon.ID || pending[1].Content != "standup #team" {
+		t.Fatalf("Pending() = %+v, want the soon post then the edited one", pending)
+	}
+
+	// The queue is reloaded from its file
+	reloaded, err := NewScheduleQueue(path)
+	if err != nil {
+		t.Fatalf("NewScheduleQueue() reload error = %v", err)
+	}
+	if got := reloaded.Pending(); len(got) != 2 || got[1].ID != later.ID || !got[1].At.Equal(now.Add(30*time.Minute)) {
+		t.Errorf("Pending() after reload = %+v, want the same two posts", got)
+	}
+
+	// Due posts are removed from the queue
+	if due, err := queue.Due(now); err != nil || len(due) != 0 {
+		t.Errorf("Due(now) = %+v, %v, want nothing", due, err)
+	}
+	due, err := queue.Due(now.Add(45 * time.Minute))
+	if err != nil {
+		t.Fatalf("Due() error = %v", err)
+	}
+	if len(due) != 2 || due[0].ID != soon.ID || due[1].ID != later.ID {
+		t.Errorf("Due() = %+v, want both posts oldest first", due)
+	}
+	if got := queue.Pending(); len(got) != 0 {
+		t.Errorf("Pending() after Due() = %+v, want none", got)
+	}
+
+	// A queue without a path never touches the disk
+	memory, err := NewScheduleQueue("")
+	if err != nil {
+		t.Fatalf("NewScheduleQueue(\"\") error = %v", err)
+	}
+	if memory.Persistent() {
+		t.Error("Persistent() = true for an in-memory queue")
+	}
+	if _, err := memory.Add("text", now); err != nil {
+		t.Errorf("Add() to an in-memory queue error = %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("persisted queue file missing: %v", err)
+	}
+}
+
+// TestScheduleQueueSaveFails tests that changes that can't be saved are
+// undone, so the queue in memory never differs from the one on disk.
+func TestScheduleQueueSaveFails(t *testing.T) {
+	now := time.Now()
+	dir := filepath.Join(t.TempDir(), "gone")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	queue, err := NewScheduleQueue(filepath.Join(dir, "scheduled.json"))
+	if err != nil {
+		t.Fatalf("NewScheduleQueue() error = %v", err)
+	}
+	post, err := queue.Add("standup", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	// Saving fails once the directory is gone
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("RemoveAll() error = %v", err)
+	}
+
+	if _, err := queue.Add("lost", now); err == nil {
+		t.Error("Add() error = nil, want the save error")
+	}
+	if err := queue.Update(post.ID, "changed", now); err == nil {
+		t.Error("Update() error = nil, want the save error")
+	}
+	if err := queue.Cancel(post.ID); err == nil {
+		t.Error("Cancel() error = nil, want the save error")
+	}
+	if got := queue.Pending(); len(got) != 1 || got[0] != post {
+		t.Errorf("Pending() = %+v, want only the unchanged %+v", got, post)
+	}
+	// Due posts that can't be taken off the saved queue aren't published,
+	// or they would go out again after a restart
+	if due, err := queue.Due(now.Add(2 * time.Hour)); err == nil || len(due) != 0 {
+		t.Errorf("Due() = %+v, %v, want no posts and the save error", due, err)
+	}
+	if got := queue.Pending(); len(got) != 1 || got[0] != post {
+		t.Errorf("Pending() after a failed Due() = %+v, want %+v still queued", got, post)
+	}
+}
@@ -1,7 +1,6 @@
 package tui
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"socli/config"
@@ -38,11 +37,33 @@ type AppModel struct {
 	renderer        *content.MarkdownRenderer
 	composeView     *views.ComposeView
 	feedView        *views.FeedView
+	profileView     *views.ProfileView
 	broadcaster     *messaging.Broadcaster
+	outbox          *messaging.Outbox      // Publishes our posts and retries topics without peers; may be nil
+	historySync     *messaging.HistorySync // Fetches recent posts from peers; may be nil
+	directory       *messaging.Directory   // Network-wide hashtag directory; may be nil
+	presence        *messaging.Presence    // Presence heartbeats of us and our peers; may be nil
+	blobs           *messaging.Blobs       // Attachment chunks we share and fetch; may be nil
+	handshake       *messaging.Handshake   // Versions of connected peers; may be nil
+	inboundLimits   *messaging.RateLimits  // Flood protection for posts on their way to the feed; nil when disabled
+	circles         *messaging.Circles     // Invite-only circles we are a member of; may be nil
+	schedule        *storage.ScheduleQueue // Posts waiting to be published at a later time; may be nil
+	topicsView      *views.TopicsView
+	threadView      *views.ThreadView   // The open thread; nil unless currentView is "thread"
+	replyTo         *messaging.Message  // The post being replied to in the compose view, if any
+	sharing         *messaging.Message  // The post being shared in the compose view, if any
+	editing         *messaging.Message  // Our post being edited in the compose view, if any
+	revisionsView   *views.RevisionsView // The revisions of an edited post; nil unless currentView is "revisions"
+	notificationsView *views.NotificationsView // The posts that mentioned us
+	scheduleView    *views.ScheduleView  // The queue of scheduled posts
+	rescheduling    string               // ID of the scheduled post being edited in the compose view, if any
+	ttl             time.Duration        // How long our new posts live before every peer drops them; 0 if they don't expire
+	unreadMentions  int                 // Mentions received since the notifications were last opened
+	fetchedParents  map[string]bool     // Missing parents of replies already asked for
 	psManager       *p2p.PubSubManager // Store PubSubManager for dynamic subscriptions
 	keyPair         *crypto.KeyPair
 	cfg             *config.Config
-	currentView     string // "feed", "compose", or "help"
+	currentView     string // "feed", "compose", "profile", "topics", "thread", "revisions", "notifications", "schedule", or "help"
 	subscriptions   map[string]*pubsub.Subscription // Map of topic names to subscriptions
 	postChan        chan *messaging.Message         // Channel for receiving posts from dynamic subscriptions
 	broadcastResultChan chan types.StatusMsg                  // Channel for receiving broadcast results
@@ -52,8 +73,31 @@ type AppModel struct {
 	helpScrollOffset int                           // Scroll offset for the help view
 }
 
+// Deps are the services the application model works with. Store and Config
+// are required; a feature whose service is left nil is unavailable.
+type Deps struct {
+	NetManager    *p2p.NetworkManager
+	Store         *storage.MemoryStore
+	Renderer      *content.MarkdownRenderer
+	PubSub        *p2p.PubSubManager // For dynamic subscriptions
+	Broadcaster   *messaging.Broadcaster
+	Outbox        *messaging.Outbox      // Publishes our posts and retries topics without peers
+	HistorySync   *messaging.HistorySync // Fetches recent posts from peers
+	Directory     *messaging.Directory   // Network-wide hashtag directory
+	Presence      *messaging.Presence    // Presence heartbeats of us and our peers
+	Blobs         *messaging.Blobs       // Attachment chunks we share and fetch
+	Handshake     *messaging.Handshake   // Versions of connected peers
+	InboundLimits *messaging.RateLimits  // Flood protection for posts on their way to the feed; nil when disabled
+	Circles       *messaging.Circles     // Invite-only circles we are a member of
+	Schedule      *storage.ScheduleQueue // Posts waiting to be published at a later time
+	KeyPair       *crypto.KeyPair
+	Config        *config.Config
+}
+
 // NewApp creates and returns a new application model.
-func NewApp(netManager *p2p.NetworkManager, store *storage.MemoryStore, renderer *content.MarkdownRenderer, psManager *p2p.PubSubManager, broadcaster *messaging.Broadcaster, keyPair *crypto.KeyPair, cfg *config.Config) (*AppModel, error) {
+func NewApp(deps Deps) (*AppModel, error) {
+	netManager, store, renderer, cfg := deps.NetManager, deps.Store, deps.Renderer, deps.Config
+
 	// Initialize with default "general" subscription
 	// Note: The primary subscription logic for the default topic will remain in main.go for now
 	// to avoid duplication. AppModel will handle dynamic subscriptions.
@@ -70,15 +114,28 @@ func NewApp(netManager *p2p.NetworkManager, store *storage.MemoryStore, renderer
 	// Get our own Peer ID as a string for the UI
 	// ownPeerID := netManager.Host.ID().String()
 
-	return &AppModel{
+	m := &AppModel{
 		netManager:         netManager,
 		store:              store,
 		renderer:           renderer,
 		composeView:        views.NewComposeView(cfg), // Pass config for max length
 		feedView:        views.NewFeedView(store, renderer), // Pass store and renderer
-		broadcaster:        broadcaster,
-		psManager:          psManager, // Store psManager
-		keyPair:            keyPair,
+		profileView:        views.NewProfileView(netManager, cfg),
+		broadcaster:        deps.Broadcaster,
+		outbox:             deps.Outbox,
+		historySync:        deps.HistorySync,
+		directory:          deps.Directory,
+		presence:           deps.Presence,
+		blobs:              deps.Blobs,
+		handshake:          deps.Handshake,
+		inboundLimits:      deps.InboundLimits,
+		circles:            deps.Circles,
+		schedule:           deps.Schedule,
+		topicsView:         views.NewTopicsView(),
+		notificationsView:  views.NewNotificationsView(),
+		fetchedParents:     make(map[string]bool),
+		psManager:          deps.PubSub, // Store psManager
+		keyPair:            deps.KeyPair,
 		cfg:                cfg,
 		currentView:        "feed",
 		subscriptions:      make(map[string]*pubsub.Subscription), // Initialize empty subscriptions map
@@ -88,13 +145,20 @@ func NewApp(netManager *p2p.NetworkManager, store *storage.MemoryStore, renderer
 		terminalHeight:     height,
 		statusMsg:          nil, // No initial status message
 		helpScrollOffset:   0,   // Initialize help scroll offset
-	}, nil
+	}
+	// Posts that waited for peers are reported in the status bar once they go out
+	m.reportDeliveries()
+	return m, nil
 }
 
 // Init is the first function that will be called. It returns a command.
 func (m *AppModel) Init() tea.Cmd {
-	// Start the commands to listen for posts and broadcast results
-	return tea.Batch(m.listenForPostsCmd(), m.listenForBroadcastResults())
+	// Start the commands to listen for posts and broadcast results,
+	// catch up on recent posts from the peers we're already connected to,
+	// start announcing our hashtags to the topic directory,
+	// keep the sidebar's presence information current,
+	// and publish scheduled posts when they are due
+	return tea.Batch(m.listenForPostsCmd(), m.listenForBroadcastResults(), m.syncHistoryCmd(m.connectedPeers(), m.subscribedHashtags()), m.announceTickCmd(), m.presenceTickCmd(), m.scheduleTickCmd())
 }
 
 // Update is called when a message is received.
@@ -123,15 +187,163 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Toggle help view
 				m.currentView = "help"
 				return m, nil
+			case "p":
+				// Show profile and network status
+				m.currentView = "profile"
+				return m, nil
+			case "r":
+				// Reply to the selected post
+				if post := m.feedView.Selected(); post != nil {
+					m.startReply(post)
+				}
+				return m, nil
+			case "t":
+				// Show the conversation the selected post belongs to
+				if post := m.feedView.Selected(); post != nil {
+					return m, m.openThread(post)
+				}
+				return m, nil
+			case "s":
+				// Share the selected post to other hashtags
+				if post := m.feedView.Selected(); post != nil {
+					m.startShare(post)
+				}
+				return m, nil
+			case "+":
+				// React to the selected post
+				return m, m.reactCmd(m.feedView.Selected(), quickReaction)
+			case "e":
+				// Edit the selected post, if it is ours
+				if post := m.feedView.Selected(); post != nil {
+					m.startEdit(post)
+				}
+				return m, nil
+			case "v":
+				// Show the earlier revisions of the selected post
+				m.openRevisions(m.feedView.Selected())
+				return m, nil
+			case "n":
+				// Show the posts that mentioned us
+				m.openNotifications()
+				return m, nil
+			case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+				// Vote on the selected poll
+				if post := m.feedView.Selected(); post != nil && post.Type == messaging.PollMsg {
+					return m, m.voteCmd(post, msg.String())
+				}
+				return m, nil
+			}
+		case "thread":
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "q", "esc":
+				m.currentView = "feed"
+				m.threadView = nil
+				return m, nil
+			case "j", "down":
+				m.threadView.MoveDown()
+				return m, nil
+			case "k", "up":
+				m.threadView.MoveUp()
+				return m, nil
+			case "r":
+				if post := m.threadView.Selected(); post != nil {
+					m.startReply(post)
+				}
+				return m, nil
+			case "s":
+				if post := m.threadView.Selected(); post != nil {
+					m.startShare(post)
+				}
+				return m, nil
+			case "+":
+				return m, m.reactCmd(m.threadView.Selected(), quickReaction)
+			case "e":
+				if post := m.threadView.Selected(); post != nil {
+					m.startEdit(post)
+				}
+				return m, nil
+			case "v":
+				m.openRevisions(m.threadView.Selected())
+				return m, nil
+			case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+				if post := m.threadView.Selected(); post != nil && post.Type == messaging.PollMsg {
+					return m, m.voteCmd(post, msg.String())
+				}
+				return m, nil
+			}
+		case "revisions":
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "q", "esc":
+				m.revisionsView = nil
+				m.currentView = "feed"
+				if m.threadView != nil {
+					m.currentView = "thread"
+				}
+				return m, nil
+			case "j", "down":
+				m.revisionsView.ScrollDown()
+				return m, nil
+			case "k", "up":
+				m.revisionsView.ScrollUp()
+				return m, nil
+			}
+			return m, nil
+		case "topics":
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "q", "esc":
+				m.currentView = "feed"
+				return m, nil
+			case "j", "down":
+				m.topicsView.MoveDown()
+				return m, nil
+			case "k", "up":
+				m.topicsView.MoveUp()
+				return m, nil
+			case "r":
+				m.openTopicsView()
+				return m, nil
+			case "enter", "s":
+				// Subscribe to the selected hashtag with one key
+				entry, ok := m.topicsView.Selected()
+				if !ok {
+					return m, nil
+				}
+				if m.subscribedSet()[entry.Hashtag] {
+					m.statusMsg = &types.StatusMsg{Type: types.Info, Message: "Already subscribed to #" + entry.Hashtag}
+					return m, nil
+				}
+				m.subscribeToHashtag(entry.Hashtag)
+				m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Subscribed to #" + entry.Hashtag}
+				return m, tea.Batch(m.syncHistoryCmd(m.connectedPeers(), []string{entry.Hashtag}), m.announceCmd())
 			}
+			return m, nil
+		case "profile":
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "q", "esc":
+				m.currentView = "feed"
+				return m, nil
+			}
+			return m, nil
 		case "compose":
 			switch msg.String() {
 			case "enter":
 				content := m.composeView.Value()
 				// Check if it's a command
 				if strings.HasPrefix(strings.TrimSpace(content), "/") {
-					// Parse and handle command
+					// Parse and handle command; commands are never replies, shares or edits
+					m.replyTo, m.sharing, m.editing = nil, nil, nil
+					rescheduling := m.rescheduling // The scheduled post being edited, if any
+					m.rescheduling = ""
 					command, args := ParseCommand(content)
+					var cmd tea.Cmd
 					switch command {
 					case "subscribe":
 						if len(args) > 0 {
@@ -140,6 +352,9 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							m.statusMsg = &types.SubscribingMsg
 							// Handle subscription logic
 							m.subscribeToHashtag(hashtag)
+							// Fetch what was posted to the hashtag before we subscribed,
+							// and let the directory know
+							cmd = tea.Batch(m.syncHistoryCmd(m.connectedPeers(), []string{hashtag}), m.announceCmd())
 							// Clear the input after command
 							m.composeView = views.NewComposeView(m.cfg)
 						}
@@ -151,10 +366,157 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							m.statusMsg = &types.UnsubscribingMsg
 							// Handle unsubscription logic
 							m.unsubscribeFromHashtag(hashtag)
+							cmd = m.announceCmd()
 							// Clear the input after command
 							m.composeView = views.NewComposeView(m.cfg)
 						}
 						// Fall through to switch back to feed
+					case "status":
+						// Set our presence status, e.g. "/status away back at 2pm"
+						status, ok := messaging.StatusOnline, false
+						if len(args) > 0 {
+							status, ok = messaging.ParsePresenceStatus(args[0])
+						}
+						if ok {
+							text := strings.Join(args[1:], " ")
+							cmd = m.setStatusCmd(status, text)
+							m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Status set to " + string(status)}
+						} else {
+							m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: "Usage: /status online|away|dnd [text]"}
+						}
+						m.composeView = views.NewComposeView(m.cfg)
+					case "attach":
+						// Share a file, e.g. "/attach ./app.log crash on startup #ops"
+						if len(args) > 0 && m.blobs != nil {
+							cmd = m.attachCmd(args[0], strings.Join(args[1:], " "))
+							m.statusMsg = &types.StatusMsg{Type: types.Info, Message: "Attaching " + args[0] + "..."}
+						} else {
+							m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: "Usage: /attach <path> [text]"}
+						}
+						m.composeView = views.NewComposeView(m.cfg)
+					case "fetch":
+						// Fetch an attachment by the hash prefix shown in the feed
+						if len(args) > 0 && len(args[0]) >= minFetchPrefix && m.blobs != nil {
+							if post, ok := m.findAttachment(args[0]); ok {
+								cmd = m.fetchAttachmentCmd(post)
+								m.statusMsg = &types.StatusMsg{Type: types.Info, Message: "Fetching " + post.Attachment.Name + "..."}
+							} else {
+								m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: "No attachment matches " + args[0]}
+							}
+						} else {
+							m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: fmt.Sprintf("Usage: /fetch <hash> (at least %d characters)", minFetchPrefix)}
+						}
+						m.composeView = views.NewComposeView(m.cfg)
+					case "circle":
+						// Create an invite-only circle, e.g. "/circle friends"
+						if len(args) > 0 && m.circles != nil {
+							name := circleName(args[0])
+							if err := m.circles.Create(name); err != nil {
+								m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Failed to create circle: " + err.Error()}
+							} else {
+								m.subscribeToHashtag(name)
+								m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Created circle #" + name + "; add members with /invite"}
+							}
+						} else {
+							m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: "Usage: /circle <name>"}
+						}
+						m.composeView = views.NewComposeView(m.cfg)
+					case "invite", "remove":
+						// Change the members of a circle we own, e.g. "/invite <peer> #friends"
+						var member peer.ID
+						var err error
+						if len(args) > 1 && m.circles != nil {
+							member, err = peer.Decode(args[0])
+						}
+						if len(args) > 1 && m.circles != nil && err == nil {
+							if command == "invite" {
+								cmd = m.inviteCmd(member, circleName(args[1]))
+							} else {
+								cmd = m.removeCmd(member, circleName(args[1]))
+							}
+							m.statusMsg = &types.StatusMsg{Type: types.Info, Message: "Updating #" + circleName(args[1]) + "..."}
+						} else {
+							m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: "Usage: /" + command + " <peer ID> <#circle>"}
+						}
+						m.composeView = views.NewComposeView(m.cfg)
+					case "join":
+						// Join a circle with an invite token or invite file
+						if len(args) > 0 && m.circles != nil {
+							cmd = m.joinCmd(args[0])
+							m.statusMsg = &types.StatusMsg{Type: types.Info, Message: "Joining circle..."}
+						} else {
+							m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: "Usage: /join <invite file|token>"}
+						}
+						m.composeView = views.NewComposeView(m.cfg)
+					case "favorite", "unfavorite":
+						// Keep a teammate connected, e.g. "/favorite <peer ID or multiaddr>"
+						if len(args) > 0 && m.favorites() != nil {
+							if command == "favorite" {
+								if id, err := m.favorites().Add(args[0]); err != nil {
+									m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Invalid favorite: " + err.Error()}
+								} else {
+									m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Added " + shortPeerID(id) + " to favorites; it will be redialed when it drops"}
+								}
+							} else if id, err := peer.Decode(args[0]); err != nil || !m.favorites().Remove(id) {
+								m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: args[0] + " is not a favorite"}
+							} else {
+								m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Removed " + shortPeerID(id) + " from favorites"}
+							}
+						} else {
+							m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: "Usage: /" + command + " <peer ID>"}
+						}
+						m.composeView = views.NewComposeView(m.cfg)
+					case "react":
+						// React to the selected post with any emoji or shortcode
+						if len(args) > 0 {
+							cmd = m.reactCmd(m.selectedPost(), args[0])
+						} else {
+							m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: "Usage: /react <emoji|:shortcode:>"}
+						}
+						m.composeView = views.NewComposeView(m.cfg)
+					case "poll":
+						// Ask a question with up to 10 answers to vote on
+						cmd = m.pollCmd(content)
+						m.composeView = views.NewComposeView(m.cfg)
+					case "vote":
+						// Vote on the selected poll by option number or text
+						if len(args) > 0 {
+							cmd = m.voteCmd(m.selectedPost(), strings.Join(args, " "))
+						} else {
+							m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: "Usage: /vote <number|option>"}
+						}
+						m.composeView = views.NewComposeView(m.cfg)
+					case "closepoll":
+						// Close our selected poll to further votes
+						cmd = m.closePollCmd(m.selectedPost())
+						m.composeView = views.NewComposeView(m.cfg)
+					case "schedule":
+						// Hold a post until a time of day or for a delay, e.g.
+						// "/schedule 09:00 standup reminder #team"; without
+						// arguments, list the posts waiting to go out
+						m.composeView = views.NewComposeView(m.cfg)
+						if m.schedule == nil {
+							m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: "Scheduling is not available"}
+						} else if len(args) == 0 {
+							m.openSchedule()
+							return m, nil
+						} else {
+							m.schedulePost(content, rescheduling)
+							if rescheduling != "" {
+								m.currentView = "schedule"
+								return m, nil
+							}
+						}
+					case "ttl":
+						// Make our next posts expire, e.g. "/ttl 10m", or
+						// stop with "/ttl off"
+						m.setTTL(args)
+						m.composeView = views.NewComposeView(m.cfg)
+					case "topics":
+						// Browse the hashtags announced on the network
+						m.composeView = views.NewComposeView(m.cfg)
+						m.openTopicsView()
+						return m, nil
 					default:
 						// Show unknown command message
 						m.statusMsg = &types.UnknownCmdMsg
@@ -162,82 +524,82 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.composeView = views.NewComposeView(m.cfg)
 					}
 					m.currentView = "feed"
+					return m, cmd
+				}
+
+				// A scheduled post being edited keeps its /schedule command
+				if content != "" && m.rescheduling != "" {
+					m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: scheduleUsage}
 					return m, nil
 				}
 
+				// An edit of one of our posts
+				if content != "" && m.editing != nil {
+					cmd := m.editCmd(content)
+					m.currentView = m.afterCompose()
+					m.composeView = views.NewComposeView(m.cfg)
+					return m, cmd
+				}
+
 				// Regular post
 				if content != "" { // Only send non-empty messages
 					// 1. Create Message struct
+					now := time.Now()
 					msg := &messaging.Message{
 						ID:        uuid.New().String(), // Generate unique ID
 						Author:    m.netManager.Host.ID().String(),
 						Content:   content,
 						Hashtags:  internal.ExtractHashtags(content), // Extract hashtags
-						Timestamp: time.Now(),
+						Timestamp: now,
 						Type:      messaging.PostMsg, // Set message type
+						ExpiresAt: m.expiresAt(now), // Set by /ttl or ctrl+t; nil if posts don't expire
+					}
+					// A reply goes to the same topics as the post it replies to
+					if m.replyTo != nil {
+						msg.Type = messaging.ReplyMsg
+						msg.ReplyTo = m.replyTo.ID
+						msg.Hashtags = internal.ReplyHashtags(m.replyTo, content)
+					}
+					// A share embeds the signed original, so it can be verified on its own
+					if m.sharing != nil {
+						msg.Type = messaging.ShareMsg
+						msg.Shared = m.sharing
 					}
 
+					// Mentioned peers are notified through their inbox topics
+					var unknown []string
+					msg.Mentions, unknown = m.resolveMentions(content)
+
 					// 2. Sign the message
-					// For simplicity, we'll sign the content. A more robust approach
-					// might sign a hash of the entire message or specific fields.
-					signature, err := crypto.SignMessage([]byte(msg.Content), m.keyPair.PrivateKey)
-					if err != nil {
+					// The signature covers the whole message, so peers' validators
+					// reject it if any field is tampered with on the way.
+					if err := msg.Sign(m.keyPair); err != nil {
 						// TODO: Handle signing error in UI
 						log.Printf("Error signing message: %v\n", err)
-					} else {
-						msg.Signature = signature
 					}
+					// Keep our own post, so it shows in our feed and can be
+					// served to peers that sync history from us later
+					m.store.AddPost(msg)
 
 					// 3. Show "Publishing..." status
 					m.statusMsg = &types.PostingMsg
-					// Trigger a refresh to show the status immediately
-					// We can return a simple 'nil' command or a custom one if needed
-					// For now, the view will update on the next refresh cycle.
-					
-					// 4. Broadcast the message asynchronously
-					// To provide feedback after broadcasting, we perform the broadcast
-					// in a goroutine and then send a message back to the Update loop.
-					// This requires defining new message types for success/failure.
-					// Let's define them.
-					
-					// Perform broadcast in a goroutine to avoid blocking the UI
-					// and send a result message back to the Update function.
-					// We capture 'm' (the model) to access its fields, but we must be
-					// careful not to mutate it directly in the goroutine.
-					// Sending a message back is the safe way.
-					go func() {
-						err := m.broadcaster.Broadcast(context.Background(), msg)
-						// Send a message back to the Update loop with the result
-						// We need to get the program instance to send the message.
-						// One way is to pass it to the model or have a callback.
-						// BubbleTea programs can send messages using p.Send().
-						// However, we don't have direct access to 'p' here.
-						// A common pattern is to pass the program to the model
-						// or use a channel. Let's re-evaluate this.
-						
-						// Placeholder for now, just log the result.
-						// A full implementation would send a PostPublishedMsg or PostPublishFailedMsg
-						// back to the Update loop.
-						if err != nil {
-							log.Printf("Error broadcasting message: %v", err)
-							// Ideally, send PostPublishFailedMsg
-						} else {
-							log.Println("Message broadcast successfully")
-							// Ideally, send PostSentMsg
-						}
-					}()
 
-					// 5. Clear compose view and switch back to feed
-					m.currentView = "feed"
+					// 4. Publish the post through the outbox in a goroutine, so the UI
+					// doesn't block. Where it went is reported on broadcastResultChan,
+					// and topics without peers yet are retried by the outbox.
+					go m.sendPost(msg, unknown)
+
+					// 5. Clear compose view and switch back to feed, or to the thread replied in
+					m.currentView = m.afterCompose()
 					m.composeView = views.NewComposeView(m.cfg)
 					return m, nil
 				}
 				// If content was empty, just go back to feed
-				m.currentView = "feed"
+				m.currentView = m.afterCompose()
 				m.composeView = views.NewComposeView(m.cfg)
 				return m, nil
 			case "esc":
-				m.currentView = "feed"
+				m.currentView = m.afterCompose()
 				// Reset compose view to clear content
 				m.composeView = views.NewComposeView(m.cfg)
 				// Clear status message
@@ -247,7 +609,47 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Toggle help view from compose as well
 				m.currentView = "help"
 				return m, nil
+			case "ctrl+t":
+				// Cycle how long the post lives: off and the configured presets
+				m.cycleTTL()
+				return m, nil
+			}
+		case "schedule":
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "q", "esc":
+				m.currentView = "feed"
+				return m, nil
+			case "j", "down":
+				m.scheduleView.MoveDown()
+				return m, nil
+			case "k", "up":
+				m.scheduleView.MoveUp()
+				return m, nil
+			case "e":
+				m.startReschedule()
+				return m, nil
+			case "d":
+				m.cancelScheduled()
+				return m, nil
 			}
+			return m, nil
+		case "notifications":
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "q", "esc":
+				m.currentView = "feed"
+				return m, nil
+			case "j", "down":
+				m.notificationsView.ScrollDown()
+				return m, nil
+			case "k", "up":
+				m.notificationsView.ScrollUp()
+				return m, nil
+			}
+			return m, nil
 		case "help":
 			switch msg.String() {
 			case "ctrl+c", "q":
@@ -271,8 +673,28 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 	case PostReceivedMsg:
-		m.store.AddPost(msg.Post)
-		return m, nil
+		// The store drops copies of posts that arrive on more than one topic
+		var mentionCmd tea.Cmd
+		if m.store.AddPost(msg.Post) {
+			mentionCmd = m.noteMention(msg.Post)
+		}
+		return m, tea.Batch(m.missingParentCmd(msg.Post), mentionCmd)
+	case subscriptionPostMsg:
+		var mentionCmd tea.Cmd
+		if m.store.AddPost(msg.Post) {
+			mentionCmd = m.noteMention(msg.Post)
+		}
+		// Keep listening for posts from dynamic subscriptions
+		return m, tea.Batch(m.listenForPostsCmd(), m.missingParentCmd(msg.Post), mentionCmd)
+	case parentsFetchedMsg:
+		// Keep walking up the thread until we reach its root
+		var cmds []tea.Cmd
+		for _, post := range msg.Posts {
+			if internal.ApplyFilters(post) && m.store.AddPost(post) && post.Type == messaging.ReplyMsg {
+				cmds = append(cmds, m.fetchParentCmd(post.ReplyTo, msg.Depth))
+			}
+		}
+		return m, tea.Batch(cmds...)
 	case PeerConnectedMsg:
 		// A new peer has connected. Add it to our local store.
 		// The peer ID is in msg.PeerID. We need to get AddrInfo.
@@ -291,10 +713,99 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Add the peer to our in-memory store
 		m.store.AddPeer(pi)
 		
-		// We don't need to return a specific command here for the peer list update
-		// as the View() method will read from the store directly.
-		// If we had a more complex UI update mechanism, we might return a cmd.
+		// The View() method reads the peer list from the store directly.
+		// Ask the new peer for the posts we missed on our hashtags,
+		// and check that it runs a version we can talk to.
+		return m, tea.Batch(m.syncHistoryCmd([]peer.ID{peerID}, m.subscribedHashtags()), m.handshakeCmd(peerID))
+	case PeerVersionMsg:
+		// Warn about peers whose posts we may not be able to read
+		if warning := versionWarning(msg.PeerID, msg.Version, m.cfg.Wire.Format); warning != "" {
+			m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: warning}
+		}
+		return m, nil
+	case attachmentPostedMsg:
+		if msg.Err != nil {
+			m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Failed to attach file: " + msg.Err.Error()}
+			return m, nil
+		}
+		m.store.AddPost(msg.Post)
+		m.statusMsg = &types.StatusMsg{Type: types.Success, Message: fmt.Sprintf("Attached %s (%s)", msg.Post.Attachment.Name, views.FormatSize(msg.Post.Attachment.Size))}
+		return m, nil
+	case circleResultMsg:
+		if msg.Err != nil {
+			m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Circle: " + msg.Err.Error()}
+			return m, nil
+		}
+		m.statusMsg = &types.StatusMsg{Type: types.Success, Message: msg.Message}
+		if msg.Joined != "" {
+			// Catch up on the circle's posts from members we're connected to
+			m.subscribeToHashtag(msg.Joined)
+			return m, m.syncHistoryCmd(m.connectedPeers(), []string{msg.Joined})
+		}
+		return m, nil
+	case reactionSentMsg:
+		if msg.Err != nil {
+			m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Failed to react: " + msg.Err.Error()}
+		} else {
+			m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Reacted with " + messaging.NormalizeReaction(msg.Reaction)}
+		}
+		return m, nil
+	case pollPublishedMsg:
+		if msg.Err != nil {
+			m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Poll: " + msg.Err.Error()}
+		} else {
+			m.statusMsg = &types.StatusMsg{Type: types.Success, Message: msg.Success}
+		}
+		return m, nil
+	case editSentMsg:
+		if msg.Err != nil {
+			m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Failed to publish edit: " + msg.Err.Error()}
+		} else {
+			m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Post edited"}
+		}
+		return m, nil
+	case attachmentFetchedMsg:
+		// The feed shows fetched attachments from the store on the next render
+		if msg.Err != nil {
+			m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Failed to fetch " + msg.Attachment.Name + ": " + msg.Err.Error()}
+		} else {
+			m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Fetched " + msg.Attachment.Name}
+		}
+		return m, nil
+	case scheduleTickMsg:
+		// Publish the scheduled posts that are due and check again later;
+		// re-rendering also refreshes the countdowns in the queue view
+		return m, tea.Batch(m.publishDueCmd(), m.scheduleTickCmd())
+	case scheduledPublishedMsg:
+		status := publishedStatus("Post scheduled for "+msg.Post.At.Format("15:04"), msg.Deliveries, msg.Err)
+		m.statusMsg = &status
 		return m, nil
+	case presenceTickMsg:
+		// Nothing to update; re-rendering refreshes last-seen times and expiries
+		return m, m.presenceTickCmd()
+	case directoryTickMsg:
+		// Refresh the directory listing if it is open, announce, and schedule the next tick
+		if m.currentView == "topics" {
+			m.openTopicsView()
+		}
+		return m, tea.Batch(m.announceCmd(), m.announceTickCmd())
+	case historySyncedMsg:
+		// Merge synced posts; the store skips posts we already have.
+		// Mentions we missed ring the bell once.
+		added := 0
+		var bellCmd tea.Cmd
+		for _, post := range msg.Posts {
+			if internal.ApplyFilters(post) && m.store.AddPost(post) {
+				added++
+				if cmd := m.noteMention(post); cmd != nil {
+					bellCmd = cmd
+				}
+			}
+		}
+		if added > 0 && m.unreadMentions == 0 {
+			m.statusMsg = &types.StatusMsg{Type: types.Info, Message: fmt.Sprintf("Loaded %d recent posts from peers", added)}
+		}
+		return m, bellCmd
 	case tea.WindowSizeMsg:
 		// Handle terminal resize events
 		m.terminalWidth = msg.Width
@@ -329,9 +840,33 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m *AppModel) View() string {
 	switch m.currentView {
 	case "compose":
-		return appStyle.Render(m.composeView.View())
+		if m.replyTo != nil {
+			return appStyle.Render(m.replyHeader() + m.ttlHeader() + m.composeView.View())
+		}
+		if m.sharing != nil {
+			return appStyle.Render(m.shareHeader() + m.ttlHeader() + m.composeView.View())
+		}
+		if m.editing != nil {
+			return appStyle.Render(m.editHeader() + m.composeView.View())
+		}
+		if m.rescheduling != "" {
+			return appStyle.Render(m.rescheduleHeader() + m.composeView.View())
+		}
+		return appStyle.Render(m.ttlHeader() + m.composeView.View())
+	case "thread":
+		return appStyle.Render(m.threadView.View(m.terminalWidth, m.terminalHeight))
+	case "revisions":
+		return appStyle.Render(m.revisionsView.View(m.terminalWidth, m.terminalHeight))
+	case "notifications":
+		return appStyle.Render(m.notificationsView.View(m.terminalWidth, m.terminalHeight-2))
+	case "schedule":
+		return appStyle.Render(m.scheduleView.View(m.terminalWidth, m.terminalHeight))
 	case "help":
 		return m.renderHelpView()
+	case "profile":
+		return appStyle.Render(m.profileView.View(m.terminalWidth, m.terminalHeight))
+	case "topics":
+		return appStyle.Render(m.topicsView.View(m.terminalWidth, m.terminalHeight, m.subscribedSet()))
 	default: // "feed" view
 		// --- Main Layout Construction ---
 		// For simplicity, let's create a basic layout with a header, main content (feed),
@@ -339,7 +874,7 @@ func (m *AppModel) View() string {
 		// A more complex layout could use lipgloss.Layout or similar.
 
 		// 1. Header
-		header := headerStyle.Render("SOCLI - Decentralized P2P Social Platform")
+		header := headerStyle.Render("SOCLI - Decentralized P2P Social Platform" + m.unreadLabel())
 
 		// 2. Main Content Area (Feed)
 		// We need to calculate the space available for the main content
@@ -357,7 +892,7 @@ func (m *AppModel) View() string {
 		}
 
 		// 3. Sidebar (Peers and Topics)
-		sidebarWidth := 20
+		sidebarWidth := 30
 		mainContentWidth := availableWidth - sidebarWidth - 1 // -1 for potential spacing
 		if mainContentWidth < 0 {
 			mainContentWidth = availableWidth
@@ -365,13 +900,19 @@ func (m *AppModel) View() string {
 		}
 
 		// Get data for sidebar
-		peers := m.store.GetAllPeers()
+		peers := m.sidebarPeers()
 		topics := make([]string, 0, len(m.subscriptions))
 		for topicName := range m.subscriptions {
 			// Extract hashtag name from topic name, e.g., "socli/hashtag/general" -> "general"
 			parts := strings.Split(topicName, "/")
 			if len(parts) > 0 {
-				topics = append(topics, "#"+parts[len(parts)-1])
+				hashtag := parts[len(parts)-1]
+				if m.circles.IsCircle(hashtag) {
+					// Circles are marked with a lock and their member count
+					topics = append(topics, fmt.Sprintf("🔒#%s (%d)", hashtag, len(m.circles.Members(hashtag))))
+				} else {
+					topics = append(topics, "#"+hashtag)
+				}
 			} else {
 				topics = append(topics, topicName) // Fallback
 			}
@@ -384,6 +925,9 @@ func (m *AppModel) View() string {
 		// Combine peer and topic lists for the sidebar
 		// Using lipgloss to join them vertically
 		sidebarContent := lipgloss.JoinVertical(lipgloss.Left, peerList, topicList)
+		if favoriteList := m.renderFavoriteList(); favoriteList != "" {
+			sidebarContent = lipgloss.JoinVertical(lipgloss.Left, peerList, favoriteList, topicList)
+		}
 		sidebar := sidebarStyle.Width(sidebarWidth).Render(sidebarContent)
 
 		// 4. Main Feed Content
@@ -395,7 +939,7 @@ func (m *AppModel) View() string {
 
 		// 5. Status Bar
 		// Determine the status message to display
-		statusText := fmt.Sprintf("My Peer ID: %s | Press 'c' to compose, '?' for help, 'q' to quit", m.netManager.Host.ID().String())
+		statusText := fmt.Sprintf("My Peer ID: %s | Press 'c' to compose, 'p' for profile, '?' for help, 'q' to quit", m.netManager.Host.ID().String())
 		if m.statusMsg != nil {
 			statusText = m.statusMsg.Message
 		}
@@ -419,16 +963,55 @@ func (m *AppModel) renderPeerList(peers []peer.AddrInfo) string {
 	items := make([]string, 0, len(peers)+1)
 	items = append(items, title)
 
+	// GossipSub scores show which peers the router considers well-behaved
+	var scores map[peer.ID]float64
+	if m.psManager != nil {
+		scores = m.psManager.PeerScores()
+	}
+
+	// Our own status, as set with /status
+	if m.presence != nil {
+		status, text := m.presence.Status()
+		own := "You: " + string(status)
+		if text != "" {
+			own += ": " + text
+		}
+		items = append(items, listItemStyle.Render(presenceStyle(status, false).Render("●")+" "+truncate(own, 24)))
+	}
+
 	if len(peers) == 0 {
 		items = append(items, listItemStyle.Render("No peers connected"))
 	} else {
+		now := time.Now()
 		for _, p := range peers {
 			// Truncate or format peer ID for display
 			peerIDStr := p.ID.String()
 			if len(peerIDStr) > 15 {
 				peerIDStr = peerIDStr[:8] + "..." + peerIDStr[len(peerIDStr)-6:]
 			}
-			items = append(items, listItemStyle.Render(peerIDStr))
+			if score, ok := scores[p.ID]; ok {
+				peerIDStr = fmt.Sprintf("%s %s", peerIDStr, scoreStyle(score).Render(fmt.Sprintf("%+.1f", score)))
+			}
+			// Mark peers running a newer or incompatible version
+			if m.handshake != nil {
+				if version, ok := m.handshake.Lookup(p.ID); ok && version.Compat != messaging.Compatible {
+					peerIDStr += " " + warningStyle.Render("⚠")
+				}
+			}
+
+			// Presence from the peer's heartbeats, if it sends any
+			var presence messaging.PeerPresence
+			var known bool
+			if m.presence != nil {
+				presence, known = m.presence.Lookup(p.ID.String())
+			}
+			if !known {
+				items = append(items, listItemStyle.Render("  "+peerIDStr))
+				continue
+			}
+			dot := presenceStyle(presence.Status, presence.Expired).Render("●")
+			items = append(items, listItemStyle.Render(dot+" "+peerIDStr))
+			items = append(items, listItemStyle.Render("  "+presenceStyle(presence.Status, presence.Expired).Render(truncate(presenceLine(presence, now), 24))))
 		}
 	}
 
@@ -1,16 +1,23 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"socli/config"
 	"socli/content"
 	"socli/crypto"
 	"socli/messaging"
 	"socli/p2p"
 	"socli/storage"
+	"socli/tui/types"
+	"reflect"
 	"testing"
 	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
 )
 
 // TestAppModelPostIntegration tests the integration of posting a message
@@ -38,7 +45,7 @@ func TestAppModelPostIntegration(t *testing.T) {
 	}
 
 	// Create the AppModel
-	appModel, err := NewApp(netManager, store, renderer, psManager, broadcaster, keyPair, cfg)
+	appModel, err := NewApp(Deps{NetManager: netManager, Store: store, Renderer: renderer, PubSub: psManager, Broadcaster: broadcaster, KeyPair: keyPair, Config: cfg})
 	if err != nil {
 		t.Fatalf("Failed to create AppModel: %v", err)
 	}
@@ -76,4 +83,306 @@ func TestAppModelPostIntegration(t *testing.T) {
 	if !found {
 		t.Errorf("Post with ID %q was not found in the store after PostReceivedMsg", testPost.ID)
 	}
-}
\ No newline at end of file
+}
+
+// runCmd runs a command and every command batched into it, and returns the
+// messages they produce.
+func runCmd(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		var msgs []tea.Msg
+		for _, cmd := range batch {
+			msgs = append(msgs, runCmd(cmd)...)
+		}
+		return msgs
+	}
+	return []tea.Msg{msg}
+}
+
+// TestAppModelSyncLateJoiner tests that a peer connecting after the app has
+// started is asked for the posts we missed.
+func TestAppModelSyncLateJoiner(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Privacy.EncryptMessages = false
+	cfg.Network.EnableMDNS = false
+	cfg.Network.EnableDHT = false
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	mn := mocknet.New()
+	defer mn.Close()
+	ours, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	theirs, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+
+	// The late joiner has a post we haven't seen
+	missed := &messaging.Message{ID: "missed", Author: theirs.ID().String(), Content: "While you were away", Hashtags: []string{"general"}, Timestamp: time.Now(), Type: messaging.PostMsg}
+	if err := missed.Sign(keyPair); err != nil {
+		t.Fatalf("Sign() error = %v, want nil", err)
+	}
+	theirStore := storage.NewMemoryStore()
+	theirStore.AddPost(missed)
+	messaging.NewHistorySync(theirs, theirStore, cfg, messaging.NewValidator(cfg, keyPair))
+
+	netManager, err := p2p.NewNetworkManagerWithHost(ours, cfg)
+	if err != nil {
+		t.Fatalf("NewNetworkManagerWithHost() error = %v, want nil", err)
+	}
+	defer netManager.Close()
+	if err := netManager.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v, want nil", err)
+	}
+	store := storage.NewMemoryStore()
+	appModel, err := NewApp(Deps{
+		NetManager:  netManager,
+		Store:       store,
+		HistorySync: messaging.NewHistorySync(ours, store, cfg, messaging.NewValidator(cfg, keyPair)),
+		KeyPair:     keyPair,
+		Config:      cfg,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create AppModel: %v", err)
+	}
+	// Wired up as in main.go, once the app exists
+	connected := make(chan PeerConnectedMsg, 1)
+	netManager.SetPeerConnectedCallback(func(id peer.ID) {
+		connected <- PeerConnectedMsg{PeerID: id.String()}
+	})
+
+	if _, err := mn.ConnectPeers(theirs.ID(), ours.ID()); err != nil {
+		t.Fatalf("Failed to connect peers: %v", err)
+	}
+	var msg PeerConnectedMsg
+	select {
+	case msg = <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("No PeerConnectedMsg for a peer that connected after the app started")
+	}
+
+	_, cmd := appModel.Update(msg)
+	for _, msg := range runCmd(cmd) {
+		appModel.Update(msg)
+	}
+	if _, ok := store.GetPost(missed.ID); !ok {
+		t.Error("The post of a peer that connected after the app started was not synced")
+	}
+}
+
+// TestAppModelPeerVersion tests that a peer running a newer version is
+// warned about, and a compatible one is not.
+func TestAppModelPeerVersion(t *testing.T) {
+	appModel, err := NewApp(Deps{Store: storage.NewMemoryStore(), Config: config.DefaultConfig()})
+	if err != nil {
+		t.Fatalf("Failed to create AppModel: %v", err)
+	}
+	p := test.RandPeerIDFatal(t)
+
+	appModel.Update(PeerVersionMsg{PeerID: p, Version: messaging.PeerVersion{Version: "1.0.0", Schema: messaging.SchemaVersion, Features: messaging.Features}})
+	if appModel.statusMsg != nil {
+		t.Errorf("Status after a compatible peer = %+v, want none", appModel.statusMsg)
+	}
+	appModel.Update(PeerVersionMsg{PeerID: p, Version: messaging.PeerVersion{Version: "9.0.0", Schema: messaging.SchemaVersion + 1, Compat: messaging.PeerNewer}})
+	if appModel.statusMsg == nil || appModel.statusMsg.Type != types.Warning {
+		t.Errorf("Status after a newer peer = %+v, want a warning", appModel.statusMsg)
+	}
+}
+
+// TestAppModelSidebarPeers tests that peers we are connected to are listed
+// in the sidebar and can be mentioned, without having been stored first.
+func TestAppModelSidebarPeers(t *testing.T) {
+	cfg := config.DefaultConfig()
+	mn := mocknet.New()
+	defer mn.Close()
+	ours, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	theirs, err := mn.GenPeer()
+	if err != nil {
+		t.Fatalf("Failed to create mock host: %v", err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatalf("Failed to link peers: %v", err)
+	}
+	if _, err := mn.ConnectPeers(theirs.ID(), ours.ID()); err != nil {
+		t.Fatalf("Failed to connect peers: %v", err)
+	}
+
+	netManager, err := p2p.NewNetworkManagerWithHost(ours, cfg)
+	if err != nil {
+		t.Fatalf("NewNetworkManagerWithHost() error = %v, want nil", err)
+	}
+	defer netManager.Close()
+	store := storage.NewMemoryStore()
+	stored := test.RandPeerIDFatal(t)
+	store.AddPeer(peer.AddrInfo{ID: stored})
+	appModel, err := NewApp(Deps{NetManager: netManager, Store: store, Config: cfg})
+	if err != nil {
+		t.Fatalf("Failed to create AppModel: %v", err)
+	}
+
+	var got []peer.ID
+	for _, info := range appModel.sidebarPeers() {
+		got = append(got, info.ID)
+	}
+	if want := []peer.ID{stored, theirs.ID()}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sidebarPeers() = %v, want %v", got, want)
+	}
+	if mentions, _ := appModel.resolveMentions("@" + shortPeerID(theirs.ID())); !reflect.DeepEqual(mentions, []string{theirs.ID().String()}) {
+		t.Errorf("resolveMentions() of a connected peer = %v, want %v", mentions, theirs.ID())
+	}
+}
+
+// TestAppModelResolveMentions tests that @mentions resolve to the peer IDs of
+// known authors, by full ID or by the end shown in the sidebar.
+func TestAppModelResolveMentions(t *testing.T) {
+	cfg := config.DefaultConfig()
+	store := storage.NewMemoryStore()
+	renderer, err := content.NewMarkdownRenderer()
+	if err != nil {
+		t.Fatalf("Failed to create MarkdownRenderer: %v", err)
+	}
+	keyPair, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	appModel, err := NewApp(Deps{Store: store, Renderer: renderer, KeyPair: keyPair, Config: cfg})
+	if err != nil {
+		t.Fatalf("Failed to create AppModel: %v", err)
+	}
+
+	alice, bob, stranger := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	for _, author := range []peer.ID{alice, bob} {
+		store.AddPost(&messaging.Message{ID: author.String(), Author: author.String(), Content: "hi", Type: messaging.PostMsg, Timestamp: time.Now()})
+	}
+
+	content := "@" + shortPeerID(alice) + " and @" + stranger.String() + " meet @nobody, cc @" + shortPeerID(alice)
+	mentions, unknown := appModel.resolveMentions(content)
+	if want := []string{alice.String(), stranger.String()}; !reflect.DeepEqual(mentions, want) {
+		t.Errorf("resolveMentions() mentions = %v, want %v", mentions, want)
+	}
+	if want := []string{"nobody"}; !reflect.DeepEqual(unknown, want) {
+		t.Errorf("resolveMentions() unknown = %v, want %v", unknown, want)
+	}
+
+	// A peer mentioned by its full ID and by the end of it is mentioned once
+	content = "@" + bob.String() + " @" + shortPeerID(bob) + " @" + bob.String()
+	if mentions, _ := appModel.resolveMentions(content); !reflect.DeepEqual(mentions, []string{bob.String()}) {
+		t.Errorf("resolveMentions() of a repeated mention = %v, want only %v", mentions, bob.String())
+	}
+}
+
+// TestDeliveryStatus tests the status bar text for where a post went.
+func TestDeliveryStatus(t *testing.T) {
+	ops, dev := messaging.GetTopicForHashtag("ops"), messaging.GetTopicForHashtag("dev")
+	tests := []struct {
+		name       string
+		deliveries []messaging.Delivery
+		wantType   types.StatusType
+		want       string
+	}{
+		{
+			name:       "Sent",
+			deliveries: []messaging.Delivery{{Topic: ops, State: messaging.DeliverySent, Peers: 3}},
+			wantType:   types.Success,
+			want:       "Post sent to 3 peers on #ops",
+		},
+		{
+			name: "Waiting for peers",
+			deliveries: []messaging.Delivery{
+				{Topic: ops, State: messaging.DeliverySent, Peers: 1},
+				{Topic: dev, State: messaging.DeliveryWaiting, Err: messaging.ErrNoPeers},
+			},
+			wantType: types.Warning,
+			want:     "Post sent to 1 peer on #ops; waiting for peers on #dev",
+		},
+		{
+			name: "Given up",
+			deliveries: []messaging.Delivery{
+				{Topic: ops, State: messaging.DeliveryFailed, Err: messaging.ErrNoPeers},
+				{Topic: dev, State: messaging.DeliveryWaiting, Err: errors.New("publish failed")},
+			},
+			wantType: types.Error,
+			want:     "Post retrying #dev; not delivered on #ops",
+		},
+		{
+			name: "Expired",
+			deliveries: []messaging.Delivery{
+				{Topic: ops, State: messaging.DeliverySent, Peers: 2},
+				{Topic: dev, State: messaging.DeliveryFailed, Err: messaging.ErrExpired},
+			},
+			wantType: types.Warning,
+			want:     "Post sent to 2 peers on #ops; expired before reaching #dev",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := deliveryStatus("Post", tt.deliveries)
+			if got.Type != tt.wantType || got.Message != tt.want {
+				t.Errorf("deliveryStatus() = %+v, want %v %q", got, tt.wantType, tt.want)
+			}
+		})
+	}
+}
+
+// TestAppModelTTL tests setting how long posts live with /ttl and ctrl+t.
+func TestAppModelTTL(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Expiry.Presets = []time.Duration{10 * time.Minute, time.Hour}
+	appModel, err := NewApp(Deps{Store: storage.NewMemoryStore(), Config: cfg})
+	if err != nil {
+		t.Fatalf("Failed to create AppModel: %v", err)
+	}
+
+	// ctrl+t cycles through the presets and back to off
+	var labels []string
+	for range 3 {
+		appModel.cycleTTL()
+		labels = append(labels, ttlLabel(appModel.ttl))
+	}
+	if want := []string{"10m", "1h", "off"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("cycleTTL() went through %v, want %v", labels, want)
+	}
+	if got := appModel.expiresAt(time.Now()); got != nil {
+		t.Errorf("expiresAt() = %v with expiry off, want nil", got)
+	}
+
+	tests := []struct {
+		arg      string
+		want     time.Duration
+		wantType types.StatusType
+	}{
+		{"1h30m", 90 * time.Minute, types.Success},
+		{"-5m", 90 * time.Minute, types.Warning},
+		{"soon", 90 * time.Minute, types.Warning},
+		{"off", 0, types.Success},
+	}
+	for _, tt := range tests {
+		appModel.setTTL([]string{tt.arg})
+		if appModel.ttl != tt.want || appModel.statusMsg.Type != tt.wantType {
+			t.Errorf("setTTL(%q) = %v with status %+v, want %v", tt.arg, appModel.ttl, appModel.statusMsg, tt.want)
+		}
+	}
+
+	appModel.setTTL([]string{"10m"})
+	sent := time.Now()
+	if got := appModel.expiresAt(sent); got == nil || !got.Equal(sent.Add(10*time.Minute)) {
+		t.Errorf("expiresAt() = %v, want 10m after sending", got)
+	}
+	if got := ttlLabel(24 * time.Hour); got != "24h" {
+		t.Errorf("ttlLabel(24h) = %q, want \"24h\"", got)
+	}
+}
@@ -0,0 +1,110 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"socli/internal"
+	"socli/messaging"
+	"socli/tui/views"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// minFetchPrefix is the shortest attachment hash prefix /fetch accepts.
+const minFetchPrefix = 4
+
+// attachmentPostedMsg reports the result of posting a file with /attach.
+type attachmentPostedMsg struct {
+	Post *messaging.Message
+	Err  error
+}
+
+// attachmentFetchedMsg reports the result of fetching an attachment with /fetch.
+type attachmentFetchedMsg struct {
+	Attachment *messaging.Attachment
+	Err        error
+}
+
+// attachCmd returns a tea.Cmd that reads the file at path, keeps its chunks
+// in the store for peers to fetch, and publishes a post carrying its content
+// address. The caption is the post's text; it defaults to the file name.
+func (m *AppModel) attachCmd(path, caption string) tea.Cmd {
+	if m.blobs == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		// Check the size before reading, so a huge file isn't loaded into memory
+		info, err := os.Stat(path)
+		if err != nil {
+			return attachmentPostedMsg{Err: err}
+		}
+		if info.Size() > m.cfg.Attachments.MaxSize {
+			return attachmentPostedMsg{Err: fmt.Errorf("%s is %s, limit is %s", info.Name(), views.FormatSize(info.Size()), views.FormatSize(m.cfg.Attachments.MaxSize))}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return attachmentPostedMsg{Err: err}
+		}
+		attachment, err := m.blobs.Add(path, data)
+		if err != nil {
+			return attachmentPostedMsg{Err: err}
+		}
+
+		if caption == "" {
+			caption = attachment.Name
+		}
+		msg := &messaging.Message{
+			ID:         uuid.New().String(),
+			Author:     m.netManager.Host.ID().String(),
+			Content:    caption,
+			Hashtags:   internal.ExtractHashtags(caption),
+			Timestamp:  time.Now(),
+			Type:       messaging.PostMsg,
+			Attachment: attachment,
+		}
+		if err := msg.Sign(m.keyPair); err != nil {
+			return attachmentPostedMsg{Err: err}
+		}
+		if err := m.broadcaster.Broadcast(context.Background(), msg); err != nil {
+			return attachmentPostedMsg{Err: err}
+		}
+		return attachmentPostedMsg{Post: msg}
+	}
+}
+
+// fetchAttachmentCmd returns a tea.Cmd that fetches a post's attachment,
+// asking the author first and then every connected peer.
+func (m *AppModel) fetchAttachmentCmd(post *messaging.Message) tea.Cmd {
+	if m.blobs == nil {
+		return nil
+	}
+	var peers []peer.ID
+	if author, err := peer.Decode(post.Author); err == nil {
+		peers = append(peers, author)
+	}
+	for _, p := range m.connectedPeers() {
+		if len(peers) == 0 || p != peers[0] {
+			peers = append(peers, p)
+		}
+	}
+	return func() tea.Msg {
+		_, err := m.blobs.Fetch(context.Background(), post.Attachment, peers)
+		return attachmentFetchedMsg{Attachment: post.Attachment, Err: err}
+	}
+}
+
+// findAttachment returns the newest post whose attachment hash starts with prefix.
+func (m *AppModel) findAttachment(prefix string) (*messaging.Message, bool) {
+	posts := m.store.GetAllPosts()
+	for i := len(posts) - 1; i >= 0; i-- {
+		if posts[i].Attachment != nil && strings.HasPrefix(posts[i].Attachment.Hash, prefix) {
+			return posts[i], true
+		}
+	}
+	return nil, false
+}
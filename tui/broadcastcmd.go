@@ -1,8 +1,16 @@
 package tui
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"socli/messaging"
 	"socli/tui/types"
+	"strings"
+
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // listenForBroadcastResults returns a tea.Cmd that listens for StatusMsg
@@ -17,4 +25,113 @@ func (m *AppModel) listenForBroadcastResults() tea.Cmd {
 		// This msg will be passed to the Update function
 		return types.BroadcastResultMsg{Status: status}
 	}
-}
\ No newline at end of file
+}
+
+// topicLabel names a topic the way the feed does: #hashtag, or @peer for an inbox.
+func topicLabel(topic string) string {
+	if hashtag, ok := strings.CutPrefix(topic, messaging.HashtagTopicPrefix); ok {
+		return "#" + hashtag
+	}
+	if owner, ok := strings.CutPrefix(topic, messaging.InboxTopicPrefix); ok {
+		if id, err := peer.Decode(owner); err == nil {
+			return "@" + shortPeerID(id)
+		}
+		return "@" + owner
+	}
+	return topic
+}
+
+// deliveryStatus describes where a post went, e.g. "Post sent to 3 peers on
+// #ops; waiting for peers on #dev". subject names the post.
+func deliveryStatus(subject string, deliveries []messaging.Delivery) types.StatusMsg {
+	var sent, waiting, retrying, failed, expired []string
+	for _, d := range deliveries {
+		label := topicLabel(d.Topic)
+		switch {
+		case d.State == messaging.DeliverySent && d.Peers == 1:
+			sent = append(sent, "1 peer on "+label)
+		case d.State == messaging.DeliverySent:
+			sent = append(sent, fmt.Sprintf("%d peers on %s", d.Peers, label))
+		case d.State == messaging.DeliveryFailed && errors.Is(d.Err, messaging.ErrExpired):
+			expired = append(expired, label)
+		case d.State == messaging.DeliveryFailed:
+			failed = append(failed, label)
+		case errors.Is(d.Err, messaging.ErrNoPeers):
+			waiting = append(waiting, label)
+		default:
+			retrying = append(retrying, label)
+		}
+	}
+
+	var clauses []string
+	if len(sent) > 0 {
+		clauses = append(clauses, "sent to "+strings.Join(sent, ", "))
+	}
+	if len(waiting) > 0 {
+		clauses = append(clauses, "waiting for peers on "+strings.Join(waiting, ", "))
+	}
+	if len(retrying) > 0 {
+		clauses = append(clauses, "retrying "+strings.Join(retrying, ", "))
+	}
+	if len(failed) > 0 {
+		clauses = append(clauses, "not delivered on "+strings.Join(failed, ", "))
+	}
+	if len(expired) > 0 {
+		clauses = append(clauses, "expired before reaching "+strings.Join(expired, ", "))
+	}
+
+	status := types.StatusMsg{Type: types.Success, Message: subject + " " + strings.Join(clauses, "; ")}
+	if len(waiting)+len(retrying)+len(failed)+len(expired) > 0 {
+		status.Type = types.Warning
+	}
+	if len(sent) == 0 && len(failed)+len(expired) > 0 {
+		status.Type = types.Error
+	}
+	return status
+}
+
+// reportDeliveries makes the outbox report posts that waited for peers on
+// broadcastResultChan once they are sent or given up on.
+func (m *AppModel) reportDeliveries() {
+	if m.outbox == nil {
+		return
+	}
+	m.outbox.SetReporter(func(id string, deliveries []messaging.Delivery) {
+		m.broadcastResultChan <- deliveryStatus("Post", deliveries)
+	})
+}
+
+// publishPost sends one of our posts through the outbox and returns where it
+// went. Without an outbox, the post is broadcast once and nil is returned.
+func (m *AppModel) publishPost(msg *messaging.Message) ([]messaging.Delivery, error) {
+	if m.outbox == nil {
+		return nil, m.broadcaster.Broadcast(context.Background(), msg)
+	}
+	return m.outbox.Send(context.Background(), msg)
+}
+
+// publishedStatus describes the result of publishPost. subject names the post.
+func publishedStatus(subject string, deliveries []messaging.Delivery, err error) types.StatusMsg {
+	switch {
+	case err != nil:
+		log.Printf("Error broadcasting message: %v", err)
+		return types.StatusMsg{Type: types.Error, Message: "Failed to publish " + strings.ToLower(subject) + ": " + err.Error()}
+	case deliveries == nil:
+		return types.StatusMsg{Type: types.Success, Message: subject + " sent"}
+	}
+	return deliveryStatus(subject, deliveries)
+}
+
+// sendPost publishes one of our posts and reports where it went on
+// broadcastResultChan, along with any mentions that didn't match a peer.
+func (m *AppModel) sendPost(msg *messaging.Message, unknownMentions []string) {
+	deliveries, err := m.publishPost(msg)
+	status := publishedStatus("Post", deliveries, err)
+	if len(unknownMentions) > 0 {
+		status.Message += "; no single known peer matches @" + strings.Join(unknownMentions, ", @")
+		if status.Type == types.Success {
+			status.Type = types.Warning
+		}
+	}
+	m.broadcastResultChan <- status
+}
@@ -0,0 +1,87 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"socli/messaging"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// circleResultMsg reports the result of /invite, /join or /remove.
+type circleResultMsg struct {
+	Message string
+	Joined  string // Circle to subscribe to after /join
+	Err     error
+}
+
+// circleName returns the circle named by a command argument, with or
+// without the leading '#'.
+func circleName(arg string) string {
+	return strings.TrimPrefix(arg, "#")
+}
+
+// inviteFile returns the file an invite token for p to a circle is written to.
+func inviteFile(name string, p peer.ID) string {
+	return fmt.Sprintf("%s-%s.invite", name, shortPeerID(p))
+}
+
+// inviteCmd returns a tea.Cmd that adds a peer to a circle we own and writes
+// the invite token to a file, since tokens are too long to copy from the
+// status bar. The file must reach the peer over a private channel.
+func (m *AppModel) inviteCmd(invitee peer.ID, name string) tea.Cmd {
+	if m.circles == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		token, err := m.circles.Invite(context.Background(), name, invitee)
+		if err != nil {
+			return circleResultMsg{Err: err}
+		}
+		path := inviteFile(name, invitee)
+		if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+			return circleResultMsg{Err: err}
+		}
+		return circleResultMsg{Message: fmt.Sprintf("Invited %s to #%s; send them %s privately", shortPeerID(invitee), name, path)}
+	}
+}
+
+// joinCmd returns a tea.Cmd that joins a circle with an invite token, given
+// either as the token itself or as the path of an invite file.
+func (m *AppModel) joinCmd(arg string) tea.Cmd {
+	if m.circles == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		token := arg
+		if !strings.HasPrefix(arg, messaging.InvitePrefix) {
+			data, err := os.ReadFile(arg)
+			if err != nil {
+				return circleResultMsg{Err: err}
+			}
+			token = string(data)
+		}
+		name, err := m.circles.Join(token)
+		if err != nil {
+			return circleResultMsg{Err: err}
+		}
+		return circleResultMsg{Message: "Joined circle #" + name, Joined: name}
+	}
+}
+
+// removeCmd returns a tea.Cmd that removes a member from a circle we own and
+// rotates the circle's key.
+func (m *AppModel) removeCmd(member peer.ID, name string) tea.Cmd {
+	if m.circles == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		if err := m.circles.Remove(context.Background(), name, member); err != nil {
+			return circleResultMsg{Err: err}
+		}
+		return circleResultMsg{Message: fmt.Sprintf("Removed %s from #%s and rotated its key", shortPeerID(member), name)}
+	}
+}
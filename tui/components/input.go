@@ -85,3 +85,8 @@ func (i *Input) View() string {
 func (i *Input) Value() string {
 	return i.textarea.Value()
 }
+
+// SetValue replaces the value of the input.
+func (i *Input) SetValue(value string) {
+	i.textarea.SetValue(value)
+}
@@ -0,0 +1,71 @@
+package tui
+
+import (
+	"context"
+	"log"
+	"socli/messaging"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// activityWindow is how far back posts are counted for directory announcements.
+const activityWindow = time.Hour
+
+// directoryTickMsg is sent every announce interval to publish our hashtags.
+type directoryTickMsg struct{}
+
+// announceTickCmd returns a tea.Cmd that sends a directoryTickMsg after one
+// announce interval. It returns nil when there is no directory.
+func (m *AppModel) announceTickCmd() tea.Cmd {
+	if m.directory == nil {
+		return nil
+	}
+	return tea.Tick(m.directory.Interval(), func(time.Time) tea.Msg {
+		return directoryTickMsg{}
+	})
+}
+
+// announceCmd returns a tea.Cmd that announces our subscribed hashtags and
+// their recent activity on the directory topic.
+func (m *AppModel) announceCmd() tea.Cmd {
+	if m.directory == nil {
+		return nil
+	}
+	// Collect the activity here, since the subscriptions map belongs to Update
+	since := time.Now().Add(-activityWindow)
+	var topics []messaging.TopicActivity
+	for _, hashtag := range m.subscribedHashtags() {
+		// Circles are invite-only, so they aren't advertised
+		if m.circles.IsCircle(hashtag) {
+			continue
+		}
+		topics = append(topics, messaging.TopicActivity{
+			Hashtag: hashtag,
+			Posts:   len(m.store.RecentPosts([]string{hashtag}, since, -1)),
+		})
+	}
+	return func() tea.Msg {
+		if err := m.directory.Announce(context.Background(), topics); err != nil {
+			log.Printf("Error announcing hashtags: %v", err)
+		}
+		return nil
+	}
+}
+
+// openTopicsView switches to the topic directory with fresh entries.
+func (m *AppModel) openTopicsView() {
+	if m.directory != nil {
+		m.topicsView.SetEntries(m.directory.Entries())
+	}
+	m.currentView = "topics"
+}
+
+// subscribedSet returns the subscribed hashtags as a set, for marking them in views.
+func (m *AppModel) subscribedSet() map[string]bool {
+	set := make(map[string]bool)
+	for _, hashtag := range m.subscribedHashtags() {
+		set[hashtag] = true
+	}
+	return set
+}
@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"context"
+	"socli/messaging"
+	"socli/tui/types"
+	"socli/tui/views"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+)
+
+// editSentMsg reports the result of publishing an edit.
+type editSentMsg struct {
+	Err error
+}
+
+// currentContent returns the latest revision of a post's content.
+func (m *AppModel) currentContent(post *messaging.Message) string {
+	if revisions := m.store.Revisions(post.ID); len(revisions) > 0 {
+		return revisions[len(revisions)-1].Content
+	}
+	return post.Content
+}
+
+// startEdit opens the compose view on the current content of one of our
+// own posts. Peers reject edits signed by anyone but the post's author.
+func (m *AppModel) startEdit(post *messaging.Message) {
+	if post.Author != m.netManager.Host.ID().String() {
+		m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "You can only edit your own posts"}
+		return
+	}
+	if post.Type == messaging.PollMsg {
+		m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Polls can't be edited, only closed with /closepoll"}
+		return
+	}
+	m.replyTo, m.sharing = nil, nil
+	m.editing = post
+	m.composeView = views.NewComposeView(m.cfg)
+	m.composeView.SetValue(m.currentContent(post))
+	m.currentView = "compose"
+	m.statusMsg = nil
+}
+
+// editCmd publishes new content for the post being edited, on the post's
+// hashtags so it reaches everyone who saw the post.
+func (m *AppModel) editCmd(content string) tea.Cmd {
+	post := m.editing
+	if strings.TrimSpace(content) == strings.TrimSpace(m.currentContent(post)) {
+		m.statusMsg = &types.StatusMsg{Type: types.Info, Message: "No changes to save"}
+		return nil
+	}
+	msg := &messaging.Message{
+		ID:        uuid.New().String(),
+		Author:    post.Author,
+		Content:   content,
+		Hashtags:  post.Hashtags,
+		Timestamp: time.Now(),
+		Type:      messaging.EditMsg,
+		ReplyTo:   post.ID,
+		ExpiresAt: post.ExpiresAt, // An edit expires with the post, so its text doesn't outlive it
+	}
+	var unknown []string
+	msg.Mentions, unknown = m.resolveMentions(content)
+	if len(unknown) > 0 {
+		m.statusMsg = mentionWarning(unknown)
+	}
+	if err := msg.Sign(m.keyPair); err != nil {
+		return func() tea.Msg { return editSentMsg{Err: err} }
+	}
+	m.store.AddPost(msg)
+	return func() tea.Msg {
+		return editSentMsg{Err: m.broadcaster.Broadcast(context.Background(), msg)}
+	}
+}
+
+// editHeader describes the post being edited above the compose view.
+func (m *AppModel) editHeader() string {
+	return headerStyle.Render("Editing your post from "+m.editing.Timestamp.Format(time.Stamp)) + "\n" +
+		helpStyle.Render("Earlier revisions stay visible to others with 'v'") + "\n\n"
+}
+
+// openRevisions shows every revision of a post.
+func (m *AppModel) openRevisions(post *messaging.Message) {
+	if post == nil {
+		return
+	}
+	if len(m.store.Revisions(post.ID)) < 2 {
+		m.statusMsg = &types.StatusMsg{Type: types.Info, Message: "This post hasn't been edited"}
+		return
+	}
+	m.revisionsView = views.NewRevisionsView(m.store, m.renderer, post.ID)
+	m.currentView = "revisions"
+	m.statusMsg = nil
+}
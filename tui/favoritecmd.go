@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"fmt"
+	"socli/messaging"
+	"socli/p2p"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// favorites returns the favorites reconnect manager, or nil without a network.
+func (m *AppModel) favorites() *p2p.Reconnector {
+	if m.netManager == nil {
+		return nil
+	}
+	return m.netManager.Favorites
+}
+
+// favoriteLine describes a favorite's connection for the sidebar, e.g.
+// "retry in 8s (3 failed)".
+func favoriteLine(status p2p.FavoriteStatus, now time.Time) string {
+	switch {
+	case status.Connected:
+		return "connected"
+	case status.Dialing:
+		return "reconnecting..."
+	case status.Attempts == 0:
+		return "disconnected"
+	}
+	wait := status.NextDial.Sub(now).Round(time.Second)
+	if wait < 0 {
+		wait = 0
+	}
+	return fmt.Sprintf("retry in %s (%d failed)", wait, status.Attempts)
+}
+
+// renderFavoriteList creates a styled list of favorite peers and their
+// reconnection state. It returns "" when there are no favorites.
+func (m *AppModel) renderFavoriteList() string {
+	favorites := m.favorites()
+	if favorites == nil {
+		return ""
+	}
+	statuses := favorites.Favorites()
+	if len(statuses) == 0 {
+		return ""
+	}
+
+	items := []string{headerStyle.Render("Favorites")}
+	now := time.Now()
+	for _, status := range statuses {
+		star := presenceStyle(messaging.StatusOnline, !status.Connected).Render("★")
+		items = append(items, listItemStyle.Render(star+" "+shortPeerID(status.ID)+" "+truncate(favoriteLine(status, now), 16)))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, items...)
+}
@@ -0,0 +1,62 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"socli/messaging"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// PeerVersionMsg carries the version a peer told us about, either in answer to
+// our hello or in a hello of its own.
+type PeerVersionMsg struct {
+	PeerID  peer.ID
+	Version messaging.PeerVersion
+}
+
+// handshakeCmd returns a tea.Cmd that exchanges hellos with a newly connected
+// peer and sends its version to Update as a PeerVersionMsg. It returns nil
+// when there is no handshake.
+func (m *AppModel) handshakeCmd(p peer.ID) tea.Cmd {
+	if m.handshake == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		version, err := m.handshake.Check(context.Background(), p)
+		if err != nil {
+			log.Printf("Error exchanging hellos with %s: %v", p, err)
+			return nil
+		}
+		return PeerVersionMsg{PeerID: p, Version: version}
+	}
+}
+
+// versionWarning describes what an incompatible or newer peer, or one that
+// can't read the wire format we publish in, means for the user. It returns ""
+// when there is nothing to worry about.
+func versionWarning(p peer.ID, version messaging.PeerVersion, wireFormat string) string {
+	short := shortPeerID(p)
+	switch version.Compat {
+	case messaging.PeerNewer:
+		return fmt.Sprintf("Peer %s runs a newer socli (%s); some of its posts may be dropped. Consider upgrading.", short, version.Version)
+	case messaging.PeerIncompatible:
+		return fmt.Sprintf("Peer %s runs an incompatible socli (%s); its posts may not verify.", short, version.Version)
+	}
+	if wireFormat == messaging.WireBinary && !version.Supports(messaging.WireBinary) {
+		return fmt.Sprintf("Peer %s (%s) can't read binary messages; set wire.format to json until it upgrades.", short, version.Version)
+	}
+	return ""
+}
+
+// shortPeerID returns the last characters of a peer ID, enough to tell
+// peers apart in status messages.
+func shortPeerID(p peer.ID) string {
+	short := p.String()
+	if len(short) > 8 {
+		short = short[len(short)-8:]
+	}
+	return short
+}
@@ -56,12 +56,35 @@ func (m *AppModel) renderHelpViewInternal() string {
 	b.WriteString(sectionTitleStyle.Render("Feed View Keybindings"))
 	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Scroll down to older posts", keyStyle.Render("j"))) + "\n")
 	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Scroll up to newer posts", keyStyle.Render("k"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Show your profile, reachability and relay addresses", keyStyle.Render("p"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Reply to the selected (top) post. The reply inherits its hashtags.", keyStyle.Render("r"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Show the thread of the selected post. Missing parents are fetched from peers.", keyStyle.Render("t"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Share the selected post to the #hashtags you type, with an optional comment.", keyStyle.Render("s"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : React to the selected post with 👍. Use /react for other emoji.", keyStyle.Render("+"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Edit the selected post, if it is yours", keyStyle.Render("e"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Show the earlier revisions of an edited post", keyStyle.Render("v"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Show the posts that @mentioned you and clear the unread count", keyStyle.Render("n"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Vote for an option of the selected poll. Press another number to change your vote.", keyStyle.Render("1-9"))) + "\n")
+	b.WriteString("\n")
+
+	// Thread View Keybindings
+	b.WriteString(sectionTitleStyle.Render("Thread View Keybindings"))
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Select the next or previous post in the thread", keyStyle.Render("j, k"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Reply to the selected post", keyStyle.Render("r"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Share the selected post", keyStyle.Render("s"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : React to the selected post with 👍", keyStyle.Render("+"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Edit the selected post, if it is yours", keyStyle.Render("e"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Show the earlier revisions of an edited post", keyStyle.Render("v"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Vote for an option of the selected poll", keyStyle.Render("1-9"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Return to the feed", keyStyle.Render("q, Esc"))) + "\n")
 	b.WriteString("\n")
 
 	// Compose View Keybindings
 	b.WriteString(sectionTitleStyle.Render("Compose View Keybindings"))
 	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Send the typed message or execute the command", keyStyle.Render("Enter"))) + "\n")
 	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Discard the current message/command and return to the feed view", keyStyle.Render("Esc"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Cycle how long the post lives before every peer drops it: off or one of the presets", keyStyle.Render("Ctrl+T"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Mention a peer by full peer ID or the short ID shown in the sidebar. They are notified even off their hashtags.", keyStyle.Render("@name"))) + " Example: " + exampleStyle.Render("thanks @f3kA9xQz!") + "\n")
 	b.WriteString("\n")
 
 	// Commands
@@ -69,6 +92,23 @@ func (m *AppModel) renderHelpViewInternal() string {
 	b.WriteString("While in the compose view, you can enter special commands prefixed with '/'.\n")
 	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Join a new topic to start receiving posts tagged with #hashtag.", keyStyle.Render("/subscribe <hashtag>"))) + " Example: " + exampleStyle.Render("/subscribe tech") + "\n")
 	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Leave a topic to stop receiving posts for #hashtag.", keyStyle.Render("/unsubscribe <hashtag>"))) + " Example: " + exampleStyle.Render("/unsubscribe tech") + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Browse the hashtags announced on the network. Use j/k to move, Enter or s to subscribe.", keyStyle.Render("/topics"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Set your presence status, e.g. /status away back at 2pm.", keyStyle.Render("/status <online|away|dnd> [text]"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Post a file with optional text. Peers fetch it from you on demand.", keyStyle.Render("/attach <path> [text]"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Fetch the attachment with this short hash and preview it in the feed.", keyStyle.Render("/fetch <hash>"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : React to the selected post. Each emoji counts once per author.", keyStyle.Render("/react <emoji|:shortcode:>"))) + " Example: " + exampleStyle.Render("/react :tada:") + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Start a poll with 2 to 10 options. Quote anything with spaces.", keyStyle.Render(`/poll "question" option1 option2 ...`))) + " Example: " + exampleStyle.Render(`/poll "deploy now? #ops" yes no "after lunch"`) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Vote on the selected poll. Voting again changes your vote.", keyStyle.Render("/vote <number|option>"))) + " Example: " + exampleStyle.Render("/vote 2") + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Close your selected poll. Later votes aren't counted.", keyStyle.Render("/closepoll"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Publish a post at a time of day or after a delay.", keyStyle.Render("/schedule <09:00|10m> <text>"))) + " Example: " + exampleStyle.Render("/schedule 09:00 standup reminder #team") + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : List the scheduled posts. Use j/k to move, e to edit, d to cancel.", keyStyle.Render("/schedule"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Make your next posts expire that long after sending, until /ttl off.", keyStyle.Render("/ttl <10m|1h|off>"))) + " Example: " + exampleStyle.Render("/ttl 10m") + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Keep a peer connected. It is redialed with backoff whenever the connection drops.", keyStyle.Render("/favorite <peer ID|multiaddr>"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Stop redialing a favorite peer.", keyStyle.Render("/unfavorite <peer ID>"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Create an invite-only circle. Posts tagged #name are only readable by its members.", keyStyle.Render("/circle <name>"))) + " Example: " + exampleStyle.Render("/circle friends") + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Add a peer to a circle you own and write their invite token to a file.", keyStyle.Render("/invite <peer ID> <#circle>"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Join a circle with the invite file or token you were sent.", keyStyle.Render("/join <invite file|token>"))) + "\n")
+	b.WriteString(itemStyle.Render(fmt.Sprintf("%s : Remove a member from a circle you own and rotate its key.", keyStyle.Render("/remove <peer ID> <#circle>"))) + "\n")
 	b.WriteString("\n")
 
 	// Features
@@ -92,6 +132,7 @@ func (m *AppModel) renderHelpViewInternal() string {
 	b.WriteString(sectionTitleStyle.Render("Troubleshooting"))
 	b.WriteString(itemStyle.Render("If you encounter issues, check the application logs for error messages.") + "\n")
 	b.WriteString(itemStyle.Render("Ensure your firewall allows traffic on the configured port (default random).") + "\n")
+	b.WriteString(itemStyle.Render("Behind a NAT? Check reachability in the profile view ('p'). Private nodes are reached through relays; set 'static_relays' or ask a public teammate to enable 'enable_relay_service'.") + "\n")
 	b.WriteString(itemStyle.Render("For network connectivity issues, verify that other SOCLI instances are on the same network (mDNS) or reachable via DHT.") + "\n")
 	b.WriteString("\n")
 
@@ -32,4 +32,33 @@ func ParseCommand(input string) (command string, args []string) {
 	args = parts[1:]
 	
 	return command, args
-}
\ No newline at end of file
+}
+
+// SplitQuoted splits input into whitespace separated fields like
+// strings.Fields, but keeps text in double quotes together as one field,
+// e.g. `"deploy now?" yes no` gives "deploy now?", "yes" and "no".
+func SplitQuoted(input string) []string {
+	var fields []string
+	var field strings.Builder
+	inQuotes, inField := false, false
+	for _, r := range input {
+		switch {
+		case r == '"' || r == '“' || r == '”':
+			inQuotes = !inQuotes
+			inField = true
+		case unicode.IsSpace(r) && !inQuotes:
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
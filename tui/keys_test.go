@@ -86,4 +86,24 @@ func TestParseCommand(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// TestSplitQuoted tests the SplitQuoted function.
+func TestSplitQuoted(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{`"deploy now?" yes no`, []string{"deploy now?", "yes", "no"}},
+		{`lunch? pizza "thai food"  sushi `, []string{"lunch?", "pizza", "thai food", "sushi"}},
+		{`“smart quotes” ok`, []string{"smart quotes", "ok"}},
+		{`"" empty`, []string{"", "empty"}},
+		{`"unterminated quote`, []string{"unterminated quote"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := SplitQuoted(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitQuoted(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
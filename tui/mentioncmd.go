@@ -0,0 +1,131 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"socli/internal"
+	"socli/messaging"
+	"socli/tui/types"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// minMentionName is the shortest end of a peer ID that @name resolves.
+const minMentionName = 4
+
+// selfID returns our peer ID, or "" without a network.
+func (m *AppModel) selfID() string {
+	if m.netManager == nil {
+		return ""
+	}
+	return m.netManager.Host.ID().String()
+}
+
+// knownPeers returns the peers a mention can name: those in the sidebar and
+// the authors of stored posts.
+func (m *AppModel) knownPeers() []string {
+	seen := make(map[string]bool)
+	var peers []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			peers = append(peers, id)
+		}
+	}
+	for _, info := range m.sidebarPeers() {
+		add(info.ID.String())
+	}
+	for _, post := range m.store.GetAllPosts() {
+		add(post.Author)
+	}
+	return peers
+}
+
+// resolveMentions turns the @mentions in content into peer IDs. A mention is
+// either a full peer ID or the end of one, as shown in the sidebar, that
+// matches exactly one known peer. Names that match no peer, or several, are
+// returned as unknown. A peer mentioned several times, by any of its names,
+// is only returned once, so it gets the post in its inbox once.
+func (m *AppModel) resolveMentions(content string) (mentions, unknown []string) {
+	names := internal.ExtractMentions(content)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	mention := func(id string) {
+		if !slices.Contains(mentions, id) {
+			mentions = append(mentions, id)
+		}
+	}
+	known := m.knownPeers()
+	for _, name := range names {
+		if id, err := peer.Decode(name); err == nil {
+			mention(id.String())
+			continue
+		}
+		var matches []string
+		if len(name) >= minMentionName {
+			for _, id := range known {
+				if strings.HasSuffix(id, name) {
+					matches = append(matches, id)
+				}
+			}
+		}
+		if len(matches) != 1 {
+			unknown = append(unknown, name)
+		} else {
+			mention(matches[0])
+		}
+	}
+	if len(mentions) > messaging.MaxMentions {
+		mentions = mentions[:messaging.MaxMentions]
+	}
+	return mentions, unknown
+}
+
+// mentionWarning describes the mentions that couldn't be resolved.
+func mentionWarning(unknown []string) *types.StatusMsg {
+	return &types.StatusMsg{Type: types.Warning, Message: "Sent, but no single known peer matches @" + strings.Join(unknown, ", @")}
+}
+
+// noteMention records a new post that mentions us in the notifications,
+// counts it as unread and rings the terminal bell if enabled.
+func (m *AppModel) noteMention(post *messaging.Message) tea.Cmd {
+	self := m.selfID()
+	if self == "" || post.Author == self || !slices.Contains(post.Mentions, self) {
+		return nil
+	}
+	content := []rune(post.Content)
+	if len(content) > 60 {
+		content = append(content[:60], '…')
+	}
+	note := fmt.Sprintf("@%s mentioned you: %s", shortAuthor(post.Author), string(content))
+	m.notificationsView.AddMessage(types.StatusMsg{Type: types.Info, Message: note})
+	m.unreadMentions++
+	m.statusMsg = &types.StatusMsg{Type: types.Info, Message: "🔔 " + note + " ('n' to view)"}
+	if !m.cfg.UI.MentionBell {
+		return nil
+	}
+	return func() tea.Msg {
+		os.Stdout.WriteString("\a")
+		return nil
+	}
+}
+
+// openNotifications shows the mentions we received and marks them read.
+func (m *AppModel) openNotifications() {
+	m.unreadMentions = 0
+	m.notificationsView.SetMaxLines(max(m.terminalHeight-6, 1))
+	m.currentView = "notifications"
+	m.statusMsg = nil
+}
+
+// unreadLabel is shown in the header while mentions are unread.
+func (m *AppModel) unreadLabel() string {
+	if m.unreadMentions == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  🔔 %d unread ('n')", m.unreadMentions)
+}
@@ -0,0 +1,144 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"socli/crypto"
+	"socli/internal"
+	"socli/messaging"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+)
+
+// pollUsage explains the /poll command.
+const pollUsage = `Usage: /poll "question" option1 option2 ...`
+
+// pollPublishedMsg reports the result of publishing a poll, a vote or a closing.
+type pollPublishedMsg struct {
+	Success string
+	Err     error
+}
+
+// parsePoll reads the question and options of a /poll command. Quote the
+// question and any option with spaces in it.
+func parsePoll(content string) (question string, options []string, err error) {
+	fields := SplitQuoted(strings.TrimSpace(content))
+	if len(fields) < 4 {
+		return "", nil, errors.New(pollUsage)
+	}
+	question, options = strings.TrimSpace(fields[1]), fields[2:]
+	if question == "" {
+		return "", nil, errors.New(pollUsage)
+	}
+	if len(options) > messaging.MaxPollOptions {
+		return "", nil, fmt.Errorf("a poll can have at most %d options", messaging.MaxPollOptions)
+	}
+	for i, option := range options {
+		if option = strings.TrimSpace(option); option == "" || slices.Contains(options[:i], option) {
+			return "", nil, fmt.Errorf("poll options must be distinct and not empty")
+		}
+		options[i] = option
+	}
+	return question, options, nil
+}
+
+// pollCmd creates a poll from a /poll command, published on the hashtags in
+// its question.
+func (m *AppModel) pollCmd(content string) tea.Cmd {
+	question, options, err := parsePoll(content)
+	if err != nil {
+		return func() tea.Msg { return pollPublishedMsg{Err: err} }
+	}
+	msg := &messaging.Message{
+		ID:       uuid.New().String(),
+		Author:   m.netManager.Host.ID().String(),
+		Content:  question,
+		Hashtags: internal.ExtractHashtags(question),
+		Type:     messaging.PollMsg,
+		Poll:     &messaging.Poll{Options: options},
+	}
+	msg.Mentions, _ = m.resolveMentions(question)
+	return m.publishPollCmd(msg, "Poll created")
+}
+
+// voteCmd votes for an option of a poll, given as its number or its text.
+// Voting again replaces the earlier vote.
+func (m *AppModel) voteCmd(post *messaging.Message, choice string) tea.Cmd {
+	tally, ok := m.store.Tally(pollID(post))
+	if !ok {
+		return func() tea.Msg { return pollPublishedMsg{Err: errors.New("select a poll to vote on")} }
+	}
+	option := choice
+	if n, err := strconv.Atoi(choice); err == nil && n >= 1 && n <= len(tally.Options) {
+		option = tally.Options[n-1]
+	}
+	switch {
+	case !slices.Contains(tally.Options, option):
+		return func() tea.Msg { return pollPublishedMsg{Err: fmt.Errorf("%q is not an option of the poll", choice)} }
+	case tally.Closed:
+		return func() tea.Msg { return pollPublishedMsg{Err: errors.New("the poll is closed")} }
+	}
+	author := m.netManager.Host.ID().String()
+	if tally.VoteOf(author, crypto.SigningPublicKey(m.keyPair.PrivateKey)[:]) == option {
+		return func() tea.Msg { return pollPublishedMsg{Success: "You already voted for " + strconv.Quote(option)} }
+	}
+
+	poll, _ := m.store.GetPost(pollID(post))
+	msg := &messaging.Message{
+		ID:       uuid.New().String(),
+		Author:   author,
+		Content:  option,
+		Hashtags: internal.ReplyHashtags(poll, ""),
+		Type:     messaging.VoteMsg,
+		ReplyTo:  poll.ID,
+	}
+	return m.publishPollCmd(msg, "Voted for "+strconv.Quote(option))
+}
+
+// closePollCmd closes one of our polls, so no further votes count.
+func (m *AppModel) closePollCmd(post *messaging.Message) tea.Cmd {
+	tally, ok := m.store.Tally(pollID(post))
+	switch {
+	case !ok:
+		return func() tea.Msg { return pollPublishedMsg{Err: errors.New("select a poll to close")} }
+	case post.Author != m.netManager.Host.ID().String():
+		return func() tea.Msg { return pollPublishedMsg{Err: errors.New("only the author of a poll can close it")} }
+	case tally.Closed:
+		return func() tea.Msg { return pollPublishedMsg{Success: "The poll is already closed"} }
+	}
+	msg := &messaging.Message{
+		ID:       uuid.New().String(),
+		Author:   post.Author,
+		Hashtags: internal.ReplyHashtags(post, ""),
+		Type:     messaging.PollCloseMsg,
+		ReplyTo:  post.ID,
+	}
+	return m.publishPollCmd(msg, "Poll closed")
+}
+
+// pollID returns the ID of the selected poll, or "" if nothing is selected.
+func pollID(post *messaging.Message) string {
+	if post == nil {
+		return ""
+	}
+	return post.ID
+}
+
+// publishPollCmd signs a poll message, counts it in our store right away and
+// publishes it.
+func (m *AppModel) publishPollCmd(msg *messaging.Message, success string) tea.Cmd {
+	msg.Timestamp = time.Now()
+	if err := msg.Sign(m.keyPair); err != nil {
+		return func() tea.Msg { return pollPublishedMsg{Err: err} }
+	}
+	m.store.AddPost(msg)
+	return func() tea.Msg {
+		return pollPublishedMsg{Success: success, Err: m.broadcaster.Broadcast(context.Background(), msg)}
+	}
+}
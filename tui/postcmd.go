@@ -1,18 +1,24 @@
 package tui
 
 import (
+	"socli/messaging"
+
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+// subscriptionPostMsg carries a post read from postChan. Unlike PostReceivedMsg,
+// which main.go sends directly, it tells Update to listen for the next post.
+type subscriptionPostMsg struct{ Post *messaging.Message }
+
 // listenForPostsCmd returns a tea.Cmd that listens for posts on the postChan.
-// When a post is received, it sends a PostReceivedMsg to the model's Update function.
+// When a post is received, it sends a subscriptionPostMsg to the model's Update function.
 func (m *AppModel) listenForPostsCmd() tea.Cmd {
 	return func() tea.Msg {
 		// This function will be called by BubbleTea in a goroutine
 		// It blocks until a message is received on the channel
 		post := <-m.postChan
-		// Wrap the post in a PostReceivedMsg and return it
+		// Wrap the post in a subscriptionPostMsg and return it
 		// This msg will be passed to the Update function
-		return PostReceivedMsg{Post: post}
+		return subscriptionPostMsg{Post: post}
 	}
 }
\ No newline at end of file
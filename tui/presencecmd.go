@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"socli/messaging"
+	"socli/tui/types"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// presenceTickMsg is sent every heartbeat interval so the sidebar shows
+// presence changes and expiries even when nothing else happens.
+type presenceTickMsg struct{}
+
+// presenceTickCmd returns a tea.Cmd that sends a presenceTickMsg after one
+// heartbeat interval. It returns nil when there is no presence tracker.
+func (m *AppModel) presenceTickCmd() tea.Cmd {
+	if m.presence == nil {
+		return nil
+	}
+	return tea.Tick(m.presence.Interval(), func(time.Time) tea.Msg {
+		return presenceTickMsg{}
+	})
+}
+
+// setStatusCmd returns a tea.Cmd that changes our presence status and
+// announces it to peers right away.
+func (m *AppModel) setStatusCmd(status messaging.PresenceStatus, text string) tea.Cmd {
+	if m.presence == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		err := m.presence.SetStatus(context.Background(), status, text)
+		if errors.Is(err, messaging.ErrStatusTooSoon) {
+			return types.StatusMsg{Type: types.Warning, Message: "Status set to " + string(status) + ", but " + err.Error()}
+		}
+		if err != nil {
+			log.Printf("Error sending presence heartbeat: %v", err)
+			return types.StatusMsg{Type: types.Error, Message: "Failed to announce status: " + err.Error()}
+		}
+		return nil
+	}
+}
+
+// sidebarPeers returns the peers for the sidebar: those we have stored or are
+// connected to, and those we only know from their presence heartbeats, which
+// may reach us through other peers.
+func (m *AppModel) sidebarPeers() []peer.AddrInfo {
+	seen := make(map[peer.ID]bool)
+	var peers []peer.AddrInfo
+	add := func(info peer.AddrInfo) {
+		if info.ID != "" && !seen[info.ID] {
+			seen[info.ID] = true
+			peers = append(peers, info)
+		}
+	}
+	for _, info := range m.store.GetAllPeers() {
+		add(info)
+	}
+	for _, id := range m.connectedPeers() {
+		add(m.netManager.Host.Peerstore().PeerInfo(id))
+	}
+	if m.presence != nil {
+		for _, s := range m.presence.Peers() {
+			if id, err := peer.Decode(s); err == nil {
+				add(peer.AddrInfo{ID: id})
+			}
+		}
+	}
+	return peers
+}
+
+// presenceLine describes a peer's presence for the sidebar, e.g. "away: lunch · 2m ago".
+func presenceLine(presence messaging.PeerPresence, now time.Time) string {
+	status := string(presence.Status)
+	if presence.Expired {
+		status = "offline"
+	}
+	if presence.Text != "" && !presence.Expired {
+		status += ": " + presence.Text
+	}
+	return fmt.Sprintf("%s · %s", status, formatLastSeen(now.Sub(presence.LastSeen)))
+}
+
+// formatLastSeen formats the time since a peer's last heartbeat compactly.
+func formatLastSeen(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// truncate shortens s to at most width runes, marking the cut with an ellipsis.
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 || len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return strings.TrimSpace(string(runes[:width-1])) + "…"
+}
@@ -0,0 +1,67 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"socli/crypto"
+	"socli/internal"
+	"socli/messaging"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+)
+
+// quickReaction is the reaction the '+' key sends.
+const quickReaction = "👍"
+
+// reactionSentMsg reports the result of publishing a reaction.
+type reactionSentMsg struct {
+	Reaction string
+	Err      error
+}
+
+// selectedPost returns the post selected in the open thread, or else in the feed.
+func (m *AppModel) selectedPost() *messaging.Message {
+	if m.threadView != nil {
+		return m.threadView.Selected()
+	}
+	return m.feedView.Selected()
+}
+
+// reactCmd reacts to a post with an emoji or shortcode. The reaction is
+// counted in our store right away and published on the post's hashtags.
+// Reacting twice with the same emoji is refused.
+func (m *AppModel) reactCmd(post *messaging.Message, reaction string) tea.Cmd {
+	if post == nil {
+		return nil
+	}
+	if !messaging.ValidReaction(reaction) {
+		return func() tea.Msg {
+			return reactionSentMsg{Reaction: reaction, Err: fmt.Errorf("%q is not an emoji or :shortcode:", reaction)}
+		}
+	}
+	author := m.netManager.Host.ID().String()
+	if m.store.HasReacted(post.ID, author, crypto.SigningPublicKey(m.keyPair.PrivateKey)[:], reaction) {
+		return func() tea.Msg {
+			return reactionSentMsg{Reaction: reaction, Err: fmt.Errorf("you already reacted with %s", messaging.NormalizeReaction(reaction))}
+		}
+	}
+
+	msg := &messaging.Message{
+		ID:        uuid.New().String(),
+		Author:    author,
+		Content:   reaction,
+		Hashtags:  internal.ReplyHashtags(post, ""),
+		Timestamp: time.Now(),
+		Type:      messaging.ReactionMsg,
+		ReplyTo:   post.ID,
+	}
+	if err := msg.Sign(m.keyPair); err != nil {
+		return func() tea.Msg { return reactionSentMsg{Reaction: reaction, Err: err} }
+	}
+	m.store.AddPost(msg)
+	return func() tea.Msg {
+		return reactionSentMsg{Reaction: reaction, Err: m.broadcaster.Broadcast(context.Background(), msg)}
+	}
+}
@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"context"
+	"socli/messaging"
+	"socli/tui/views"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// maxParentFetchDepth is how many missing ancestors of a reply are fetched
+	// from peers, one after the other.
+	maxParentFetchDepth = 10
+	// parentFetchTimeout bounds asking the peers for one missing post.
+	parentFetchTimeout = 10 * time.Second
+)
+
+// parentsFetchedMsg carries a post fetched from peers because a reply refers
+// to it, and how many ancestors deep it was.
+type parentsFetchedMsg struct {
+	Posts []*messaging.Message
+	Depth int
+}
+
+// fetchParentCmd returns a tea.Cmd that asks the connected peers for a post
+// that a reply refers to but we don't have. Every post is only asked for
+// once, and nil is returned when there is nothing to fetch.
+func (m *AppModel) fetchParentCmd(id string, depth int) tea.Cmd {
+	if id == "" || m.historySync == nil || depth >= maxParentFetchDepth || m.fetchedParents[id] {
+		return nil
+	}
+	if _, ok := m.store.GetPost(id); ok {
+		return nil
+	}
+	peers := m.connectedPeers()
+	if len(peers) == 0 {
+		return nil
+	}
+	m.fetchedParents[id] = true
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), parentFetchTimeout)
+		defer cancel()
+		return parentsFetchedMsg{Posts: m.historySync.Fetch(ctx, peers, []string{id}), Depth: depth + 1}
+	}
+}
+
+// missingParentCmd fetches the parent of a reply if we don't have it yet.
+func (m *AppModel) missingParentCmd(post *messaging.Message) tea.Cmd {
+	if post.Type != messaging.ReplyMsg {
+		return nil
+	}
+	return m.fetchParentCmd(post.ReplyTo, 0)
+}
+
+// openThread shows the conversation around a post and starts fetching the
+// parents that are missing from it.
+func (m *AppModel) openThread(post *messaging.Message) tea.Cmd {
+	m.threadView = views.NewThreadView(m.store, m.renderer, post.ID)
+	m.currentView = "thread"
+	m.statusMsg = nil
+	return m.fetchParentCmd(m.threadView.Missing(), 0)
+}
+
+// startReply opens the compose view to reply to a post.
+func (m *AppModel) startReply(post *messaging.Message) {
+	m.sharing = nil
+	m.replyTo = post
+	m.currentView = "compose"
+	m.statusMsg = nil
+}
+
+// afterCompose ends replying, sharing or editing and returns the view to go
+// back to from the compose view: the open thread, if any, or the feed.
+func (m *AppModel) afterCompose() string {
+	m.replyTo, m.sharing, m.editing = nil, nil, nil
+	if m.rescheduling != "" {
+		m.rescheduling = ""
+		return "schedule"
+	}
+	if m.threadView != nil {
+		return "thread"
+	}
+	return "feed"
+}
+
+// replyHeader describes the post being replied to above the compose view.
+func (m *AppModel) replyHeader() string {
+	content := []rune(m.replyTo.Content)
+	if len(content) > 60 {
+		content = append(content[:60], '…')
+	}
+	return headerStyle.Render("Replying to "+shortAuthor(m.replyTo.Author)) + "\n" + listItemStyle.Render(string(content)) + "\n\n"
+}
+
+// shortAuthor shortens an author's peer ID like shortPeerID.
+func shortAuthor(author string) string {
+	id, err := peer.Decode(author)
+	if err != nil {
+		return author
+	}
+	return shortPeerID(id)
+}
@@ -0,0 +1,184 @@
+package tui
+
+import (
+	"errors"
+	"log"
+	"socli/internal"
+	"socli/messaging"
+	"socli/storage"
+	"socli/tui/types"
+	"socli/tui/views"
+	"strings"
+	"time"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+)
+
+const (
+	// scheduleUsage explains the /schedule command.
+	scheduleUsage = "Usage: /schedule <09:00|10m> <text>"
+	// scheduleTickInterval is how often the queue is checked for posts that are due.
+	scheduleTickInterval = time.Second
+)
+
+// scheduleTickMsg is sent every scheduleTickInterval to publish the
+// scheduled posts that are due.
+type scheduleTickMsg struct{}
+
+// scheduledPublishedMsg reports the result of publishing a scheduled post.
+type scheduledPublishedMsg struct {
+	Post       storage.ScheduledPost
+	Deliveries []messaging.Delivery // Where it went; nil without an outbox
+	Err        error
+}
+
+// scheduleTickCmd returns a tea.Cmd that sends a scheduleTickMsg after one
+// interval. It returns nil when there is no schedule queue.
+func (m *AppModel) scheduleTickCmd() tea.Cmd {
+	if m.schedule == nil {
+		return nil
+	}
+	return tea.Tick(scheduleTickInterval, func(time.Time) tea.Msg {
+		return scheduleTickMsg{}
+	})
+}
+
+// cutField splits off the first whitespace-separated field of s.
+func cutField(s string) (field, rest string) {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	i := strings.IndexFunc(s, unicode.IsSpace)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i:])
+}
+
+// parseSchedule reads the time and text of a /schedule command. The text
+// keeps its line breaks.
+func parseSchedule(content string, now time.Time) (time.Time, string, error) {
+	_, rest := cutField(content)
+	when, text := cutField(rest)
+	if when == "" || text == "" {
+		return time.Time{}, "", errors.New(scheduleUsage)
+	}
+	at, err := internal.ParseScheduleTime(when, now)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return at, text, nil
+}
+
+// schedulePost adds the post of a /schedule command to the queue, or
+// replaces the scheduled post with the given ID if it isn't empty.
+func (m *AppModel) schedulePost(content, id string) {
+	at, text, err := parseSchedule(content, time.Now())
+	if err != nil {
+		m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: err.Error()}
+		return
+	}
+	if id != "" {
+		err = m.schedule.Update(id, text, at)
+	} else {
+		_, err = m.schedule.Add(text, at)
+	}
+	if err != nil {
+		m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Failed to schedule post: " + err.Error()}
+		return
+	}
+	m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Scheduled for " + at.Format("Mon 15:04") + "; /schedule lists pending posts"}
+}
+
+// openSchedule shows the posts waiting in the schedule queue.
+func (m *AppModel) openSchedule() {
+	if m.scheduleView == nil {
+		m.scheduleView = views.NewScheduleView(m.schedule)
+	}
+	m.currentView = "schedule"
+	m.statusMsg = nil
+}
+
+// scheduleWhen formats the time of a scheduled post the way /schedule reads
+// it: as a time of day if it's due within a day, or else as a delay.
+func scheduleWhen(at, now time.Time) string {
+	if at.Sub(now) < 24*time.Hour {
+		return at.Format("15:04")
+	}
+	return at.Sub(now).Round(time.Minute).String()
+}
+
+// startReschedule opens the compose view on the selected scheduled post,
+// with its time and text as a /schedule command to change.
+func (m *AppModel) startReschedule() {
+	post, ok := m.scheduleView.Selected()
+	if !ok {
+		return
+	}
+	m.rescheduling = post.ID
+	m.composeView = views.NewComposeView(m.cfg)
+	m.composeView.SetValue("/schedule " + scheduleWhen(post.At, time.Now()) + " " + post.Content)
+	m.currentView = "compose"
+	m.statusMsg = nil
+}
+
+// rescheduleHeader explains the compose view while a scheduled post is edited.
+func (m *AppModel) rescheduleHeader() string {
+	return headerStyle.Render("Editing a scheduled post") + "\n" +
+		helpStyle.Render("Change its time or text and press enter; esc keeps it as it was") + "\n\n"
+}
+
+// cancelScheduled removes the selected post from the schedule queue.
+func (m *AppModel) cancelScheduled() {
+	post, ok := m.scheduleView.Selected()
+	if !ok {
+		return
+	}
+	if err := m.schedule.Cancel(post.ID); err != nil {
+		m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Failed to cancel scheduled post: " + err.Error()}
+		return
+	}
+	m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Cancelled the post scheduled for " + post.At.Format("Mon 15:04")}
+}
+
+// publishDueCmd takes the posts that are due off the schedule queue and
+// publishes them. It returns nil when none are due, or when the queue can't
+// be saved; the posts then stay queued until it can.
+func (m *AppModel) publishDueCmd() tea.Cmd {
+	if m.schedule == nil {
+		return nil
+	}
+	due, err := m.schedule.Due(time.Now())
+	if err != nil {
+		log.Printf("Schedule: Failed to save the queue: %v\n", err)
+		m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Scheduled posts are held back, the queue can't be saved: " + err.Error()}
+		return nil
+	}
+	cmds := make([]tea.Cmd, 0, len(due))
+	for _, post := range due {
+		cmds = append(cmds, m.publishScheduledCmd(post))
+	}
+	return tea.Batch(cmds...)
+}
+
+// publishScheduledCmd publishes a scheduled post like one written just now:
+// it is signed with the current time, stored, and sent through the outbox.
+func (m *AppModel) publishScheduledCmd(post storage.ScheduledPost) tea.Cmd {
+	msg := &messaging.Message{
+		ID:        uuid.New().String(),
+		Author:    m.netManager.Host.ID().String(),
+		Content:   post.Content,
+		Hashtags:  internal.ExtractHashtags(post.Content),
+		Timestamp: time.Now(),
+		Type:      messaging.PostMsg,
+	}
+	msg.Mentions, _ = m.resolveMentions(post.Content)
+	if err := msg.Sign(m.keyPair); err != nil {
+		return func() tea.Msg { return scheduledPublishedMsg{Post: post, Err: err} }
+	}
+	m.store.AddPost(msg)
+	return func() tea.Msg {
+		deliveries, err := m.publishPost(msg)
+		return scheduledPublishedMsg{Post: post, Deliveries: deliveries, Err: err}
+	}
+}
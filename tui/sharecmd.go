@@ -0,0 +1,45 @@
+package tui
+
+import (
+	"socli/messaging"
+	"socli/tui/types"
+)
+
+// startShare opens the compose view to share a post into the hashtags the
+// user types, with an optional comment. Sharing a share shares its original.
+// Circle posts stay in their circle and expiring posts would outlive their
+// expiry in the share, so neither can be shared.
+func (m *AppModel) startShare(post *messaging.Message) {
+	if post.Shared != nil {
+		post = post.Shared
+	}
+	if post.Type == messaging.PollMsg {
+		m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Polls can't be shared"}
+		return
+	}
+	if post.ExpiresAt != nil {
+		m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Expiring posts can't be shared"}
+		return
+	}
+	for _, hashtag := range post.Hashtags {
+		if m.circles.IsCircle(hashtag) {
+			m.statusMsg = &types.StatusMsg{Type: types.Error, Message: "Posts in circles can't be shared outside #" + hashtag}
+			return
+		}
+	}
+	m.replyTo = nil
+	m.sharing = post
+	m.currentView = "compose"
+	m.statusMsg = nil
+}
+
+// shareHeader describes the post being shared above the compose view.
+func (m *AppModel) shareHeader() string {
+	content := []rune(m.sharing.Content)
+	if len(content) > 60 {
+		content = append(content[:60], '…')
+	}
+	return headerStyle.Render("Sharing a post by "+shortAuthor(m.sharing.Author)) + "\n" +
+		listItemStyle.Render(string(content)) + "\n" +
+		helpStyle.Render("Add the #hashtags to share it to and an optional comment") + "\n\n"
+}
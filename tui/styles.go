@@ -1,6 +1,8 @@
 package tui
 
 import (
+	"socli/messaging"
+
 	"github.com/charmbracelet/lipgloss"
 )
 
@@ -40,8 +42,38 @@ var (
 				PaddingLeft(1).
 				Foreground(lipgloss.Color("205")) // Pink
 
+	// Marker for peers running a newer or incompatible version
+	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("220")) // Yellow
+
 	// Help text styles
 	helpStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("240")). // Grey
 			Align(lipgloss.Center)
-)
\ No newline at end of file
+)
+
+// scoreStyle colors a GossipSub peer score: red when penalized, green when positive.
+func scoreStyle(score float64) lipgloss.Style {
+	switch {
+	case score < 0:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("196")) // Red
+	case score > 0:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("46")) // Green
+	default:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("240")) // Grey
+	}
+}
+
+// presenceStyle colors a presence status dot: green when online, yellow when
+// away, red for do-not-disturb and grey once heartbeats have stopped.
+func presenceStyle(status messaging.PresenceStatus, expired bool) lipgloss.Style {
+	switch {
+	case expired:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("240")) // Grey
+	case status == messaging.StatusOnline:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("46")) // Green
+	case status == messaging.StatusAway:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("220")) // Yellow
+	default:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("196")) // Red
+	}
+}
@@ -0,0 +1,47 @@
+package tui
+
+import (
+	"context"
+	"socli/messaging"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// historySyncedMsg carries the verified posts fetched from peers by history sync.
+type historySyncedMsg struct{ Posts []*messaging.Message }
+
+// syncHistoryCmd returns a tea.Cmd that asks the given peers for recent posts on
+// the hashtags and sends the result to Update as a historySyncedMsg.
+// It returns nil when history sync is not available or there is nobody to ask.
+func (m *AppModel) syncHistoryCmd(peers []peer.ID, hashtags []string) tea.Cmd {
+	if m.historySync == nil || len(peers) == 0 || len(hashtags) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		// This runs in a goroutine, so it may block on the network
+		return historySyncedMsg{Posts: m.historySync.Sync(context.Background(), peers, hashtags)}
+	}
+}
+
+// connectedPeers returns the peers we currently have connections to.
+func (m *AppModel) connectedPeers() []peer.ID {
+	if m.netManager == nil {
+		return nil
+	}
+	return m.netManager.Host.Network().Peers()
+}
+
+// subscribedHashtags returns the default "general" hashtag, which main.go
+// subscribes to, plus every hashtag subscribed to from the TUI.
+func (m *AppModel) subscribedHashtags() []string {
+	hashtags := []string{"general"}
+	for topicName := range m.subscriptions {
+		hashtag := strings.TrimPrefix(topicName, messaging.HashtagTopicPrefix)
+		if hashtag != "general" {
+			hashtags = append(hashtags, hashtag)
+		}
+	}
+	return hashtags
+}
@@ -0,0 +1,92 @@
+package tui
+
+import (
+	"errors"
+	"slices"
+	"socli/tui/types"
+	"strings"
+	"time"
+)
+
+// ttlUsage explains the /ttl command.
+const ttlUsage = "Usage: /ttl <10m|1h|off>"
+
+// ttlLabel formats a post lifetime the way /ttl reads it, e.g. "10m" or "1h30m".
+func ttlLabel(ttl time.Duration) string {
+	if ttl <= 0 {
+		return "off"
+	}
+	s := ttl.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// parseTTL reads the argument of /ttl: a positive duration, or "off".
+func parseTTL(arg string) (time.Duration, error) {
+	if strings.EqualFold(arg, "off") {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(arg)
+	if err != nil || ttl <= 0 {
+		return 0, errors.New(ttlUsage)
+	}
+	return ttl, nil
+}
+
+// setTTL handles /ttl: with an argument, it sets how long our next posts
+// live; without one, it shows the current setting.
+func (m *AppModel) setTTL(args []string) {
+	if len(args) == 0 {
+		m.statusMsg = &types.StatusMsg{Type: types.Info, Message: "Post expiry is " + ttlLabel(m.ttl) + "; " + ttlUsage}
+		return
+	}
+	ttl, err := parseTTL(args[0])
+	if err != nil {
+		m.statusMsg = &types.StatusMsg{Type: types.Warning, Message: err.Error()}
+		return
+	}
+	m.ttl = ttl
+	if ttl == 0 {
+		m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Posts no longer expire"}
+		return
+	}
+	m.statusMsg = &types.StatusMsg{Type: types.Success, Message: "Posts now expire " + ttlLabel(ttl) + " after they are sent"}
+}
+
+// cycleTTL switches the post lifetime to the next of the configured
+// presets, and back to off after the last one.
+func (m *AppModel) cycleTTL() {
+	presets := m.cfg.Expiry.Presets
+	i := slices.Index(presets, m.ttl)
+	switch {
+	case i < 0 && len(presets) > 0 && m.ttl == 0:
+		m.ttl = presets[0]
+	case i >= 0 && i < len(presets)-1:
+		m.ttl = presets[i+1]
+	default:
+		m.ttl = 0
+	}
+}
+
+// expiresAt returns when a post sent at the given time expires, or nil if
+// posts don't expire.
+func (m *AppModel) expiresAt(sent time.Time) *time.Time {
+	if m.ttl <= 0 {
+		return nil
+	}
+	at := sent.Add(m.ttl)
+	return &at
+}
+
+// ttlHeader reminds the user above the compose view that the post expires.
+func (m *AppModel) ttlHeader() string {
+	if m.ttl <= 0 {
+		return ""
+	}
+	return helpStyle.Render("⏳ Expires "+ttlLabel(m.ttl)+" after sending · ctrl+t to change") + "\n\n"
+}
@@ -45,3 +45,8 @@ func (v *ComposeView) View() string {
 func (v *ComposeView) Value() string {
 	return v.input.Value()
 }
+
+// SetValue replaces the value of the input, e.g. with a post being edited.
+func (v *ComposeView) SetValue(value string) {
+	v.input.SetValue(value)
+}
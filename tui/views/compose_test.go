@@ -13,6 +13,7 @@ func TestComposeView(t *testing.T) {
 			Theme         string `yaml:"theme"`
 			RefreshRate   int    `yaml:"refresh_rate_ms"`
 			MaxPostLength int    `yaml:"max_post_length"`
+			MentionBell   bool   `yaml:"mention_bell"`
 		}{
 			MaxPostLength: 10, // Set a small limit for easy testing
 		},
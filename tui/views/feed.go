@@ -1,12 +1,15 @@
 package views
 
 import (
+	"bytes"
 	"fmt"
+	"regexp"
 	"socli/content"
 	"socli/messaging"
 	"socli/storage"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -44,7 +47,7 @@ func (v *FeedView) View(width, height int) string {
 	// Simplified scrolling logic for now.
 	// A more robust implementation would use a viewport or calculate
 	// rendered content height.
-	
+
 	// For this prototype, let's display a fixed number of recent posts
 	// or all posts if fewer than the limit.
 	const maxPostsToDisplay = 50
@@ -63,6 +66,11 @@ func (v *FeedView) View(width, height int) string {
 
 	for i := startIndex; i >= endIndex && i >= 0 && displayedCount < maxPostsToDisplay; i-- {
 		post := posts[i]
+		// The top post is the one 'r' replies to and 't' opens
+		if i == startIndex {
+			b.WriteString(selectedStyle.Render("▸ selected · 'r' reply · '+' react · 's' share · 't' thread · 'e' edit"))
+			b.WriteString("\n")
+		}
 		postLines := v.renderPost(post)
 		b.WriteString(postLines)
 		b.WriteString("\n---\n") // Separator between posts
@@ -77,6 +85,20 @@ func (v *FeedView) View(width, height int) string {
 	return b.String()
 }
 
+// Selected returns the post at the top of the feed, which replies and the
+// thread view act on, or nil if there are no posts.
+func (v *FeedView) Selected() *messaging.Message {
+	posts := v.store.GetAllPosts()
+	if len(posts) == 0 {
+		return nil
+	}
+	index := len(posts) - 1 - v.offset
+	if index < 0 {
+		index = 0
+	}
+	return posts[index]
+}
+
 // ScrollUp moves the view up by one post (if possible).
 func (v *FeedView) ScrollUp() {
 	posts := v.store.GetAllPosts()
@@ -100,21 +122,59 @@ func (v *FeedView) renderPost(post *messaging.Message) string {
 
 	// Author and Timestamp
 	authorStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63")) // Purple
-	timeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))           // Grey
+	timeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))             // Grey
 	b.WriteString(authorStyle.Render(fmt.Sprintf("From: %s", post.Author)))
 	b.WriteString(" ")
 	b.WriteString(timeStyle.Render(post.Timestamp.Format(time.Stamp)))
-	b.WriteString("\n")
 
-	// Content with Markdown rendering
-	renderedContent, err := v.renderer.Render(post.Content)
-	if err != nil {
-		// Fallback to plain text if rendering fails
-		renderedContent = post.Content
+	// The latest edit replaces the content; earlier revisions stay viewable
+	text := post.Content
+	if revisions := v.store.Revisions(post.ID); len(revisions) > 1 {
+		latest := revisions[len(revisions)-1]
+		text = latest.Content
+		b.WriteString(editedStyle.Render(" ✏️ edited " + latest.Timestamp.Format(time.Stamp) + " · 'v' revisions"))
+	}
+	if post.ExpiresAt != nil {
+		b.WriteString(expiryStyle.Render(" ⏳ " + expiresIn(*post.ExpiresAt, time.Now())))
 	}
-	b.WriteString(renderedContent)
 	b.WriteString("\n")
 
+	// The post this one replies to, if any
+	if post.ReplyTo != "" {
+		b.WriteString(timeStyle.Render("↳ reply to " + v.parentLabel(post.ReplyTo)))
+		b.WriteString("\n")
+	}
+
+	// Content with Markdown rendering. A share's content is the sharer's
+	// comment, which may be nothing but the hashtags it was shared to.
+	if post.Shared == nil || !hashtagsOnly.MatchString(text) {
+		renderedContent, err := v.renderer.Render(text)
+		if err != nil {
+			// Fallback to plain text if rendering fails
+			renderedContent = text
+		}
+		b.WriteString(highlightMentions(renderedContent))
+		b.WriteString("\n")
+	}
+
+	// The poll's options with their live tally
+	if post.Type == messaging.PollMsg {
+		b.WriteString(v.renderPoll(post))
+	}
+
+	// The shared post, as a card crediting its author
+	if post.Shared != nil {
+		b.WriteString(timeStyle.Render("🔁 shared a post by " + post.Shared.Author))
+		b.WriteString("\n")
+		b.WriteString(sharedCardStyle.Render(strings.TrimRight(v.renderPost(post.Shared), "\n")))
+		b.WriteString("\n")
+	}
+
+	// Attachment, with a preview once it has been fetched
+	if post.Attachment != nil {
+		b.WriteString(v.renderAttachment(post.Attachment))
+	}
+
 	// Hashtags
 	if len(post.Hashtags) > 0 {
 		hashtagStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("33")) // Blue
@@ -122,5 +182,182 @@ func (v *FeedView) renderPost(post *messaging.Message) string {
 		b.WriteString("\n")
 	}
 
+	// Reactions and replies
+	if reactions := v.store.Reactions(post.ID); len(reactions) > 0 {
+		labels := make([]string, 0, len(reactions))
+		for _, reaction := range reactions {
+			labels = append(labels, fmt.Sprintf("%s %d", reaction.Emoji, reaction.Count))
+		}
+		b.WriteString(strings.Join(labels, "  "))
+		b.WriteString("\n")
+	}
+	if count := v.store.ReplyCount(post.ID); count > 0 {
+		b.WriteString(timeStyle.Render(replyCountLabel(count)))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// pollBarWidth is the width of the bar for an option with every vote.
+const pollBarWidth = 20
+
+// renderPoll formats a poll's options as a bar chart of the votes so far.
+func (v *FeedView) renderPoll(post *messaging.Message) string {
+	tally, ok := v.store.Tally(post.ID)
+	if !ok {
+		return ""
+	}
+	var b strings.Builder
+	labelWidth := 0
+	for _, option := range tally.Options {
+		labelWidth = max(labelWidth, lipgloss.Width(option))
+	}
+	voters := tally.Voters()
+	for i, option := range tally.Options {
+		count, filled, percent := tally.Counts[i], 0, 0
+		if voters > 0 {
+			filled = count * pollBarWidth / voters
+			percent = count * 100 / voters
+		}
+		bar := pollBarStyle.Render(strings.Repeat("█", filled)) + strings.Repeat("░", pollBarWidth-filled)
+		padding := strings.Repeat(" ", labelWidth-lipgloss.Width(option))
+		b.WriteString(fmt.Sprintf("%d. %s%s %s %d (%d%%)\n", i+1, option, padding, bar, count, percent))
+	}
+
+	timeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240")) // Grey
+	votes := "1 vote"
+	if voters != 1 {
+		votes = fmt.Sprintf("%d votes", voters)
+	}
+	if tally.Closed {
+		b.WriteString(timeStyle.Render("🔒 Poll closed · " + votes))
+	} else {
+		b.WriteString(timeStyle.Render(fmt.Sprintf("🗳 %s · select and press 1-%d to vote", votes, len(tally.Options))))
+	}
+	b.WriteString("\n")
 	return b.String()
-}
\ No newline at end of file
+}
+
+// pollBarStyle colors the filled part of a poll bar.
+var pollBarStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205")) // Pink
+
+// hashtagsOnly matches content made up of nothing but hashtags.
+var hashtagsOnly = regexp.MustCompile(`^\s*(#\w+\s*)*$`)
+
+// sharedCardStyle frames a shared post inside the share.
+var sharedCardStyle = lipgloss.NewStyle().
+	Border(lipgloss.RoundedBorder()).
+	BorderForeground(lipgloss.Color("240")). // Grey
+	PaddingLeft(1).
+	PaddingRight(1)
+
+// renderedMention matches an @mention in rendered Markdown, where it may
+// directly follow the escape code that styles it.
+var renderedMention = regexp.MustCompile(`(?:^|[^\w@]|\x1b\[[0-9;]*m)@\w+`)
+
+// mentionStyle highlights @mentions.
+var mentionStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("45")) // Cyan
+
+// highlightMentions highlights the @mentions in rendered content.
+func highlightMentions(rendered string) string {
+	return renderedMention.ReplaceAllStringFunc(rendered, func(match string) string {
+		at := strings.LastIndexByte(match, '@')
+		return match[:at] + mentionStyle.Render(match[at:])
+	})
+}
+
+// selectedStyle marks the selected post.
+var selectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205")) // Pink
+
+// expiryStyle marks the countdown of a post that expires.
+var expiryStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("203")) // Red
+
+// expiresIn formats the time left until a post expires, e.g. "9m12s", or
+// "5h20m" once it is an hour or more.
+func expiresIn(at, now time.Time) string {
+	left := at.Sub(now)
+	switch {
+	case left <= 0:
+		return "expiring"
+	case left < time.Hour:
+		return left.Truncate(time.Second).String()
+	}
+	return strings.TrimSuffix(left.Truncate(time.Minute).String(), "0s")
+}
+
+// editedStyle marks a post that has been edited.
+var editedStyle = lipgloss.NewStyle().Italic(true).Foreground(lipgloss.Color("214")) // Orange
+
+// parentLabel describes the post a reply is to: its author if we have it,
+// otherwise the start of its ID.
+func (v *FeedView) parentLabel(id string) string {
+	if parent, ok := v.store.GetPost(id); ok {
+		return parent.Author
+	}
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return "post " + id + " (not loaded)"
+}
+
+// replyCountLabel formats a reply count, e.g. "💬 3 replies".
+func replyCountLabel(count int) string {
+	if count == 1 {
+		return "💬 1 reply"
+	}
+	return fmt.Sprintf("💬 %d replies", count)
+}
+
+// attachmentPreviewLines is how many lines of a fetched text attachment the feed shows.
+const attachmentPreviewLines = 10
+
+// renderAttachment formats an attachment line, followed by a preview of the
+// file's first lines if it was fetched and is text.
+func (v *FeedView) renderAttachment(attachment *messaging.Attachment) string {
+	var b strings.Builder
+
+	attachmentStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214")) // Orange
+	shortHash := attachment.Hash
+	if len(shortHash) > 8 {
+		shortHash = shortHash[:8]
+	}
+	data, fetched := attachment.Assemble(v.store)
+	if !fetched {
+		b.WriteString(attachmentStyle.Render(fmt.Sprintf("📎 %s (%s) · /fetch %s", attachment.Name, FormatSize(attachment.Size), shortHash)))
+		b.WriteString("\n")
+		return b.String()
+	}
+
+	b.WriteString(attachmentStyle.Render(fmt.Sprintf("📎 %s (%s) · %s", attachment.Name, FormatSize(attachment.Size), shortHash)))
+	b.WriteString("\n")
+	previewStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("250")). // Light grey
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(lipgloss.Color("240")). // Grey
+		PaddingLeft(1)
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		b.WriteString(previewStyle.Render("(binary file)"))
+		b.WriteString("\n")
+		return b.String()
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > attachmentPreviewLines {
+		lines = append(lines[:attachmentPreviewLines], fmt.Sprintf("… %d more lines", len(lines)-attachmentPreviewLines))
+	}
+	b.WriteString(previewStyle.Render(strings.Join(lines, "\n")))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// FormatSize formats a size in bytes for display, e.g. "12.3 KB".
+func FormatSize(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d B", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	}
+}
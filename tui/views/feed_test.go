@@ -1,9 +1,12 @@
 package views
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"socli/content"
 	"socli/messaging"
 	"socli/storage"
+	"strings"
 	"testing"
 	"time"
 
@@ -107,4 +110,228 @@ func TestFeedViewScrolling(t *testing.T) {
 	if feedView.offset != 0 {
 		t.Errorf("ScrollDown() from offset 0 changed offset to %d, want 0", feedView.offset)
 	}
-}
\ No newline at end of file
+}
+
+// TestFeedViewAttachment tests that attachments show a fetch hint until their
+// chunks are in the store, and a preview afterwards.
+func TestFeedViewAttachment(t *testing.T) {
+	store := storage.NewMemoryStore()
+	renderer, err := content.NewMarkdownRenderer()
+	if err != nil {
+		t.Fatalf("Failed to create MarkdownRenderer: %v", err)
+	}
+	feedView := NewFeedView(store, renderer)
+
+	data := []byte("first line\nsecond line\n")
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	store.AddPost(&messaging.Message{
+		ID:         "attachment-post",
+		Author:     peer.ID("test-author").String(),
+		Content:    "Crash log #ops",
+		Hashtags:   []string{"ops"},
+		Timestamp:  time.Now(),
+		Type:       messaging.PostMsg,
+		Attachment: &messaging.Attachment{Name: "crash.log", Size: int64(len(data)), Hash: hash, Chunks: []string{hash}},
+	})
+
+	view := feedView.View(80, 24)
+	if !strings.Contains(view, "/fetch "+hash[:8]) || strings.Contains(view, "second line") {
+		t.Errorf("View() before fetching = %q, want a fetch hint and no preview", view)
+	}
+
+	store.PutChunk(hash, data)
+	view = feedView.View(80, 24)
+	if !strings.Contains(view, "crash.log") || !strings.Contains(view, "second line") || strings.Contains(view, "/fetch") {
+		t.Errorf("View() after fetching = %q, want a preview of crash.log", view)
+	}
+}
+
+// TestFormatSize tests formatting of attachment sizes.
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{512, "512 B"},
+		{2048, "2.0 KB"},
+		{1536 * 1024, "1.5 MB"},
+	}
+	for _, tt := range tests {
+		if got := FormatSize(tt.size); got != tt.want {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
+
+// TestFeedViewReplies tests that the feed shows reply counts and selects the top post.
+func TestFeedViewReplies(t *testing.T) {
+	store := storage.NewMemoryStore()
+	renderer, err := content.NewMarkdownRenderer()
+	if err != nil {
+		t.Fatalf("Failed to create MarkdownRenderer: %v", err)
+	}
+	feedView := NewFeedView(store, renderer)
+	if feedView.Selected() != nil {
+		t.Error("Selected() with no posts should be nil")
+	}
+
+	now := time.Now()
+	store.AddPost(&messaging.Message{ID: "root", Author: "alice", Content: "Root post", Type: messaging.PostMsg, Timestamp: now.Add(-time.Hour)})
+	for _, id := range []string{"a", "b"} {
+		store.AddPost(&messaging.Message{ID: id, Author: "bob", Content: "Reply " + id, Type: messaging.ReplyMsg, ReplyTo: "root", Timestamp: now})
+	}
+
+	view := feedView.View(80, 24)
+	if !strings.Contains(view, "💬 2 replies") || !strings.Contains(view, "↳ reply to alice") {
+		t.Errorf("View() = %q, want a reply count and reply markers", view)
+	}
+	feedView.ScrollUp()
+	feedView.ScrollUp()
+	if selected := feedView.Selected(); selected == nil || selected.ID != "root" {
+		t.Errorf("Selected() = %v after scrolling to the oldest post, want root", selected)
+	}
+}
+
+// TestFeedViewShare tests that a share shows the original post as a card
+// crediting its author.
+func TestFeedViewShare(t *testing.T) {
+	store := storage.NewMemoryStore()
+	renderer, err := content.NewMarkdownRenderer()
+	if err != nil {
+		t.Fatalf("Failed to create MarkdownRenderer: %v", err)
+	}
+	feedView := NewFeedView(store, renderer)
+
+	original := &messaging.Message{ID: "original", Author: "alice", Content: "Schema change", Hashtags: []string{"backend"}, Type: messaging.PostMsg, Timestamp: time.Now().Add(-time.Hour)}
+	store.AddPost(&messaging.Message{ID: "share", Author: "bob", Content: "#frontend", Hashtags: []string{"frontend"}, Type: messaging.ShareMsg, Shared: original, Timestamp: time.Now()})
+
+	view := feedView.View(80, 24)
+	for _, want := range []string{"From: bob", "🔁 shared a post by alice", "From: alice", "Tags: #backend", "Tags: #frontend"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("View() = %q, want it to contain %q", view, want)
+		}
+	}
+}
+
+// TestFeedViewReactions tests that reaction counts are shown under a post
+// and reactions themselves are not listed.
+func TestFeedViewReactions(t *testing.T) {
+	store := storage.NewMemoryStore()
+	renderer, err := content.NewMarkdownRenderer()
+	if err != nil {
+		t.Fatalf("Failed to create MarkdownRenderer: %v", err)
+	}
+	feedView := NewFeedView(store, renderer)
+
+	store.AddPost(&messaging.Message{ID: "post", Author: "alice", Content: "Shipped", Type: messaging.PostMsg, Timestamp: time.Now()})
+	for i, author := range []string{"bob", "carol", "bob"} {
+		store.AddPost(&messaging.Message{ID: string(rune('a' + i)), Author: author, Content: "🎉", Type: messaging.ReactionMsg, ReplyTo: "post", Timestamp: time.Now()})
+	}
+
+	view := feedView.View(80, 24)
+	if !strings.Contains(view, "🎉 2") || strings.Contains(view, "From: bob") {
+		t.Errorf("View() = %q, want a reaction count and no reaction posts", view)
+	}
+}
+
+// TestFeedViewEdited tests that an edited post shows its latest content and
+// an edited marker, and that edits by others are ignored.
+func TestFeedViewEdited(t *testing.T) {
+	store := storage.NewMemoryStore()
+	renderer, err := content.NewMarkdownRenderer()
+	if err != nil {
+		t.Fatalf("Failed to create MarkdownRenderer: %v", err)
+	}
+	feedView := NewFeedView(store, renderer)
+
+	now := time.Now()
+	store.AddPost(&messaging.Message{ID: "post", Author: "alice", PublicKey: []byte("alice"), Content: "Teh release", Type: messaging.PostMsg, Timestamp: now.Add(-time.Hour)})
+	store.AddPost(&messaging.Message{ID: "forged", Author: "alice", PublicKey: []byte("mallory"), Content: "Forged", Type: messaging.EditMsg, ReplyTo: "post", Timestamp: now})
+	if view := feedView.View(80, 24); strings.Contains(view, "edited") || strings.Contains(view, "Forged") {
+		t.Errorf("View() = %q, want a forged edit ignored", view)
+	}
+
+	store.AddPost(&messaging.Message{ID: "edit", Author: "alice", PublicKey: []byte("alice"), Content: "Fixed", Type: messaging.EditMsg, ReplyTo: "post", Timestamp: now})
+	view := feedView.View(80, 24)
+	if !strings.Contains(view, "edited") || !strings.Contains(view, "Fixed") || strings.Contains(view, "Teh") {
+		t.Errorf("View() = %q, want the edited content and marker", view)
+	}
+}
+
+// TestFeedViewExpiring tests that expiring posts show a countdown and
+// disappear once they expire.
+func TestFeedViewExpiring(t *testing.T) {
+	store := storage.NewMemoryStore()
+	renderer, err := content.NewMarkdownRenderer()
+	if err != nil {
+		t.Fatalf("Failed to create MarkdownRenderer: %v", err)
+	}
+	feedView := NewFeedView(store, renderer)
+
+	now := time.Now()
+	soon, expired := now.Add(10*time.Minute), now.Add(-time.Second)
+	store.AddPost(&messaging.Message{ID: "soon", Author: "alice", Content: "Fleeting", Type: messaging.PostMsg, Timestamp: now, ExpiresAt: &soon})
+	store.AddPost(&messaging.Message{ID: "gone", Author: "alice", Content: "Vanished", Type: messaging.PostMsg, Timestamp: now.Add(-time.Minute), ExpiresAt: &expired})
+	view := feedView.View(80, 24)
+	if !strings.Contains(view, "Fleeting") || !strings.Contains(view, "⏳ 9m5") {
+		t.Errorf("View() = %q, want the post with a countdown", view)
+	}
+	if strings.Contains(view, "Vanished") {
+		t.Errorf("View() = %q, want the expired post hidden", view)
+	}
+}
+
+// TestExpiresIn tests the countdown shown on expiring posts.
+func TestExpiresIn(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		left time.Duration
+		want string
+	}{
+		{9*time.Minute + 12*time.Second + 500*time.Millisecond, "9m12s"},
+		{5*time.Hour + 20*time.Minute + 30*time.Second, "5h20m"},
+		{0, "expiring"},
+	}
+	for _, tt := range tests {
+		if got := expiresIn(now.Add(tt.left), now); got != tt.want {
+			t.Errorf("expiresIn(%v) = %q, want %q", tt.left, got, tt.want)
+		}
+	}
+}
+
+// TestFeedViewPoll tests that a poll shows a bar for each option with the
+// votes so far, and whether it is closed.
+func TestFeedViewPoll(t *testing.T) {
+	store := storage.NewMemoryStore()
+	renderer, err := content.NewMarkdownRenderer()
+	if err != nil {
+		t.Fatalf("Failed to create MarkdownRenderer: %v", err)
+	}
+	feedView := NewFeedView(store, renderer)
+
+	now := time.Now()
+	store.AddPost(&messaging.Message{ID: "poll", Author: "alice", Content: "Deploy now?", Type: messaging.PollMsg, Poll: &messaging.Poll{Options: []string{"yes", "after lunch"}}, Timestamp: now})
+	for i, voter := range []string{"bob", "carol", "dave", "erin"} {
+		option := "yes"
+		if i == 3 {
+			option = "after lunch"
+		}
+		store.AddPost(&messaging.Message{ID: voter, Author: voter, Content: option, Type: messaging.VoteMsg, ReplyTo: "poll", Timestamp: now.Add(time.Minute)})
+	}
+
+	view := feedView.View(80, 24)
+	for _, want := range []string{"1. yes         " + strings.Repeat("█", 15) + strings.Repeat("░", 5) + " 3 (75%)", "2. after lunch " + strings.Repeat("█", 5), "4 votes"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("View() = %q, want it to contain %q", view, want)
+		}
+	}
+	if strings.Contains(view, "From: bob") {
+		t.Errorf("View() = %q, want votes left out of the feed", view)
+	}
+
+	store.AddPost(&messaging.Message{ID: "close", Author: "alice", Type: messaging.PollCloseMsg, ReplyTo: "poll", Timestamp: now.Add(2 * time.Minute)})
+	if view := feedView.View(80, 24); !strings.Contains(view, "Poll closed") {
+		t.Errorf("View() = %q, want the poll marked closed", view)
+	}
+}
@@ -15,6 +15,7 @@ type NotificationsView struct {
 	// In a full implementation, this might be a ring buffer or a more sophisticated structure.
 	// For now, a simple slice is sufficient.
 	messages []types.StatusMsg
+	times    []time.Time // When each message was added
 	// Offset for scrolling through the log if it gets long
 	offset   int
 	maxLines int // Maximum number of lines to display based on terminal height
@@ -34,6 +35,7 @@ func NewNotificationsView() *NotificationsView {
 // AddMessage adds a new status message to the log.
 func (v *NotificationsView) AddMessage(msg types.StatusMsg) {
 	v.messages = append(v.messages, msg)
+	v.times = append(v.times, time.Now())
 	// TODO: Implement logic to limit the number of stored messages (e.g., keep last 100)
 	// TODO: Implement auto-scrolling to the latest message when a new one is added
 	//       (unless the user has manually scrolled up).
@@ -105,7 +107,7 @@ func (v *NotificationsView) View(width, height int) string {
 		messagesToShow := v.messages[startIndex:endIndex]
 
 		// Display messages from oldest to newest within the slice
-		for _, msg := range messagesToShow {
+		for i, msg := range messagesToShow {
 			// Style the message based on its type
 			var msgStyle lipgloss.Style
 			switch msg.Type {
@@ -123,7 +125,7 @@ func (v *NotificationsView) View(width, height int) string {
 
 			// Add a timestamp prefix
 			// Using a fixed timestamp format for consistency
-			timestamp := time.Now().Format("15:04:05")
+			timestamp := v.times[startIndex+i].Format("15:04:05")
 			formattedMsg := fmt.Sprintf("[%s] %s", timestamp, msg.Message)
 			b.WriteString(msgStyle.Render(formattedMsg))
 			b.WriteString("\n")
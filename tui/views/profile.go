@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/libp2p/go-libp2p/core/network"
 )
 
 // ProfileView displays user profile information and application settings.
@@ -69,6 +70,20 @@ func (v *ProfileView) View(width, height int) string {
 		b.WriteString("Listening Addresses: Not available\n")
 	}
 
+	// NAT traversal status, as determined by AutoNAT and AutoRelay
+	if v.netManager != nil && v.netManager.Host != nil {
+		b.WriteString(fmt.Sprintf("Reachability: %s\n", reachabilityLabel(v.netManager.Reachability())))
+		relayAddrs := v.netManager.RelayAddrs()
+		if len(relayAddrs) == 0 {
+			b.WriteString("Relay Addresses: None\n")
+		} else {
+			b.WriteString("Relay Addresses:\n")
+			for _, addr := range relayAddrs {
+				b.WriteString(fmt.Sprintf("  - %s\n", addr.String()))
+			}
+		}
+	}
+
 	// Connected Peers
 	// This overlaps with the sidebar, but could show more detail here.
 	// For now, we'll just note it's available in the main feed sidebar.
@@ -97,6 +112,7 @@ func (v *ProfileView) View(width, height int) string {
 	b.WriteString(fmt.Sprintf("  Listen Port: %d\n", v.cfg.Network.ListenPort))
 	b.WriteString(fmt.Sprintf("  Enable mDNS: %t\n", v.cfg.Network.EnableMDNS))
 	b.WriteString(fmt.Sprintf("  Enable DHT: %t\n", v.cfg.Network.EnableDHT))
+	b.WriteString(fmt.Sprintf("  Relay Service: %t\n", v.cfg.Network.EnableRelayService))
 	b.WriteString("\n")
 
 	// --- Footer ---
@@ -109,6 +125,18 @@ func (v *ProfileView) View(width, height int) string {
 	return b.String()
 }
 
+// reachabilityLabel turns an AutoNAT reachability value into a user-facing label.
+func reachabilityLabel(r network.Reachability) string {
+	switch r {
+	case network.ReachabilityPublic:
+		return "Public"
+	case network.ReachabilityPrivate:
+		return "Private (behind NAT, reachable via relays)"
+	default:
+		return "Unknown (still probing)"
+	}
+}
+
 // TODO: Add methods to handle user input for editing settings if needed.
 // This might involve adding fields to ProfileView to track temporary changes
 // and then applying them on a 'Save' action.
\ No newline at end of file
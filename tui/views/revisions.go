@@ -0,0 +1,92 @@
+package views
+
+import (
+	"fmt"
+	"socli/content"
+	"socli/storage"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// RevisionsView displays every revision of an edited post, newest first.
+type RevisionsView struct {
+	store    *storage.MemoryStore
+	renderer *content.MarkdownRenderer
+	postID   string
+	offset   int // Number of revisions scrolled past
+}
+
+// NewRevisionsView creates a view of the revisions of a post.
+func NewRevisionsView(store *storage.MemoryStore, renderer *content.MarkdownRenderer, postID string) *RevisionsView {
+	return &RevisionsView{
+		store:    store,
+		renderer: renderer,
+		postID:   postID,
+	}
+}
+
+// ScrollUp moves the view to the next older revision.
+func (v *RevisionsView) ScrollUp() {
+	if v.offset < len(v.store.Revisions(v.postID))-1 {
+		v.offset++
+	}
+}
+
+// ScrollDown moves the view back to the next newer revision.
+func (v *RevisionsView) ScrollDown() {
+	if v.offset > 0 {
+		v.offset--
+	}
+}
+
+// View renders the revisions, labelling the current one and the original.
+func (v *RevisionsView) View(width, height int) string {
+	var b strings.Builder
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205")) // Pink
+	labelStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63"))  // Purple
+	helpStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))             // Grey
+	b.WriteString(titleStyle.Render("Revisions"))
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("j/k scroll · esc back"))
+	b.WriteString("\n\n")
+
+	revisions := v.store.Revisions(v.postID)
+	if len(revisions) == 0 {
+		b.WriteString("This post isn't stored anymore.\n")
+		return b.String()
+	}
+	b.WriteString(helpStyle.Render(fmt.Sprintf("Post by %s", revisions[0].Author)))
+	b.WriteString("\n\n")
+
+	if v.offset >= len(revisions) {
+		v.offset = len(revisions) - 1
+	}
+	for i := len(revisions) - 1 - v.offset; i >= 0; i-- {
+		revision := revisions[i]
+		label := fmt.Sprintf("Revision %d", i+1)
+		switch {
+		case i == len(revisions)-1:
+			label = "Current"
+		case i == 0:
+			label = "Original"
+		}
+		b.WriteString(labelStyle.Render(label))
+		b.WriteString(" ")
+		b.WriteString(helpStyle.Render(revision.Timestamp.Format(time.Stamp)))
+		b.WriteString("\n")
+		rendered, err := v.renderer.Render(revision.Content)
+		if err != nil {
+			rendered = revision.Content
+		}
+		b.WriteString(rendered)
+		b.WriteString("\n---\n")
+	}
+
+	if v.offset > 0 {
+		b.WriteString(helpStyle.Render(fmt.Sprintf("\n(%d newer revisions above. Press 'j' to scroll down)", v.offset)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package views
+
+import (
+	"socli/content"
+	"socli/messaging"
+	"socli/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRevisionsView tests that an edited post lists its revisions newest first.
+func TestRevisionsView(t *testing.T) {
+	store := storage.NewMemoryStore()
+	renderer, err := content.NewMarkdownRenderer()
+	if err != nil {
+		t.Fatalf("Failed to create MarkdownRenderer: %v", err)
+	}
+
+	now := time.Now()
+	store.AddPost(&messaging.Message{ID: "post", Author: "alice", Content: "Frist", Type: messaging.PostMsg, Timestamp: now.Add(-time.Hour)})
+	store.AddPost(&messaging.Message{ID: "edit", Author: "alice", Content: "First", Type: messaging.EditMsg, ReplyTo: "post", Timestamp: now})
+
+	v := NewRevisionsView(store, renderer, "post")
+	view := v.View(80, 24)
+	current, original := strings.Index(view, "Current"), strings.Index(view, "Original")
+	if current < 0 || original < current {
+		t.Errorf("View() = %q, want the current revision above the original", view)
+	}
+
+	v.ScrollUp()
+	if view := v.View(80, 24); strings.Contains(view, "Current") || !strings.Contains(view, "Original") {
+		t.Errorf("View() after ScrollUp() = %q, want only the original", view)
+	}
+
+	if view := NewRevisionsView(store, renderer, "unknown").View(80, 24); !strings.Contains(view, "isn't stored") {
+		t.Errorf("View() of an unknown post = %q, want a note", view)
+	}
+}
@@ -0,0 +1,95 @@
+package views
+
+import (
+	"fmt"
+	"socli/storage"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ScheduleView lists the posts waiting in the schedule queue, the next one
+// due first, so they can be edited or cancelled.
+type ScheduleView struct {
+	queue  *storage.ScheduleQueue
+	cursor int // Index of the selected post
+}
+
+// NewScheduleView creates a view of the schedule queue.
+func NewScheduleView(queue *storage.ScheduleQueue) *ScheduleView {
+	return &ScheduleView{queue: queue}
+}
+
+// Selected returns the scheduled post under the cursor, if there is one.
+func (v *ScheduleView) Selected() (storage.ScheduledPost, bool) {
+	pending := v.queue.Pending()
+	if len(pending) == 0 {
+		return storage.ScheduledPost{}, false
+	}
+	v.cursor = min(v.cursor, len(pending)-1)
+	return pending[v.cursor], true
+}
+
+// MoveUp moves the selection to the post due before it.
+func (v *ScheduleView) MoveUp() {
+	if v.cursor > 0 {
+		v.cursor--
+	}
+}
+
+// MoveDown moves the selection to the post due after it.
+func (v *ScheduleView) MoveDown() {
+	if v.cursor < len(v.queue.Pending())-1 {
+		v.cursor++
+	}
+}
+
+// countdown formats the time left until a post is due, e.g. "in 1h5m".
+func countdown(at, now time.Time) string {
+	left := at.Sub(now)
+	if left < time.Minute {
+		return "in under a minute"
+	}
+	return "in " + strings.TrimSuffix(left.Truncate(time.Minute).String(), "0s")
+}
+
+// View renders the queue with the time each post is due and its first line.
+func (v *ScheduleView) View(width, height int) string {
+	var b strings.Builder
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205")) // Pink
+	helpStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))             // Grey
+	timeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("33"))              // Blue
+	selectedStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205"))
+	b.WriteString(titleStyle.Render("Scheduled posts"))
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("j/k select · 'e' edit · 'd' cancel · esc back"))
+	b.WriteString("\n\n")
+
+	pending := v.queue.Pending()
+	if len(pending) == 0 {
+		b.WriteString(helpStyle.Render("No scheduled posts. Schedule one with /schedule 09:00 <text>"))
+		b.WriteString("\n")
+	}
+	v.cursor = min(v.cursor, max(len(pending)-1, 0))
+	now := time.Now()
+	for i, post := range pending {
+		text, _, _ := strings.Cut(post.Content, "\n")
+		if width > 40 && len([]rune(text)) > width-40 {
+			text = string([]rune(text)[:width-41]) + "…"
+		}
+		marker := "  "
+		if i == v.cursor {
+			marker = selectedStyle.Render("> ")
+		}
+		when := timeStyle.Render(fmt.Sprintf("%s (%s)", post.At.Format("Mon 15:04"), countdown(post.At, now)))
+		b.WriteString(fmt.Sprintf("%s%s  %s\n", marker, when, text))
+	}
+
+	if !v.queue.Persistent() {
+		b.WriteString("\n")
+		b.WriteString(helpStyle.Render("Kept in memory only; set schedule.persist to keep them across restarts"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
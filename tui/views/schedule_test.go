@@ -0,0 +1,51 @@
+package views
+
+import (
+	"socli/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestScheduleView tests that the queue is listed next due first and that
+// the selection follows it.
+func TestScheduleView(t *testing.T) {
+	queue, err := storage.NewScheduleQueue("")
+	if err != nil {
+		t.Fatalf("NewScheduleQueue() error = %v", err)
+	}
+	v := NewScheduleView(queue)
+	if _, ok := v.Selected(); ok {
+		t.Error("Selected() of an empty queue reported a post")
+	}
+	if view := v.View(80, 24); !strings.Contains(view, "No scheduled posts") || !strings.Contains(view, "memory only") {
+		t.Errorf("View() of an empty queue = %q, want a hint and the memory-only note", view)
+	}
+
+	now := time.Now()
+	queue.Add("later post", now.Add(2*time.Hour+30*time.Minute+30*time.Second))
+	queue.Add("standup reminder #team", now.Add(10*time.Minute+30*time.Second))
+
+	view := v.View(80, 24)
+	standup, later := strings.Index(view, "standup reminder"), strings.Index(view, "later post")
+	if standup < 0 || later < standup {
+		t.Errorf("View() = %q, want the standup reminder listed first", view)
+	}
+	if !strings.Contains(view, "in 10m") || !strings.Contains(view, "in 2h30m") {
+		t.Errorf("View() = %q, want countdowns", view)
+	}
+
+	if post, ok := v.Selected(); !ok || post.Content != "standup reminder #team" {
+		t.Errorf("Selected() = %+v, want the standup reminder", post)
+	}
+	v.MoveDown()
+	v.MoveDown()
+	if post, _ := v.Selected(); post.Content != "later post" {
+		t.Errorf("Selected() after MoveDown() = %+v, want the later post", post)
+	}
+	selected, _ := v.Selected()
+	queue.Cancel(selected.ID)
+	if post, _ := v.Selected(); post.Content != "standup reminder #team" {
+		t.Errorf("Selected() after cancelling = %+v, want the remaining post", post)
+	}
+}
@@ -0,0 +1,174 @@
+package views
+
+import (
+	"fmt"
+	"socli/content"
+	"socli/messaging"
+	"socli/storage"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// maxThreadDepth bounds how far up and down a thread is followed.
+	maxThreadDepth = 50
+	// maxThreadIndent is the deepest level that is indented further.
+	maxThreadIndent = 6
+)
+
+// threadEntry is a post in a thread and its depth below the root.
+type threadEntry struct {
+	post  *messaging.Message
+	depth int
+}
+
+// ThreadView displays the conversation a post belongs to, from the root post
+// down through every stored reply.
+type ThreadView struct {
+	feed   *FeedView // Renders the individual posts
+	store  *storage.MemoryStore
+	postID string // The post the thread was opened on
+	cursor int    // Index of the selected post
+}
+
+// NewThreadView creates a thread view for the conversation around a post.
+func NewThreadView(store *storage.MemoryStore, renderer *content.MarkdownRenderer, postID string) *ThreadView {
+	v := &ThreadView{
+		feed:   NewFeedView(store, renderer),
+		store:  store,
+		postID: postID,
+	}
+	for i, entry := range v.entries() {
+		if entry.post.ID == postID {
+			v.cursor = i
+		}
+	}
+	return v
+}
+
+// root walks up from the opened post and returns the oldest stored ancestor,
+// and the ID of its parent if that hasn't been fetched yet.
+func (v *ThreadView) root() (*messaging.Message, string) {
+	post, ok := v.store.GetPost(v.postID)
+	if !ok {
+		return nil, v.postID
+	}
+	seen := map[string]bool{post.ID: true}
+	for depth := 0; post.ReplyTo != "" && depth < maxThreadDepth; depth++ {
+		if seen[post.ReplyTo] {
+			break
+		}
+		parent, ok := v.store.GetPost(post.ReplyTo)
+		if !ok {
+			return post, post.ReplyTo
+		}
+		seen[parent.ID] = true
+		post = parent
+	}
+	return post, ""
+}
+
+// Missing returns the ID of the nearest ancestor that isn't in the store, or
+// "" if the thread is complete up to its root.
+func (v *ThreadView) Missing() string {
+	_, missing := v.root()
+	return missing
+}
+
+// entries returns the posts of the thread in display order: each post is
+// followed by its replies, oldest first.
+func (v *ThreadView) entries() []threadEntry {
+	root, _ := v.root()
+	if root == nil {
+		return nil
+	}
+	var entries []threadEntry
+	seen := make(map[string]bool)
+	var walk func(post *messaging.Message, depth int)
+	walk = func(post *messaging.Message, depth int) {
+		if seen[post.ID] || depth > maxThreadDepth {
+			return
+		}
+		seen[post.ID] = true
+		entries = append(entries, threadEntry{post: post, depth: depth})
+		for _, reply := range v.store.Replies(post.ID) {
+			walk(reply, depth+1)
+		}
+	}
+	walk(root, 0)
+	return entries
+}
+
+// Selected returns the post under the cursor, or nil if none is stored.
+func (v *ThreadView) Selected() *messaging.Message {
+	entries := v.entries()
+	if len(entries) == 0 {
+		return nil
+	}
+	if v.cursor >= len(entries) {
+		v.cursor = len(entries) - 1
+	}
+	return entries[v.cursor].post
+}
+
+// MoveUp moves the selection to the previous post.
+func (v *ThreadView) MoveUp() {
+	if v.cursor > 0 {
+		v.cursor--
+	}
+}
+
+// MoveDown moves the selection to the next post.
+func (v *ThreadView) MoveDown() {
+	if v.cursor < len(v.entries())-1 {
+		v.cursor++
+	}
+}
+
+// View renders the thread as an indented tree, starting at the selected post.
+func (v *ThreadView) View(width, height int) string {
+	var b strings.Builder
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205")) // Pink
+	helpStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))             // Grey
+	b.WriteString(titleStyle.Render("Thread"))
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("j/k select · 'r' reply · '+' react · 's' share · 'e' edit · esc back"))
+	b.WriteString("\n\n")
+
+	entries := v.entries()
+	if missing := v.Missing(); missing != "" {
+		if len(missing) > 8 {
+			missing = missing[:8]
+		}
+		b.WriteString(helpStyle.Render(fmt.Sprintf("… fetching earlier posts from peers (%s)", missing)))
+		b.WriteString("\n\n")
+	}
+	if len(entries) == 0 {
+		return b.String()
+	}
+
+	if v.cursor >= len(entries) {
+		v.cursor = len(entries) - 1
+	}
+	for i := v.cursor; i < len(entries); i++ {
+		entry := entries[i]
+		indent := min(entry.depth, maxThreadIndent) * 2
+		style := lipgloss.NewStyle().
+			PaddingLeft(1).
+			MarginLeft(indent).
+			Border(lipgloss.NormalBorder(), false, false, false, true).
+			BorderForeground(lipgloss.Color("240")) // Grey
+		if i == v.cursor {
+			style = style.BorderForeground(lipgloss.Color("205")) // Pink
+		}
+		b.WriteString(style.Render(strings.TrimRight(v.feed.renderPost(entry.post), "\n")))
+		b.WriteString("\n")
+	}
+
+	if v.cursor > 0 {
+		b.WriteString(helpStyle.Render(fmt.Sprintf("\n(%d earlier posts above. Press 'k' to scroll up)", v.cursor)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package views
+
+import (
+	"socli/content"
+	"socli/messaging"
+	"socli/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestThreadView tests that a thread is shown from its root down, and that
+// missing parents are reported.
+func TestThreadView(t *testing.T) {
+	store := storage.NewMemoryStore()
+	renderer, err := content.NewMarkdownRenderer()
+	if err != nil {
+		t.Fatalf("Failed to create MarkdownRenderer: %v", err)
+	}
+
+	now := time.Now()
+	posts := []*messaging.Message{
+		{ID: "root", Author: "alice", Content: "Root post", Type: messaging.PostMsg, Timestamp: now.Add(-3 * time.Hour)},
+		{ID: "first", Author: "bob", Content: "First reply", Type: messaging.ReplyMsg, ReplyTo: "root", Timestamp: now.Add(-2 * time.Hour)},
+		{ID: "nested", Author: "carol", Content: "Nested reply", Type: messaging.ReplyMsg, ReplyTo: "first", Timestamp: now.Add(-time.Hour)},
+		{ID: "second", Author: "dave", Content: "Second reply", Type: messaging.ReplyMsg, ReplyTo: "root", Timestamp: now},
+	}
+	for _, post := range posts {
+		store.AddPost(post)
+	}
+
+	v := NewThreadView(store, renderer, "nested")
+	var order []string
+	for _, entry := range v.entries() {
+		order = append(order, entry.post.ID)
+	}
+	if got, want := strings.Join(order, ","), "root,first,nested,second"; got != want {
+		t.Errorf("entries() = %s, want %s", got, want)
+	}
+	if selected := v.Selected(); selected == nil || selected.ID != "nested" {
+		t.Errorf("Selected() = %v, want the post the thread was opened on", selected)
+	}
+	if missing := v.Missing(); missing != "" {
+		t.Errorf("Missing() = %q for a complete thread, want \"\"", missing)
+	}
+	v.MoveUp()
+	v.MoveUp()
+	if view := v.View(80, 24); !strings.Contains(view, "From: alice") || !strings.Contains(view, "From: dave") {
+		t.Errorf("View() = %q, want the whole thread", view)
+	}
+
+	// A reply whose parent hasn't arrived yet
+	store.AddPost(&messaging.Message{ID: "orphan", Author: "erin", Content: "Orphan reply", Type: messaging.ReplyMsg, ReplyTo: "unknown", Timestamp: now})
+	v = NewThreadView(store, renderer, "orphan")
+	if missing := v.Missing(); missing != "unknown" {
+		t.Errorf("Missing() = %q, want %q", missing, "unknown")
+	}
+	if view := v.View(80, 24); !strings.Contains(view, "fetching earlier posts") || !strings.Contains(view, "From: erin") {
+		t.Errorf("View() = %q, want the reply and a fetching note", view)
+	}
+}
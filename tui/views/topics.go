@@ -0,0 +1,112 @@
+package views
+
+import (
+	"fmt"
+	"socli/messaging"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TopicsView lists the hashtags announced on the network, so users can find
+// and subscribe to topics without guessing their names.
+type TopicsView struct {
+	entries []messaging.DirectoryEntry
+	cursor  int // Index of the selected entry
+}
+
+// NewTopicsView creates an empty topics view.
+func NewTopicsView() *TopicsView {
+	return &TopicsView{}
+}
+
+// SetEntries replaces the listed hashtags, keeping the selection on the same
+// hashtag if it is still listed.
+func (v *TopicsView) SetEntries(entries []messaging.DirectoryEntry) {
+	selected, ok := v.Selected()
+	v.entries = entries
+	v.cursor = 0
+	if ok {
+		for i, entry := range entries {
+			if entry.Hashtag == selected.Hashtag {
+				v.cursor = i
+				break
+			}
+		}
+	}
+}
+
+// Selected returns the entry under the cursor, if there is one.
+func (v *TopicsView) Selected() (messaging.DirectoryEntry, bool) {
+	if v.cursor < 0 || v.cursor >= len(v.entries) {
+		return messaging.DirectoryEntry{}, false
+	}
+	return v.entries[v.cursor], true
+}
+
+// MoveUp moves the selection to the previous hashtag.
+func (v *TopicsView) MoveUp() {
+	if v.cursor > 0 {
+		v.cursor--
+	}
+}
+
+// MoveDown moves the selection to the next hashtag.
+func (v *TopicsView) MoveDown() {
+	if v.cursor < len(v.entries)-1 {
+		v.cursor++
+	}
+}
+
+// View renders the list of hashtags. Hashtags in subscribed are marked.
+func (v *TopicsView) View(width, height int, subscribed map[string]bool) string {
+	var b strings.Builder
+
+	headerStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("63")). // Purple
+		MarginBottom(1)
+	b.WriteString(headerStyle.Render("Topic Directory"))
+	b.WriteString("\n\n")
+
+	if len(v.entries) == 0 {
+		b.WriteString("No hashtags announced yet. Peers announce their topics about once a minute.\n")
+	} else {
+		columnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))   // Grey
+		selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("205")) // Pink
+		b.WriteString(columnStyle.Render(fmt.Sprintf("  %-3s %-30s %6s %6s", "", "Hashtag", "Peers", "Posts")))
+		b.WriteString("\n")
+
+		// Keep the selection visible when the list is longer than the screen
+		visible := height - 8
+		if visible < 1 {
+			visible = 1
+		}
+		start := 0
+		if v.cursor >= visible {
+			start = v.cursor - visible + 1
+		}
+
+		for i := start; i < len(v.entries) && i < start+visible; i++ {
+			entry := v.entries[i]
+			mark := ""
+			if subscribed[entry.Hashtag] {
+				mark = "✓"
+			}
+			line := fmt.Sprintf("  %-3s %-30s %6d %6d", mark, "#"+entry.Hashtag, entry.Peers, entry.Posts)
+			if i == v.cursor {
+				line = selectedStyle.Render(">" + line[1:])
+			}
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+
+	footerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")). // Grey
+		MarginTop(1)
+	b.WriteString("\n")
+	b.WriteString(footerStyle.Render("j/k: move  enter/s: subscribe  r: refresh  q/esc: back to feed"))
+
+	return b.String()
+}
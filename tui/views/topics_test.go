@@ -0,0 +1,43 @@
+package views
+
+import (
+	"socli/messaging"
+	"strings"
+	"testing"
+)
+
+// TestTopicsViewSelection tests moving the selection and keeping it across refreshes.
+func TestTopicsViewSelection(t *testing.T) {
+	v := NewTopicsView()
+	if _, ok := v.Selected(); ok {
+		t.Error("Selected() on an empty view = true, want false")
+	}
+
+	v.SetEntries([]messaging.DirectoryEntry{
+		{Hashtag: "general", Peers: 5, Posts: 40},
+		{Hashtag: "go", Peers: 2, Posts: 3},
+		{Hashtag: "rust", Peers: 1, Posts: 0},
+	})
+	v.MoveDown()
+	v.MoveDown()
+	v.MoveDown() // Already at the last entry
+	if entry, _ := v.Selected(); entry.Hashtag != "rust" {
+		t.Errorf("Selected() = #%s, want #rust", entry.Hashtag)
+	}
+
+	// The selected hashtag moved up the list after a refresh
+	v.SetEntries([]messaging.DirectoryEntry{
+		{Hashtag: "rust", Peers: 6, Posts: 50},
+		{Hashtag: "general", Peers: 5, Posts: 40},
+	})
+	if entry, _ := v.Selected(); entry.Hashtag != "rust" {
+		t.Errorf("Selected() after refresh = #%s, want #rust", entry.Hashtag)
+	}
+
+	out := v.View(80, 24, map[string]bool{"general": true})
+	for _, want := range []string{"#rust", "#general", "✓"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("View() output does not contain %q", want)
+		}
+	}
+}